@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/wrangler/pkg/signals"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+
+	"github.com/harvester/harvester/pkg/cmd"
+	"github.com/harvester/harvester/pkg/config"
+	"github.com/harvester/harvester/pkg/exporter"
+)
+
+func main() {
+	var server exporter.Server
+
+	flags := []cli.Flag{
+		cli.StringFlag{
+			Name:        "device",
+			Usage:       "The disk to export",
+			Destination: &server.Device,
+			Required:    true,
+		},
+		cli.StringFlag{
+			Name:        "work-dir",
+			Usage:       "The directory to convert the disk in",
+			Value:       "/tmp",
+			Destination: &server.WorkDir,
+		},
+		cli.Int64Flag{
+			Name:        "work-dir-size",
+			Usage:       "The size limit in bytes of the converted disk in the work directory, 0 means no limit",
+			Destination: &server.WorkDirSize,
+		},
+		cli.StringFlag{
+			Name:        "token",
+			EnvVar:      exporter.TokenEnv,
+			Usage:       "The token the requests of the disk must have",
+			Destination: &server.Token,
+			Required:    true,
+		},
+		cli.DurationFlag{
+			Name:        "idle-timeout",
+			Usage:       "Exit when no disk is downloaded in the timeout",
+			Value:       10 * time.Minute,
+			Destination: &server.IdleTimeout,
+		},
+	}
+
+	app := cmd.NewApp("Harvester Volume Exporter", "", flags, func(commonOptions *config.CommonOptions) error {
+		logrus.Infof("Exporting %s", server.Device)
+		return server.Run(signals.SetupSignalContext(), fmt.Sprintf(":%d", exporter.Port))
+	})
+	app.Run()
+}
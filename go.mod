@@ -49,6 +49,7 @@ replace (
 )
 
 require (
+	github.com/aws/aws-sdk-go v1.38.65
 	github.com/containerd/containerd v1.4.11 // indirect
 	github.com/containernetworking/cni v0.8.0
 	github.com/ehazlett/simplelog v0.0.0-20200226020431-d374894e92a4
@@ -81,6 +82,7 @@ require (
 	github.com/rancher/steve v0.0.0-20220126170519-376e30bba7be
 	github.com/rancher/system-upgrade-controller/pkg/apis v0.0.0-20210727200656-10b094e30007
 	github.com/rancher/wrangler v0.8.11-0.20211214201934-f5aa5d9f2e81
+	github.com/robfig/cron v1.2.0
 	github.com/sirupsen/logrus v1.8.1
 	github.com/stretchr/testify v1.7.0
 	github.com/tidwall/gjson v1.9.3
@@ -63,6 +63,37 @@ func main() {
 			Usage:       "Specify whether the Harvester is running with embedded Rancher mode, default to false",
 			Destination: &options.RancherEmbedded,
 		},
+		cli.BoolFlag{
+			Name:        "image-conversion",
+			EnvVar:      "IMAGE_CONVERSION_ENABLED",
+			Usage:       "Enable converting the downloaded images Longhorn can't import, they are converted in the image conversion volume",
+			Destination: &options.ImageConversionEnabled,
+		},
+		cli.IntFlag{
+			Name:        "image-conversion-concurrency",
+			EnvVar:      "IMAGE_CONVERSION_CONCURRENCY",
+			Usage:       "Specify the max number of the images converted at the same time",
+			Value:       2,
+			Destination: &options.ImageConversionConcurrency,
+		},
+		cli.BoolFlag{
+			Name:        "chunked-image-upload",
+			EnvVar:      "CHUNKED_IMAGE_UPLOAD_ENABLED",
+			Usage:       "Enable the resumable image uploads, the chunks are assembled in the image upload volume",
+			Destination: &options.ChunkedImageUploadEnabled,
+		},
+		cli.StringFlag{
+			Name:        "pod-ip",
+			EnvVar:      "POD_IP",
+			Usage:       "The IP of the pod, the chunked image uploads in progress on the pod are forwarded to it by the other replicas",
+			Destination: &options.PodIP,
+		},
+		cli.StringFlag{
+			Name:        "exporter-image",
+			EnvVar:      "EXPORTER_IMAGE",
+			Usage:       "The image of the jobs exporting volumes for download",
+			Destination: &options.ExporterImage,
+		},
 		cli.StringFlag{
 			Name:        "rancher-server-url",
 			EnvVar:      "RANCHER_SERVER_URL",
@@ -0,0 +1,81 @@
+package backup
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+
+	ctlbackup "github.com/harvester/harvester/pkg/controller/master/backup"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+)
+
+const (
+	actionVerify = "verify"
+)
+
+func Formatter(request *types.APIRequest, resource *types.RawResource) {
+	resource.Actions = make(map[string]string, 1)
+	if request.AccessControl.CanUpdate(request, resource.APIObject, resource.Schema) != nil {
+		return
+	}
+
+	if resource.APIObject.Data().Bool("status", "readyToUse") {
+		resource.AddAction(request, actionVerify)
+	}
+}
+
+// VerifyActionHandler requests the VM backup verify controller to verify the backup,
+// the result is written to the Verified condition of the backup.
+type VerifyActionHandler struct {
+	vmBackups     ctlharvesterv1.VirtualMachineBackupClient
+	vmBackupCache ctlharvesterv1.VirtualMachineBackupCache
+}
+
+func (h VerifyActionHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if err := h.do(rw, req); err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*apierror.APIError); ok {
+			status = e.Code.Status
+		}
+		rw.WriteHeader(status)
+		_, _ = rw.Write([]byte(err.Error()))
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h VerifyActionHandler) do(rw http.ResponseWriter, req *http.Request) error {
+	vars := mux.Vars(req)
+	action := vars["action"]
+	namespace := vars["namespace"]
+	name := vars["name"]
+
+	switch action {
+	case actionVerify:
+		return h.verify(namespace, name)
+	default:
+		return apierror.NewAPIError(validation.InvalidAction, "Unsupported action")
+	}
+}
+
+func (h VerifyActionHandler) verify(namespace, name string) error {
+	vmBackup, err := h.vmBackupCache.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if vmBackup.Status == nil || vmBackup.Status.ReadyToUse == nil || !*vmBackup.Status.ReadyToUse {
+		return apierror.NewAPIError(validation.InvalidAction, "Backup is not ready to verify")
+	}
+
+	vmBackupCpy := vmBackup.DeepCopy()
+	if vmBackupCpy.Annotations == nil {
+		vmBackupCpy.Annotations = make(map[string]string)
+	}
+	vmBackupCpy.Annotations[ctlbackup.VerifyRequestedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	_, err = h.vmBackups.Update(vmBackupCpy)
+	return err
+}
@@ -0,0 +1,33 @@
+package backup
+
+import (
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/schema"
+	"github.com/rancher/steve/pkg/server"
+	"github.com/rancher/wrangler/pkg/schemas"
+
+	"github.com/harvester/harvester/pkg/config"
+)
+
+func RegisterSchema(scaled *config.Scaled, server *server.Server, options config.Options) error {
+	vmBackups := scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
+	t := schema.Template{
+		ID: "harvesterhci.io.virtualmachinebackup",
+		Customize: func(s *types.APISchema) {
+			s.Formatter = Formatter
+			s.ResourceActions = map[string]schemas.Action{
+				actionVerify: {},
+			}
+			s.ActionHandlers = map[string]http.Handler{
+				actionVerify: VerifyActionHandler{
+					vmBackups:     vmBackups,
+					vmBackupCache: vmBackups.Cache(),
+				},
+			}
+		},
+	}
+	server.SchemaFactory.AddTemplate(t)
+	return nil
+}
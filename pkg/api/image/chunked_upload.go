@@ -0,0 +1,598 @@
+package image
+
+import (
+	"context"
+	"crypto/sha512"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/rancher/apiserver/pkg/apierror"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	apisv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	actionUploadChunk  = "uploadChunk"
+	actionUploadOffset = "uploadOffset"
+
+	// uploadStagingDir is where the chunks are assembled, the chart mounts an ephemeral volume sized for the images
+	// to upload there when the chunked upload is enabled, so the uploads don't fill up the disk of the node
+	uploadStagingDir = "/var/lib/harvester/harvester/image-upload"
+
+	// uploadSessionCheckInterval is the interval of checking the upload sessions interrupted or abandoned
+	uploadSessionCheckInterval = 5 * time.Minute
+	// uploadSessionTimeout is how long an upload can take before the session expires
+	uploadSessionTimeout = 24 * time.Hour
+
+	// forwardedHeader marks the requests forwarded to the pod owning the upload, so they aren't forwarded again
+	forwardedHeader = "X-Harvester-Upload-Forwarded"
+)
+
+var apiserverPodLabels = labels.Set{
+	"app.kubernetes.io/name":      "harvester",
+	"app.kubernetes.io/component": "apiserver",
+}
+
+// uploadSession is a chunked upload in progress, it's saved in the AnnotationUploadSession annotation of the image.
+// The chunks are assembled on the disk of the owner pod, the chunks sent to the other pods are forwarded to it.
+type uploadSession struct {
+	// Owner is the address of the pod assembling the chunks
+	Owner     string      `json:"owner"`
+	Size      int64       `json:"size"`
+	StartedAt metav1.Time `json:"startedAt"`
+}
+
+// ChunkedUploadHandler serves the resumable uploads. The client sends the chunks in order with their offsets,
+// and resumes from the offset the server reports after a failure. The checksum is verified over the assembled image
+// before it's uploaded to the backing image.
+type ChunkedUploadHandler struct {
+	UploadActionHandler
+	PodCache   ctlcorev1.PodCache
+	Namespace  string
+	PodAddress string
+	StagingDir string
+
+	proxyTransport http.RoundTripper
+	// locks serialize the chunks of the same image
+	locks sync.Map
+	// finishing has the keys of the images being verified and uploaded to the backing images by this process
+	finishing sync.Map
+}
+
+func NewChunkedUploadHandler(upload UploadActionHandler, podCache ctlcorev1.PodCache, namespace, podIP string, port int) *ChunkedUploadHandler {
+	var podAddress string
+	if podIP != "" {
+		podAddress = net.JoinHostPort(podIP, strconv.Itoa(port))
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	// the pods serve the self-signed certificates, the owner is verified to be a Harvester pod instead
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	return &ChunkedUploadHandler{
+		UploadActionHandler: upload,
+		PodCache:            podCache,
+		Namespace:           namespace,
+		PodAddress:          podAddress,
+		StagingDir:          uploadStagingDir,
+		proxyTransport:      transport,
+	}
+}
+
+func (h *ChunkedUploadHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	output, err := h.do(rw, req)
+	if err == errForwarded {
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*apierror.APIError); ok {
+			status = e.Code.Status
+		}
+		if e, ok := err.(*offsetMismatchError); ok {
+			status = http.StatusConflict
+			output = &e.UploadChunkOutput
+		}
+		if output == nil {
+			rw.WriteHeader(status)
+			_, _ = rw.Write([]byte(err.Error()))
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(status)
+		_ = json.NewEncoder(rw).Encode(output)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(rw).Encode(output)
+}
+
+var errForwarded = fmt.Errorf("the request is forwarded to the owner of the upload")
+
+// offsetMismatchError tells the client to resume from the offset the server has
+type offsetMismatchError struct {
+	UploadChunkOutput
+}
+
+func (e *offsetMismatchError) Error() string {
+	return fmt.Sprintf("the upload is at offset %d", e.Offset)
+}
+
+func (h *ChunkedUploadHandler) do(rw http.ResponseWriter, req *http.Request) (*UploadChunkOutput, error) {
+	vars := mux.Vars(req)
+	image, err := h.ImageCache.Get(vars["namespace"], vars["name"])
+	if err != nil {
+		return nil, err
+	}
+	if image.Spec.SourceType != apisv1beta1.VirtualMachineImageSourceTypeUpload {
+		return nil, apierror.NewAPIError(validation.InvalidAction, "The image is not of the upload source type")
+	}
+	session, err := getUploadSession(image)
+	if err != nil {
+		return nil, err
+	}
+
+	switch vars["action"] {
+	case actionUploadOffset:
+		if session == nil {
+			return &UploadChunkOutput{}, nil
+		}
+		forwarded, err := h.forwardToOwner(rw, req, session)
+		if forwarded {
+			return nil, err
+		}
+		// the owner is gone with the chunks, the upload can only start over
+		if err != nil {
+			return &UploadChunkOutput{Size: session.Size}, nil
+		}
+		return h.getOffset(image, session)
+	case actionUploadChunk:
+		return h.uploadChunk(rw, req, image, session)
+	default:
+		return nil, apierror.NewAPIError(validation.InvalidAction, "Unsupported action")
+	}
+}
+
+func (h *ChunkedUploadHandler) uploadChunk(rw http.ResponseWriter, req *http.Request, image *apisv1beta1.VirtualMachineImage,
+	session *uploadSession) (*UploadChunkOutput, error) {
+	offset, err := parseQueryInt(req, "offset")
+	if err != nil {
+		return nil, err
+	}
+	size, err := parseQueryInt(req, "size")
+	if err != nil {
+		return nil, err
+	}
+	if !apisv1beta1.ImageImported.IsUnknown(image) || isUploadFinishing(image) {
+		return nil, apierror.NewAPIError(validation.Conflict, "The image is not waiting for an upload")
+	}
+
+	if session != nil {
+		forwarded, err := h.forwardToOwner(rw, req, session)
+		if forwarded {
+			return nil, err
+		}
+		// the owner is gone with the chunks, the upload can only start over
+		if err != nil && offset > 0 {
+			return nil, &offsetMismatchError{UploadChunkOutput{Offset: 0, Size: session.Size}}
+		}
+	}
+	// a chunk at offset 0 starts a new upload, it takes over the upload of a pod that is gone
+	if offset == 0 && (session == nil || session.Owner != h.PodAddress || session.Size != size) {
+		if size <= 0 {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent, "The size of the image is required")
+		}
+		if session, err = h.startSession(image, size); err != nil {
+			return nil, err
+		}
+	}
+	if session == nil {
+		return nil, &offsetMismatchError{UploadChunkOutput{Offset: 0}}
+	}
+	if size != 0 && size != session.Size {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent,
+			fmt.Sprintf("The size %d doesn't match the size %d of the upload in progress", size, session.Size))
+	}
+
+	key := ref.Construct(image.Namespace, image.Name)
+	lock, _ := h.locks.LoadOrStore(key, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	output, err := h.appendChunk(image, session, offset, req.Body)
+	if err != nil {
+		return output, err
+	}
+	if output.Complete {
+		h.finishing.Store(key, true)
+		if err := h.updateImportedConditionOnConflict(image, "Unknown", "Verifying", "verifying the checksum of the uploaded image"); err != nil {
+			h.finishing.Delete(key)
+			return nil, err
+		}
+		go h.finish(image)
+	}
+	return output, nil
+}
+
+// appendChunk appends the chunk at the offset to the staging file. The bytes received before a failure are kept,
+// so the client can resume from there.
+func (h *ChunkedUploadHandler) appendChunk(image *apisv1beta1.VirtualMachineImage, session *uploadSession, offset int64,
+	body io.Reader) (*UploadChunkOutput, error) {
+	file := h.stagingFile(image)
+	staged, err := getFileSize(file)
+	if err != nil {
+		return nil, err
+	}
+	if offset != staged {
+		return nil, &offsetMismatchError{UploadChunkOutput{Offset: staged, Size: session.Size}}
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	written, copyErr := io.Copy(f, io.LimitReader(body, session.Size-offset+1))
+	if offset+written > session.Size {
+		if err := f.Truncate(offset); err != nil {
+			return nil, err
+		}
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("The chunk exceeds the size %d of the image", session.Size))
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	output := &UploadChunkOutput{
+		Offset:   offset + written,
+		Size:     session.Size,
+		Complete: offset+written == session.Size,
+	}
+	if copyErr != nil {
+		return nil, fmt.Errorf("failed to receive the chunk at offset %d, resume from offset %d: %w", offset, output.Offset, copyErr)
+	}
+	return output, nil
+}
+
+// finish verifies the checksum of the assembled image and uploads it to the backing image
+func (h *ChunkedUploadHandler) finish(image *apisv1beta1.VirtualMachineImage) {
+	file := h.stagingFile(image)
+	defer func() {
+		h.finishing.Delete(ref.Construct(image.Namespace, image.Name))
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			logrus.Errorf("failed to remove the staged upload of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+		if err := h.endSession(image); err != nil {
+			logrus.Errorf("failed to remove the upload session of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+	}()
+
+	if image.Spec.Checksum != "" {
+		checksum, err := sha512File(file)
+		if err != nil {
+			h.setImportedFalse(image, "UploadFailed", err.Error())
+			return
+		}
+		if !strings.EqualFold(checksum, image.Spec.Checksum) {
+			h.setImportedFalse(image, "ChecksumMismatch",
+				fmt.Sprintf("the SHA512 checksum %s of the uploaded image doesn't match the expected %s", checksum, image.Spec.Checksum))
+			return
+		}
+	}
+	if err := h.updateImportedConditionOnConflict(image, "Unknown", "Uploading", "uploading the image to the backing image"); err != nil {
+		logrus.Errorf("failed to update the imported condition of image %s/%s: %v", image.Namespace, image.Name, err)
+	}
+	if err := h.uploadToBackingImage(image, file); err != nil {
+		h.setImportedFalse(image, "UploadFailed", err.Error())
+	}
+}
+
+func (h *ChunkedUploadHandler) uploadToBackingImage(image *apisv1beta1.VirtualMachineImage, file string) error {
+	dsName := fmt.Sprintf("%s-%s", image.Namespace, image.Name)
+	if err := h.waitForBackingImageDataSourceReady(dsName); err != nil {
+		return err
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	bodyReader, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	go func() {
+		part, err := form.CreateFormFile("chunk", "blob")
+		if err == nil {
+			_, err = io.Copy(part, f)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		bodyWriter.CloseWithError(err)
+	}()
+
+	uploadURL := fmt.Sprintf("http://longhorn-backend.longhorn-system:9500/v1/backingimages/%s?action=upload&size=%d", dsName, info.Size())
+	uploadReq, err := http.NewRequestWithContext(context.Background(), http.MethodPost, uploadURL, bodyReader)
+	if err != nil {
+		bodyReader.Close()
+		return fmt.Errorf("failed to create the upload request: %w", err)
+	}
+	uploadReq.Header.Set("Content-Type", form.FormDataContentType())
+	uploadResp, err := h.httpClient.Do(uploadReq)
+	if err != nil {
+		bodyReader.Close()
+		return fmt.Errorf("failed to send the upload request: %w", err)
+	}
+	defer uploadResp.Body.Close()
+	if uploadResp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(uploadResp.Body)
+		return fmt.Errorf("upload failed: %s", string(body))
+	}
+	return nil
+}
+
+func (h *ChunkedUploadHandler) getOffset(image *apisv1beta1.VirtualMachineImage, session *uploadSession) (*UploadChunkOutput, error) {
+	staged, err := getFileSize(h.stagingFile(image))
+	if err != nil {
+		return nil, err
+	}
+	return &UploadChunkOutput{
+		Offset:   staged,
+		Size:     session.Size,
+		Complete: staged == session.Size,
+	}, nil
+}
+
+// forwardToOwner forwards the request to the pod owning the upload session. It returns an error without forwarding
+// if the owner pod is gone.
+func (h *ChunkedUploadHandler) forwardToOwner(rw http.ResponseWriter, req *http.Request, session *uploadSession) (bool, error) {
+	if session.Owner == h.PodAddress {
+		return false, nil
+	}
+	if req.Header.Get(forwardedHeader) != "" {
+		return false, fmt.Errorf("the upload is owned by %s", session.Owner)
+	}
+	if err := h.checkOwner(session.Owner); err != nil {
+		return false, err
+	}
+	proxy := &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = "https"
+			r.URL.Host = session.Owner
+			r.Host = session.Owner
+			r.Header.Set(forwardedHeader, h.PodAddress)
+		},
+		Transport: h.proxyTransport,
+	}
+	proxy.ServeHTTP(rw, req)
+	return true, errForwarded
+}
+
+// checkOwner checks the owner is a running Harvester pod before the request with the credentials of the user
+// is forwarded to it
+func (h *ChunkedUploadHandler) checkOwner(owner string) error {
+	host, _, err := net.SplitHostPort(owner)
+	if err != nil {
+		return err
+	}
+	pods, err := h.PodCache.List(h.Namespace, labels.SelectorFromSet(apiserverPodLabels))
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if pod.Status.PodIP == host && pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("the owner %s of the upload is gone", owner)
+}
+
+func (h *ChunkedUploadHandler) startSession(image *apisv1beta1.VirtualMachineImage, size int64) (*uploadSession, error) {
+	h.cleanupStagingFiles()
+	if err := os.MkdirAll(filepath.Join(h.StagingDir, image.Namespace), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(h.stagingFile(image)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	session := &uploadSession{
+		Owner:     h.PodAddress,
+		Size:      size,
+		StartedAt: metav1.Now(),
+	}
+	value, err := json.Marshal(session)
+	if err != nil {
+		return nil, err
+	}
+	return session, h.updateSessionAnnotation(image, string(value))
+}
+
+// cleanupStagingFiles removes the chunks of the uploads abandoned by removing the images or restarting on another pod
+func (h *ChunkedUploadHandler) cleanupStagingFiles() {
+	files, err := filepath.Glob(filepath.Join(h.StagingDir, "*", "*"))
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		namespace, name := filepath.Base(filepath.Dir(file)), filepath.Base(file)
+		image, err := h.ImageCache.Get(namespace, name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			continue
+		}
+		if err == nil {
+			if session, err := getUploadSession(image); err != nil || (session != nil && session.Owner == h.PodAddress) {
+				continue
+			}
+		}
+		if err := os.Remove(file); err != nil {
+			logrus.Warnf("failed to remove the abandoned upload %s: %v", file, err)
+		}
+	}
+}
+
+// Run checks the upload sessions periodically until the context is done
+func (h *ChunkedUploadHandler) Run(ctx context.Context) {
+	wait.Until(h.checkSessions, uploadSessionCheckInterval, ctx.Done())
+}
+
+// checkSessions fails the uploads interrupted while they were verified or uploaded to the backing images, as the
+// staged images are gone with the pods restarted, and ends the sessions abandoned by the clients
+func (h *ChunkedUploadHandler) checkSessions() {
+	images, err := h.ImageCache.List(metav1.NamespaceAll, labels.Everything())
+	if err != nil {
+		logrus.Errorf("failed to list images to check the upload sessions: %v", err)
+		return
+	}
+	for _, image := range images {
+		session, err := getUploadSession(image)
+		if err != nil || session == nil || image.DeletionTimestamp != nil {
+			continue
+		}
+		if err := h.checkSession(image, session); err != nil {
+			logrus.Errorf("failed to check the upload session of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+	}
+}
+
+func (h *ChunkedUploadHandler) checkSession(image *apisv1beta1.VirtualMachineImage, session *uploadSession) error {
+	if apisv1beta1.ImageImported.IsUnknown(image) && isUploadFinishing(image) {
+		if session.Owner == h.PodAddress {
+			if _, ok := h.finishing.Load(ref.Construct(image.Namespace, image.Name)); ok {
+				return nil
+			}
+		} else if err := h.checkOwner(session.Owner); err == nil {
+			return nil
+		}
+		h.setImportedFalse(image, "UploadInterrupted", "the upload is interrupted by a restart of the Harvester server, please upload the image again")
+		return h.endSession(image)
+	}
+	if time.Since(session.StartedAt.Time) > uploadSessionTimeout {
+		logrus.Infof("the upload of image %s/%s started at %s expired", image.Namespace, image.Name, session.StartedAt)
+		if session.Owner == h.PodAddress {
+			if err := os.Remove(h.stagingFile(image)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return h.endSession(image)
+	}
+	return nil
+}
+
+func (h *ChunkedUploadHandler) endSession(image *apisv1beta1.VirtualMachineImage) error {
+	return h.updateSessionAnnotation(image, "")
+}
+
+func (h *ChunkedUploadHandler) updateSessionAnnotation(image *apisv1beta1.VirtualMachineImage, value string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := h.Images.Get(image.Namespace, image.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && value == "" {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		toUpdate := current.DeepCopy()
+		if value == "" {
+			delete(toUpdate.Annotations, util.AnnotationUploadSession)
+		} else {
+			if toUpdate.Annotations == nil {
+				toUpdate.Annotations = map[string]string{}
+			}
+			toUpdate.Annotations[util.AnnotationUploadSession] = value
+		}
+		_, err = h.Images.Update(toUpdate)
+		return err
+	})
+}
+
+func (h *ChunkedUploadHandler) setImportedFalse(image *apisv1beta1.VirtualMachineImage, reason, message string) {
+	logrus.Errorf("failed to upload image %s/%s: %s", image.Namespace, image.Name, message)
+	if err := h.updateImportedConditionOnConflict(image, "False", reason, message); err != nil {
+		logrus.Errorf("failed to update the imported condition of image %s/%s: %v", image.Namespace, image.Name, err)
+	}
+}
+
+func (h *ChunkedUploadHandler) stagingFile(image *apisv1beta1.VirtualMachineImage) string {
+	return filepath.Join(h.StagingDir, image.Namespace, image.Name)
+}
+
+// isUploadFinishing tells whether the assembled image is being verified or uploaded to the backing image
+func isUploadFinishing(image *apisv1beta1.VirtualMachineImage) bool {
+	reason := apisv1beta1.ImageImported.GetReason(image)
+	return reason == "Verifying" || reason == "Uploading"
+}
+
+func getUploadSession(image *apisv1beta1.VirtualMachineImage) (*uploadSession, error) {
+	value := image.Annotations[util.AnnotationUploadSession]
+	if value == "" {
+		return nil, nil
+	}
+	session := &uploadSession{}
+	if err := json.Unmarshal([]byte(value), session); err != nil {
+		return nil, fmt.Errorf("invalid upload session of image %s/%s: %w", image.Namespace, image.Name, err)
+	}
+	return session, nil
+}
+
+func parseQueryInt(req *http.Request, key string) (int64, error) {
+	value := req.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, apierror.NewAPIError(validation.InvalidFormat, fmt.Sprintf("Invalid %s %q", key, value))
+	}
+	return n, nil
+}
+
+func getFileSize(file string) (int64, error) {
+	info, err := os.Stat(file)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func sha512File(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha512.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,184 @@
+package image
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	apisv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+const (
+	testImageNamespace = "default"
+	testImageName      = "image-abcde"
+)
+
+func newTestUploadImage(checksum string) *apisv1beta1.VirtualMachineImage {
+	image := &apisv1beta1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: testImageNamespace,
+			Name:      testImageName,
+		},
+		Spec: apisv1beta1.VirtualMachineImageSpec{
+			DisplayName: "ubuntu.iso",
+			SourceType:  apisv1beta1.VirtualMachineImageSourceTypeUpload,
+			Checksum:    checksum,
+		},
+	}
+	apisv1beta1.ImageImported.Unknown(image)
+	apisv1beta1.ImageImported.Reason(image, "Importing")
+	return image
+}
+
+func newTestChunkedUploadHandler(t *testing.T, image *apisv1beta1.VirtualMachineImage) (*ChunkedUploadHandler, *fake.Clientset) {
+	clientset := fake.NewSimpleClientset(image)
+	dir, err := ioutil.TempDir("", "image-upload")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	h := NewChunkedUploadHandler(UploadActionHandler{
+		Images:     fakeclients.VirtualMachineImageClient(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+		ImageCache: fakeclients.VirtualMachineImageCache(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+	}, nil, "harvester-system", "", 8443)
+	h.StagingDir = dir
+	return h, clientset
+}
+
+func sendChunk(h *ChunkedUploadHandler, action string, offset, size int, chunk string) (int, UploadChunkOutput) {
+	url := fmt.Sprintf("/v1/harvester/harvesterhci.io.virtualmachineimages/%s/%s?action=%s&offset=%d&size=%d",
+		testImageNamespace, testImageName, action, offset, size)
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(chunk))
+	req = mux.SetURLVars(req, map[string]string{
+		"namespace": testImageNamespace,
+		"name":      testImageName,
+		"action":    action,
+	})
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, req)
+	var output UploadChunkOutput
+	_ = json.Unmarshal(rw.Body.Bytes(), &output)
+	return rw.Code, output
+}
+
+func TestChunkedUpload(t *testing.T) {
+	h, clientset := newTestChunkedUploadHandler(t, newTestUploadImage(""))
+
+	code, output := sendChunk(h, actionUploadChunk, 0, 10, "hello")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, UploadChunkOutput{Offset: 5, Size: 10}, output)
+	image, err := clientset.HarvesterhciV1beta1().VirtualMachineImages(testImageNamespace).Get(context.TODO(), testImageName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, image.Annotations[util.AnnotationUploadSession])
+
+	// a chunk after a lost response is rejected with the offset to resume from
+	code, output = sendChunk(h, actionUploadChunk, 3, 10, "lo wor")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, UploadChunkOutput{Offset: 5, Size: 10}, output)
+
+	code, output = sendChunk(h, actionUploadOffset, 0, 0, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, UploadChunkOutput{Offset: 5, Size: 10}, output)
+
+	// the chunk exceeding the size is discarded
+	code, _ = sendChunk(h, actionUploadChunk, 5, 10, " world!")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	code, output = sendChunk(h, actionUploadOffset, 0, 0, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, int64(5), output.Offset)
+
+	// the last chunk is appended without finishing, which uploads to Longhorn in background
+	session, err := getUploadSession(image)
+	assert.NoError(t, err)
+	last, err := h.appendChunk(image, session, 5, strings.NewReader(" worl"))
+	assert.NoError(t, err)
+	assert.Equal(t, &UploadChunkOutput{Offset: 10, Size: 10, Complete: true}, last)
+	content, err := ioutil.ReadFile(h.stagingFile(image))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello worl", string(content))
+}
+
+func TestChunkedUploadChecksumMismatch(t *testing.T) {
+	checksum := sha512.Sum512([]byte("expected"))
+	image := newTestUploadImage(hex.EncodeToString(checksum[:]))
+	h, clientset := newTestChunkedUploadHandler(t, image)
+
+	session, err := h.startSession(image, 6)
+	assert.NoError(t, err)
+	_, err = h.appendChunk(image, session, 0, strings.NewReader("actual"))
+	assert.NoError(t, err)
+
+	h.finish(image)
+	image, err = clientset.HarvesterhciV1beta1().VirtualMachineImages(testImageNamespace).Get(context.TODO(), testImageName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, apisv1beta1.ImageImported.IsFalse(image))
+	assert.Equal(t, "ChecksumMismatch", apisv1beta1.ImageImported.GetReason(image))
+	assert.Empty(t, image.Annotations[util.AnnotationUploadSession])
+	_, err = os.Stat(h.stagingFile(image))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestChunkedUploadNotUploadSource(t *testing.T) {
+	image := newTestUploadImage("")
+	image.Spec.SourceType = apisv1beta1.VirtualMachineImageSourceTypeDownload
+	h, _ := newTestChunkedUploadHandler(t, image)
+
+	code, _ := sendChunk(h, actionUploadChunk, 0, 10, "hello")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+}
+
+func TestChunkedUploadCheckSessions(t *testing.T) {
+	image := newTestUploadImage("")
+	h, clientset := newTestChunkedUploadHandler(t, image)
+	session, err := h.startSession(image, 6)
+	assert.NoError(t, err)
+	_, err = h.appendChunk(image, session, 0, strings.NewReader("upload"))
+	assert.NoError(t, err)
+
+	// the upload in progress is kept
+	h.checkSessions()
+	image, err = clientset.HarvesterhciV1beta1().VirtualMachineImages(testImageNamespace).Get(context.TODO(), testImageName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, image.Annotations[util.AnnotationUploadSession])
+
+	// the upload verified by a process restarted since is failed
+	assert.NoError(t, h.updateImportedConditionOnConflict(image, "Unknown", "Verifying", ""))
+	h.checkSessions()
+	image, err = clientset.HarvesterhciV1beta1().VirtualMachineImages(testImageNamespace).Get(context.TODO(), testImageName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.True(t, apisv1beta1.ImageImported.IsFalse(image))
+	assert.Equal(t, "UploadInterrupted", apisv1beta1.ImageImported.GetReason(image))
+	assert.Empty(t, image.Annotations[util.AnnotationUploadSession])
+}
+
+func TestChunkedUploadSessionExpired(t *testing.T) {
+	image := newTestUploadImage("")
+	h, clientset := newTestChunkedUploadHandler(t, image)
+	session, err := h.startSession(image, 6)
+	assert.NoError(t, err)
+	_, err = h.appendChunk(image, session, 0, strings.NewReader("up"))
+	assert.NoError(t, err)
+
+	session.StartedAt = metav1.NewTime(time.Now().Add(-uploadSessionTimeout - time.Minute))
+	assert.NoError(t, h.checkSession(image, session))
+	image, err = clientset.HarvesterhciV1beta1().VirtualMachineImages(testImageNamespace).Get(context.TODO(), testImageName, metav1.GetOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, image.Annotations[util.AnnotationUploadSession])
+	_, err = os.Stat(h.stagingFile(image))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,109 @@
+package image
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/name"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/harvester/harvester/pkg/api/volume"
+	apisv1beta1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	downloadLink = "download"
+)
+
+// DownloadHandler serves the download link of images. The backing image is exported by a temporary volume
+// created from the image, which is owned by the exporter job and removed with it.
+type DownloadHandler struct {
+	downloader *volume.Downloader
+	imageCache ctlharvesterv1.VirtualMachineImageCache
+	pvcs       ctlcorev1.PersistentVolumeClaimClient
+	pvcCache   ctlcorev1.PersistentVolumeClaimCache
+}
+
+func (h DownloadHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	options, err := h.downloader.ParseRequest(r)
+	if err != nil {
+		volume.ResponseError(rw, err)
+		return
+	}
+
+	apiOp := types.GetAPIContext(r.Context())
+	image, err := h.imageCache.Get(apiOp.Namespace, apiOp.Name)
+	if err != nil {
+		volume.ResponseError(rw, err)
+		return
+	}
+	if !apisv1beta1.ImageImported.IsTrue(image) || image.Status.Size == 0 {
+		util.ResponseErrorMsg(rw, http.StatusConflict, fmt.Sprintf("image %s/%s is not ready", image.Namespace, image.Name))
+		return
+	}
+
+	pvcName := name.SafeConcatName(image.Name, "export")
+	job, err := h.downloader.EnsureExporter(image.Namespace, pvcName, corev1.PersistentVolumeBlock, image.Status.Size, metav1.OwnerReference{
+		APIVersion: apisv1beta1.SchemeGroupVersion.String(),
+		Kind:       "VirtualMachineImage",
+		Name:       image.Name,
+		UID:        image.UID,
+	})
+	if err != nil {
+		volume.ResponseError(rw, err)
+		return
+	}
+	if err := h.ensureVolume(image, pvcName, job); err != nil {
+		util.ResponseError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	displayName := image.Spec.DisplayName
+	h.downloader.Serve(rw, r, job, options, strings.TrimSuffix(displayName, filepath.Ext(displayName)))
+}
+
+func (h DownloadHandler) ensureVolume(image *apisv1beta1.VirtualMachineImage, pvcName string, job *batchv1.Job) error {
+	if _, err := h.pvcCache.Get(image.Namespace, pvcName); !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	volumeMode := corev1.PersistentVolumeBlock
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: image.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       job.Name,
+					UID:        job.UID,
+				},
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: &image.Status.StorageClassName,
+			VolumeMode:       &volumeMode,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: *resource.NewQuantity(image.Status.Size, resource.BinarySI),
+				},
+			},
+		},
+	}
+	if _, err := h.pvcs.Create(pvc); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the volume exporting image %s/%s: %w", image.Namespace, image.Name, err)
+	}
+	return nil
+}
@@ -28,14 +28,21 @@ const (
 	actionUpload = "upload"
 )
 
-func Formatter(request *types.APIRequest, resource *types.RawResource) {
-	resource.Actions = make(map[string]string, 1)
-	if request.AccessControl.CanUpdate(request, resource.APIObject, resource.Schema) != nil {
-		return
-	}
+// NewFormatter returns the formatter of the images, the actions of the chunked upload are only added if it's enabled
+func NewFormatter(chunkedUploadEnabled bool) types.Formatter {
+	return func(request *types.APIRequest, resource *types.RawResource) {
+		resource.Actions = make(map[string]string, 1)
+		if request.AccessControl.CanUpdate(request, resource.APIObject, resource.Schema) != nil {
+			return
+		}
 
-	if resource.APIObject.Data().String("spec", "sourceType") == apisv1beta1.VirtualMachineImageSourceTypeUpload {
-		resource.AddAction(request, actionUpload)
+		if resource.APIObject.Data().String("spec", "sourceType") == apisv1beta1.VirtualMachineImageSourceTypeUpload {
+			resource.AddAction(request, actionUpload)
+			if chunkedUploadEnabled {
+				resource.AddAction(request, actionUploadChunk)
+				resource.AddAction(request, actionUploadOffset)
+			}
+		}
 	}
 }
 
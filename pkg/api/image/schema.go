@@ -8,25 +8,68 @@ import (
 	"github.com/rancher/steve/pkg/server"
 	"github.com/rancher/wrangler/pkg/schemas"
 
+	"github.com/harvester/harvester/pkg/api/volume"
 	"github.com/harvester/harvester/pkg/config"
+	"github.com/harvester/harvester/pkg/util/imageusage"
 )
 
 func RegisterSchema(scaled *config.Scaled, server *server.Server, options config.Options) error {
+	uploadHandler := UploadActionHandler{
+		httpClient:                  http.Client{},
+		Images:                      scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage(),
+		ImageCache:                  scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage().Cache(),
+		BackingImageDataSources:     scaled.LonghornFactory.Longhorn().V1beta1().BackingImageDataSource(),
+		BackingImageDataSourceCache: scaled.LonghornFactory.Longhorn().V1beta1().BackingImageDataSource().Cache(),
+	}
+	resourceActions := map[string]schemas.Action{
+		actionUpload: {},
+	}
+	actionHandlers := map[string]http.Handler{
+		actionUpload: uploadHandler,
+	}
+	// the chunks are assembled in the upload staging volume, the resumable uploads are only served with it
+	if options.ChunkedImageUploadEnabled {
+		chunkedUploadHandler := NewChunkedUploadHandler(uploadHandler, scaled.CoreFactory.Core().V1().Pod().Cache(),
+			options.Namespace, options.PodIP, options.HTTPSListenPort)
+		go chunkedUploadHandler.Run(scaled.Ctx)
+		resourceActions[actionUploadChunk] = schemas.Action{Output: "uploadChunkOutput"}
+		resourceActions[actionUploadOffset] = schemas.Action{Output: "uploadChunkOutput"}
+		actionHandlers[actionUploadChunk] = chunkedUploadHandler
+		actionHandlers[actionUploadOffset] = chunkedUploadHandler
+	}
+
+	pvcs := scaled.CoreFactory.Core().V1().PersistentVolumeClaim()
+	downloadHandler := DownloadHandler{
+		downloader: volume.NewDownloader(options.ExporterImage, scaled.BatchFactory.Batch().V1().Job(),
+			scaled.CoreFactory.Core().V1().Pod().Cache(), scaled.CoreFactory.Core().V1().Secret()),
+		imageCache: scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage().Cache(),
+		pvcs:       pvcs,
+		pvcCache:   pvcs.Cache(),
+	}
+
+	usageHandler := UsageHandler{
+		imageCache:        scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage().Cache(),
+		backingImageCache: scaled.LonghornFactory.Longhorn().V1beta1().BackingImage().Cache(),
+		usageGetter: &imageusage.Getter{
+			PVCCache:             pvcs.Cache(),
+			VMCache:              scaled.VirtFactory.Kubevirt().V1().VirtualMachine().Cache(),
+			TemplateVersionCache: scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineTemplateVersion().Cache(),
+			BackupCache:          scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup().Cache(),
+			SnapshotCache:        scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineSnapshot().Cache(),
+		},
+	}
+
+	server.BaseSchemas.MustImportAndCustomize(UploadChunkOutput{}, nil)
+
 	t := schema.Template{
 		ID: "harvesterhci.io.virtualmachineimage",
 		Customize: func(s *types.APISchema) {
-			s.Formatter = Formatter
-			s.ResourceActions = map[string]schemas.Action{
-				actionUpload: {},
-			}
-			s.ActionHandlers = map[string]http.Handler{
-				actionUpload: UploadActionHandler{
-					httpClient:                  http.Client{},
-					Images:                      scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage(),
-					ImageCache:                  scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage().Cache(),
-					BackingImageDataSources:     scaled.LonghornFactory.Longhorn().V1beta1().BackingImageDataSource(),
-					BackingImageDataSourceCache: scaled.LonghornFactory.Longhorn().V1beta1().BackingImageDataSource().Cache(),
-				},
+			s.Formatter = NewFormatter(options.ChunkedImageUploadEnabled)
+			s.ResourceActions = resourceActions
+			s.ActionHandlers = actionHandlers
+			s.LinkHandlers = map[string]http.Handler{
+				downloadLink: downloadHandler,
+				usageLink:    usageHandler,
 			}
 		},
 	}
@@ -0,0 +1,25 @@
+package image
+
+// UploadChunkOutput is the progress of a chunked upload, the next chunk is expected at the offset
+type UploadChunkOutput struct {
+	Offset   int64 `json:"offset"`
+	Size     int64 `json:"size"`
+	Complete bool  `json:"complete"`
+}
+
+// ImageUsage is what uses an image and the space it occupies on the Longhorn disks. Only the resources the user
+// is allowed to get are listed, InUse tells whether anything uses the image.
+type ImageUsage struct {
+	InUse bool  `json:"inUse"`
+	Size  int64 `json:"size"`
+	// DiskFileCount is how many copies of the image the Longhorn disks keep
+	DiskFileCount int    `json:"diskFileCount"`
+	OccupiedSize  int64  `json:"occupiedSize"`
+	UnusedSince   string `json:"unusedSince,omitempty"`
+
+	Volumes          []string `json:"volumes"`
+	VirtualMachines  []string `json:"virtualMachines"`
+	TemplateVersions []string `json:"templateVersions"`
+	Backups          []string `json:"backups"`
+	Snapshots        []string `json:"snapshots"`
+}
@@ -0,0 +1,79 @@
+package image
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rancher/apiserver/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctllhv1beta1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/imageusage"
+)
+
+const (
+	usageLink = "usage"
+
+	pvcSchemaID             = "persistentvolumeclaim"
+	vmSchemaID              = "kubevirt.io.virtualmachine"
+	templateVersionSchemaID = "harvesterhci.io.virtualmachinetemplateversion"
+	vmBackupSchemaID        = "harvesterhci.io.virtualmachinebackup"
+	vmSnapshotSchemaID      = "harvesterhci.io.virtualmachinesnapshot"
+)
+
+// UsageHandler serves the usage link of images, which lists the volumes, VMs, template versions, VM backups and
+// snapshots depending on the image, so the users can tell which images are forgotten
+type UsageHandler struct {
+	imageCache        ctlharvesterv1.VirtualMachineImageCache
+	backingImageCache ctllhv1beta1.BackingImageCache
+	usageGetter       *imageusage.Getter
+}
+
+func (h UsageHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	apiOp := types.GetAPIContext(r.Context())
+	image, err := h.imageCache.Get(apiOp.Namespace, apiOp.Name)
+	if err != nil {
+		util.ResponseError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	usage, err := h.usageGetter.Get(image)
+	if err != nil {
+		util.ResponseError(rw, http.StatusInternalServerError, err)
+		return
+	}
+
+	output := ImageUsage{
+		InUse:            usage.InUse(),
+		Size:             image.Status.Size,
+		UnusedSince:      image.Annotations[util.AnnotationImageUnusedSince],
+		Volumes:          filterAccessible(apiOp, pvcSchemaID, usage.Volumes),
+		VirtualMachines:  filterAccessible(apiOp, vmSchemaID, usage.VirtualMachines),
+		TemplateVersions: filterAccessible(apiOp, templateVersionSchemaID, usage.TemplateVersions),
+		Backups:          filterAccessible(apiOp, vmBackupSchemaID, usage.Backups),
+		Snapshots:        filterAccessible(apiOp, vmSnapshotSchemaID, usage.Snapshots),
+	}
+	backingImage, err := h.backingImageCache.Get(util.LonghornSystemNamespaceName, fmt.Sprintf("%s-%s", image.Namespace, image.Name))
+	if err != nil && !apierrors.IsNotFound(err) {
+		util.ResponseError(rw, http.StatusInternalServerError, err)
+		return
+	} else if err == nil {
+		output.DiskFileCount = len(backingImage.Status.DiskFileStatusMap)
+		output.OccupiedSize = int64(output.DiskFileCount) * backingImage.Status.Size
+	}
+	util.ResponseOKWithBody(rw, output)
+}
+
+// filterAccessible leaves out the resources the user is not allowed to get, an image may be used in other namespaces
+func filterAccessible(apiOp *types.APIRequest, schemaID string, ids []string) []string {
+	accessible := make([]string, 0, len(ids))
+	for _, id := range ids {
+		namespace, name := ref.Parse(id)
+		if apiOp.AccessControl.CanDo(apiOp, schemaID, "get", namespace, name) == nil {
+			accessible = append(accessible, id)
+		}
+	}
+	return accessible
+}
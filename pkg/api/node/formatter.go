@@ -16,7 +16,6 @@ import (
 )
 
 const (
-	drainKey                     = "kubevirt.io/drain"
 	enableMaintenanceModeAction  = "enableMaintenanceMode"
 	disableMaintenanceModeAction = "disableMaintenanceMode"
 	cordonAction                 = "cordon"
@@ -93,14 +92,9 @@ func (h ActionHandler) cordonUncordonNode(node *corev1.Node, actionName string,
 }
 
 func (h ActionHandler) enableMaintenanceMode(node *corev1.Node) error {
+	// the VMs are migrated by the maintain node controller with the maintenance-migration-concurrency setting,
+	// the drain taint isn't added, otherwise KubeVirt evicts all the VMs on the node at once.
 	node.Spec.Unschedulable = true
-	if !hasDrainTaint(node.Spec.Taints) {
-		node.Spec.Taints = append(node.Spec.Taints, corev1.Taint{
-			Key:    drainKey,
-			Value:  "scheduling",
-			Effect: corev1.TaintEffectNoSchedule,
-		})
-	}
 	if node.Annotations == nil {
 		node.Annotations = make(map[string]string)
 	}
@@ -109,24 +103,10 @@ func (h ActionHandler) enableMaintenanceMode(node *corev1.Node) error {
 	return err
 }
 
-func hasDrainTaint(taints []corev1.Taint) bool {
-	for _, taint := range taints {
-		if taint.Key == drainKey {
-			return true
-		}
-	}
-	return false
-}
-
 func (h ActionHandler) disableMaintenanceMode(node *corev1.Node) error {
 	node.Spec.Unschedulable = false
-	for i, taint := range node.Spec.Taints {
-		if taint.Key == drainKey {
-			node.Spec.Taints = append(node.Spec.Taints[:i], node.Spec.Taints[i+1:]...)
-			break
-		}
-	}
 	delete(node.Annotations, ctlnode.MaintainStatusAnnotationKey)
+	delete(node.Annotations, ctlnode.MaintainProgressAnnotationKey)
 	_, err := h.nodeClient.Update(node)
 	return err
 }
@@ -0,0 +1,205 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/rancher/wrangler/pkg/slice"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/util/workqueue"
+	kv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	batchDelete = "delete"
+
+	// maxBatchWorkers is the number of VMs operated at the same time by a batch
+	maxBatchWorkers = 10
+
+	vmBackupSchemaID = "harvesterhci.io.virtualmachinebackup"
+)
+
+var batchOperations = []string{startVM, stopVM, restartVM, migrate, backupVM, batchDelete}
+
+// batch runs the operation on the listed VMs or the VMs matching the label selector, and reports the result
+// of each VM. The VMs are checked against the permissions of the user one by one, as the collection action
+// is authorized without knowing the VMs.
+func (h *vmActionHandler) batch(ctx context.Context, rw http.ResponseWriter, apiOp *types.APIRequest, input BatchInput) error {
+	if !slice.ContainsString(batchOperations, input.Operation) {
+		return apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("Unsupported batch operation %q", input.Operation))
+	}
+	targets, err := h.getBatchTargets(apiOp.Namespace, input)
+	if err != nil {
+		return err
+	}
+	if input.Operation == backupVM {
+		if err := h.checkBackupTargetConfigured(); err != nil {
+			return err
+		}
+	}
+
+	// the backups of the same batch share the suffix of their names
+	backupSuffix := time.Now().UTC().Format("20060102-150405")
+	results := make([]BatchResult, len(targets))
+	workqueue.ParallelizeUntil(ctx, maxBatchWorkers, len(targets), func(i int) {
+		results[i] = BatchResult{
+			Namespace: targets[i].Namespace,
+			Name:      targets[i].Name,
+			Success:   true,
+		}
+		if err := h.batchOperate(ctx, apiOp, targets[i], input, backupSuffix); err != nil {
+			results[i].Success = false
+			results[i].Error = err.Error()
+		}
+	})
+
+	output := BatchOutput{
+		Operation: input.Operation,
+		DryRun:    input.DryRun,
+		Results:   results,
+	}
+	for _, result := range results {
+		if result.Success {
+			output.Succeeded++
+		} else {
+			output.Failed++
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	return json.NewEncoder(rw).Encode(output)
+}
+
+func (h *vmActionHandler) getBatchTargets(namespace string, input BatchInput) ([]BatchTarget, error) {
+	if len(input.VMs) > 0 && input.Selector != "" {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, "Only one of vms and selector is allowed")
+	}
+	if len(input.VMs) > 0 {
+		var targets = make([]BatchTarget, 0, len(input.VMs))
+		var seen = map[BatchTarget]bool{}
+		for _, target := range input.VMs {
+			if target.Namespace == "" {
+				target.Namespace = namespace
+			}
+			if target.Namespace == "" || target.Name == "" {
+				return nil, apierror.NewAPIError(validation.InvalidBodyContent, "Both namespace and name of the VMs are required")
+			}
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+		return targets, nil
+	}
+
+	// an empty selector would match all the VMs, it's more likely a mistake than the intention
+	if input.Selector == "" {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, "One of vms and selector is required")
+	}
+	selector, err := labels.Parse(input.Selector)
+	if err != nil {
+		return nil, apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("Invalid selector %s: %v", input.Selector, err))
+	}
+	if input.Namespace != "" {
+		namespace = input.Namespace
+	}
+	vms, err := h.vmCache.List(namespace, selector)
+	if err != nil {
+		return nil, err
+	}
+	var targets = make([]BatchTarget, 0, len(vms))
+	for _, vm := range vms {
+		targets = append(targets, BatchTarget{Namespace: vm.Namespace, Name: vm.Name})
+	}
+	return targets, nil
+}
+
+func (h *vmActionHandler) batchOperate(ctx context.Context, apiOp *types.APIRequest, target BatchTarget, input BatchInput, backupSuffix string) error {
+	if err := checkBatchAccess(apiOp, input.Operation, target); err != nil {
+		return err
+	}
+	vm, err := h.vmCache.Get(target.Namespace, target.Name)
+	if err != nil {
+		return err
+	}
+	vmi, err := h.vmiCache.Get(target.Namespace, target.Name)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	if errors.IsNotFound(err) {
+		vmi = nil
+	}
+	if err := h.checkBatchOperation(input, vm, vmi); err != nil || input.DryRun {
+		return err
+	}
+
+	switch input.Operation {
+	case startVM, stopVM, restartVM:
+		if err := h.subresourceOperate(ctx, vmResource, vm.Namespace, vm.Name, input.Operation); err != nil {
+			return fmt.Errorf("%s virtual machine %s/%s failed, %v", input.Operation, vm.Namespace, vm.Name, err)
+		}
+		return nil
+	case migrate:
+		return h.migrate(ctx, vm.Namespace, vm.Name, input.NodeName)
+	case backupVM:
+		return h.createVMBackup(vm.Name, vm.Namespace, BackupInput{Name: fmt.Sprintf("%s-%s", vm.Name, backupSuffix)})
+	case batchDelete:
+		// the disks are kept as deleting a single VM without removedDisks
+		return h.vms.Delete(vm.Namespace, vm.Name, &metav1.DeleteOptions{})
+	}
+	return nil
+}
+
+// checkBatchOperation checks the VM is in the state the operation is offered by the VM formatter
+func (h *vmActionHandler) checkBatchOperation(input BatchInput, vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) error {
+	vf := &vmformatter{vmiCache: h.vmiCache}
+	switch input.Operation {
+	case startVM:
+		if !vf.canStart(vm, vmi) {
+			return fmt.Errorf("the VM is already running or starting")
+		}
+	case stopVM:
+		if !vf.canStop(vm) {
+			return fmt.Errorf("the VM is already stopped")
+		}
+	case restartVM:
+		if !vf.canRestart(vm, vmi) {
+			return fmt.Errorf("the VM is not running")
+		}
+	case migrate:
+		if !canMigrate(vmi) {
+			return fmt.Errorf("the VM is not running or is already migrating")
+		}
+		return h.dryRunMigrate(vm.Namespace, vm.Name, input.NodeName)
+	case backupVM:
+		if !vf.canDoBackup(vm, vmi) {
+			return fmt.Errorf("the VM is not running or is in progress of a snapshot")
+		}
+	}
+	return nil
+}
+
+func checkBatchAccess(apiOp *types.APIRequest, operation string, target BatchTarget) error {
+	verb := "update"
+	if operation == batchDelete {
+		verb = "delete"
+	}
+	if err := apiOp.AccessControl.CanDo(apiOp, vmSchemaID, verb, target.Namespace, target.Name); err != nil {
+		return fmt.Errorf("user is not allowed to %s the VM", operation)
+	}
+	if operation == backupVM {
+		if err := apiOp.AccessControl.CanDo(apiOp, vmBackupSchemaID, "create", target.Namespace, ""); err != nil {
+			return fmt.Errorf("user is not allowed to create backups in namespace %s", target.Namespace)
+		}
+	}
+	return nil
+}
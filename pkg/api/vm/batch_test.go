@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+func TestGetBatchTargets(t *testing.T) {
+	var clientset = fake.NewSimpleClientset()
+	for _, vm := range []*kv1.VirtualMachine{
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "lab-1", Labels: map[string]string{"group": "lab"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "lab-2", Labels: map[string]string{"group": "lab"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "prod-1", Labels: map[string]string{"group": "prod"}}},
+		{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "lab-3", Labels: map[string]string{"group": "lab"}}},
+	} {
+		assert.Nil(t, clientset.Tracker().Add(vm), "Mock resource should add into fake controller tracker")
+	}
+	var handler = &vmActionHandler{
+		vmCache: fakeclients.VirtualMachineCache(clientset.KubevirtV1().VirtualMachines),
+	}
+
+	var testCases = []struct {
+		name      string
+		namespace string
+		input     BatchInput
+		expected  []BatchTarget
+		expectErr bool
+	}{
+		{
+			name:      "explicit list defaults to the request namespace and removes duplicates",
+			namespace: "default",
+			input: BatchInput{VMs: []BatchTarget{
+				{Name: "lab-1"}, {Namespace: "other", Name: "lab-3"}, {Namespace: "default", Name: "lab-1"},
+			}},
+			expected: []BatchTarget{{Namespace: "default", Name: "lab-1"}, {Namespace: "other", Name: "lab-3"}},
+		},
+		{
+			name:      "explicit list without namespace",
+			input:     BatchInput{VMs: []BatchTarget{{Name: "lab-1"}}},
+			expectErr: true,
+		},
+		{
+			name:     "selector in a namespace",
+			input:    BatchInput{Selector: "group=lab", Namespace: "default"},
+			expected: []BatchTarget{{Namespace: "default", Name: "lab-1"}, {Namespace: "default", Name: "lab-2"}},
+		},
+		{
+			name:     "selector in all namespaces",
+			input:    BatchInput{Selector: "group=lab"},
+			expected: []BatchTarget{{Namespace: "default", Name: "lab-1"}, {Namespace: "default", Name: "lab-2"}, {Namespace: "other", Name: "lab-3"}},
+		},
+		{
+			name:      "neither list nor selector",
+			input:     BatchInput{},
+			expectErr: true,
+		},
+		{
+			name:      "both list and selector",
+			input:     BatchInput{VMs: []BatchTarget{{Namespace: "default", Name: "lab-1"}}, Selector: "group=lab"},
+			expectErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		targets, err := handler.getBatchTargets(tc.namespace, tc.input)
+		if tc.expectErr {
+			assert.NotNil(t, err, tc.name)
+			continue
+		}
+		assert.Nil(t, err, tc.name)
+		assert.ElementsMatch(t, tc.expected, targets, tc.name)
+	}
+}
@@ -0,0 +1,166 @@
+package vm
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/harvester/harvester/pkg/config"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/scheme"
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	consoleSubresource = "console"
+	vncSubresource     = "vnc"
+
+	consoleControllerName = "harvester-vm-console"
+
+	eventReasonConsoleConnected    = "ConsoleConnected"
+	eventReasonConsoleDisconnected = "ConsoleDisconnected"
+)
+
+// ConsoleHandler proxies the serial console and VNC websockets of the KubeVirt subresources, after checking the user
+// is allowed to access them. The connections are recorded as events of the VMI.
+type ConsoleHandler struct {
+	vmiCache                  ctlkubevirtv1.VirtualMachineInstanceCache
+	accessSetLookup           accesscontrol.AccessSetLookup
+	virtSubresourceRestClient rest.Interface
+	transport                 http.RoundTripper
+	recorder                  record.EventRecorder
+	// rancherHost is the host of the Rancher server Harvester is configured with, whose pages may open the consoles
+	rancherHost string
+}
+
+func NewConsoleHandler(scaled *config.Scaled, restConfig *rest.Config, asl accesscontrol.AccessSetLookup, rancherURL string) (*ConsoleHandler, error) {
+	var rancherHost string
+	if rancherURL != "" {
+		u, err := url.Parse(rancherURL)
+		if err != nil {
+			return nil, err
+		}
+		rancherHost = u.Host
+	}
+
+	copyConfig := rest.CopyConfig(restConfig)
+	copyConfig.GroupVersion = &kubevirtSubResouceGroupVersion
+	copyConfig.APIPath = "/apis"
+	copyConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	// websockets can't be upgraded over HTTP/2
+	copyConfig.TLSClientConfig.NextProtos = []string{"http/1.1"}
+	virtSubresourceClient, err := rest.RESTClientFor(copyConfig)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := rest.TransportFor(copyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ConsoleHandler{
+		vmiCache:                  scaled.VirtFactory.Kubevirt().V1().VirtualMachineInstance().Cache(),
+		accessSetLookup:           asl,
+		virtSubresourceRestClient: virtSubresourceClient,
+		transport:                 transport,
+		recorder:                  scaled.Management.NewRecorder(consoleControllerName, "", ""),
+		rancherHost:               rancherHost,
+	}, nil
+}
+
+func (h *ConsoleHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	namespace, name, subresource := vars["namespace"], vars["name"], vars["subresource"]
+	if subresource != consoleSubresource && subresource != vncSubresource {
+		util.ResponseErrorMsg(rw, http.StatusNotFound, fmt.Sprintf("unsupported subresource %s", subresource))
+		return
+	}
+	if !h.checkOrigin(r) {
+		util.ResponseErrorMsg(rw, http.StatusForbidden, fmt.Sprintf("origin %s is not allowed", r.Header.Get("Origin")))
+		return
+	}
+
+	// the user is authenticated by the auth middleware of the steve server
+	userInfo, ok := (&types.APIRequest{Request: r}).GetUserInfo()
+	if !ok {
+		util.ResponseErrorMsg(rw, http.StatusUnauthorized, "failed to get the user of the request")
+		return
+	}
+	if !canAccessSubresource(h.accessSetLookup.AccessFor(userInfo), namespace, name, subresource) {
+		util.ResponseErrorMsg(rw, http.StatusForbidden, fmt.Sprintf("user %s is not allowed to access the %s of VM %s/%s",
+			userInfo.GetName(), subresource, namespace, name))
+		return
+	}
+
+	vmi, err := h.vmiCache.Get(namespace, name)
+	if err != nil {
+		util.ResponseError(rw, http.StatusNotFound, errors.Wrapf(err, "failed to get the VMI %s/%s", namespace, name))
+		return
+	}
+	if !vmi.IsRunning() {
+		util.ResponseErrorMsg(rw, http.StatusConflict, fmt.Sprintf("VM %s/%s is not running", namespace, name))
+		return
+	}
+
+	target := h.virtSubresourceRestClient.Get().Namespace(namespace).Resource(vmiResource).
+		SubResource(subresource).Name(name).URL()
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL = target
+			req.Host = target.Host
+			// the transport authenticates as Harvester, the credentials of the user must not be forwarded
+			req.Header.Del("Authorization")
+			req.Header.Del("Cookie")
+		},
+		Transport: h.transport,
+	}
+
+	start := time.Now()
+	h.recorder.Eventf(vmi, corev1.EventTypeNormal, eventReasonConsoleConnected,
+		"User %s connected to the %s of the VM", userInfo.GetName(), subresource)
+	logrus.Infof("user %s connected to the %s of vm %s/%s", userInfo.GetName(), subresource, namespace, name)
+
+	proxy.ServeHTTP(rw, r)
+
+	duration := time.Since(start).Round(time.Second)
+	h.recorder.Eventf(vmi, corev1.EventTypeNormal, eventReasonConsoleDisconnected,
+		"User %s disconnected from the %s of the VM after %s", userInfo.GetName(), subresource, duration)
+	logrus.Infof("user %s disconnected from the %s of vm %s/%s after %s", userInfo.GetName(), subresource, namespace, name, duration)
+}
+
+// checkOrigin prevents cross-site websocket hijacking. Browsers send the cookies of Harvester along with
+// the websocket handshakes of any site, so the handshakes from browsers must come from the pages of Harvester itself
+// or of the configured Rancher server. The clients other than browsers don't send the Origin header.
+func (h *ConsoleHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) || (h.rancherHost != "" && strings.EqualFold(u.Host, h.rancherHost))
+}
+
+// canAccessSubresource checks the user is allowed to access the subresource of the VMI by the RBAC rules,
+// the same as accessing it through the Kubernetes API.
+func canAccessSubresource(accessSet *accesscontrol.AccessSet, namespace, name, subresource string) bool {
+	return accessSet.Grants("get", schema.GroupResource{
+		Group:    kubevirtSubResouceGroupVersion.Group,
+		Resource: vmiResource + "/" + subresource,
+	}, namespace, name)
+}
@@ -0,0 +1,66 @@
+package vm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCanAccessSubresource(t *testing.T) {
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("get", schema.GroupResource{
+		Group:    "subresources.kubevirt.io",
+		Resource: "virtualmachineinstances/console",
+	}, accesscontrol.Access{Namespace: "default", ResourceName: "*"})
+	accessSet.Add("get", schema.GroupResource{
+		Group:    "subresources.kubevirt.io",
+		Resource: "virtualmachineinstances/vnc",
+	}, accesscontrol.Access{Namespace: "default", ResourceName: "vm1"})
+
+	var testCases = []struct {
+		name        string
+		namespace   string
+		vmName      string
+		subresource string
+		expected    bool
+	}{
+		{name: "console of any VM in the namespace", namespace: "default", vmName: "vm2", subresource: consoleSubresource, expected: true},
+		{name: "console in another namespace", namespace: "other", vmName: "vm1", subresource: consoleSubresource, expected: false},
+		{name: "VNC of the granted VM", namespace: "default", vmName: "vm1", subresource: vncSubresource, expected: true},
+		{name: "VNC of another VM", namespace: "default", vmName: "vm2", subresource: vncSubresource, expected: false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, canAccessSubresource(accessSet, tc.namespace, tc.vmName, tc.subresource), tc.name)
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	handler := &ConsoleHandler{rancherHost: "rancher.example.com"}
+
+	var testCases = []struct {
+		name     string
+		host     string
+		origin   string
+		expected bool
+	}{
+		{name: "no origin from the clients other than browsers", host: "192.168.0.10", origin: "", expected: true},
+		{name: "same origin", host: "192.168.0.10", origin: "https://192.168.0.10", expected: true},
+		{name: "same origin in another case", host: "harvester.example.com", origin: "https://HARVESTER.example.com", expected: true},
+		{name: "configured rancher server", host: "192.168.0.10", origin: "https://rancher.example.com", expected: true},
+		{name: "another site", host: "192.168.0.10", origin: "https://evil.example.com", expected: false},
+		{name: "another port", host: "192.168.0.10", origin: "https://192.168.0.10:8443", expected: false},
+		{name: "invalid origin", host: "192.168.0.10", origin: "://", expected: false},
+	}
+	for _, tc := range testCases {
+		r := httptest.NewRequest(http.MethodGet, "/v1/harvester/virtualmachines/default/vm1/vnc", nil)
+		r.Host = tc.host
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		assert.Equal(t, tc.expected, handler.checkOrigin(r), tc.name)
+	}
+}
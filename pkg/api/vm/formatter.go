@@ -7,37 +7,47 @@ import (
 	kv1 "kubevirt.io/client-go/api/v1"
 
 	"github.com/harvester/harvester/pkg/controller/master/migration"
+	ctlvm "github.com/harvester/harvester/pkg/controller/master/virtualmachine"
 	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
 	"github.com/harvester/harvester/pkg/util"
 )
 
 const (
-	startVM        = "start"
-	stopVM         = "stop"
-	restartVM      = "restart"
-	pauseVM        = "pause"
-	unpauseVM      = "unpause"
-	ejectCdRom     = "ejectCdRom"
-	migrate        = "migrate"
-	abortMigration = "abortMigration"
-	backupVM       = "backup"
-	restoreVM      = "restore"
-	createTemplate = "createTemplate"
-	addVolume      = "addVolume"
-	removeVolume   = "removeVolume"
+	startVM             = "start"
+	stopVM              = "stop"
+	restartVM           = "restart"
+	softReboot          = util.PowerOperationSoftReboot
+	shutdown            = util.PowerOperationShutdown
+	pauseVM             = "pause"
+	unpauseVM           = "unpause"
+	ejectCdRom          = "ejectCdRom"
+	migrate             = "migrate"
+	abortMigration      = "abortMigration"
+	findMigratableNodes = "findMigratableNodes"
+	backupVM            = "backup"
+	restoreVM           = "restore"
+	snapshotVM          = "snapshot"
+	revertSnapshot      = "revertToSnapshot"
+	cloneVM             = "clone"
+	createTemplate      = "createTemplate"
+	addVolume           = "addVolume"
+	removeVolume        = "removeVolume"
+	resizeVM            = "resize"
+	batchVM             = "batch"
 )
 
 type vmformatter struct {
 	vmiCache ctlkubevirtv1.VirtualMachineInstanceCache
 }
 
+func (vf *vmformatter) collectionFormatter(request *types.APIRequest, collection *types.GenericCollection) {
+	collection.AddAction(request, batchVM)
+}
+
 func (vf *vmformatter) formatter(request *types.APIRequest, resource *types.RawResource) {
 	// reset resource actions, because action map already be set when add actions handler,
 	// but current framework can't support use formatter to remove key from action map
 	resource.Actions = make(map[string]string, 1)
-	if request.AccessControl.CanUpdate(request, resource.APIObject, resource.Schema) != nil {
-		return
-	}
 
 	vm := &kv1.VirtualMachine{}
 	err := convert.ToObj(resource.APIObject.Data(), vm)
@@ -45,14 +55,28 @@ func (vf *vmformatter) formatter(request *types.APIRequest, resource *types.RawR
 		return
 	}
 
+	vmi := vf.getVMI(vm)
+	// the changes of a running VM which take effect after it restarts
+	if changes := ctlvm.GetPendingChanges(vm, vmi); len(changes) > 0 {
+		resource.APIObject.Data().SetNested(changes, "status", "pendingChanges")
+	}
+
+	if canGetGuestInfo(vmi) {
+		resource.Links[guestInfoLink] = request.URLBuilder.Link(resource.Schema, resource.ID, guestInfoLink)
+	}
+
+	if request.AccessControl.CanUpdate(request, resource.APIObject, resource.Schema) != nil {
+		return
+	}
+
 	resource.AddAction(request, addVolume)
 	resource.AddAction(request, removeVolume)
+	resource.AddAction(request, resizeVM)
 
 	if canEjectCdRom(vm) {
 		resource.AddAction(request, ejectCdRom)
 	}
 
-	vmi := vf.getVMI(vm)
 	if vf.canStart(vm, vmi) {
 		resource.AddAction(request, startVM)
 	}
@@ -65,6 +89,11 @@ func (vf *vmformatter) formatter(request *types.APIRequest, resource *types.RawR
 		resource.AddAction(request, restartVM)
 	}
 
+	if canGracefulPowerOperate(vm, vmi) {
+		resource.AddAction(request, softReboot)
+		resource.AddAction(request, shutdown)
+	}
+
 	if vf.canPause(vmi) {
 		resource.AddAction(request, pauseVM)
 	}
@@ -75,6 +104,7 @@ func (vf *vmformatter) formatter(request *types.APIRequest, resource *types.RawR
 
 	if canMigrate(vmi) {
 		resource.AddAction(request, migrate)
+		resource.AddAction(request, findMigratableNodes)
 	}
 
 	if canAbortMigrate(vmi) {
@@ -89,9 +119,33 @@ func (vf *vmformatter) formatter(request *types.APIRequest, resource *types.RawR
 		resource.AddAction(request, restoreVM)
 	}
 
+	if vf.canDoBackup(vm, vmi) {
+		resource.AddAction(request, snapshotVM)
+	}
+
+	if vf.canDoRestore(vm, vmi) {
+		resource.AddAction(request, revertSnapshot)
+	}
+
 	if vf.canCreateTemplate(vmi) {
 		resource.AddAction(request, createTemplate)
 	}
+
+	if canClone(vm) {
+		resource.AddAction(request, cloneVM)
+	}
+}
+
+func canGracefulPowerOperate(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) bool {
+	if vmi == nil || !vmi.IsRunning() {
+		return false
+	}
+	_, inProgress := vm.Annotations[util.AnnotationPowerOperation]
+	return !inProgress
+}
+
+func canGetGuestInfo(vmi *kv1.VirtualMachineInstance) bool {
+	return vmi != nil && vmi.IsRunning() && vmiAgentConnected.IsTrue(vmi)
 }
 
 func canEjectCdRom(vm *kv1.VirtualMachine) bool {
@@ -209,6 +263,10 @@ func (vf *vmformatter) canDoRestore(vm *kv1.VirtualMachine, vmi *kv1.VirtualMach
 	return true
 }
 
+func canClone(vm *kv1.VirtualMachine) bool {
+	return vm.DeletionTimestamp == nil && vm.Status.SnapshotInProgress == nil
+}
+
 func (vf *vmformatter) isVMStarting(vm *kv1.VirtualMachine) bool {
 	for _, req := range vm.Status.StateChangeRequests {
 		if req.Action == kv1.StartRequest {
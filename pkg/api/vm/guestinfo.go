@@ -0,0 +1,187 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/apiserver/pkg/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/flowcontrol"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	guestInfoLink = "guestinfo"
+
+	guestOSInfoSubresource    = "guestosinfo"
+	fileSystemListSubresource = "filesystemlist"
+	userListSubresource       = "userlist"
+
+	// guestInfoTTL is how long the guest info of a VM is cached, the guest agent is queried at most once in it
+	guestInfoTTL       = 30 * time.Second
+	guestInfoCacheSize = 1024
+	// guestInfoQPS and guestInfoBurst limit the queries to all the guest agents
+	guestInfoQPS   = 5
+	guestInfoBurst = 20
+)
+
+// guestInfoHandler serves the guestinfo link of VMs, which aggregates the data the QEMU guest agent reports through
+// the KubeVirt subresources, so the users don't need to log in to the guests to know e.g. which filesystem is full.
+type guestInfoHandler struct {
+	vmiCache                  ctlkubevirtv1.VirtualMachineInstanceCache
+	virtSubresourceRestClient rest.Interface
+	cache                     *cache.LRUExpireCache
+	rateLimiter               flowcontrol.RateLimiter
+}
+
+func newGuestInfoHandler(vmiCache ctlkubevirtv1.VirtualMachineInstanceCache, virtSubresourceRestClient rest.Interface) *guestInfoHandler {
+	return &guestInfoHandler{
+		vmiCache:                  vmiCache,
+		virtSubresourceRestClient: virtSubresourceRestClient,
+		cache:                     cache.NewLRUExpireCache(guestInfoCacheSize),
+		rateLimiter:               flowcontrol.NewTokenBucketRateLimiter(guestInfoQPS, guestInfoBurst),
+	}
+}
+
+func (h *guestInfoHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	apiOp := types.GetAPIContext(r.Context())
+	namespace, name := apiOp.Namespace, apiOp.Name
+
+	// the user is allowed to get the VM by the link, but the guest data is guarded by the KubeVirt subresources
+	for _, subresource := range []string{guestOSInfoSubresource, fileSystemListSubresource, userListSubresource} {
+		resource := fmt.Sprintf("%s/%s/%s", kubevirtSubResouceGroupVersion.Group, vmiResource, subresource)
+		if err := apiOp.AccessControl.CanDo(apiOp, resource, "get", namespace, name); err != nil {
+			util.ResponseErrorMsg(rw, http.StatusForbidden, fmt.Sprintf("user is not allowed to get the %s of VM %s/%s", subresource, namespace, name))
+			return
+		}
+	}
+
+	info, err := h.getGuestInfo(namespace, name)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(guestInfoError); ok {
+			status = e.status
+		}
+		util.ResponseError(rw, status, err)
+		return
+	}
+	util.ResponseOKWithBody(rw, info)
+}
+
+type guestInfoError struct {
+	status int
+	error
+}
+
+func (h *guestInfoHandler) getGuestInfo(namespace, name string) (*GuestInfo, error) {
+	key := namespace + "/" + name
+	if cached, ok := h.cache.Get(key); ok {
+		return cached.(*GuestInfo), nil
+	}
+
+	vmi, err := h.vmiCache.Get(namespace, name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, guestInfoError{http.StatusNotFound, fmt.Errorf("VM %s is not running", key)}
+		}
+		return nil, err
+	}
+	if !vmi.IsRunning() || !vmiAgentConnected.IsTrue(vmi) {
+		return nil, guestInfoError{http.StatusConflict, fmt.Errorf("the guest agent of VM %s is not connected", key)}
+	}
+	if !h.rateLimiter.TryAccept() {
+		return nil, guestInfoError{http.StatusTooManyRequests, errors.New("too many guest info requests, please retry later")}
+	}
+
+	guestOSInfo := &kv1.VirtualMachineInstanceGuestAgentInfo{}
+	if err := h.getSubresource(namespace, name, guestOSInfoSubresource, guestOSInfo); err != nil {
+		return nil, err
+	}
+	fileSystemList := &kv1.VirtualMachineInstanceFileSystemList{}
+	if err := h.getSubresource(namespace, name, fileSystemListSubresource, fileSystemList); err != nil {
+		return nil, err
+	}
+	userList := &kv1.VirtualMachineInstanceGuestOSUserList{}
+	if err := h.getSubresource(namespace, name, userListSubresource, userList); err != nil {
+		return nil, err
+	}
+
+	info := toGuestInfo(vmi, guestOSInfo, fileSystemList, userList)
+	h.cache.Add(key, info, guestInfoTTL)
+	return info, nil
+}
+
+func (h *guestInfoHandler) getSubresource(namespace, name, subresource string, result interface{}) error {
+	body, err := h.virtSubresourceRestClient.Get().Namespace(namespace).Resource(vmiResource).SubResource(subresource).Name(name).DoRaw(context.TODO())
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the %s of VM %s/%s", subresource, namespace, name)
+	}
+	return json.Unmarshal(body, result)
+}
+
+func toGuestInfo(vmi *kv1.VirtualMachineInstance, guestOSInfo *kv1.VirtualMachineInstanceGuestAgentInfo,
+	fileSystemList *kv1.VirtualMachineInstanceFileSystemList, userList *kv1.VirtualMachineInstanceGuestOSUserList) *GuestInfo {
+	info := &GuestInfo{
+		Hostname:     guestOSInfo.Hostname,
+		AgentVersion: guestOSInfo.GAVersion,
+		OS: GuestOS{
+			Name:          guestOSInfo.OS.Name,
+			PrettyName:    guestOSInfo.OS.PrettyName,
+			Version:       guestOSInfo.OS.Version,
+			KernelRelease: guestOSInfo.OS.KernelRelease,
+		},
+		Interfaces:  make([]GuestInterface, 0, len(vmi.Status.Interfaces)),
+		FileSystems: make([]GuestFileSystem, 0, len(fileSystemList.Items)),
+		Users:       make([]GuestUser, 0, len(userList.Items)),
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// the IPs inside the guest are reported by the guest agent to the VMI status
+	for _, iface := range vmi.Status.Interfaces {
+		ips := iface.IPs
+		if len(ips) == 0 && iface.IP != "" {
+			ips = []string{iface.IP}
+		}
+		info.Interfaces = append(info.Interfaces, GuestInterface{
+			Name:          iface.Name,
+			InterfaceName: iface.InterfaceName,
+			MAC:           strings.ToLower(iface.MAC),
+			IPs:           ips,
+		})
+	}
+	for _, fs := range fileSystemList.Items {
+		var usage float64
+		if fs.TotalBytes > 0 {
+			usage = float64(fs.UsedBytes) * 100 / float64(fs.TotalBytes)
+		}
+		info.FileSystems = append(info.FileSystems, GuestFileSystem{
+			DiskName:       fs.DiskName,
+			MountPoint:     fs.MountPoint,
+			FileSystemType: fs.FileSystemType,
+			UsedBytes:      int64(fs.UsedBytes),
+			TotalBytes:     int64(fs.TotalBytes),
+			UsagePercent:   usage,
+		})
+	}
+	for _, user := range userList.Items {
+		guestUser := GuestUser{
+			UserName: user.UserName,
+			Domain:   user.Domain,
+		}
+		if user.LoginTime > 0 {
+			guestUser.LoginTime = time.Unix(int64(user.LoginTime), 0).UTC().Format(time.RFC3339)
+		}
+		info.Users = append(info.Users, guestUser)
+	}
+	return info
+}
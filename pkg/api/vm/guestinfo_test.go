@@ -0,0 +1,59 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	kv1 "kubevirt.io/client-go/api/v1"
+)
+
+func TestToGuestInfo(t *testing.T) {
+	vmi := &kv1.VirtualMachineInstance{
+		Status: kv1.VirtualMachineInstanceStatus{
+			Interfaces: []kv1.VirtualMachineInstanceNetworkInterface{
+				{Name: "default", InterfaceName: "eth0", MAC: "52:54:00:AB:CD:EF", IP: "10.52.0.10", IPs: []string{"10.52.0.10", "fd00::10"}},
+				{Name: "vlan1", InterfaceName: "eth1", IP: "192.168.1.10"},
+			},
+		},
+	}
+	guestOSInfo := &kv1.VirtualMachineInstanceGuestAgentInfo{
+		GAVersion: "4.2.0",
+		Hostname:  "vm1",
+		OS: kv1.VirtualMachineInstanceGuestOSInfo{
+			Name:          "Ubuntu",
+			PrettyName:    "Ubuntu 20.04.3 LTS",
+			Version:       "20.04.3 LTS (Focal Fossa)",
+			KernelRelease: "5.4.0-89-generic",
+		},
+	}
+	fileSystemList := &kv1.VirtualMachineInstanceFileSystemList{
+		Items: []kv1.VirtualMachineInstanceFileSystem{
+			{DiskName: "vda1", MountPoint: "/", FileSystemType: "ext4", UsedBytes: 9 * 1024, TotalBytes: 10 * 1024},
+			{DiskName: "sr0", MountPoint: "/media/cdrom", FileSystemType: "iso9660"},
+		},
+	}
+	userList := &kv1.VirtualMachineInstanceGuestOSUserList{
+		Items: []kv1.VirtualMachineInstanceGuestOSUser{
+			{UserName: "ubuntu", LoginTime: 1635000000.5},
+		},
+	}
+
+	info := toGuestInfo(vmi, guestOSInfo, fileSystemList, userList)
+	assert.Equal(t, "vm1", info.Hostname)
+	assert.Equal(t, "4.2.0", info.AgentVersion)
+	assert.Equal(t, GuestOS{
+		Name:          "Ubuntu",
+		PrettyName:    "Ubuntu 20.04.3 LTS",
+		Version:       "20.04.3 LTS (Focal Fossa)",
+		KernelRelease: "5.4.0-89-generic",
+	}, info.OS)
+	assert.Equal(t, []GuestInterface{
+		{Name: "default", InterfaceName: "eth0", MAC: "52:54:00:ab:cd:ef", IPs: []string{"10.52.0.10", "fd00::10"}},
+		{Name: "vlan1", InterfaceName: "eth1", IPs: []string{"192.168.1.10"}},
+	}, info.Interfaces)
+	assert.Equal(t, []GuestFileSystem{
+		{DiskName: "vda1", MountPoint: "/", FileSystemType: "ext4", UsedBytes: 9 * 1024, TotalBytes: 10 * 1024, UsagePercent: 90},
+		{DiskName: "sr0", MountPoint: "/media/cdrom", FileSystemType: "iso9660"},
+	}, info.FileSystems)
+	assert.Equal(t, []GuestUser{{UserName: "ubuntu", LoginTime: "2021-10-23T14:40:00Z"}}, info.Users)
+}
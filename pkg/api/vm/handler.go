@@ -11,20 +11,28 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	wranglername "github.com/rancher/wrangler/pkg/name"
 	"github.com/rancher/wrangler/pkg/schemas/validation"
 	"github.com/rancher/wrangler/pkg/slice"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/rand"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
 	kv1 "kubevirt.io/client-go/api/v1"
 
 	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/builder"
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
 	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/settings"
 	"github.com/harvester/harvester/pkg/util"
 )
@@ -48,13 +56,19 @@ type vmActionHandler struct {
 	backups                   ctlharvesterv1.VirtualMachineBackupClient
 	backupCache               ctlharvesterv1.VirtualMachineBackupCache
 	restores                  ctlharvesterv1.VirtualMachineRestoreClient
+	vmSnapshots               ctlharvesterv1.VirtualMachineSnapshotClient
+	vmSnapshotCache           ctlharvesterv1.VirtualMachineSnapshotCache
 	settingCache              ctlharvesterv1.SettingCache
 	nodeCache                 ctlcorev1.NodeCache
+	podCache                  ctlcorev1.PodCache
+	pvcClient                 ctlcorev1.PersistentVolumeClaimClient
 	pvcCache                  ctlcorev1.PersistentVolumeClaimCache
 	secretClient              ctlcorev1.SecretClient
 	secretCache               ctlcorev1.SecretCache
+	resourceQuotaClient       corev1client.ResourceQuotasGetter
 	virtSubresourceRestClient rest.Interface
 	virtRestClient            rest.Interface
+	recorder                  record.EventRecorder
 }
 
 func (h vmActionHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
@@ -67,6 +81,10 @@ func (h vmActionHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 		_, _ = rw.Write([]byte(err.Error()))
 		return
 	}
+	// the actions with output have written the response
+	if action := mux.Vars(req)["action"]; action == findMigratableNodes || action == resizeVM || action == batchVM {
+		return
+	}
 	rw.WriteHeader(http.StatusNoContent)
 }
 
@@ -93,9 +111,32 @@ func (h *vmActionHandler) doAction(rw http.ResponseWriter, r *http.Request) erro
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
 		}
+		if input.DryRun {
+			return h.dryRunMigrate(namespace, name, input.NodeName)
+		}
 		return h.migrate(r.Context(), namespace, name, input.NodeName)
+	case findMigratableNodes:
+		return h.findMigratableNodes(rw, namespace, name)
 	case abortMigration:
 		return h.abortMigration(namespace, name)
+	case resizeVM:
+		var input ResizeInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+		return h.resize(r.Context(), rw, namespace, name, input)
+	case batchVM:
+		var input BatchInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+		return h.batch(r.Context(), rw, types.GetAPIContext(r.Context()), input)
+	case softReboot, shutdown:
+		var input PowerOperationInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+		return h.gracefulPowerOperate(r.Context(), namespace, name, action, input)
 	case startVM, stopVM, restartVM:
 		if err := h.subresourceOperate(r.Context(), vmResource, namespace, name, action); err != nil {
 			return fmt.Errorf("%s virtual machine %s/%s failed, %v", action, namespace, name, err)
@@ -140,6 +181,39 @@ func (h *vmActionHandler) doAction(rw http.ResponseWriter, r *http.Request) erro
 			return err
 		}
 		return nil
+	case snapshotVM:
+		var input SnapshotInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+
+		if input.Name == "" {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Parameter snapshot name is required")
+		}
+
+		return h.createVMSnapshot(name, namespace, input)
+	case revertSnapshot:
+		var input RevertToSnapshotInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+
+		if input.SnapshotName == "" {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Parameter snapshotName is required")
+		}
+
+		return h.revertToSnapshot(name, namespace, input)
+	case cloneVM:
+		var input CloneInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Failed to decode request body: %v "+err.Error())
+		}
+
+		if input.TargetName == "" {
+			return apierror.NewAPIError(validation.InvalidBodyContent, "Parameter targetName is required")
+		}
+
+		return h.clone(r.Context(), namespace, name, input)
 	case createTemplate:
 		var input CreateTemplateInput
 		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -380,6 +454,61 @@ func (h *vmActionHandler) restoreBackup(vmName, vmNamespace string, input Restor
 	return nil
 }
 
+func (h *vmActionHandler) createVMSnapshot(vmName, vmNamespace string, input SnapshotInput) error {
+	apiGroup := kv1.SchemeGroupVersion.Group
+	vmSnapshot := &harvesterv1.VirtualMachineSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      input.Name,
+			Namespace: vmNamespace,
+		},
+		Spec: harvesterv1.VirtualMachineSnapshotSpec{
+			Source: corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     kv1.VirtualMachineGroupVersionKind.Kind,
+				Name:     vmName,
+			},
+		},
+	}
+	if _, err := h.vmSnapshots.Create(vmSnapshot); err != nil {
+		return fmt.Errorf("failed to create VM snapshot, error: %s", err.Error())
+	}
+	return nil
+}
+
+// revertToSnapshot reverts the VM to one of its snapshots by a VirtualMachineRestore. The replaced volumes are deleted,
+// except the ones kept by the storage driver along with the other snapshots of the VM until the snapshots are removed.
+func (h *vmActionHandler) revertToSnapshot(vmName, vmNamespace string, input RevertToSnapshotInput) error {
+	vmSnapshot, err := h.vmSnapshotCache.Get(vmNamespace, input.SnapshotName)
+	if err != nil {
+		return err
+	}
+	if vmSnapshot.Spec.Source.Name != vmName {
+		return apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("snapshot %s does not belong to VM %s", input.SnapshotName, vmName))
+	}
+
+	apiGroup := kv1.SchemeGroupVersion.Group
+	restore := &harvesterv1.VirtualMachineRestore{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: input.SnapshotName + "-revert-",
+			Namespace:    vmNamespace,
+		},
+		Spec: harvesterv1.VirtualMachineRestoreSpec{
+			Target: corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     kv1.VirtualMachineGroupVersionKind.Kind,
+				Name:     vmName,
+			},
+			VirtualMachineSnapshotName: input.SnapshotName,
+			NewVM:                      false,
+			DeletionPolicy:             harvesterv1.VirtualMachineRestoreDelete,
+		},
+	}
+	if _, err := h.restores.Create(restore); err != nil {
+		return fmt.Errorf("failed to revert to snapshot, error: %s", err.Error())
+	}
+	return nil
+}
+
 func (h *vmActionHandler) checkBackupTargetConfigured() error {
 	targetSetting, err := h.settingCache.Get(settings.BackupTargetSettingName)
 	if err == nil && harvesterv1.SettingConfigured.IsTrue(targetSetting) {
@@ -454,16 +583,22 @@ func (h *vmActionHandler) createTemplate(namespace, name string, input CreateTem
 }
 
 func (h *vmActionHandler) createSecrets(templateVersion *harvesterv1.VirtualMachineTemplateVersion, vm *kv1.VirtualMachine) error {
+	owner := metav1.OwnerReference{
+		APIVersion: templateVersion.APIVersion,
+		Kind:       templateVersion.Kind,
+		Name:       templateVersion.Name,
+		UID:        templateVersion.UID,
+	}
 	for index, credential := range vm.Spec.Template.Spec.AccessCredentials {
 		if sshPublicKey := credential.SSHPublicKey; sshPublicKey != nil && sshPublicKey.Source.Secret != nil {
 			toCreateSecretName := getTemplateVersionSSHPublicKeySecretName(templateVersion.Name, index)
-			if err := h.copySecret(sshPublicKey.Source.Secret.SecretName, toCreateSecretName, templateVersion); err != nil {
+			if err := h.copySecret(templateVersion.Namespace, sshPublicKey.Source.Secret.SecretName, templateVersion.Namespace, toCreateSecretName, owner); err != nil {
 				return err
 			}
 		}
 		if userPassword := credential.UserPassword; userPassword != nil && userPassword.Source.Secret != nil {
 			toCreateSecretName := getTemplateVersionUserPasswordSecretName(templateVersion.Name, index)
-			if err := h.copySecret(userPassword.Source.Secret.SecretName, toCreateSecretName, templateVersion); err != nil {
+			if err := h.copySecret(templateVersion.Namespace, userPassword.Source.Secret.SecretName, templateVersion.Namespace, toCreateSecretName, owner); err != nil {
 				return err
 			}
 		}
@@ -474,13 +609,13 @@ func (h *vmActionHandler) createSecrets(templateVersion *harvesterv1.VirtualMach
 		}
 		if volume.CloudInitNoCloud.UserDataSecretRef != nil {
 			toCreateSecretName := getTemplateVersionUserDataSecretName(templateVersion.Name, volume.Name)
-			if err := h.copySecret(volume.CloudInitNoCloud.UserDataSecretRef.Name, toCreateSecretName, templateVersion); err != nil {
+			if err := h.copySecret(templateVersion.Namespace, volume.CloudInitNoCloud.UserDataSecretRef.Name, templateVersion.Namespace, toCreateSecretName, owner); err != nil {
 				return err
 			}
 		}
 		if volume.CloudInitNoCloud.NetworkDataSecretRef != nil {
 			toCreateSecretName := getTemplateVersionNetworkDataSecretName(templateVersion.Name, volume.Name)
-			if err := h.copySecret(volume.CloudInitNoCloud.NetworkDataSecretRef.Name, toCreateSecretName, templateVersion); err != nil {
+			if err := h.copySecret(templateVersion.Namespace, volume.CloudInitNoCloud.NetworkDataSecretRef.Name, templateVersion.Namespace, toCreateSecretName, owner); err != nil {
 				return err
 			}
 		}
@@ -488,23 +623,16 @@ func (h *vmActionHandler) createSecrets(templateVersion *harvesterv1.VirtualMach
 	return nil
 }
 
-func (h *vmActionHandler) copySecret(sourceName, targetName string, templateVersion *harvesterv1.VirtualMachineTemplateVersion) error {
-	secret, err := h.secretCache.Get(templateVersion.Namespace, sourceName)
+func (h *vmActionHandler) copySecret(namespace, sourceName, targetNamespace, targetName string, owner metav1.OwnerReference) error {
+	secret, err := h.secretCache.Get(namespace, sourceName)
 	if err != nil {
 		return err
 	}
 	toCreate := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      targetName,
-			Namespace: secret.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: templateVersion.APIVersion,
-					Kind:       templateVersion.Kind,
-					Name:       templateVersion.Name,
-					UID:        templateVersion.UID,
-				},
-			},
+			Name:            targetName,
+			Namespace:       targetNamespace,
+			OwnerReferences: []metav1.OwnerReference{owner},
 		},
 		Data: secret.Data,
 	}
@@ -513,6 +641,174 @@ func (h *vmActionHandler) copySecret(sourceName, targetName string, templateVers
 
 }
 
+// clone creates a copy of the VM. The PVC-backed disks are copied by CSI volume clone, the secrets are duplicated
+// and the MAC addresses and hostname are regenerated. The copy is started only if it's requested.
+// CSI volume clone only copies a PVC in its own namespace, so the disks of a copy in another namespace are cloned
+// in the source namespace and moved to the target namespace by the VM clone volume controller.
+func (h *vmActionHandler) clone(ctx context.Context, namespace, name string, input CloneInput) error {
+	targetNamespace := input.TargetNamespace
+	if targetNamespace == "" {
+		targetNamespace = namespace
+	}
+	if targetNamespace != namespace {
+		apiOp := types.GetAPIContext(ctx)
+		if err := apiOp.AccessControl.CanDo(apiOp, vmSchemaID, "create", targetNamespace, ""); err != nil {
+			return apierror.NewAPIError(validation.PermissionDenied, fmt.Sprintf("user is not allowed to create VMs in namespace %s", targetNamespace))
+		}
+	}
+
+	vm, err := h.vmCache.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	newVM, secretNames, clonedPVCs, err := h.getClonedVM(vm, targetNamespace, input.TargetName)
+	if err != nil {
+		return err
+	}
+
+	if newVM, err = h.vms.Create(newVM); err != nil {
+		return fmt.Errorf("failed to create VM %s/%s, error: %s", targetNamespace, input.TargetName, err.Error())
+	}
+
+	if err := h.createCloneDependencies(namespace, newVM, secretNames, clonedPVCs); err != nil {
+		h.deletePartialClone(newVM, clonedPVCs)
+		return err
+	}
+
+	if input.Start {
+		return h.subresourceOperate(ctx, vmResource, targetNamespace, newVM.Name, startVM)
+	}
+	return nil
+}
+
+// createCloneDependencies copies the secrets of the source VM to the namespace of the copy, and creates the PVCs
+// cloned in the source namespace for a copy in another namespace.
+func (h *vmActionHandler) createCloneDependencies(namespace string, newVM *kv1.VirtualMachine, secretNames map[string]string, clonedPVCs []*corev1.PersistentVolumeClaim) error {
+	owner := metav1.OwnerReference{
+		APIVersion: kv1.VirtualMachineGroupVersionKind.GroupVersion().String(),
+		Kind:       kv1.VirtualMachineGroupVersionKind.Kind,
+		Name:       newVM.Name,
+		UID:        newVM.UID,
+	}
+	for sourceName, targetName := range secretNames {
+		if err := h.copySecret(namespace, sourceName, newVM.Namespace, targetName, owner); err != nil {
+			return fmt.Errorf("failed to copy secret %s/%s, error: %s", namespace, sourceName, err.Error())
+		}
+	}
+	for _, pvc := range clonedPVCs {
+		// the VM clone volume controller only moves the volumes of the copy with this UID
+		pvc.Annotations[util.AnnotationCloneTargetUID] = string(newVM.UID)
+		if _, err := h.pvcClient.Create(pvc); err != nil {
+			return fmt.Errorf("failed to clone volume %s/%s, error: %s", pvc.Namespace, pvc.Spec.DataSource.Name, err.Error())
+		}
+	}
+	return nil
+}
+
+// deletePartialClone deletes the copy failing to get its dependencies. The copied secrets are owned by the copy,
+// and the cloned PVCs in the source namespace can't be, so they are deleted here.
+func (h *vmActionHandler) deletePartialClone(newVM *kv1.VirtualMachine, clonedPVCs []*corev1.PersistentVolumeClaim) {
+	if err := h.vms.Delete(newVM.Namespace, newVM.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		logrus.Errorf("failed to delete the partial clone %s/%s: %v", newVM.Namespace, newVM.Name, err)
+	}
+	for _, pvc := range clonedPVCs {
+		if err := h.pvcClient.Delete(pvc.Namespace, pvc.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			logrus.Errorf("failed to delete the cloned volume %s/%s: %v", pvc.Namespace, pvc.Name, err)
+		}
+	}
+}
+
+// getClonedVM returns the copy of the VM to create, the names of the secrets to duplicate for it and the PVCs to
+// clone in the source namespace if the copy is in another namespace.
+func (h *vmActionHandler) getClonedVM(vm *kv1.VirtualMachine, targetNamespace, targetName string) (*kv1.VirtualMachine, map[string]string, []*corev1.PersistentVolumeClaim, error) {
+	sanitizedVM := removeMacAddresses(vm)
+	sanitizedVM, secretNames := replaceSecretsForClone(targetName, sanitizedVM)
+
+	volumeClaimTemplates := []*corev1.PersistentVolumeClaim{}
+	clonedPVCs := []*corev1.PersistentVolumeClaim{}
+	for index, volume := range sanitizedVM.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := h.pvcCache.Get(vm.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		clonedPVC := getClonedPVC(pvc, wranglername.SafeConcatName(targetName, volume.Name, rand.String(5)))
+		sanitizedVM.Spec.Template.Spec.Volumes[index].PersistentVolumeClaim.ClaimName = clonedPVC.Name
+		if targetNamespace == vm.Namespace {
+			volumeClaimTemplates = append(volumeClaimTemplates, clonedPVC)
+			continue
+		}
+		// the PVC of the same name in the target namespace is created by the VM clone volume controller,
+		// so it's left out of the volume claim templates
+		clonedPVC.Namespace = vm.Namespace
+		clonedPVC.Annotations[util.AnnotationCloneTarget] = ref.Construct(targetNamespace, targetName)
+		clonedPVCs = append(clonedPVCs, clonedPVC)
+	}
+	volumeClaimTemplatesBytes, err := json.Marshal(volumeClaimTemplates)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	annotations := map[string]string{}
+	for key, value := range vm.Annotations {
+		if key == util.RemovedPVCsAnnotationKey || key == corev1.LastAppliedConfigAnnotation ||
+			strings.HasPrefix(key, "restore.harvesterhci.io/") {
+			continue
+		}
+		annotations[key] = value
+	}
+	annotations[util.AnnotationVolumeClaimTemplates] = string(volumeClaimTemplatesBytes)
+
+	template := sanitizedVM.Spec.Template
+	if _, ok := template.ObjectMeta.Labels[builder.LabelKeyVirtualMachineName]; ok {
+		template.ObjectMeta.Labels[builder.LabelKeyVirtualMachineName] = targetName
+	}
+	if template.Spec.Hostname != "" {
+		template.Spec.Hostname = targetName
+	}
+
+	newVM := &kv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        targetName,
+			Namespace:   targetNamespace,
+			Labels:      sanitizedVM.Labels,
+			Annotations: annotations,
+		},
+		Spec: sanitizedVM.Spec,
+	}
+	// the copy is started after its secrets are created
+	newVM.Spec.Running = pointer.BoolPtr(false)
+	newVM.Spec.RunStrategy = nil
+	return newVM, secretNames, clonedPVCs, nil
+}
+
+// getClonedPVC returns a PVC cloned from the source PVC by CSI volume clone.
+func getClonedPVC(pvc *corev1.PersistentVolumeClaim, name string) *corev1.PersistentVolumeClaim {
+	annotations := map[string]string{}
+	if imageID, ok := pvc.Annotations[util.AnnotationImageID]; ok {
+		annotations[util.AnnotationImageID] = imageID
+	}
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Resources:        pvc.Spec.Resources,
+			StorageClassName: pvc.Spec.StorageClassName,
+			VolumeMode:       pvc.Spec.VolumeMode,
+			DataSource: &corev1.TypedLocalObjectReference{
+				Kind: "PersistentVolumeClaim",
+				Name: pvc.Name,
+			},
+		},
+	}
+}
+
 // addVolume add a hotplug volume with given volume source and disk name.
 func (h *vmActionHandler) addVolume(ctx context.Context, namespace, name string, input AddVolumeInput) error {
 	// We only permit volume source from existing PersistentVolumeClaim at this moment.
@@ -629,6 +925,38 @@ func replaceSecrets(templateVersionName string, vm *kv1.VirtualMachine) *kv1.Vir
 	return sanitizedVm
 }
 
+// replaceSecretsForClone points the secret references of the VM to the copies for the cloned VM,
+// and returns the mapping from the source secret names to the copy names.
+func replaceSecretsForClone(vmName string, vm *kv1.VirtualMachine) (*kv1.VirtualMachine, map[string]string) {
+	sanitizedVm := vm.DeepCopy()
+	secretNames := map[string]string{}
+	replace := func(secretName *string) {
+		copyName := wranglername.SafeConcatName(vmName, *secretName)
+		secretNames[*secretName] = copyName
+		*secretName = copyName
+	}
+	for _, credential := range sanitizedVm.Spec.Template.Spec.AccessCredentials {
+		if sshPublicKey := credential.SSHPublicKey; sshPublicKey != nil && sshPublicKey.Source.Secret != nil {
+			replace(&sshPublicKey.Source.Secret.SecretName)
+		}
+		if userPassword := credential.UserPassword; userPassword != nil && userPassword.Source.Secret != nil {
+			replace(&userPassword.Source.Secret.SecretName)
+		}
+	}
+	for _, volume := range sanitizedVm.Spec.Template.Spec.Volumes {
+		if volume.CloudInitNoCloud == nil {
+			continue
+		}
+		if volume.CloudInitNoCloud.UserDataSecretRef != nil {
+			replace(&volume.CloudInitNoCloud.UserDataSecretRef.Name)
+		}
+		if volume.CloudInitNoCloud.NetworkDataSecretRef != nil {
+			replace(&volume.CloudInitNoCloud.NetworkDataSecretRef.Name)
+		}
+	}
+	return sanitizedVm, secretNames
+}
+
 // removeMacAddresses replaces the mac address of each device interface with an empty string.
 // This is because macAddresses are unique, and should not reuse the original's.
 func removeMacAddresses(vm *kv1.VirtualMachine) *kv1.VirtualMachine {
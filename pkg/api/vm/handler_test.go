@@ -2,6 +2,7 @@ package vm
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -15,6 +16,7 @@ import (
 	corefake "k8s.io/client-go/kubernetes/fake"
 	kubevirtapis "kubevirt.io/client-go/api/v1"
 
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	"github.com/harvester/harvester/pkg/controller/master/migration"
 	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
 	kubevirttype "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/kubevirt.io/v1"
@@ -601,3 +603,230 @@ func (c fakeVirtualMachineInstanceMigrationClient) Watch(namespace string, opts
 func (c fakeVirtualMachineInstanceMigrationClient) Patch(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (result *kubevirtapis.VirtualMachineInstanceMigration, err error) {
 	return c(namespace).Patch(context.TODO(), name, pt, data, metav1.PatchOptions{}, subresources...)
 }
+
+func TestRevertToSnapshot(t *testing.T) {
+	type input struct {
+		namespace  string
+		name       string
+		input      RevertToSnapshotInput
+		vmSnapshot *harvesterv1.VirtualMachineSnapshot
+	}
+	type output struct {
+		restoreCount int
+		err          error
+	}
+	var testVMSnapshot = &harvesterv1.VirtualMachineSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "snapshot",
+		},
+		Spec: harvesterv1.VirtualMachineSnapshotSpec{
+			Source: corev1.TypedLocalObjectReference{
+				Kind: kubevirtapis.VirtualMachineGroupVersionKind.Kind,
+				Name: "test",
+			},
+		},
+	}
+	var testCases = []struct {
+		name     string
+		given    input
+		expected output
+	}{
+		{
+			name: "VM snapshot not found",
+			given: input{
+				namespace: "default",
+				name:      "test",
+				input: RevertToSnapshotInput{
+					SnapshotName: "not-exist",
+				},
+			},
+			expected: output{
+				err: errors.New("virtualmachinesnapshots.harvesterhci.io \"not-exist\" not found"),
+			},
+		},
+		{
+			name: "VM snapshot of another VM",
+			given: input{
+				namespace: "default",
+				name:      "another",
+				input: RevertToSnapshotInput{
+					SnapshotName: "snapshot",
+				},
+				vmSnapshot: testVMSnapshot,
+			},
+			expected: output{
+				err: errors.New("InvalidBodyContent 422: snapshot snapshot does not belong to VM another"),
+			},
+		},
+		{
+			name: "Revert VM to snapshot",
+			given: input{
+				namespace: "default",
+				name:      "test",
+				input: RevertToSnapshotInput{
+					SnapshotName: "snapshot",
+				},
+				vmSnapshot: testVMSnapshot,
+			},
+			expected: output{
+				restoreCount: 1,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		var clientset = fake.NewSimpleClientset()
+		if tc.given.vmSnapshot != nil {
+			err := clientset.Tracker().Add(tc.given.vmSnapshot.DeepCopy())
+			assert.Nil(t, err, "Mock resource should add into fake controller tracker")
+		}
+
+		var handler = &vmActionHandler{
+			restores:        fakeclients.VirtualMachineRestoreClient(clientset.HarvesterhciV1beta1().VirtualMachineRestores),
+			vmSnapshotCache: fakeclients.VirtualMachineSnapshotCache(clientset.HarvesterhciV1beta1().VirtualMachineSnapshots),
+		}
+
+		var actual output
+		actual.err = handler.revertToSnapshot(tc.given.name, tc.given.namespace, tc.given.input)
+		restores, err := clientset.HarvesterhciV1beta1().VirtualMachineRestores(tc.given.namespace).List(context.Background(), metav1.ListOptions{})
+		assert.Nil(t, err, "List should return no error")
+		actual.restoreCount = len(restores.Items)
+
+		for _, restore := range restores.Items {
+			assert.Equal(t, tc.given.input.SnapshotName, restore.Spec.VirtualMachineSnapshotName, "case %q", tc.name)
+			assert.Equal(t, tc.given.name, restore.Spec.Target.Name, "case %q", tc.name)
+			assert.Equal(t, harvesterv1.VirtualMachineRestoreDelete, restore.Spec.DeletionPolicy, "case %q", tc.name)
+		}
+
+		assert.Equal(t, tc.expected.restoreCount, actual.restoreCount, "case %q", tc.name)
+		if tc.expected.err != nil && actual.err != nil {
+			assert.Equal(t, tc.expected.err.Error(), actual.err.Error(), "case %q", tc.name)
+		} else {
+			assert.Equal(t, tc.expected.err, actual.err, "case %q", tc.name)
+		}
+	}
+}
+
+func TestGetClonedVM(t *testing.T) {
+	var coreclientset = corefake.NewSimpleClientset()
+	var storageClassName = "longhorn-image-abcde"
+	var sourcePVC = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "source-disk-0",
+			Annotations: map[string]string{
+				util.AnnotationImageID:            "default/image-abcde",
+				"pv.kubernetes.io/bind-completed": "yes",
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+			StorageClassName: &storageClassName,
+			VolumeName:       "pvc-abcde",
+		},
+	}
+	err := coreclientset.Tracker().Add(sourcePVC)
+	assert.Nil(t, err, "Mock resource should add into fake controller tracker")
+
+	var running = true
+	var sourceVM = &kubevirtapis.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "source",
+			Annotations: map[string]string{
+				util.AnnotationVolumeClaimTemplates: "[]",
+				"restore.harvesterhci.io/name":      "restore",
+			},
+		},
+		Spec: kubevirtapis.VirtualMachineSpec{
+			Running: &running,
+			Template: &kubevirtapis.VirtualMachineInstanceTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"harvesterhci.io/vmName": "source",
+					},
+				},
+				Spec: kubevirtapis.VirtualMachineInstanceSpec{
+					Hostname: "source",
+					Domain: kubevirtapis.DomainSpec{
+						Devices: kubevirtapis.Devices{
+							Interfaces: []kubevirtapis.Interface{
+								{Name: "default", MacAddress: "52:54:00:12:34:56"},
+							},
+						},
+					},
+					Volumes: []kubevirtapis.Volume{
+						{
+							Name: "disk-0",
+							VolumeSource: kubevirtapis.VolumeSource{
+								PersistentVolumeClaim: &kubevirtapis.PersistentVolumeClaimVolumeSource{
+									PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+										ClaimName: "source-disk-0",
+									},
+								},
+							},
+						},
+						{
+							Name: "cloudinitdisk",
+							VolumeSource: kubevirtapis.VolumeSource{
+								CloudInitNoCloud: &kubevirtapis.CloudInitNoCloudSource{
+									UserDataSecretRef:    &corev1.LocalObjectReference{Name: "source-cloudinit"},
+									NetworkDataSecretRef: &corev1.LocalObjectReference{Name: "source-cloudinit"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var handler = &vmActionHandler{
+		pvcCache: fakeclients.PersistentVolumeClaimCache(coreclientset.CoreV1().PersistentVolumeClaims),
+	}
+	clonedVM, secretNames, clonedPVCs, err := handler.getClonedVM(sourceVM, "default", "target")
+	assert.Nil(t, err)
+	assert.Empty(t, clonedPVCs)
+
+	assert.Equal(t, "target", clonedVM.Name)
+	assert.Equal(t, "default", clonedVM.Namespace)
+	assert.False(t, *clonedVM.Spec.Running)
+	assert.Equal(t, "target", clonedVM.Spec.Template.Spec.Hostname)
+	assert.Equal(t, "target", clonedVM.Spec.Template.ObjectMeta.Labels["harvesterhci.io/vmName"])
+	assert.Equal(t, "", clonedVM.Spec.Template.Spec.Domain.Devices.Interfaces[0].MacAddress)
+	assert.NotContains(t, clonedVM.Annotations, "restore.harvesterhci.io/name")
+
+	assert.Equal(t, map[string]string{"source-cloudinit": "target-source-cloudinit"}, secretNames)
+	cloudInit := clonedVM.Spec.Template.Spec.Volumes[1].CloudInitNoCloud
+	assert.Equal(t, "target-source-cloudinit", cloudInit.UserDataSecretRef.Name)
+	assert.Equal(t, "target-source-cloudinit", cloudInit.NetworkDataSecretRef.Name)
+
+	var volumeClaimTemplates []*corev1.PersistentVolumeClaim
+	err = json.Unmarshal([]byte(clonedVM.Annotations[util.AnnotationVolumeClaimTemplates]), &volumeClaimTemplates)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(volumeClaimTemplates))
+	clonedPVC := volumeClaimTemplates[0]
+	assert.Equal(t, clonedPVC.Name, clonedVM.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, map[string]string{util.AnnotationImageID: "default/image-abcde"}, clonedPVC.Annotations)
+	assert.Equal(t, &storageClassName, clonedPVC.Spec.StorageClassName)
+	assert.Equal(t, "", clonedPVC.Spec.VolumeName)
+	assert.Equal(t, &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "source-disk-0"}, clonedPVC.Spec.DataSource)
+
+	// the source VM is left untouched
+	assert.Equal(t, "52:54:00:12:34:56", sourceVM.Spec.Template.Spec.Domain.Devices.Interfaces[0].MacAddress)
+	assert.Equal(t, "source-cloudinit", sourceVM.Spec.Template.Spec.Volumes[1].CloudInitNoCloud.UserDataSecretRef.Name)
+
+	// the disks of a copy in another namespace are cloned in the source namespace to be moved
+	clonedVM, secretNames, clonedPVCs, err = handler.getClonedVM(sourceVM, "other", "target")
+	assert.Nil(t, err)
+	assert.Equal(t, "other", clonedVM.Namespace)
+	assert.Equal(t, "[]", clonedVM.Annotations[util.AnnotationVolumeClaimTemplates])
+	assert.Equal(t, map[string]string{"source-cloudinit": "target-source-cloudinit"}, secretNames)
+	assert.Equal(t, 1, len(clonedPVCs))
+	clonedPVC = clonedPVCs[0]
+	assert.Equal(t, "default", clonedPVC.Namespace)
+	assert.Equal(t, clonedPVC.Name, clonedVM.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, "other/target", clonedPVC.Annotations[util.AnnotationCloneTarget])
+	assert.Equal(t, &corev1.TypedLocalObjectReference{Kind: "PersistentVolumeClaim", Name: "source-disk-0"}, clonedPVC.Spec.DataSource)
+}
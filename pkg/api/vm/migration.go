@@ -0,0 +1,399 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	ctlnode "github.com/harvester/harvester/pkg/controller/master/node"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+func (h *vmActionHandler) findMigratableNodes(rw http.ResponseWriter, namespace, name string) error {
+	nodes, err := h.checkMigrationTargets(namespace, name, "")
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	// the status is already written, the error can't be returned to the client anymore
+	if err := json.NewEncoder(rw).Encode(FindMigratableNodesOutput{Nodes: nodes}); err != nil {
+		logrus.Errorf("failed to encode the migratable nodes of vm %s/%s: %v", namespace, name, err)
+	}
+	return nil
+}
+
+// dryRunMigrate checks the VM can be migrated to the node, or to any node if the node name is empty,
+// without creating the migration.
+func (h *vmActionHandler) dryRunMigrate(namespace, name, nodeName string) error {
+	nodes, err := h.checkMigrationTargets(namespace, name, nodeName)
+	if err != nil {
+		return err
+	}
+
+	var reasons []string
+	for _, node := range nodes {
+		if node.Migratable {
+			return nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %s", node.NodeName, strings.Join(node.Reasons, ", ")))
+	}
+	if len(reasons) == 0 {
+		return apierror.NewAPIError(validation.InvalidAction, "There is no other node to migrate the VM to")
+	}
+	return apierror.NewAPIError(validation.InvalidAction, "The VM can't be migrated, "+strings.Join(reasons, "; "))
+}
+
+// checkMigrationTargets evaluates if the VM can be migrated to each node except the one it's running on,
+// or only the given node if the node name isn't empty.
+func (h *vmActionHandler) checkMigrationTargets(namespace, name, nodeName string) ([]MigratableNode, error) {
+	vmi, err := h.vmiCache.Get(namespace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// all the nodes are needed to find the topology domains of the pods for the pod affinity
+	allNodes, err := h.nodeCache.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	nodes := allNodes
+	if nodeName != "" {
+		if nodeName == vmi.Status.NodeName {
+			return nil, apierror.NewAPIError(validation.InvalidBodyContent, "The VM is currently running on the target node")
+		}
+		node, err := h.nodeCache.Get(nodeName)
+		if err != nil {
+			return nil, err
+		}
+		nodes = []*corev1.Node{node}
+	}
+
+	vmReasons, err := h.getVMMigrationReasons(vmi)
+	if err != nil {
+		return nil, err
+	}
+	vmRequests, err := h.getVMIRequests(vmi)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := h.podCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]MigratableNode, 0, len(nodes))
+	for _, node := range nodes {
+		if node.Name == vmi.Status.NodeName {
+			continue
+		}
+		reasons := append([]string{}, vmReasons...)
+		reasons = append(reasons, getNodeMigrationReasons(vmi, node)...)
+		reasons = append(reasons, getPodAffinityReasons(vmi, node, allNodes, pods)...)
+		reasons = append(reasons, getNodeResourceReasons(node, pods, vmRequests)...)
+		results = append(results, MigratableNode{
+			NodeName:   node.Name,
+			Migratable: len(reasons) == 0,
+			Reasons:    reasons,
+		})
+	}
+	return results, nil
+}
+
+// getVMMigrationReasons returns why the VM can't be migrated to any node
+func (h *vmActionHandler) getVMMigrationReasons(vmi *kv1.VirtualMachineInstance) ([]string, error) {
+	var reasons []string
+	if !vmi.IsRunning() {
+		return append(reasons, "The VM is not in running state"), nil
+	}
+	if !isReady(vmi) {
+		reasons = append(reasons, "The VM is not in ready status")
+	}
+	if !canMigrate(vmi) {
+		reasons = append(reasons, "The VM is already in migrating state")
+	}
+
+	devices := vmi.Spec.Domain.Devices
+	if len(devices.HostDevices) > 0 || len(devices.GPUs) > 0 {
+		reasons = append(reasons, "The VM has host devices")
+	}
+	for _, volume := range vmi.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := h.pvcCache.Get(vmi.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return nil, err
+		}
+		if !hasAccessMode(pvc, corev1.ReadWriteMany) {
+			reasons = append(reasons, fmt.Sprintf("Volume %s is not ReadWriteMany", pvc.Name))
+		}
+	}
+	for _, cond := range vmi.Status.Conditions {
+		// the disks are checked above
+		if cond.Type == kv1.VirtualMachineInstanceIsMigratable && cond.Status == corev1.ConditionFalse &&
+			cond.Reason != kv1.VirtualMachineInstanceReasonDisksNotMigratable {
+			reasons = append(reasons, cond.Message)
+		}
+	}
+	return reasons, nil
+}
+
+// getVMIRequests returns the resources requested by the virt-launcher pod of the VMI, which is what the scheduler
+// takes for the target pod. The requests are already lowered by the overcommit-config setting.
+func (h *vmActionHandler) getVMIRequests(vmi *kv1.VirtualMachineInstance) (corev1.ResourceList, error) {
+	pods, err := h.podCache.List(vmi.Namespace, labels.Set{
+		kv1.CreatedByLabel: string(vmi.UID),
+	}.AsSelector())
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName == vmi.Status.NodeName && !util.IsPodTerminated(pod) {
+			return util.GetPodRequests(pod), nil
+		}
+	}
+	return vmi.Spec.Domain.Resources.Requests, nil
+}
+
+// getNodeMigrationReasons returns why the VM can't be migrated to the node, regarding the node state,
+// the node selectors, the required node affinity and the taints. The pod affinity is checked by getPodAffinityReasons.
+func getNodeMigrationReasons(vmi *kv1.VirtualMachineInstance, node *corev1.Node) []string {
+	var reasons []string
+	if !isNodeReady(node) {
+		reasons = append(reasons, "Node is not ready")
+	}
+	if node.Annotations[ctlnode.MaintainStatusAnnotationKey] != "" {
+		reasons = append(reasons, "Node is in maintenance mode")
+	} else if node.Spec.Unschedulable {
+		reasons = append(reasons, "Node is cordoned")
+	}
+
+	for key, value := range vmi.Spec.NodeSelector {
+		// the hostname selector is replaced with the target node of the migration
+		if key == corev1.LabelHostname {
+			continue
+		}
+		if node.Labels[key] != value {
+			reasons = append(reasons, fmt.Sprintf("Node doesn't match the node selector %s=%s", key, value))
+		}
+	}
+	if affinity := vmi.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil &&
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil &&
+		!matchNodeSelectorTerms(node, affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms) {
+		reasons = append(reasons, "Node doesn't match the node affinity")
+	}
+
+	for i, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !toleratesTaint(vmi.Spec.Tolerations, &node.Spec.Taints[i]) {
+			reasons = append(reasons, fmt.Sprintf("Node has the taint %s which is not tolerated", taint.ToString()))
+		}
+	}
+	return reasons
+}
+
+// getPodAffinityReasons returns why the VM can't be migrated to the node, regarding the required pod affinity and
+// anti-affinity of the VM and the required anti-affinity of the pods running in the cluster.
+// The pods of the VM itself are skipped since they move along with the migration.
+// The alpha namespace selector of the terms isn't supported.
+func getPodAffinityReasons(vmi *kv1.VirtualMachineInstance, node *corev1.Node, nodes []*corev1.Node, pods []*corev1.Pod) []string {
+	nodeLabels := make(map[string]map[string]string, len(nodes))
+	for _, n := range nodes {
+		nodeLabels[n.Name] = n.Labels
+	}
+	var otherPods []*corev1.Pod
+	for _, pod := range pods {
+		if pod.Spec.NodeName == "" || util.IsPodTerminated(pod) || pod.Labels[kv1.CreatedByLabel] == string(vmi.UID) {
+			continue
+		}
+		otherPods = append(otherPods, pod)
+	}
+
+	// inTopology checks if the pod runs in the same topology domain as the node
+	inTopology := func(pod *corev1.Pod, topologyKey string) bool {
+		value, ok := node.Labels[topologyKey]
+		if !ok {
+			return false
+		}
+		podNodeValue, ok := nodeLabels[pod.Spec.NodeName][topologyKey]
+		return ok && podNodeValue == value
+	}
+
+	var reasons []string
+	if affinity := vmi.Spec.Affinity; affinity != nil && affinity.PodAffinity != nil {
+		for _, term := range affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			matched, matchedInTopology := false, false
+			for _, pod := range otherPods {
+				if matchPodAffinityTerm(vmi.Namespace, &term, pod.Namespace, pod.Labels) {
+					matched = true
+					if inTopology(pod, term.TopologyKey) {
+						matchedInTopology = true
+						break
+					}
+				}
+			}
+			// like the scheduler, the term is ignored if no pod matches but the VM matches itself
+			if !matched && matchPodAffinityTerm(vmi.Namespace, &term, vmi.Namespace, vmi.Labels) {
+				continue
+			}
+			if !matchedInTopology {
+				reasons = append(reasons, fmt.Sprintf("Node doesn't match the pod affinity with topology key %s", term.TopologyKey))
+			}
+		}
+	}
+	if affinity := vmi.Spec.Affinity; affinity != nil && affinity.PodAntiAffinity != nil {
+		for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			for _, pod := range otherPods {
+				if matchPodAffinityTerm(vmi.Namespace, &term, pod.Namespace, pod.Labels) && inTopology(pod, term.TopologyKey) {
+					reasons = append(reasons, fmt.Sprintf("Node doesn't match the pod anti-affinity with topology key %s, pod %s/%s is in the same topology",
+						term.TopologyKey, pod.Namespace, pod.Name))
+					break
+				}
+			}
+		}
+	}
+	for _, pod := range otherPods {
+		if pod.Spec.Affinity == nil || pod.Spec.Affinity.PodAntiAffinity == nil {
+			continue
+		}
+		for _, term := range pod.Spec.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if matchPodAffinityTerm(pod.Namespace, &term, vmi.Namespace, vmi.Labels) && inTopology(pod, term.TopologyKey) {
+				reasons = append(reasons, fmt.Sprintf("Pod %s/%s on the node has an anti-affinity with the VM", pod.Namespace, pod.Name))
+				break
+			}
+		}
+	}
+	return reasons
+}
+
+// matchPodAffinityTerm checks if the pod labels in the namespace match the term defined by a pod in the owner namespace
+func matchPodAffinityTerm(ownerNamespace string, term *corev1.PodAffinityTerm, namespace string, podLabels map[string]string) bool {
+	namespaces := term.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{ownerNamespace}
+	}
+	namespaceMatched := false
+	for _, ns := range namespaces {
+		if ns == namespace {
+			namespaceMatched = true
+			break
+		}
+	}
+	if !namespaceMatched {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(podLabels))
+}
+
+// getNodeResourceReasons checks if the node has enough CPU and memory for the requests
+func getNodeResourceReasons(node *corev1.Node, pods []*corev1.Pod, requests corev1.ResourceList) []string {
+	used := corev1.ResourceList{}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != node.Name || util.IsPodTerminated(pod) {
+			continue
+		}
+		util.AddResourceList(used, util.GetPodRequests(pod))
+	}
+
+	var reasons []string
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		request, ok := requests[name]
+		if !ok {
+			continue
+		}
+		free := node.Status.Allocatable[name].DeepCopy()
+		free.Sub(used[name])
+		if free.Cmp(request) < 0 {
+			reasons = append(reasons, fmt.Sprintf("Insufficient %s, requested %s but %s is available", name, request.String(), free.String()))
+		}
+	}
+	return reasons
+}
+
+func matchNodeSelectorTerms(node *corev1.Node, terms []corev1.NodeSelectorTerm) bool {
+	// the terms are ORed
+	for _, term := range terms {
+		if matchNodeSelectorTerm(node, term) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNodeSelectorTerm(node *corev1.Node, term corev1.NodeSelectorTerm) bool {
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false
+	}
+	if !matchNodeSelectorRequirements(labels.Set(node.Labels), term.MatchExpressions) {
+		return false
+	}
+	// metadata.name is the only supported field
+	return matchNodeSelectorRequirements(labels.Set{"metadata.name": node.Name}, term.MatchFields)
+}
+
+func matchNodeSelectorRequirements(set labels.Set, requirements []corev1.NodeSelectorRequirement) bool {
+	operators := map[corev1.NodeSelectorOperator]selection.Operator{
+		corev1.NodeSelectorOpIn:           selection.In,
+		corev1.NodeSelectorOpNotIn:        selection.NotIn,
+		corev1.NodeSelectorOpExists:       selection.Exists,
+		corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+		corev1.NodeSelectorOpGt:           selection.GreaterThan,
+		corev1.NodeSelectorOpLt:           selection.LessThan,
+	}
+	for _, requirement := range requirements {
+		operator, ok := operators[requirement.Operator]
+		if !ok {
+			return false
+		}
+		r, err := labels.NewRequirement(requirement.Key, operator, requirement.Values)
+		if err != nil || !r.Matches(set) {
+			return false
+		}
+	}
+	return true
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAccessMode(pvc *corev1.PersistentVolumeClaim, mode corev1.PersistentVolumeAccessMode) bool {
+	for _, accessMode := range pvc.Spec.AccessModes {
+		if accessMode == mode {
+			return true
+		}
+	}
+	return false
+}
+
+func isNodeReady(node *corev1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtapis "kubevirt.io/client-go/api/v1"
+
+	ctlnode "github.com/harvester/harvester/pkg/controller/master/node"
+)
+
+func newTestNode(name string, labels, annotations map[string]string, taints ...corev1.Taint) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.NodeSpec{
+			Taints: taints,
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("4"),
+				corev1.ResourceMemory: resource.MustParse("8Gi"),
+			},
+		},
+	}
+}
+
+func TestGetNodeMigrationReasons(t *testing.T) {
+	vmi := &kubevirtapis.VirtualMachineInstance{
+		Spec: kubevirtapis.VirtualMachineInstanceSpec{
+			NodeSelector: map[string]string{
+				corev1.LabelHostname: "node-1",
+				"zone":               "a",
+			},
+			Affinity: &corev1.Affinity{
+				NodeAffinity: &corev1.NodeAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+						NodeSelectorTerms: []corev1.NodeSelectorTerm{
+							{
+								MatchExpressions: []corev1.NodeSelectorRequirement{
+									{Key: "gpu", Operator: corev1.NodeSelectorOpDoesNotExist},
+								},
+							},
+						},
+					},
+				},
+			},
+			Tolerations: []corev1.Toleration{
+				{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "vm", Effect: corev1.TaintEffectNoSchedule},
+			},
+		},
+	}
+
+	var testCases = []struct {
+		name     string
+		node     *corev1.Node
+		expected []string
+	}{
+		{
+			name: "migratable",
+			node: newTestNode("node-2", map[string]string{"zone": "a"}, nil,
+				corev1.Taint{Key: "dedicated", Value: "vm", Effect: corev1.TaintEffectNoSchedule}),
+			expected: nil,
+		},
+		{
+			name:     "maintenance mode",
+			node:     newTestNode("node-2", map[string]string{"zone": "a"}, map[string]string{ctlnode.MaintainStatusAnnotationKey: ctlnode.MaintainStatusRunning}),
+			expected: []string{"Node is in maintenance mode"},
+		},
+		{
+			name:     "node selector and affinity",
+			node:     newTestNode("node-2", map[string]string{"zone": "b", "gpu": "true"}, nil),
+			expected: []string{"Node doesn't match the node selector zone=a", "Node doesn't match the node affinity"},
+		},
+		{
+			name: "untolerated taint",
+			node: newTestNode("node-2", map[string]string{"zone": "a"}, nil,
+				corev1.Taint{Key: "dedicated", Value: "db", Effect: corev1.TaintEffectNoSchedule}),
+			expected: []string{"Node has the taint dedicated=db:NoSchedule which is not tolerated"},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, getNodeMigrationReasons(vmi, tc.node), "case %q", tc.name)
+	}
+}
+
+func TestGetNodeResourceReasons(t *testing.T) {
+	node := newTestNode("node-2", nil, nil)
+	pods := []*corev1.Pod{
+		{
+			Spec: corev1.PodSpec{
+				NodeName: "node-2",
+				Containers: []corev1.Container{
+					{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("3"),
+								corev1.ResourceMemory: resource.MustParse("2Gi"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// terminated pods don't take resources
+			Spec: corev1.PodSpec{
+				NodeName: "node-2",
+				Containers: []corev1.Container{
+					{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{
+								corev1.ResourceMemory: resource.MustParse("6Gi"),
+							},
+						},
+					},
+				},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodSucceeded},
+		},
+	}
+
+	requests := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("2"),
+		corev1.ResourceMemory: resource.MustParse("4Gi"),
+	}
+	assert.Equal(t, []string{"Insufficient cpu, requested 2 but 1 is available"}, getNodeResourceReasons(node, pods, requests))
+
+	requests[corev1.ResourceCPU] = resource.MustParse("500m")
+	assert.Nil(t, getNodeResourceReasons(node, pods, requests))
+}
+
+func TestGetPodAffinityReasons(t *testing.T) {
+	nodes := []*corev1.Node{
+		newTestNode("node-1", map[string]string{corev1.LabelHostname: "node-1", "zone": "a"}, nil),
+		newTestNode("node-2", map[string]string{corev1.LabelHostname: "node-2", "zone": "a"}, nil),
+		newTestNode("node-3", map[string]string{corev1.LabelHostname: "node-3", "zone": "b"}, nil),
+	}
+	newPod := func(name, nodeName string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels},
+			Spec:       corev1.PodSpec{NodeName: nodeName},
+		}
+	}
+	vmi := &kubevirtapis.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vm", UID: "vm-uid", Labels: map[string]string{"app": "web"}},
+		Spec: kubevirtapis.VirtualMachineInstanceSpec{
+			Affinity: &corev1.Affinity{
+				PodAffinity: &corev1.PodAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+						{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+							TopologyKey:   "zone",
+						},
+					},
+				},
+				PodAntiAffinity: &corev1.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+						{
+							LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+							TopologyKey:   corev1.LabelHostname,
+						},
+					},
+				},
+			},
+		},
+	}
+	pods := []*corev1.Pod{
+		// the pod of the VM itself is skipped
+		newPod("virt-launcher-vm", "node-1", map[string]string{"app": "web", kubevirtapis.CreatedByLabel: "vm-uid"}),
+		newPod("db", "node-1", map[string]string{"app": "db"}),
+		newPod("web", "node-3", map[string]string{"app": "web"}),
+	}
+
+	assert.Nil(t, getPodAffinityReasons(vmi, nodes[1], nodes, pods))
+	assert.Equal(t, []string{
+		"Node doesn't match the pod affinity with topology key zone",
+		"Node doesn't match the pod anti-affinity with topology key kubernetes.io/hostname, pod default/web is in the same topology",
+	}, getPodAffinityReasons(vmi, nodes[2], nodes, pods))
+
+	// the anti-affinity of the other pods is checked against the VM
+	cache := newPod("cache", "node-2", map[string]string{"app": "cache"})
+	cache.Spec.Affinity = &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{
+				{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					TopologyKey:   corev1.LabelHostname,
+				},
+			},
+		},
+	}
+	assert.Equal(t, []string{"Pod default/cache on the node has an anti-affinity with the VM"},
+		getPodAffinityReasons(vmi, nodes[1], nodes, append(pods, cache)))
+}
@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	// defaultPowerOperationTimeout is how long the guest has to shut down if the caller doesn't specify it
+	defaultPowerOperationTimeout = 120
+	maxPowerOperationTimeout     = 3600
+
+	eventReasonShutdownRequested   = "ShutdownRequested"
+	eventReasonSoftRebootRequested = "SoftRebootRequested"
+)
+
+// gracefulPowerOperate asks the guest to shut down by ACPI, which QEMU forwards to the guest agent if it's connected,
+// with the timeout as the grace period of the stop or restart. The soft reboot starts the VM again by restarting
+// the VMI instead of stopping it. The VM power operation controller forces the VM off if the guest doesn't shut down
+// in the timeout, and records the outcome as events.
+func (h *vmActionHandler) gracefulPowerOperate(ctx context.Context, namespace, name, operation string, input PowerOperationInput) error {
+	timeout := input.TimeoutSeconds
+	if timeout < 0 || timeout > maxPowerOperationTimeout {
+		return apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf("timeoutSeconds must be between 0 and %d", maxPowerOperationTimeout))
+	}
+	if timeout == 0 {
+		timeout = defaultPowerOperationTimeout
+	}
+
+	vm, err := h.vmCache.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+	if op, err := util.GetPowerOperation(vm); err != nil {
+		return err
+	} else if op != nil {
+		return apierror.NewAPIError(validation.Conflict, fmt.Sprintf("The VM is already in progress of %s", op.Operation))
+	}
+	vmi, err := h.vmiCache.Get(namespace, name)
+	if err != nil || !vmi.IsRunning() {
+		return apierror.NewAPIError(validation.InvalidAction, "The VM is not running")
+	}
+
+	// saves the operation before stopping the VMI, so the outcome is recorded even if the guest shuts down at once
+	vmCopy := vm.DeepCopy()
+	if err := util.SetPowerOperation(vmCopy, &util.PowerOperation{
+		Operation:      operation,
+		TimeoutSeconds: timeout,
+		RequestedAt:    metav1.Now(),
+		VMIUID:         vmi.UID,
+	}); err != nil {
+		return err
+	}
+	if vm, err = h.vms.Update(vmCopy); err != nil {
+		return err
+	}
+
+	if err := h.requestPowerOperation(ctx, vmi, operation, timeout); err != nil {
+		vmCopy = vm.DeepCopy()
+		_ = util.SetPowerOperation(vmCopy, nil)
+		if _, updateErr := h.vms.Update(vmCopy); updateErr != nil {
+			return fmt.Errorf("%s virtual machine %s/%s failed, %v, and failed to clean up, %v", operation, namespace, name, err, updateErr)
+		}
+		return fmt.Errorf("%s virtual machine %s/%s failed, %v", operation, namespace, name, err)
+	}
+
+	if operation == util.PowerOperationSoftReboot {
+		h.recorder.Eventf(vm, corev1.EventTypeNormal, eventReasonSoftRebootRequested,
+			"Requested the guest to reboot, it will be forced off and started again if it doesn't shut down in %d seconds", timeout)
+	} else {
+		h.recorder.Eventf(vm, corev1.EventTypeNormal, eventReasonShutdownRequested,
+			"Requested the guest to shut down, it will be forced off if it doesn't shut down in %d seconds", timeout)
+	}
+	return nil
+}
+
+func (h *vmActionHandler) requestPowerOperation(ctx context.Context, vmi *kv1.VirtualMachineInstance, operation string, timeout int64) error {
+	subresource := stopVM
+	var options interface{} = kv1.StopOptions{GracePeriod: &timeout}
+	if operation == util.PowerOperationSoftReboot {
+		subresource = restartVM
+		options = kv1.RestartOptions{GracePeriodSeconds: &timeout}
+	}
+	body, err := json.Marshal(options)
+	if err != nil {
+		return err
+	}
+	return h.virtSubresourceRestClient.Put().Namespace(vmi.Namespace).Resource(vmResource).
+		SubResource(subresource).Name(vmi.Name).Body(body).Do(ctx).Error()
+}
@@ -0,0 +1,255 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/wrangler/pkg/schemas/validation"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	// reservedMemory is the memory reserved for QEMU by the VM mutator
+	reservedMemory = 100 * 1024 * 1024
+
+	resizeChangeCPU    = "cpu"
+	resizeChangeMemory = "memory"
+)
+
+// resize changes the CPU and memory of the VM. KubeVirt doesn't support hot plugging CPU or memory yet, so the changes
+// on a running VM take effect after it restarts, and the VM pending changes controller lists them in the
+// pendingChanges annotation of the VM.
+func (h *vmActionHandler) resize(ctx context.Context, rw http.ResponseWriter, namespace, name string, input ResizeInput) error {
+	vm, err := h.vmCache.Get(namespace, name)
+	if err != nil {
+		return err
+	}
+
+	vmCopy := vm.DeepCopy()
+	changes, err := applyResize(vmCopy, input)
+	if err != nil {
+		return apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
+	}
+
+	output := ResizeOutput{}
+	if len(changes) > 0 {
+		vmi, err := h.vmiCache.Get(namespace, name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		running := err == nil && !vmi.IsFinal()
+		if err := h.checkResizeRequests(ctx, vm, vmCopy, running); err != nil {
+			return err
+		}
+
+		if _, err := h.vms.Update(vmCopy); err != nil {
+			return err
+		}
+		if running {
+			output.RestartRequired = true
+			output.PendingChanges = changes
+		}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	return json.NewEncoder(rw).Encode(output)
+}
+
+// checkResizeRequests checks the requests of the resized VM, which the VM mutator lowers from the limits by
+// the overcommit-config setting, fit into a node and the resource quotas of the namespace.
+func (h *vmActionHandler) checkResizeRequests(ctx context.Context, oldVM, newVM *kv1.VirtualMachine, running bool) error {
+	overcommit, err := h.getOvercommit()
+	if err != nil {
+		return err
+	}
+	newLimits := newVM.Spec.Template.Spec.Domain.Resources.Limits
+	newRequests := corev1.ResourceList{}
+	for name, request := range newVM.Spec.Template.Spec.Domain.Resources.Requests {
+		newRequests[name] = request
+	}
+	if overcommit != nil {
+		if cpu, ok := newLimits[corev1.ResourceCPU]; ok {
+			newRequests[corev1.ResourceCPU] = util.GetOvercommitRequest(corev1.ResourceCPU, cpu, overcommit.Cpu)
+		}
+		if memory, ok := newLimits[corev1.ResourceMemory]; ok {
+			newRequests[corev1.ResourceMemory] = util.GetOvercommitRequest(corev1.ResourceMemory, memory, overcommit.Memory)
+		}
+	}
+
+	nodes, err := h.nodeCache.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	if err := checkNodesAllocatable(nodes, newRequests); err != nil {
+		return err
+	}
+
+	// a stopped VM doesn't take the quota yet
+	var oldLimits, oldRequests corev1.ResourceList
+	if running {
+		oldLimits = oldVM.Spec.Template.Spec.Domain.Resources.Limits
+		oldRequests = oldVM.Spec.Template.Spec.Domain.Resources.Requests
+	}
+	quotas, err := h.resourceQuotaClient.ResourceQuotas(newVM.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	for _, quota := range quotas.Items {
+		if err := checkResourceQuota(&quota, oldLimits, oldRequests, newLimits, newRequests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *vmActionHandler) getOvercommit() (*settings.Overcommit, error) {
+	s, err := h.settingCache.Get(settings.OvercommitConfigSettingName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	value := s.Value
+	if value == "" {
+		value = s.Default
+	}
+	if value == "" {
+		return nil, nil
+	}
+	overcommit := &settings.Overcommit{}
+	if err := json.Unmarshal([]byte(value), overcommit); err != nil {
+		return nil, err
+	}
+	return overcommit, nil
+}
+
+// applyResize updates the CPU topology, the CPU and memory limits of the VM, and returns the changed resources
+func applyResize(vm *kv1.VirtualMachine, input ResizeInput) ([]string, error) {
+	if input.Sockets == 0 && input.Cores == 0 && input.Memory == "" {
+		return nil, fmt.Errorf("at least one of sockets, cores and memory is required")
+	}
+
+	domain := &vm.Spec.Template.Spec.Domain
+	if domain.Resources.Limits == nil {
+		domain.Resources.Limits = corev1.ResourceList{}
+	}
+
+	var changes []string
+	if input.Sockets > 0 || input.Cores > 0 {
+		if domain.CPU == nil {
+			domain.CPU = &kv1.CPU{}
+		}
+		sockets, cores, threads := input.Sockets, input.Cores, domain.CPU.Threads
+		if sockets == 0 {
+			sockets = maxUint32(domain.CPU.Sockets, 1)
+		}
+		if cores == 0 {
+			cores = maxUint32(domain.CPU.Cores, 1)
+		}
+		threads = maxUint32(threads, 1)
+		if sockets != maxUint32(domain.CPU.Sockets, 1) || cores != maxUint32(domain.CPU.Cores, 1) {
+			domain.CPU.Sockets = sockets
+			domain.CPU.Cores = cores
+			domain.Resources.Limits[corev1.ResourceCPU] = *resource.NewQuantity(int64(sockets*cores*threads), resource.DecimalSI)
+			changes = append(changes, resizeChangeCPU)
+		}
+	}
+
+	if input.Memory != "" {
+		memory, err := resource.ParseQuantity(input.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory %s: %w", input.Memory, err)
+		}
+		if memory.Value() <= reservedMemory {
+			return nil, fmt.Errorf("memory must be more than the 100Mi reserved for QEMU")
+		}
+		if current, ok := domain.Resources.Limits[corev1.ResourceMemory]; !ok || !current.Equal(memory) {
+			domain.Resources.Limits[corev1.ResourceMemory] = memory
+			changes = append(changes, resizeChangeMemory)
+		}
+	}
+	return changes, nil
+}
+
+// checkNodesAllocatable checks there is at least one schedulable node with enough allocatable resources for the requests
+func checkNodesAllocatable(nodes []*corev1.Node, requests corev1.ResourceList) error {
+	var reasons []string
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !isNodeReady(node) {
+			continue
+		}
+		var insufficient []string
+		for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+			request, ok := requests[name]
+			if !ok {
+				continue
+			}
+			if allocatable, ok := node.Status.Allocatable[name]; ok && allocatable.Cmp(request) < 0 {
+				insufficient = append(insufficient, fmt.Sprintf("%s %s", name, allocatable.String()))
+			}
+		}
+		if len(insufficient) == 0 {
+			return nil
+		}
+		reasons = append(reasons, fmt.Sprintf("%s has %s", node.Name, strings.Join(insufficient, ", ")))
+	}
+	if len(reasons) == 0 {
+		return apierror.NewAPIError(validation.InvalidAction, "There is no schedulable node for the VM")
+	}
+	return apierror.NewAPIError(validation.InvalidBodyContent,
+		"None of the nodes has enough allocatable resources for the VM, "+strings.Join(reasons, "; "))
+}
+
+// checkResourceQuota checks replacing the old limits and requests of the VM with the new ones doesn't exceed the quota
+func checkResourceQuota(quota *corev1.ResourceQuota, oldLimits, oldRequests, newLimits, newRequests corev1.ResourceList) error {
+	sources := map[corev1.ResourceName]struct {
+		name     corev1.ResourceName
+		old, new corev1.ResourceList
+	}{
+		corev1.ResourceCPU:            {corev1.ResourceCPU, oldRequests, newRequests},
+		corev1.ResourceMemory:         {corev1.ResourceMemory, oldRequests, newRequests},
+		corev1.ResourceRequestsCPU:    {corev1.ResourceCPU, oldRequests, newRequests},
+		corev1.ResourceRequestsMemory: {corev1.ResourceMemory, oldRequests, newRequests},
+		corev1.ResourceLimitsCPU:      {corev1.ResourceCPU, oldLimits, newLimits},
+		corev1.ResourceLimitsMemory:   {corev1.ResourceMemory, oldLimits, newLimits},
+	}
+	for quotaName, hard := range quota.Status.Hard {
+		source, ok := sources[quotaName]
+		if !ok {
+			continue
+		}
+		newValue, ok := source.new[source.name]
+		if !ok {
+			continue
+		}
+		total := quota.Status.Used[quotaName].DeepCopy()
+		total.Add(newValue)
+		total.Sub(source.old[source.name])
+		if total.Cmp(hard) > 0 {
+			return apierror.NewAPIError(validation.InvalidBodyContent, fmt.Sprintf(
+				"The VM exceeds the resource quota %s, %s would be %s but the limit is %s", quota.Name, quotaName, total.String(), hard.String()))
+		}
+	}
+	return nil
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,108 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kv1 "kubevirt.io/client-go/api/v1"
+)
+
+func newResizeTestVM() *kv1.VirtualMachine {
+	return &kv1.VirtualMachine{
+		Spec: kv1.VirtualMachineSpec{
+			Template: &kv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kv1.VirtualMachineInstanceSpec{
+					Domain: kv1.DomainSpec{
+						CPU: &kv1.CPU{Cores: 2},
+						Resources: kv1.ResourceRequirements{
+							Limits: corev1.ResourceList{
+								corev1.ResourceCPU:    resource.MustParse("2"),
+								corev1.ResourceMemory: resource.MustParse("4Gi"),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyResize(t *testing.T) {
+	var testCases = []struct {
+		name            string
+		input           ResizeInput
+		expectedChanges []string
+		expectedErr     bool
+		expectedCPU     string
+		expectedMemory  string
+	}{
+		{
+			name:        "empty input",
+			input:       ResizeInput{},
+			expectedErr: true,
+		},
+		{
+			name:        "memory too small",
+			input:       ResizeInput{Memory: "100Mi"},
+			expectedErr: true,
+		},
+		{
+			name:           "unchanged",
+			input:          ResizeInput{Cores: 2, Memory: "4Gi"},
+			expectedCPU:    "2",
+			expectedMemory: "4Gi",
+		},
+		{
+			name:            "sockets",
+			input:           ResizeInput{Sockets: 2},
+			expectedChanges: []string{resizeChangeCPU},
+			expectedCPU:     "4",
+			expectedMemory:  "4Gi",
+		},
+		{
+			name:            "cores and memory",
+			input:           ResizeInput{Cores: 4, Memory: "8Gi"},
+			expectedChanges: []string{resizeChangeCPU, resizeChangeMemory},
+			expectedCPU:     "4",
+			expectedMemory:  "8Gi",
+		},
+	}
+
+	for _, tc := range testCases {
+		vm := newResizeTestVM()
+		changes, err := applyResize(vm, tc.input)
+		if tc.expectedErr {
+			assert.Error(t, err, "case %q", tc.name)
+			continue
+		}
+		assert.Nil(t, err, "case %q", tc.name)
+		assert.Equal(t, tc.expectedChanges, changes, "case %q", tc.name)
+		limits := vm.Spec.Template.Spec.Domain.Resources.Limits
+		assert.Equal(t, tc.expectedCPU, limits.Cpu().String(), "case %q", tc.name)
+		assert.Equal(t, tc.expectedMemory, limits.Memory().String(), "case %q", tc.name)
+	}
+}
+
+func TestCheckResourceQuota(t *testing.T) {
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota"},
+		Status: corev1.ResourceQuotaStatus{
+			Hard: corev1.ResourceList{
+				corev1.ResourceLimitsMemory: resource.MustParse("10Gi"),
+			},
+			Used: corev1.ResourceList{
+				corev1.ResourceLimitsMemory: resource.MustParse("8Gi"),
+			},
+		},
+	}
+	oldLimits := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")}
+	newLimits := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("6Gi")}
+
+	// the running VM takes 4Gi of the used quota
+	assert.Nil(t, checkResourceQuota(quota, oldLimits, nil, newLimits, nil))
+	// the stopped VM doesn't take the quota
+	assert.Error(t, checkResourceQuota(quota, nil, nil, newLimits, nil))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/rancher/steve/pkg/stores/proxy"
 	"github.com/rancher/wrangler/pkg/schemas"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 
 	"github.com/harvester/harvester/pkg/config"
@@ -18,6 +19,8 @@ import (
 
 const (
 	vmSchemaID = "kubevirt.io.virtualmachine"
+
+	vmControllerName = "harvester-vm-api"
 )
 
 var (
@@ -31,7 +34,16 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 	server.BaseSchemas.MustImportAndCustomize(EjectCdRomActionInput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(BackupInput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(RestoreInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(SnapshotInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(RevertToSnapshotInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(CloneInput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(MigrateInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(FindMigratableNodesOutput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(ResizeInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(ResizeOutput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(PowerOperationInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(BatchInput{}, nil)
+	server.BaseSchemas.MustImportAndCustomize(BatchOutput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(CreateTemplateInput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(AddVolumeInput{}, nil)
 	server.BaseSchemas.MustImportAndCustomize(RemoveVolumeInput{}, nil)
@@ -41,8 +53,10 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 	vmims := scaled.VirtFactory.Kubevirt().V1().VirtualMachineInstanceMigration()
 	backups := scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
 	restores := scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineRestore()
+	vmSnapshots := scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineSnapshot()
 	settings := scaled.HarvesterFactory.Harvesterhci().V1beta1().Setting()
 	nodes := scaled.CoreFactory.Core().V1().Node()
+	pods := scaled.CoreFactory.Core().V1().Pod()
 	pvcs := scaled.CoreFactory.Core().V1().PersistentVolumeClaim()
 	secrets := scaled.CoreFactory.Core().V1().Secret()
 	vmt := scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineTemplate()
@@ -60,6 +74,10 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 	if err != nil {
 		return err
 	}
+	coreClient, err := corev1client.NewForConfig(rest.CopyConfig(server.RESTConfig))
+	if err != nil {
+		return err
+	}
 	actionHandler := vmActionHandler{
 		namespace:                 options.Namespace,
 		vms:                       vms,
@@ -73,15 +91,23 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 		backups:                   backups,
 		backupCache:               backups.Cache(),
 		restores:                  restores,
+		vmSnapshots:               vmSnapshots,
+		vmSnapshotCache:           vmSnapshots.Cache(),
 		settingCache:              settings.Cache(),
 		nodeCache:                 nodes.Cache(),
+		podCache:                  pods.Cache(),
+		pvcClient:                 pvcs,
 		pvcCache:                  pvcs.Cache(),
 		secretClient:              secrets,
 		secretCache:               secrets.Cache(),
+		resourceQuotaClient:       coreClient,
 		virtSubresourceRestClient: virtSubresourceClient,
 		virtRestClient:            virtv1Client.RESTClient(),
+		recorder:                  scaled.Management.NewRecorder(vmControllerName, "", ""),
 	}
 
+	guestInfoHandler := newGuestInfoHandler(vmis.Cache(), virtSubresourceClient)
+
 	vmformatter := vmformatter{
 		vmiCache: vmis.Cache(),
 	}
@@ -98,30 +124,58 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 		ID: vmSchemaID,
 		Customize: func(apiSchema *types.APISchema) {
 			apiSchema.ActionHandlers = map[string]http.Handler{
-				startVM:        &actionHandler,
-				stopVM:         &actionHandler,
-				restartVM:      &actionHandler,
-				ejectCdRom:     &actionHandler,
-				pauseVM:        &actionHandler,
-				unpauseVM:      &actionHandler,
-				migrate:        &actionHandler,
-				abortMigration: &actionHandler,
-				backupVM:       &actionHandler,
-				restoreVM:      &actionHandler,
-				createTemplate: &actionHandler,
-				addVolume:      &actionHandler,
-				removeVolume:   &actionHandler,
+				startVM:             &actionHandler,
+				stopVM:              &actionHandler,
+				restartVM:           &actionHandler,
+				softReboot:          &actionHandler,
+				shutdown:            &actionHandler,
+				ejectCdRom:          &actionHandler,
+				pauseVM:             &actionHandler,
+				unpauseVM:           &actionHandler,
+				migrate:             &actionHandler,
+				abortMigration:      &actionHandler,
+				findMigratableNodes: &actionHandler,
+				resizeVM:            &actionHandler,
+				backupVM:            &actionHandler,
+				restoreVM:           &actionHandler,
+				snapshotVM:          &actionHandler,
+				revertSnapshot:      &actionHandler,
+				cloneVM:             &actionHandler,
+				createTemplate:      &actionHandler,
+				addVolume:           &actionHandler,
+				removeVolume:        &actionHandler,
+				batchVM:             &actionHandler,
+			}
+			apiSchema.CollectionFormatter = vmformatter.collectionFormatter
+			apiSchema.CollectionActions = map[string]schemas.Action{
+				batchVM: {
+					Input:  "batchInput",
+					Output: "batchOutput",
+				},
 			}
 			apiSchema.ResourceActions = map[string]schemas.Action{
 				startVM:   {},
 				stopVM:    {},
 				restartVM: {},
+				softReboot: {
+					Input: "powerOperationInput",
+				},
+				shutdown: {
+					Input: "powerOperationInput",
+				},
 				pauseVM:   {},
 				unpauseVM: {},
 				migrate: {
 					Input: "migrateInput",
 				},
 				abortMigration: {},
+				findMigratableNodes: {
+					Output: "findMigratableNodesOutput",
+				},
+				resizeVM: {
+					Input:  "resizeInput",
+					Output: "resizeOutput",
+				},
 				ejectCdRom: {
 					Input: "ejectCdRomActionInput",
 				},
@@ -131,6 +185,15 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 				restoreVM: {
 					Input: "restoreInput",
 				},
+				snapshotVM: {
+					Input: "snapshotInput",
+				},
+				revertSnapshot: {
+					Input: "revertToSnapshotInput",
+				},
+				cloneVM: {
+					Input: "cloneInput",
+				},
 				createTemplate: {
 					Input: "createTemplateInput",
 				},
@@ -141,6 +204,9 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 					Input: "removeVolumeInput",
 				},
 			}
+			apiSchema.LinkHandlers = map[string]http.Handler{
+				guestInfoLink: guestInfoHandler,
+			}
 		},
 		Formatter: vmformatter.formatter,
 		Store:     vmStore,
@@ -5,6 +5,8 @@ import "github.com/rancher/wrangler/pkg/condition"
 var (
 	vmReady   condition.Cond = "Ready"
 	vmiPaused condition.Cond = "Paused"
+
+	vmiAgentConnected condition.Cond = "AgentConnected"
 )
 
 type EjectCdRomActionInput struct {
@@ -20,8 +22,126 @@ type RestoreInput struct {
 	BackupName string `json:"backupName"`
 }
 
+type SnapshotInput struct {
+	Name string `json:"name"`
+}
+
+type RevertToSnapshotInput struct {
+	SnapshotName string `json:"snapshotName"`
+}
+
+type CloneInput struct {
+	TargetName      string `json:"targetName"`
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	Start           bool   `json:"start,omitempty"`
+}
+
 type MigrateInput struct {
 	NodeName string `json:"nodeName"`
+	// DryRun only checks the VM can be migrated to the node, or to any node if the node name is empty
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type FindMigratableNodesOutput struct {
+	Nodes []MigratableNode `json:"nodes"`
+}
+
+type MigratableNode struct {
+	NodeName   string   `json:"nodeName"`
+	Migratable bool     `json:"migratable"`
+	Reasons    []string `json:"reasons,omitempty"`
+}
+
+type ResizeInput struct {
+	Sockets uint32 `json:"sockets,omitempty"`
+	Cores   uint32 `json:"cores,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+}
+
+type ResizeOutput struct {
+	// RestartRequired means the changes take effect after the VM restarts
+	RestartRequired bool     `json:"restartRequired"`
+	PendingChanges  []string `json:"pendingChanges,omitempty"`
+}
+
+// GuestInfo is the data reported by the QEMU guest agent of a running VM
+type GuestInfo struct {
+	Hostname     string            `json:"hostname,omitempty"`
+	AgentVersion string            `json:"agentVersion,omitempty"`
+	OS           GuestOS           `json:"os"`
+	Interfaces   []GuestInterface  `json:"interfaces"`
+	FileSystems  []GuestFileSystem `json:"fileSystems"`
+	Users        []GuestUser       `json:"users"`
+	UpdatedAt    string            `json:"updatedAt"`
+}
+
+type GuestOS struct {
+	Name          string `json:"name,omitempty"`
+	PrettyName    string `json:"prettyName,omitempty"`
+	Version       string `json:"version,omitempty"`
+	KernelRelease string `json:"kernelRelease,omitempty"`
+}
+
+type GuestInterface struct {
+	// Name is the name of the VM network, InterfaceName is the name of the interface inside the guest
+	Name          string   `json:"name,omitempty"`
+	InterfaceName string   `json:"interfaceName,omitempty"`
+	MAC           string   `json:"mac,omitempty"`
+	IPs           []string `json:"ips,omitempty"`
+}
+
+type GuestFileSystem struct {
+	DiskName       string  `json:"diskName"`
+	MountPoint     string  `json:"mountPoint"`
+	FileSystemType string  `json:"fileSystemType"`
+	UsedBytes      int64   `json:"usedBytes"`
+	TotalBytes     int64   `json:"totalBytes"`
+	UsagePercent   float64 `json:"usagePercent"`
+}
+
+type GuestUser struct {
+	UserName  string `json:"userName"`
+	Domain    string `json:"domain,omitempty"`
+	LoginTime string `json:"loginTime,omitempty"`
+}
+
+type BatchInput struct {
+	// Operation is one of start, stop, restart, migrate, backup and delete
+	Operation string `json:"operation"`
+	// VMs are the VMs to operate, the namespace of the request is used if it's empty
+	VMs []BatchTarget `json:"vms,omitempty"`
+	// Selector is the label selector of the VMs to operate in the namespace, or all namespaces if it's empty
+	Selector  string `json:"selector,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	// NodeName is the target node of the migrate operation, it's chosen by the scheduler if empty
+	NodeName string `json:"nodeName,omitempty"`
+	// DryRun only checks the operation could be run on the VMs
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+type BatchTarget struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+type BatchOutput struct {
+	Operation string        `json:"operation"`
+	DryRun    bool          `json:"dryRun"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+type BatchResult struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+type PowerOperationInput struct {
+	// TimeoutSeconds is how long the guest has to shut down before it's forced off
+	TimeoutSeconds int64 `json:"timeoutSeconds,omitempty"`
 }
 
 type CreateTemplateInput struct {
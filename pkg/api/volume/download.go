@@ -0,0 +1,281 @@
+package volume
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	ctlbatchv1 "github.com/rancher/wrangler/pkg/generated/controllers/batch/v1"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/harvester/harvester/pkg/exporter"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	downloadLink = "download"
+
+	exporterReadyInterval = 2 * time.Second
+	exporterReadyRetry    = 60
+)
+
+// Downloader serves the downloads of the disks of volumes. The disks are served by the exporter jobs mounting
+// the volumes, and the downloads are proxied to them, so the users don't need access to the pod network.
+type Downloader struct {
+	ExporterImage string
+	Jobs          ctlbatchv1.JobClient
+	JobCache      ctlbatchv1.JobCache
+	PodCache      ctlcorev1.PodCache
+	Secrets       ctlcorev1.SecretClient
+	SecretCache   ctlcorev1.SecretCache
+
+	// exporterURL returns the URL of the disk served by the exporter pod, it's replaced in tests
+	exporterURL func(pod *corev1.Pod) *url.URL
+}
+
+func NewDownloader(exporterImage string, jobs ctlbatchv1.JobController, podCache ctlcorev1.PodCache, secrets ctlcorev1.SecretController) *Downloader {
+	return &Downloader{
+		ExporterImage: exporterImage,
+		Jobs:          jobs,
+		JobCache:      jobs.Cache(),
+		PodCache:      podCache,
+		Secrets:       secrets,
+		SecretCache:   secrets.Cache(),
+		exporterURL: func(pod *corev1.Pod) *url.URL {
+			return &url.URL{
+				Scheme: "http",
+				Host:   fmt.Sprintf("%s:%d", pod.Status.PodIP, exporter.Port),
+				Path:   exporter.DiskPath,
+			}
+		},
+	}
+}
+
+type downloadError struct {
+	status int
+	error
+}
+
+// ResponseError responds the error of the download with its status
+func ResponseError(rw http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if e, ok := err.(downloadError); ok {
+		status = e.status
+	}
+	util.ResponseError(rw, status, err)
+}
+
+// ParseRequest parses the download options. Reading the data of a volume takes creating a pod mounting it,
+// so besides getting the resource the user must be allowed to create pods in the namespace.
+func (d *Downloader) ParseRequest(r *http.Request) (exporter.Options, error) {
+	apiOp := types.GetAPIContext(r.Context())
+	if err := apiOp.AccessControl.CanDo(apiOp, "pod", "create", apiOp.Namespace, ""); err != nil {
+		return exporter.Options{}, downloadError{http.StatusForbidden, fmt.Errorf("user is not allowed to create pods in namespace %s to download the disk", apiOp.Namespace)}
+	}
+	options, err := exporter.ParseOptions(r.URL.Query())
+	if err != nil {
+		return options, downloadError{http.StatusBadRequest, err}
+	}
+	return options, nil
+}
+
+// EnsureExporter starts the exporter job of the volume if it's not running. The job is owned by the owner,
+// and is removed once the exporter exits after the downloads. The disk size limits the work directory of the exporter.
+func (d *Downloader) EnsureExporter(namespace, pvcName string, volumeMode corev1.PersistentVolumeMode, diskSize int64, owner metav1.OwnerReference) (*batchv1.Job, error) {
+	job, err := d.JobCache.Get(namespace, exporter.JobName(pvcName))
+	if apierrors.IsNotFound(err) {
+		job, err = d.Jobs.Create(exporter.NewJob(namespace, pvcName, volumeMode, diskSize, d.ExporterImage, owner))
+		if apierrors.IsAlreadyExists(err) {
+			job, err = d.Jobs.Get(namespace, exporter.JobName(pvcName), metav1.GetOptions{})
+		}
+		if err != nil {
+			return nil, err
+		}
+		return job, d.ensureToken(job)
+	} else if err != nil {
+		return nil, err
+	}
+
+	if job.Status.Succeeded > 0 || job.Status.Failed > 0 {
+		// the exporter exited, the job is going to be removed
+		propagation := metav1.DeletePropagationBackground
+		if err := d.Jobs.Delete(job.Namespace, job.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		return nil, downloadError{http.StatusServiceUnavailable, fmt.Errorf("the exporter of volume %s/%s is restarting, please retry later", namespace, pvcName)}
+	}
+	return job, d.ensureToken(job)
+}
+
+// ensureToken creates the secret of the token of the exporter job if it doesn't exist
+func (d *Downloader) ensureToken(job *batchv1.Job) error {
+	if _, err := d.SecretCache.Get(job.Namespace, job.Name); err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+	token, err := exporter.NewToken()
+	if err != nil {
+		return err
+	}
+	if _, err := d.Secrets.Create(exporter.NewTokenSecret(job, token)); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create the token of the exporter %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	return nil
+}
+
+// getToken returns the token of the exporter job, the secret may not be in the cache right after it's created
+func (d *Downloader) getToken(job *batchv1.Job) (string, error) {
+	secret, err := d.SecretCache.Get(job.Namespace, job.Name)
+	if apierrors.IsNotFound(err) {
+		secret, err = d.Secrets.Get(job.Namespace, job.Name, metav1.GetOptions{})
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the token of the exporter %s/%s: %w", job.Namespace, job.Name, err)
+	}
+	return string(secret.Data[exporter.TokenKey]), nil
+}
+
+// Serve proxies the download to the exporter of the job once it's ready
+func (d *Downloader) Serve(rw http.ResponseWriter, r *http.Request, job *batchv1.Job, options exporter.Options, diskName string) {
+	pod, err := d.waitForExporter(r.Context(), job)
+	if err != nil {
+		ResponseError(rw, err)
+		return
+	}
+	token, err := d.getToken(job)
+	if err != nil {
+		ResponseError(rw, err)
+		return
+	}
+
+	target := d.exporterURL(pod)
+	target.RawQuery = options.Query().Encode()
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": options.FileName(diskName)})
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL = target
+			req.Host = target.Host
+			// only the range of the download is passed to the exporter, not the credentials of the user
+			header := http.Header{}
+			for _, key := range []string{"Range", "If-Range"} {
+				if value := req.Header.Get(key); value != "" {
+					header.Set(key, value)
+				}
+			}
+			header.Set(exporter.TokenHeader, token)
+			req.Header = header
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+				resp.Header.Set("Content-Disposition", disposition)
+			}
+			return nil
+		},
+	}
+	proxy.ServeHTTP(rw, r)
+}
+
+func (d *Downloader) waitForExporter(ctx context.Context, job *batchv1.Job) (*corev1.Pod, error) {
+	selector := labels.Set{"job-name": job.Name}.AsSelector()
+	for i := 0; i < exporterReadyRetry; i++ {
+		pods, err := d.PodCache.List(job.Namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && isPodReady(pod) {
+				return pod, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(exporterReadyInterval):
+		}
+	}
+	return nil, downloadError{http.StatusServiceUnavailable, fmt.Errorf("timeout waiting for the exporter %s/%s to be ready, please retry later", job.Namespace, job.Name)}
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// DownloadHandler serves the download link of volumes
+type DownloadHandler struct {
+	downloader *Downloader
+	pvcCache   ctlcorev1.PersistentVolumeClaimCache
+	podCache   ctlcorev1.PodCache
+}
+
+func (h DownloadHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	options, err := h.downloader.ParseRequest(r)
+	if err != nil {
+		ResponseError(rw, err)
+		return
+	}
+
+	apiOp := types.GetAPIContext(r.Context())
+	pvc, err := h.pvcCache.Get(apiOp.Namespace, apiOp.Name)
+	if err != nil {
+		ResponseError(rw, err)
+		return
+	}
+	job, err := h.ensureExporter(pvc)
+	if err != nil {
+		ResponseError(rw, err)
+		return
+	}
+	h.downloader.Serve(rw, r, job, options, pvc.Name)
+}
+
+func (h DownloadHandler) ensureExporter(pvc *corev1.PersistentVolumeClaim) (*batchv1.Job, error) {
+	if pvc.Status.Phase != corev1.ClaimBound {
+		return nil, downloadError{http.StatusConflict, fmt.Errorf("volume %s/%s is not bound", pvc.Namespace, pvc.Name)}
+	}
+
+	// the disk is consistent only when nothing else writes to it
+	pods, err := h.podCache.List(pvc.Namespace, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	exporterJobName := exporter.JobName(pvc.Name)
+	for _, pod := range pods {
+		if pod.Labels["job-name"] == exporterJobName || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volume.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return nil, downloadError{http.StatusConflict, fmt.Errorf("volume %s/%s is in use by pod %s, stop the VM using it to download the volume", pvc.Namespace, pvc.Name, pod.Name)}
+			}
+		}
+	}
+
+	volumeMode := corev1.PersistentVolumeFilesystem
+	if pvc.Spec.VolumeMode != nil {
+		volumeMode = *pvc.Spec.VolumeMode
+	}
+	diskSize := pvc.Spec.Resources.Requests.Storage()
+	if capacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]; ok {
+		diskSize = &capacity
+	}
+	return h.downloader.EnsureExporter(pvc.Namespace, pvc.Name, volumeMode, diskSize.Value(), metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "PersistentVolumeClaim",
+		Name:       pvc.Name,
+		UID:        pvc.UID,
+	})
+}
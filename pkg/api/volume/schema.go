@@ -14,6 +14,13 @@ import (
 
 func RegisterSchema(scaled *config.Scaled, server *server.Server, options config.Options) error {
 	server.BaseSchemas.MustImportAndCustomize(vm.ExportVolumeInput{}, nil)
+	podCache := scaled.CoreFactory.Core().V1().Pod().Cache()
+	secrets := scaled.CoreFactory.Core().V1().Secret()
+	downloadHandler := DownloadHandler{
+		downloader: NewDownloader(options.ExporterImage, scaled.BatchFactory.Batch().V1().Job(), podCache, secrets),
+		pvcCache:   scaled.CoreFactory.Core().V1().PersistentVolumeClaim().Cache(),
+		podCache:   podCache,
+	}
 	t := schema.Template{
 		ID: "persistentvolumeclaim",
 		Customize: func(s *types.APISchema) {
@@ -28,6 +35,9 @@ func RegisterSchema(scaled *config.Scaled, server *server.Server, options config
 					images: scaled.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage(),
 				},
 			}
+			s.LinkHandlers = map[string]http.Handler{
+				downloadLink: downloadHandler,
+			}
 		},
 	}
 	server.SchemaFactory.AddTemplate(t)
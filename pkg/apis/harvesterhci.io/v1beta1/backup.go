@@ -17,6 +17,9 @@ const (
 
 	// ConditionProgressing is the "progressing" condition type
 	BackupConditionProgressing condition.Cond = "InProgress"
+
+	// BackupConditionVerified tells if the backup is verified to be restorable from the backup target
+	BackupConditionVerified condition.Cond = "Verified"
 )
 
 // DeletionPolicy defines that to do with resources when VirtualMachineRestore is deleted
@@ -31,6 +34,17 @@ const (
 	VirtualMachineRestoreRetain DeletionPolicy = "retain"
 )
 
+// VolumeRestoreMode defines how the selected volumes of a partial VirtualMachineRestore are restored
+type VolumeRestoreMode string
+
+const (
+	// VolumeRestoreStandalone is the default and restores the volumes as new PVCs which aren't attached to any VM
+	VolumeRestoreStandalone VolumeRestoreMode = "standalone"
+
+	// VolumeRestoreReplace replaces the disks of the target VM with the restored volumes
+	VolumeRestoreReplace VolumeRestoreMode = "replace"
+)
+
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 // +kubebuilder:resource:shortName=vmbackup;vmbackups,scope=Namespaced
@@ -63,7 +77,16 @@ type VirtualMachineBackupStatus struct {
 	CreationTime *metav1.Time `json:"creationTime,omitempty"`
 
 	// +optional
-	BackupTarget *BackupTarget `json:"backupTarget,omitempty"`
+	BackupTarget *BackupTargetLocation `json:"backupTarget,omitempty"`
+
+	// Quiesced tells if the guest filesystems were frozen by the guest agent while the volume snapshots were taken,
+	// a backup which isn't quiesced is crash-consistent
+	// +optional
+	Quiesced *bool `json:"quiesced,omitempty"`
+
+	// FreezeTime is when the guest filesystems were frozen, it's reset once they are thawed
+	// +optional
+	FreezeTime *metav1.Time `json:"freezeTime,omitempty"`
 
 	// +kubebuilder:validation:Required
 	// SourceSpec contains the vm spec source of the backup target
@@ -75,6 +98,10 @@ type VirtualMachineBackupStatus struct {
 	// +optional
 	SecretBackups []SecretBackup `json:"secretBackups,omitempty"`
 
+	// Progress is the aggregated progress of uploading all the volume backups
+	// +optional
+	Progress *TransferProgress `json:"progress,omitempty"`
+
 	// +optional
 	ReadyToUse *bool `json:"readyToUse,omitempty"`
 
@@ -83,10 +110,18 @@ type VirtualMachineBackupStatus struct {
 
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
+
+	// VerifyTime is when the backup was last verified, the result is in the Verified condition
+	// +optional
+	VerifyTime *metav1.Time `json:"verifyTime,omitempty"`
 }
 
-// BackupTarget is where VM Backup stores
-type BackupTarget struct {
+// BackupTargetLocation is where VM Backup stores
+type BackupTargetLocation struct {
+	// Name is the name of the BackupTarget of the backup store, it's empty if no BackupTarget describes the store
+	// +optional
+	Name string `json:"name,omitempty"`
+
 	Endpoint     string `json:"endpoint,omitempty"`
 	BucketName   string `json:"bucketName,omitempty"`
 	BucketRegion string `json:"bucketRegion,omitempty"`
@@ -118,6 +153,9 @@ type VolumeBackup struct {
 	// +optional
 	LonghornBackupName *string `json:"longhornBackupName,omitempty"`
 
+	// +optional
+	Progress *TransferProgress `json:"progress,omitempty"`
+
 	// +optional
 	ReadyToUse *bool `json:"readyToUse,omitempty"`
 
@@ -125,6 +163,31 @@ type VolumeBackup struct {
 	Error *Error `json:"error,omitempty"`
 }
 
+// TransferProgress is the progress of transferring the volume data between the cluster and the backup target
+type TransferProgress struct {
+	// Percentage is the percentage of the transferred data, from 0 to 100
+	Percentage int `json:"percentage"`
+
+	// BytesTransferred is the size of the transferred data,
+	// it's estimated from the percentage until the transfer is finished
+	// +optional
+	BytesTransferred int64 `json:"bytesTransferred,omitempty"`
+
+	// TotalBytes is the size of the data to transfer
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +optional
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+
+	// EstimatedFinishTime is estimated from the transfer rate so far, it's empty until some data is transferred
+	// +optional
+	EstimatedFinishTime *metav1.Time `json:"estimatedFinishTime,omitempty"`
+}
+
 // SecretBackup contains the secret data need to restore a secret referenced by the VM
 type SecretBackup struct {
 	// +kubebuilder:validation:Required
@@ -174,11 +237,53 @@ type VirtualMachineRestoreSpec struct {
 	// +kubebuilder:validation:Required
 	VirtualMachineBackupNamespace string `json:"virtualMachineBackupNamespace"`
 
+	// VirtualMachineSnapshotName reverts the target VM to a VirtualMachineSnapshot in the same namespace
+	// instead of restoring a VirtualMachineBackup
+	// +optional
+	VirtualMachineSnapshotName string `json:"virtualMachineSnapshotName,omitempty"`
+
 	// +optional
 	NewVM bool `json:"newVM,omitempty"`
 
 	// +optional
 	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Volumes restores only the named volumes of the backup instead of the whole VM,
+	// the rest of the target VM spec is left alone
+	// +optional
+	Volumes []string `json:"volumes,omitempty"`
+
+	// VolumeRestoreMode tells how the volumes selected by Volumes are restored
+	// +optional
+	// +kubebuilder:validation:Enum=standalone;replace
+	VolumeRestoreMode VolumeRestoreMode `json:"volumeRestoreMode,omitempty"`
+
+	// Mapping remaps the storage classes, networks and images referenced by the backup, e.g. when
+	// restoring a backup taken in another cluster. The VM is restored into the namespace of the
+	// VirtualMachineRestore.
+	// +optional
+	Mapping *RestoreMapping `json:"mapping,omitempty"`
+}
+
+// RestoreMapping maps resources of the backup source to resources in the restoring cluster.
+// Resources that are not mapped are expected to exist with the same name.
+type RestoreMapping struct {
+	// StorageClasses maps the source storage class names to the target storage class names
+	// +optional
+	StorageClasses map[string]string `json:"storageClasses,omitempty"`
+
+	// Networks maps the source multus network names to the target network names, in <namespace>/<name> format
+	// +optional
+	Networks map[string]string `json:"networks,omitempty"`
+
+	// Images maps the source image IDs to the target image IDs, in <namespace>/<name> format
+	// +optional
+	Images map[string]string `json:"images,omitempty"`
+
+	// Namespaces maps the source namespaces of the networks and images to the target namespaces,
+	// the networks and images mapped by name take precedence
+	// +optional
+	Namespaces map[string]string `json:"namespaces,omitempty"`
 }
 
 // VirtualMachineRestoreStatus is the spec for a VirtualMachineRestore resource
@@ -195,6 +300,10 @@ type VirtualMachineRestoreStatus struct {
 	// +optional
 	Complete *bool `json:"complete,omitempty"`
 
+	// Progress is the aggregated progress of restoring all the volumes
+	// +optional
+	Progress *TransferProgress `json:"progress,omitempty"`
+
 	// +optional
 	Conditions []Condition `json:"conditions,omitempty"`
 
@@ -208,4 +317,7 @@ type VolumeRestore struct {
 	PersistentVolumeClaim PersistentVolumeClaimSourceSpec `json:"persistentVolumeClaimSpec,omitempty"`
 
 	VolumeBackupName string `json:"volumeBackupName,omitempty"`
+
+	// +optional
+	Progress *TransferProgress `json:"progress,omitempty"`
 }
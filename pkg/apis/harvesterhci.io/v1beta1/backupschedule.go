@@ -0,0 +1,78 @@
+package v1beta1
+
+import (
+	"github.com/rancher/wrangler/pkg/condition"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupScheduleConditionReady is the "ready" condition type of VirtualMachineBackupSchedule,
+	// it turns false when the schedule is invalid or the last scheduled run failed.
+	BackupScheduleConditionReady condition.Cond = "Ready"
+)
+
+// VirtualMachineBackupSchedule creates VirtualMachineBackups of the selected VMs periodically
+// and prunes the outdated ones according to the retention policy.
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=vmbackupschedule;vmbackupschedules,scope=Namespaced
+// +kubebuilder:printcolumn:name="SCHEDULE",type=string,JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="SUSPEND",type=boolean,JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="LAST_SCHEDULE",type=date,JSONPath=`.status.lastScheduleTime`
+// +kubebuilder:printcolumn:name="NEXT_SCHEDULE",type=date,JSONPath=`.status.nextScheduleTime`
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+type VirtualMachineBackupSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VirtualMachineBackupScheduleSpec `json:"spec"`
+
+	// +optional
+	Status *VirtualMachineBackupScheduleStatus `json:"status,omitempty"`
+}
+
+type VirtualMachineBackupScheduleSpec struct {
+	// Schedule is a standard 5-field cron expression, e.g. "0 2 * * *"
+	// +kubebuilder:validation:Required
+	Schedule string `json:"schedule"`
+
+	// Suspend stops the schedule from creating new backups, the existing backups are kept
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// VMNames is the list of VMs in the same namespace to back up
+	// +optional
+	VMNames []string `json:"vmNames,omitempty"`
+
+	// Selector selects the VMs in the same namespace to back up by labels,
+	// VMs matched by either VMNames or Selector are backed up.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Retain is the number of ready backups kept for each VM, 0 means no limit
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Retain int `json:"retain,omitempty"`
+
+	// MaxAge is the max age of a ready backup before it is pruned, e.g. "168h", empty means no limit
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+type VirtualMachineBackupScheduleStatus struct {
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// +optional
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+
+	// +optional
+	LastFailure *Error `json:"lastFailure,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
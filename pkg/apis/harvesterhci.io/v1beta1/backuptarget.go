@@ -0,0 +1,72 @@
+package v1beta1
+
+import (
+	"github.com/rancher/wrangler/pkg/condition"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// BackupTargetConditionAvailable is the "available" condition type of BackupTarget,
+	// it reflects whether the backup target is reachable with the given credentials.
+	BackupTargetConditionAvailable condition.Cond = "Available"
+
+	BackupTargetTypeS3  = "s3"
+	BackupTargetTypeNFS = "nfs"
+)
+
+// BackupTarget is a named backup store with its own credentials, whose reachability is checked periodically.
+// Longhorn backs up volumes to one backup store at a time, the one configured in the backup-target setting,
+// so all the VM backups are taken to that store, and record the name of the BackupTarget describing it.
+// The BackupTargets are not backup destinations by themselves: they keep the credentials of the stores to switch
+// the backup-target setting to, and the VM backups in the metadata of all the available ones are kept in sync.
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=bt;bts,scope=Cluster
+// +kubebuilder:printcolumn:name="TYPE",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="ENDPOINT",type=string,JSONPath=`.spec.endpoint`
+// +kubebuilder:printcolumn:name="AVAILABLE",type=string,JSONPath=`.status.conditions[?(@.type=="Available")].status`
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+
+type BackupTarget struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BackupTargetSpec   `json:"spec"`
+	Status BackupTargetStatus `json:"status,omitempty"`
+}
+
+type BackupTargetSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=s3;nfs
+	Type string `json:"type"`
+
+	// Endpoint is the NFS share, e.g. "nfs://10.0.0.1:/backups", or the S3 endpoint
+	// +kubebuilder:validation:Required
+	Endpoint string `json:"endpoint"`
+
+	// +optional
+	BucketName string `json:"bucketName,omitempty"`
+
+	// +optional
+	BucketRegion string `json:"bucketRegion,omitempty"`
+
+	// +optional
+	VirtualHostedStyle bool `json:"virtualHostedStyle,omitempty"`
+
+	// CredentialSecret references the secret which holds the S3 credentials in the AWS_ACCESS_KEY_ID,
+	// AWS_SECRET_ACCESS_KEY and the optional AWS_CERT keys
+	// +optional
+	CredentialSecret *corev1.SecretReference `json:"credentialSecret,omitempty"`
+
+	// EncryptionKeySecret references the secret which holds the 16, 24 or 32 bytes AES key in the
+	// encryptionKey key, to encrypt the VM backup metadata including the secret payloads in the backup target
+	// +optional
+	EncryptionKeySecret *corev1.SecretReference `json:"encryptionKeySecret,omitempty"`
+}
+
+type BackupTargetStatus struct {
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
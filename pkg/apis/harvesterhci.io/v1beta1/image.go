@@ -9,12 +9,14 @@ import (
 var (
 	ImageInitialized condition.Cond = "Initialized"
 	ImageImported    condition.Cond = "Imported"
+	ImageConverting  condition.Cond = "Converting"
 )
 
 const (
 	VirtualMachineImageSourceTypeDownload     = "download"
 	VirtualMachineImageSourceTypeUpload       = "upload"
 	VirtualMachineImageSourceTypeExportVolume = "export-from-volume"
+	VirtualMachineImageSourceTypeRegistry     = "registry"
 )
 
 // +genclient
@@ -40,7 +42,7 @@ type VirtualMachineImageSpec struct {
 	DisplayName string `json:"displayName"`
 
 	// +kubebuilder:validation:Required
-	// +kubebuilder:validation:Enum=download;upload;export-from-volume
+	// +kubebuilder:validation:Enum=download;upload;export-from-volume;registry
 	SourceType string `json:"sourceType"`
 
 	// +optional
@@ -63,6 +65,10 @@ type VirtualMachineImageStatus struct {
 	// +optional
 	Progress int `json:"progress,omitempty"`
 
+	// ConversionProgress is the progress of converting the source to a format Longhorn accepts
+	// +optional
+	ConversionProgress int `json:"conversionProgress,omitempty"`
+
 	// +optional
 	Size int64 `json:"size,omitempty"`
 
@@ -0,0 +1,60 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// VirtualMachineSnapshot is an in-cluster point-in-time copy of a VM. Unlike VirtualMachineBackup,
+// the volume snapshots are kept by the storage driver and never uploaded to the backup target.
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=vmsnap;vmsnaps,scope=Namespaced
+// +kubebuilder:printcolumn:name="SOURCE_KIND",type=string,JSONPath=`.spec.source.kind`
+// +kubebuilder:printcolumn:name="SOURCE_NAME",type=string,JSONPath=`.spec.source.name`
+// +kubebuilder:printcolumn:name="READY_TO_USE",type=boolean,JSONPath=`.status.readyToUse`
+// +kubebuilder:printcolumn:name="AGE",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:printcolumn:name="ERROR",type=date,JSONPath=`.status.error.message`
+
+type VirtualMachineSnapshot struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec VirtualMachineSnapshotSpec `json:"spec"`
+
+	// +optional
+	Status *VirtualMachineSnapshotStatus `json:"status,omitempty"`
+}
+
+type VirtualMachineSnapshotSpec struct {
+	Source corev1.TypedLocalObjectReference `json:"source"`
+}
+
+// VirtualMachineSnapshotStatus is the status for a VirtualMachineSnapshot resource
+type VirtualMachineSnapshotStatus struct {
+	// +optional
+	SourceUID *types.UID `json:"sourceUID,omitempty"`
+
+	// +optional
+	CreationTime *metav1.Time `json:"creationTime,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// SourceSpec contains the vm spec source of the snapshot
+	SourceSpec *VirtualMachineSourceSpec `json:"source,omitempty"`
+
+	// +optional
+	VolumeSnapshots []VolumeBackup `json:"volumeSnapshots,omitempty"`
+
+	// +optional
+	SecretBackups []SecretBackup `json:"secretBackups,omitempty"`
+
+	// +optional
+	ReadyToUse *bool `json:"readyToUse,omitempty"`
+
+	// +optional
+	Error *Error `json:"error,omitempty"`
+
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+}
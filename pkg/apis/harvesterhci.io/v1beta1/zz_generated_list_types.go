@@ -128,6 +128,23 @@ func NewVirtualMachineBackup(namespace, name string, obj VirtualMachineBackup) *
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// BackupTargetList is a list of BackupTarget resources
+type BackupTargetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []BackupTarget `json:"items"`
+}
+
+func NewBackupTarget(namespace, name string, obj BackupTarget) *BackupTarget {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("BackupTarget").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // VirtualMachineRestoreList is a list of VirtualMachineRestore resources
 type VirtualMachineRestoreList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -145,6 +162,40 @@ func NewVirtualMachineRestore(namespace, name string, obj VirtualMachineRestore)
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
+// VirtualMachineBackupScheduleList is a list of VirtualMachineBackupSchedule resources
+type VirtualMachineBackupScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []VirtualMachineBackupSchedule `json:"items"`
+}
+
+func NewVirtualMachineBackupSchedule(namespace, name string, obj VirtualMachineBackupSchedule) *VirtualMachineBackupSchedule {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("VirtualMachineBackupSchedule").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VirtualMachineSnapshotList is a list of VirtualMachineSnapshot resources
+type VirtualMachineSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []VirtualMachineSnapshot `json:"items"`
+}
+
+func NewVirtualMachineSnapshot(namespace, name string, obj VirtualMachineSnapshot) *VirtualMachineSnapshot {
+	obj.APIVersion, obj.Kind = SchemeGroupVersion.WithKind("VirtualMachineSnapshot").ToAPIVersionAndKind()
+	obj.Name = name
+	obj.Namespace = namespace
+	return &obj
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
 // VirtualMachineImageList is a list of VirtualMachineImage resources
 type VirtualMachineImageList struct {
 	metav1.TypeMeta `json:",inline"`
@@ -28,6 +28,7 @@ import (
 )
 
 var (
+	BackupTargetResourceName                  = "backuptargets"
 	KeyPairResourceName                       = "keypairs"
 	PreferenceResourceName                    = "preferences"
 	SettingResourceName                       = "settings"
@@ -35,8 +36,10 @@ var (
 	UpgradeResourceName                       = "upgrades"
 	VersionResourceName                       = "versions"
 	VirtualMachineBackupResourceName          = "virtualmachinebackups"
+	VirtualMachineBackupScheduleResourceName  = "virtualmachinebackupschedules"
 	VirtualMachineImageResourceName           = "virtualmachineimages"
 	VirtualMachineRestoreResourceName         = "virtualmachinerestores"
+	VirtualMachineSnapshotResourceName        = "virtualmachinesnapshots"
 	VirtualMachineTemplateResourceName        = "virtualmachinetemplates"
 	VirtualMachineTemplateVersionResourceName = "virtualmachinetemplateversions"
 )
@@ -62,6 +65,8 @@ var (
 // Adds the list of known types to Scheme.
 func addKnownTypes(scheme *runtime.Scheme) error {
 	scheme.AddKnownTypes(SchemeGroupVersion,
+		&BackupTarget{},
+		&BackupTargetList{},
 		&KeyPair{},
 		&KeyPairList{},
 		&Preference{},
@@ -76,10 +81,14 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&VersionList{},
 		&VirtualMachineBackup{},
 		&VirtualMachineBackupList{},
+		&VirtualMachineBackupSchedule{},
+		&VirtualMachineBackupScheduleList{},
 		&VirtualMachineImage{},
 		&VirtualMachineImageList{},
 		&VirtualMachineRestore{},
 		&VirtualMachineRestoreList{},
+		&VirtualMachineSnapshot{},
+		&VirtualMachineSnapshotList{},
 		&VirtualMachineTemplate{},
 		&VirtualMachineTemplateList{},
 		&VirtualMachineTemplateVersion{},
@@ -34,7 +34,10 @@ func main() {
 					harvesterv1.Upgrade{},
 					harvesterv1.Version{},
 					harvesterv1.VirtualMachineBackup{},
+					harvesterv1.BackupTarget{},
 					harvesterv1.VirtualMachineRestore{},
+					harvesterv1.VirtualMachineBackupSchedule{},
+					harvesterv1.VirtualMachineSnapshot{},
 					harvesterv1.VirtualMachineImage{},
 					harvesterv1.VirtualMachineTemplate{},
 					harvesterv1.VirtualMachineTemplateVersion{},
@@ -82,6 +85,7 @@ func main() {
 					longhornv1.Volume{},
 					longhornv1.Setting{},
 					longhornv1.Backup{},
+					longhornv1.Engine{},
 				},
 				GenerateClients: true,
 			},
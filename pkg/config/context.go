@@ -44,6 +44,12 @@ type Options struct {
 	Threadiness     int
 	HTTPListenPort  int
 	HTTPSListenPort int
+	PodIP           string
+	ExporterImage   string
+
+	ImageConversionEnabled     bool
+	ImageConversionConcurrency int
+	ChunkedImageUploadEnabled  bool
 
 	RancherEmbedded bool
 	RancherURL      string
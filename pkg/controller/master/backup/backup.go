@@ -8,26 +8,28 @@ package backup
 import (
 	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"reflect"
 	"time"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
-	"github.com/longhorn/backupstore"
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/utils/pointer"
 	kv1 "kubevirt.io/client-go/api/v1"
 
 	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	"github.com/harvester/harvester/pkg/config"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/scheme"
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
 	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
 	ctllonghornv1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
@@ -54,6 +56,7 @@ var vmBackupKind = harvesterv1.SchemeGroupVersion.WithKind(vmBackupKindName)
 // RegisterBackup register the vmBackup and volumeSnapshot controller
 func RegisterBackup(ctx context.Context, management *config.Management, opts config.Options) error {
 	vmBackups := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
+	backupTargets := management.HarvesterFactory.Harvesterhci().V1beta1().BackupTarget()
 	pvc := management.CoreFactory.Core().V1().PersistentVolumeClaim()
 	secrets := management.CoreFactory.Core().V1().Secret()
 	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
@@ -62,15 +65,28 @@ func RegisterBackup(ctx context.Context, management *config.Management, opts con
 	snapshots := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshot()
 	snapshotContents := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshotContent()
 	snapshotClass := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshotClass()
+	vmis := management.VirtFactory.Kubevirt().V1().VirtualMachineInstance()
+
+	copyConfig := rest.CopyConfig(management.RestConfig)
+	copyConfig.GroupVersion = &k8sschema.GroupVersion{Group: kv1.SubresourceGroupName, Version: kv1.ApiLatestVersion}
+	copyConfig.APIPath = "/apis"
+	copyConfig.NegotiatedSerializer = scheme.Codecs.WithoutConversion()
+	restClient, err := rest.RESTClientFor(copyConfig)
+	if err != nil {
+		return err
+	}
 
 	vmBackupController := &Handler{
+		context:              ctx,
 		vmBackups:            vmBackups,
 		vmBackupController:   vmBackups,
 		vmBackupCache:        vmBackups.Cache(),
+		backupTargetCache:    backupTargets.Cache(),
 		pvcCache:             pvc.Cache(),
 		secretCache:          secrets.Cache(),
 		vms:                  vms,
 		vmsCache:             vms.Cache(),
+		vmiCache:             vmis.Cache(),
 		volumeCache:          volumes.Cache(),
 		volumes:              volumes,
 		lhbackupCache:        lhbackups.Cache(),
@@ -80,6 +96,8 @@ func RegisterBackup(ctx context.Context, management *config.Management, opts con
 		snapshotContentCache: snapshotContents.Cache(),
 		snapshotClassCache:   snapshotClass.Cache(),
 		recorder:             management.NewRecorder(backupControllerName, "", ""),
+
+		virtSubresourceRestClient: restClient,
 	}
 
 	vmBackups.OnChange(ctx, backupControllerName, vmBackupController.OnBackupChange)
@@ -90,11 +108,14 @@ func RegisterBackup(ctx context.Context, management *config.Management, opts con
 }
 
 type Handler struct {
+	context              context.Context
 	vmBackups            ctlharvesterv1.VirtualMachineBackupClient
 	vmBackupCache        ctlharvesterv1.VirtualMachineBackupCache
 	vmBackupController   ctlharvesterv1.VirtualMachineBackupController
+	backupTargetCache    ctlharvesterv1.BackupTargetCache
 	vms                  ctlkubevirtv1.VirtualMachineClient
 	vmsCache             ctlkubevirtv1.VirtualMachineCache
+	vmiCache             ctlkubevirtv1.VirtualMachineInstanceCache
 	pvcCache             ctlcorev1.PersistentVolumeClaimCache
 	secretCache          ctlcorev1.SecretCache
 	volumeCache          ctllonghornv1.VolumeCache
@@ -106,6 +127,8 @@ type Handler struct {
 	snapshotContentCache ctlsnapshotv1.VolumeSnapshotContentCache
 	snapshotClassCache   ctlsnapshotv1.VolumeSnapshotClassCache
 	recorder             record.EventRecorder
+
+	virtSubresourceRestClient rest.Interface
 }
 
 // OnBackupChange handles vm backup object on change and reconcile vm backup status
@@ -114,8 +137,15 @@ func (h *Handler) OnBackupChange(key string, vmBackup *harvesterv1.VirtualMachin
 		return nil, nil
 	}
 
-	target, err := settings.DecodeBackupTarget(settings.BackupTargetSet.Get())
+	target, err := h.getBackupTarget(vmBackup)
 	if err != nil {
+		if isBackupMissingStatus(vmBackup) {
+			return nil, h.setStatusError(vmBackup, err)
+		}
+		// the recorded BackupTarget is removed, there is nowhere to upload the metadata
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -145,7 +175,7 @@ func (h *Handler) OnBackupChange(key string, vmBackup *harvesterv1.VirtualMachin
 
 		// check if the VM is running, if not make sure the volumes are mounted to the host
 		if !sourceVM.Status.Ready || !sourceVM.Status.Created {
-			if err := h.mountLonghornVolumes(sourceVM); err != nil {
+			if err := mountLonghornVolumes(h.pvcCache, h.volumeCache, h.volumes, sourceVM); err != nil {
 				return nil, h.setStatusError(vmBackup, err)
 			}
 		}
@@ -155,9 +185,19 @@ func (h *Handler) OnBackupChange(key string, vmBackup *harvesterv1.VirtualMachin
 
 	// TODO, make sure status is initialized, and "Lock" the source VM by adding a finalizer and setting snapshotInProgress in status
 
+	// freeze the guest filesystems before creating volume snapshots
+	if vmBackup.Status.Quiesced == nil {
+		return nil, h.freezeGuest(vmBackup)
+	}
+
 	// create volume snapshots if not exist
 	if err := h.reconcileVolumeSnapshots(vmBackup); err != nil {
-		return nil, h.setStatusError(vmBackup, err)
+		return nil, h.setStatusError(h.thawOnError(vmBackup), err)
+	}
+
+	// thaw the guest filesystems once the volume snapshots are taken
+	if updated, err := h.reconcileThaw(vmBackup); err != nil || updated {
+		return nil, err
 	}
 
 	// reconcile backup status of volume backups, validate if those volumeSnapshots are ready to use
@@ -170,7 +210,18 @@ func (h *Handler) OnBackupChange(key string, vmBackup *harvesterv1.VirtualMachin
 
 // OnBackupRemove remove remote vm backup metadata
 func (h *Handler) OnBackupRemove(key string, vmBackup *harvesterv1.VirtualMachineBackup) (*harvesterv1.VirtualMachineBackup, error) {
-	if vmBackup == nil || vmBackup.Status == nil || vmBackup.Status.BackupTarget == nil {
+	if vmBackup == nil || vmBackup.Status == nil {
+		return nil, nil
+	}
+
+	// don't leave the guest frozen if the backup is removed in the middle of taking snapshots
+	if vmBackup.Status.FreezeTime != nil {
+		if err := h.unfreezeGuest(vmBackup); err != nil {
+			return nil, err
+		}
+	}
+
+	if vmBackup.Status.BackupTarget == nil {
 		return nil, nil
 	}
 
@@ -179,8 +230,15 @@ func (h *Handler) OnBackupRemove(key string, vmBackup *harvesterv1.VirtualMachin
 		return nil, err
 	}
 
-	if err := h.deleteVMBackupMetadata(vmBackup, target); err != nil {
+	// the VM backup metadata is in the BackupTarget recorded in the status, which may be another store than
+	// the backup-target setting of Longhorn since the setting is switched
+	metadataTarget, err := h.getBackupTarget(vmBackup)
+	if err != nil && !apierrors.IsNotFound(err) {
 		return nil, err
+	} else if err == nil {
+		if err := h.deleteVMBackupMetadata(vmBackup, metadataTarget); err != nil {
+			return nil, err
+		}
 	}
 
 	// Since VolumeSnapshot and VolumeSnapshotContent has finalizers,
@@ -195,6 +253,44 @@ func (h *Handler) OnBackupRemove(key string, vmBackup *harvesterv1.VirtualMachin
 	return nil, nil
 }
 
+func (h *Handler) getBackupTarget(vmBackup *harvesterv1.VirtualMachineBackup) (*settings.BackupTarget, error) {
+	return getVMBackupTarget(h.backupTargetCache, h.secretCache, vmBackup)
+}
+
+// getVMBackupTarget returns the backup store of the VM backup, i.e. the BackupTarget recorded in its status,
+// or the backup-target setting which Longhorn backs up the volumes to if it's not recorded.
+func getVMBackupTarget(
+	backupTargetCache ctlharvesterv1.BackupTargetCache,
+	secretCache ctlcorev1.SecretCache,
+	vmBackup *harvesterv1.VirtualMachineBackup,
+) (*settings.BackupTarget, error) {
+	if vmBackup.Status == nil || vmBackup.Status.BackupTarget == nil || vmBackup.Status.BackupTarget.Name == "" {
+		return settings.DecodeBackupTarget(settings.BackupTargetSet.Get())
+	}
+
+	backupTarget, err := backupTargetCache.Get(vmBackup.Status.BackupTarget.Name)
+	if err != nil {
+		return nil, err
+	}
+	return getBackupTargetFromResource(secretCache, backupTarget)
+}
+
+// getBackupTargetName returns the name of the BackupTarget describing the backup store,
+// or an empty string if there is none. The first one by name is taken if several describe the store.
+func getBackupTargetName(backupTargetCache ctlharvesterv1.BackupTargetCache, target *settings.BackupTarget) (string, error) {
+	backupTargets, err := backupTargetCache.List(labels.Everything())
+	if err != nil {
+		return "", err
+	}
+	var name string
+	for _, backupTarget := range backupTargets {
+		if isSameBackupStore(backupTargetFromSpec(backupTarget), target) && (name == "" || backupTarget.Name < name) {
+			name = backupTarget.Name
+		}
+	}
+	return name, nil
+}
+
 func (h *Handler) getBackupSource(vmBackup *harvesterv1.VirtualMachineBackup) (*kv1.VirtualMachine, error) {
 	switch vmBackup.Spec.Source.Kind {
 	case kv1.VirtualMachineGroupVersionKind.Kind:
@@ -204,18 +300,19 @@ func (h *Handler) getBackupSource(vmBackup *harvesterv1.VirtualMachineBackup) (*
 	return nil, fmt.Errorf("unsupported source: %+v", vmBackup.Spec.Source)
 }
 
-// getVolumeBackups helps to build a list of VolumeBackup upon the volume list of backup VM
-func (h *Handler) getVolumeBackups(backup *harvesterv1.VirtualMachineBackup, vm *kv1.VirtualMachine) ([]harvesterv1.VolumeBackup, error) {
+// getVolumeBackups helps to build a list of VolumeBackup upon the volume list of backup VM,
+// it's shared by VM backups and VM snapshots, the volume snapshots are named after the owner.
+func getVolumeBackups(pvcCache ctlcorev1.PersistentVolumeClaimCache, ownerName string, vm *kv1.VirtualMachine) ([]harvesterv1.VolumeBackup, error) {
 	sourceVolumes := vm.Spec.Template.Spec.Volumes
 	var volumeBackups = make([]harvesterv1.VolumeBackup, 0, len(sourceVolumes))
 
 	for volumeName, pvcName := range volumeToPVCMappings(sourceVolumes) {
-		pvc, err := h.getBackupPVC(vm.Namespace, pvcName)
+		pvc, err := getBackupPVC(pvcCache, vm.Namespace, pvcName)
 		if err != nil {
 			return nil, err
 		}
 
-		volumeBackupName := fmt.Sprintf("%s-volume-%s", backup.Name, pvcName)
+		volumeBackupName := fmt.Sprintf("%s-volume-%s", ownerName, pvcName)
 
 		vb := harvesterv1.VolumeBackup{
 			Name:       &volumeBackupName,
@@ -239,7 +336,7 @@ func (h *Handler) getVolumeBackups(backup *harvesterv1.VirtualMachineBackup, vm
 }
 
 // getSecretBackups helps to build a list of SecretBackup upon the secrets used by the backup VM
-func (h *Handler) getSecretBackups(vm *kv1.VirtualMachine) ([]harvesterv1.SecretBackup, error) {
+func getSecretBackups(secretCache ctlcorev1.SecretCache, vm *kv1.VirtualMachine) ([]harvesterv1.SecretBackup, error) {
 	secretRefs := []*corev1.LocalObjectReference{}
 
 	for _, credential := range vm.Spec.Template.Spec.AccessCredentials {
@@ -273,7 +370,7 @@ func (h *Handler) getSecretBackups(vm *kv1.VirtualMachine) ([]harvesterv1.Secret
 			continue
 		}
 
-		secretBackup, err := h.getSecretBackupFromSecret(vm.Namespace, secretRef.Name)
+		secretBackup, err := getSecretBackupFromSecret(secretCache, vm.Namespace, secretRef.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -284,8 +381,8 @@ func (h *Handler) getSecretBackups(vm *kv1.VirtualMachine) ([]harvesterv1.Secret
 	return secretBackups, nil
 }
 
-func (h *Handler) getSecretBackupFromSecret(namespace, name string) (*harvesterv1.SecretBackup, error) {
-	secret, err := h.secretCache.Get(namespace, name)
+func getSecretBackupFromSecret(secretCache ctlcorev1.SecretCache, namespace, name string) (*harvesterv1.SecretBackup, error) {
+	secret, err := secretCache.Get(namespace, name)
 	if err != nil {
 		return nil, err
 	}
@@ -320,15 +417,20 @@ func (h *Handler) initBackup(backup *harvesterv1.VirtualMachineBackup, vm *kv1.V
 		},
 	}
 
-	if backupCpy.Status.VolumeBackups, err = h.getVolumeBackups(backup, vm); err != nil {
+	if backupCpy.Status.VolumeBackups, err = getVolumeBackups(h.pvcCache, backup.Name, vm); err != nil {
 		return err
 	}
 
-	if backupCpy.Status.SecretBackups, err = h.getSecretBackups(vm); err != nil {
+	if backupCpy.Status.SecretBackups, err = getSecretBackups(h.secretCache, vm); err != nil {
 		return err
 	}
 
-	backupCpy.Status.BackupTarget = &harvesterv1.BackupTarget{
+	backupTargetName, err := getBackupTargetName(h.backupTargetCache, target)
+	if err != nil {
+		return err
+	}
+	backupCpy.Status.BackupTarget = &harvesterv1.BackupTargetLocation{
+		Name:         backupTargetName,
 		Endpoint:     target.Endpoint,
 		BucketName:   target.BucketName,
 		BucketRegion: target.BucketRegion,
@@ -340,8 +442,8 @@ func (h *Handler) initBackup(backup *harvesterv1.VirtualMachineBackup, vm *kv1.V
 	return nil
 }
 
-func (h *Handler) getBackupPVC(namespace, name string) (*corev1.PersistentVolumeClaim, error) {
-	pvc, err := h.pvcCache.Get(namespace, name)
+func getBackupPVC(pvcCache ctlcorev1.PersistentVolumeClaimCache, namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+	pvc, err := pvcCache.Get(namespace, name)
 	if err != nil {
 		return nil, err
 	}
@@ -492,6 +594,9 @@ func (h *Handler) createVolumeSnapshotContent(
 		return snapshotContent, nil
 	}
 
+	if volumeBackup.LonghornBackupName == nil {
+		return nil, fmt.Errorf("volume backup %s has no Longhorn backup yet", volumeBackup.VolumeName)
+	}
 	lhBackup, err := h.lhbackupCache.Get(util.LonghornSystemNamespaceName, *volumeBackup.LonghornBackupName)
 	if err != nil {
 		return nil, err
@@ -562,18 +667,7 @@ func (h *Handler) deleteVMBackupMetadata(vmBackup *harvesterv1.VirtualMachineBac
 		return nil
 	}
 
-	if target.Type == settings.S3BackupType {
-		secret, err := h.secretCache.Get(util.LonghornSystemNamespaceName, util.BackupTargetSecretName)
-		if err != nil {
-			return err
-		}
-		os.Setenv(AWSAccessKey, string(secret.Data[AWSAccessKey]))
-		os.Setenv(AWSSecretKey, string(secret.Data[AWSSecretKey]))
-		os.Setenv(AWSEndpoints, string(secret.Data[AWSEndpoints]))
-		os.Setenv(AWSCERT, string(secret.Data[AWSCERT]))
-	}
-
-	bsDriver, err := backupstore.GetBackupStoreDriver(ConstructEndpoint(target))
+	bsDriver, err := newBackupStoreDriver(h.secretCache, target)
 	if err != nil {
 		return err
 	}
@@ -610,45 +704,31 @@ func (h *Handler) uploadVMBackupMetadata(vmBackup *harvesterv1.VirtualMachineBac
 		return nil
 	}
 
-	if target.Type == settings.S3BackupType {
-		secret, err := h.secretCache.Get(util.LonghornSystemNamespaceName, util.BackupTargetSecretName)
-		if err != nil {
-			return err
-		}
-		os.Setenv(AWSAccessKey, string(secret.Data[AWSAccessKey]))
-		os.Setenv(AWSSecretKey, string(secret.Data[AWSSecretKey]))
-		os.Setenv(AWSEndpoints, string(secret.Data[AWSEndpoints]))
-		os.Setenv(AWSCERT, string(secret.Data[AWSCERT]))
-	}
-
-	bsDriver, err := backupstore.GetBackupStoreDriver(ConstructEndpoint(target))
+	bsDriver, err := newBackupStoreDriver(h.secretCache, target)
 	if err != nil {
 		return err
 	}
 
-	vmBackupMetadata := &VirtualMachineBackupMetadata{
-		Name:          vmBackup.Name,
-		Namespace:     vmBackup.Namespace,
-		BackupSpec:    vmBackup.Spec,
-		VMSourceSpec:  vmBackup.Status.SourceSpec,
-		VolumeBackups: sanitizeVolumeBackups(vmBackup.Status.VolumeBackups),
-		SecretBackups: vmBackup.Status.SecretBackups,
-	}
-	if vmBackup.Namespace == "" {
-		vmBackupMetadata.Namespace = metav1.NamespaceDefault
+	key, err := getBackupEncryptionKey(h.secretCache, target)
+	if err != nil {
+		return err
 	}
 
-	j, err := json.Marshal(vmBackupMetadata)
+	vmBackupMetadata := newVMBackupMetadata(vmBackup)
+	destURL := filepath.Join(metadataFolderPath, getVMBackupMetadataFileName(vmBackup.Namespace, vmBackup.Name))
+	j, err := encodeBackupMetadata(vmBackupMetadata, key, destURL)
 	if err != nil {
 		return err
 	}
 
 	shouldUpload := true
-	destURL := filepath.Join(metadataFolderPath, getVMBackupMetadataFileName(vmBackup.Namespace, vmBackup.Name))
 	if bsDriver.FileExists(destURL) {
-		if remoteVMBackupMetadata, err := loadBackupMetadataInBackupTarget(destURL, bsDriver); err != nil {
+		remoteVMBackupMetadata, encrypted, err := readBackupMetadataInBackupTarget(destURL, bsDriver, key)
+		if err != nil && !errors.Is(err, errBackupMetadataDecryption) {
 			return err
-		} else if reflect.DeepEqual(vmBackupMetadata, remoteVMBackupMetadata) {
+		}
+		// the metadata is uploaded again when the encryption key of the backup target is set, changed or removed
+		if err == nil && encrypted == (key != nil) && reflect.DeepEqual(vmBackupMetadata, remoteVMBackupMetadata) {
 			shouldUpload = false
 		}
 	}
@@ -663,11 +743,28 @@ func (h *Handler) uploadVMBackupMetadata(vmBackup *harvesterv1.VirtualMachineBac
 	return nil
 }
 
+// newVMBackupMetadata returns the metadata uploaded to the backup target to restore the VM backup in another cluster
+func newVMBackupMetadata(vmBackup *harvesterv1.VirtualMachineBackup) *VirtualMachineBackupMetadata {
+	vmBackupMetadata := &VirtualMachineBackupMetadata{
+		Name:          vmBackup.Name,
+		Namespace:     vmBackup.Namespace,
+		BackupSpec:    vmBackup.Spec,
+		VMSourceSpec:  vmBackup.Status.SourceSpec,
+		VolumeBackups: sanitizeVolumeBackups(vmBackup.DeepCopy().Status.VolumeBackups),
+		SecretBackups: vmBackup.Status.SecretBackups,
+	}
+	if vmBackup.Namespace == "" {
+		vmBackupMetadata.Namespace = metav1.NamespaceDefault
+	}
+	return vmBackupMetadata
+}
+
 func sanitizeVolumeBackups(volumeBackups []harvesterv1.VolumeBackup) []harvesterv1.VolumeBackup {
 	for i := 0; i < len(volumeBackups); i++ {
 		volumeBackups[i].ReadyToUse = nil
 		volumeBackups[i].CreationTime = nil
 		volumeBackups[i].Error = nil
+		volumeBackups[i].Progress = nil
 	}
 	return volumeBackups
 }
@@ -745,7 +842,7 @@ func (h *Handler) configureBackupTargetOnStatus(vmBackup *harvesterv1.VirtualMac
 
 	logrus.Debugf("configure backup target from annotation to status for vm backup %s/%s", vmBackup.Namespace, vmBackup.Name)
 	vmBackupCpy := vmBackup.DeepCopy()
-	vmBackupCpy.Status.BackupTarget = &harvesterv1.BackupTarget{
+	vmBackupCpy.Status.BackupTarget = &harvesterv1.BackupTargetLocation{
 		Endpoint:     vmBackup.Annotations[backupTargetAnnotation],
 		BucketName:   vmBackup.Annotations[backupBucketNameAnnotation],
 		BucketRegion: vmBackup.Annotations[backupBucketRegionAnnotation],
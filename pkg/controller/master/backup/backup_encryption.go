@@ -0,0 +1,119 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+
+	"github.com/harvester/harvester/pkg/settings"
+)
+
+const (
+	// BackupEncryptionKey is the key of the AES key in the encryption key secret of the backup target
+	BackupEncryptionKey = "encryptionKey"
+
+	metadataEncryptionAlgorithm = "AES-GCM"
+)
+
+var errBackupMetadataDecryption = errors.New("failed to decrypt vm backup metadata")
+
+// encryptedBackupMetadata is the form of the VM backup metadata file when the backup target has an encryption key,
+// the whole metadata including the secret payloads is sealed by AES-GCM and the nonce is prepended to the ciphertext.
+type encryptedBackupMetadata struct {
+	EncryptionAlgorithm string `json:"encryptionAlgorithm"`
+	Ciphertext          []byte `json:"ciphertext"`
+}
+
+// getBackupEncryptionKey returns the key to encrypt the VM backup metadata in the backup target, or nil if it's not set
+func getBackupEncryptionKey(secretCache ctlcorev1.SecretCache, target *settings.BackupTarget) ([]byte, error) {
+	secretRef := target.EncryptionKeySecret
+	if secretRef == nil {
+		return nil, nil
+	}
+
+	secret, err := secretCache.Get(secretRef.Namespace, secretRef.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get encryption key secret %s/%s: %w", secretRef.Namespace, secretRef.Name, err)
+	}
+	key := secret.Data[BackupEncryptionKey]
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%s in secret %s/%s must be 16, 24 or 32 bytes", BackupEncryptionKey, secretRef.Namespace, secretRef.Name)
+	}
+}
+
+// encodeBackupMetadata serializes the VM backup metadata, and encrypts it if the key isn't nil.
+// The file path is authenticated along with the metadata, so that a metadata file can't be swapped with another one.
+func encodeBackupMetadata(metadata *VirtualMachineBackupMetadata, key []byte, filePath string) ([]byte, error) {
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return data, nil
+	}
+
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return json.Marshal(&encryptedBackupMetadata{
+		EncryptionAlgorithm: metadataEncryptionAlgorithm,
+		Ciphertext:          gcm.Seal(nonce, nonce, data, []byte(filePath)),
+	})
+}
+
+// decodeBackupMetadata deserializes the VM backup metadata, the encrypted metadata is decrypted with the key.
+// It also returns whether the metadata is encrypted.
+func decodeBackupMetadata(data []byte, key []byte, filePath string) (*VirtualMachineBackupMetadata, bool, error) {
+	encrypted := &encryptedBackupMetadata{}
+	if err := json.Unmarshal(data, encrypted); err != nil {
+		return nil, false, err
+	}
+
+	if encrypted.EncryptionAlgorithm != "" {
+		if encrypted.EncryptionAlgorithm != metadataEncryptionAlgorithm {
+			return nil, true, fmt.Errorf("unsupported encryption algorithm %s of %s", encrypted.EncryptionAlgorithm, filePath)
+		}
+		if key == nil {
+			return nil, true, fmt.Errorf("%w %s: no encryption key is configured in the backup target", errBackupMetadataDecryption, filePath)
+		}
+		gcm, err := newGCM(key)
+		if err != nil {
+			return nil, true, err
+		}
+		if len(encrypted.Ciphertext) < gcm.NonceSize() {
+			return nil, true, fmt.Errorf("%w %s: ciphertext is too short", errBackupMetadataDecryption, filePath)
+		}
+		nonce, ciphertext := encrypted.Ciphertext[:gcm.NonceSize()], encrypted.Ciphertext[gcm.NonceSize():]
+		if data, err = gcm.Open(nil, nonce, ciphertext, []byte(filePath)); err != nil {
+			return nil, true, fmt.Errorf("%w %s: %v", errBackupMetadataDecryption, filePath, err)
+		}
+	}
+
+	backupMetadata := &VirtualMachineBackupMetadata{}
+	if err := json.Unmarshal(data, backupMetadata); err != nil {
+		return nil, encrypted.EncryptionAlgorithm != "", err
+	}
+	return backupMetadata, encrypted.EncryptionAlgorithm != "", nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
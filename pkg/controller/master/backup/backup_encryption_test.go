@@ -0,0 +1,64 @@
+package backup
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func TestEncodeBackupMetadata(t *testing.T) {
+	const filePath = "harvester/vmbackups/default-backup.cfg"
+	key := []byte("0123456789abcdef0123456789abcdef")
+	metadata := &VirtualMachineBackupMetadata{
+		Name:      "backup",
+		Namespace: "default",
+		SecretBackups: []harvesterv1.SecretBackup{
+			{
+				Name: "cloudinit",
+				Data: map[string][]byte{"userdata": []byte("password: secret")},
+			},
+		},
+	}
+
+	// plain metadata
+	data, err := encodeBackupMetadata(metadata, nil, filePath)
+	assert.Nil(t, err)
+	decoded, encrypted, err := decodeBackupMetadata(data, nil, filePath)
+	assert.Nil(t, err)
+	assert.False(t, encrypted)
+	assert.Equal(t, metadata, decoded)
+
+	// encrypted metadata
+	data, err = encodeBackupMetadata(metadata, key, filePath)
+	assert.Nil(t, err)
+	assert.False(t, strings.Contains(string(data), "backup"), "metadata isn't encrypted")
+	decoded, encrypted, err = decodeBackupMetadata(data, key, filePath)
+	assert.Nil(t, err)
+	assert.True(t, encrypted)
+	assert.Equal(t, metadata, decoded)
+
+	// a plain metadata file is still readable when the key is set
+	plain, _ := encodeBackupMetadata(metadata, nil, filePath)
+	_, encrypted, err = decodeBackupMetadata(plain, key, filePath)
+	assert.Nil(t, err)
+	assert.False(t, encrypted)
+
+	var failureCases = []struct {
+		name     string
+		key      []byte
+		filePath string
+	}{
+		{name: "no key", key: nil, filePath: filePath},
+		{name: "wrong key", key: []byte("fedcba9876543210fedcba9876543210"), filePath: filePath},
+		{name: "swapped file", key: key, filePath: "harvester/vmbackups/default-other.cfg"},
+	}
+	for _, tc := range failureCases {
+		_, encrypted, err := decodeBackupMetadata(data, tc.key, tc.filePath)
+		assert.True(t, encrypted, "case %q", tc.name)
+		assert.True(t, errors.Is(err, errBackupMetadataDecryption), "case %q", tc.name)
+	}
+}
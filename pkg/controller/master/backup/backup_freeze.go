@@ -0,0 +1,187 @@
+package backup
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+)
+
+const (
+	vmiResource          = "virtualmachineinstances"
+	freezeSubresource    = "freeze"
+	unfreezeSubresource  = "unfreeze"
+	fsFreezeStatusFrozen = "frozen"
+
+	backupNotQuiescedEvent    = "VirtualMachineBackupNotQuiesced"
+	backupUnfreezeFailedEvent = "VirtualMachineBackupUnfreezeFailed"
+)
+
+// freezeGuest freezes the guest filesystems of a running VM through the guest agent before the volume snapshots are taken,
+// so that the backup is application-consistent. The backup falls back to be crash-consistent if it can't be frozen.
+func (h *Handler) freezeGuest(vmBackup *harvesterv1.VirtualMachineBackup) error {
+	vmBackupCpy := vmBackup.DeepCopy()
+
+	reason, err := h.tryFreezeGuest(vmBackup)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		vmBackupCpy.Status.Quiesced = pointer.BoolPtr(true)
+		now := metav1.Now()
+		vmBackupCpy.Status.FreezeTime = &now
+	} else {
+		vmBackupCpy.Status.Quiesced = pointer.BoolPtr(false)
+		logrus.Debugf("vm backup %s/%s is not quiesced: %s", vmBackup.Namespace, vmBackup.Name, reason)
+		h.recorder.Eventf(vmBackup, corev1.EventTypeNormal, backupNotQuiescedEvent, "Taking crash-consistent backup: %s", reason)
+	}
+
+	_, err = h.vmBackups.Update(vmBackupCpy)
+	if err != nil && vmBackupCpy.Status.FreezeTime != nil {
+		// don't leave the guest frozen if the status can't be recorded, it's retried with a new freeze
+		if unfreezeErr := h.unfreezeGuest(vmBackup); unfreezeErr != nil {
+			logrus.Errorf("failed to unfreeze vm %s/%s after failing to update vm backup %s: %v",
+				vmBackup.Namespace, vmBackup.Spec.Source.Name, vmBackup.Name, unfreezeErr)
+			h.recorder.Eventf(vmBackup, corev1.EventTypeWarning, backupUnfreezeFailedEvent, "Failed to unfreeze guest filesystems: %v", unfreezeErr)
+		}
+	}
+	return err
+}
+
+// tryFreezeGuest returns the reason why the guest filesystems aren't frozen, or an empty string if they are frozen
+func (h *Handler) tryFreezeGuest(vmBackup *harvesterv1.VirtualMachineBackup) (string, error) {
+	if settings.BackupFreezeTimeout.GetInt() <= 0 {
+		return fmt.Sprintf("%s is disabled", settings.BackupFreezeTimeoutSettingName), nil
+	}
+
+	vmi, err := h.vmiCache.Get(vmBackup.Namespace, vmBackup.Spec.Source.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return "VM is not running", nil
+		}
+		return "", err
+	}
+	if !isGuestAgentConnected(vmi) {
+		return "guest agent is not connected", nil
+	}
+
+	if err := h.virtSubresourceRestClient.Put().Namespace(vmi.Namespace).Resource(vmiResource).SubResource(freezeSubresource).Name(vmi.Name).Do(h.context).Error(); err != nil {
+		return fmt.Sprintf("failed to freeze guest filesystems: %v", err), nil
+	}
+	return "", nil
+}
+
+// reconcileThaw thaws the guest filesystems once all the volume snapshots are cut, or when the freeze timeout is reached.
+// It returns true if the VM backup is updated.
+func (h *Handler) reconcileThaw(vmBackup *harvesterv1.VirtualMachineBackup) (bool, error) {
+	if vmBackup.Status.FreezeTime == nil {
+		return false, nil
+	}
+
+	vmBackupCpy := vmBackup.DeepCopy()
+	timeout := time.Duration(settings.BackupFreezeTimeout.GetInt()) * time.Second
+	frozenTime := time.Since(vmBackup.Status.FreezeTime.Time)
+
+	cut, err := h.isVolumeSnapshotsCut(vmBackup)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case cut:
+		logrus.Debugf("thaw vm %s/%s after %s, vm backup %s", vmBackup.Namespace, vmBackup.Spec.Source.Name, frozenTime, vmBackup.Name)
+	case frozenTime >= timeout:
+		// the volume snapshots may be cut after the guest is thawed
+		vmBackupCpy.Status.Quiesced = pointer.BoolPtr(false)
+		h.recorder.Eventf(vmBackup, corev1.EventTypeWarning, backupNotQuiescedEvent,
+			"Taking crash-consistent backup: volume snapshots are not taken within the freeze timeout %s", timeout)
+	default:
+		h.vmBackupController.EnqueueAfter(vmBackup.Namespace, vmBackup.Name, timeout-frozenTime)
+		return false, nil
+	}
+
+	if err := h.unfreezeGuest(vmBackup); err != nil {
+		return false, err
+	}
+	vmBackupCpy.Status.FreezeTime = nil
+	if _, err := h.vmBackups.Update(vmBackupCpy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// thawOnError thaws the guest filesystems when the volume snapshots fail to be created, the guest shouldn't stay frozen
+// while they are retried. It returns the VM backup with the thaw recorded, the backup falls back to be crash-consistent.
+func (h *Handler) thawOnError(vmBackup *harvesterv1.VirtualMachineBackup) *harvesterv1.VirtualMachineBackup {
+	if vmBackup.Status.FreezeTime == nil {
+		return vmBackup
+	}
+
+	if err := h.unfreezeGuest(vmBackup); err != nil {
+		// keep the freeze time so that the thaw is retried
+		h.recorder.Eventf(vmBackup, corev1.EventTypeWarning, backupUnfreezeFailedEvent, "Failed to unfreeze guest filesystems: %v", err)
+		return vmBackup
+	}
+	vmBackupCpy := vmBackup.DeepCopy()
+	vmBackupCpy.Status.FreezeTime = nil
+	vmBackupCpy.Status.Quiesced = pointer.BoolPtr(false)
+	h.recorder.Eventf(vmBackup, corev1.EventTypeWarning, backupNotQuiescedEvent, "Taking crash-consistent backup: failed to create volume snapshots while frozen")
+	return vmBackupCpy
+}
+
+// isVolumeSnapshotsCut checks if the point-in-time copies of all the volumes are taken,
+// the volume snapshots may not be ready to use until the data is uploaded to the backup target.
+func (h *Handler) isVolumeSnapshotsCut(vmBackup *harvesterv1.VirtualMachineBackup) (bool, error) {
+	for _, volumeBackup := range vmBackup.Status.VolumeBackups {
+		if volumeBackup.Name == nil {
+			continue
+		}
+		volumeSnapshot, err := h.getVolumeSnapshot(vmBackup.Namespace, *volumeBackup.Name)
+		if err != nil {
+			return false, err
+		}
+		if volumeSnapshot == nil || volumeSnapshot.Status == nil {
+			return false, nil
+		}
+		if volumeSnapshot.Status.CreationTime == nil && volumeSnapshot.Status.Error == nil {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (h *Handler) unfreezeGuest(vmBackup *harvesterv1.VirtualMachineBackup) error {
+	vmi, err := h.vmiCache.Get(vmBackup.Namespace, vmBackup.Spec.Source.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if vmi.Status.FSFreezeStatus != fsFreezeStatusFrozen {
+		return nil
+	}
+
+	if err := h.virtSubresourceRestClient.Put().Namespace(vmi.Namespace).Resource(vmiResource).SubResource(unfreezeSubresource).Name(vmi.Name).Do(h.context).Error(); err != nil {
+		logrus.Errorf("failed to thaw vm %s/%s: %v", vmi.Namespace, vmi.Name, err)
+		return err
+	}
+	return nil
+}
+
+func isGuestAgentConnected(vmi *kv1.VirtualMachineInstance) bool {
+	for _, condition := range vmi.Status.Conditions {
+		if condition.Type == kv1.VirtualMachineInstanceAgentConnected && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
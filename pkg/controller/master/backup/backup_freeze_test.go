@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	restfake "k8s.io/client-go/rest/fake"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+const (
+	freezeTestNamespace = "default"
+	freezeTestVMName    = "vm"
+)
+
+type fakeSubresourceServer struct {
+	paths      []string
+	statusCode int
+}
+
+func (s *fakeSubresourceServer) client() *restfake.RESTClient {
+	return &restfake.RESTClient{
+		NegotiatedSerializer: scheme.Codecs.WithoutConversion(),
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			s.paths = append(s.paths, req.URL.Path)
+			return &http.Response{
+				StatusCode: s.statusCode,
+				Header:     http.Header{},
+				Body:       ioutil.NopCloser(strings.NewReader("")),
+			}, nil
+		}),
+	}
+}
+
+func subresourcePath(subresource string) string {
+	return fmt.Sprintf("/namespaces/%s/%s/%s/%s", freezeTestNamespace, vmiResource, freezeTestVMName, subresource)
+}
+
+func newFreezeTestVMI(agentConnected bool, fsFreezeStatus string) *kv1.VirtualMachineInstance {
+	vmi := &kv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: freezeTestNamespace, Name: freezeTestVMName},
+		Status:     kv1.VirtualMachineInstanceStatus{FSFreezeStatus: fsFreezeStatus},
+	}
+	if agentConnected {
+		vmi.Status.Conditions = []kv1.VirtualMachineInstanceCondition{
+			{Type: kv1.VirtualMachineInstanceAgentConnected, Status: corev1.ConditionTrue},
+		}
+	}
+	return vmi
+}
+
+func newFreezeTestVMBackup(freezeTime *metav1.Time, snapshotName string) *harvesterv1.VirtualMachineBackup {
+	vmBackup := &harvesterv1.VirtualMachineBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: freezeTestNamespace, Name: "backup"},
+		Spec: harvesterv1.VirtualMachineBackupSpec{
+			Source: corev1.TypedLocalObjectReference{Kind: kv1.VirtualMachineGroupVersionKind.Kind, Name: freezeTestVMName},
+		},
+		Status: &harvesterv1.VirtualMachineBackupStatus{},
+	}
+	if freezeTime != nil {
+		vmBackup.Status.Quiesced = pointer.BoolPtr(true)
+		vmBackup.Status.FreezeTime = freezeTime
+	}
+	if snapshotName != "" {
+		vmBackup.Status.VolumeBackups = []harvesterv1.VolumeBackup{{Name: pointer.StringPtr(snapshotName)}}
+	}
+	return vmBackup
+}
+
+func newFreezeTestHandler(clientset *fake.Clientset, server *fakeSubresourceServer, recorder record.EventRecorder) *Handler {
+	return &Handler{
+		context:                   context.Background(),
+		vmBackups:                 fakeclients.VirtualMachineBackupClient(clientset.HarvesterhciV1beta1().VirtualMachineBackups),
+		vmiCache:                  fakeclients.VirtualMachineInstanceCache(clientset.KubevirtV1().VirtualMachineInstances),
+		snapshotCache:             fakeclients.VolumeSnapshotCache(clientset.SnapshotV1beta1().VolumeSnapshots),
+		recorder:                  recorder,
+		virtSubresourceRestClient: server.client(),
+	}
+}
+
+func TestFreezeGuest(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		vmi              *kv1.VirtualMachineInstance
+		expectedQuiesced bool
+		expectedPaths    []string
+		expectedEvent    string
+	}{
+		{
+			name:             "guest agent connected",
+			vmi:              newFreezeTestVMI(true, ""),
+			expectedQuiesced: true,
+			expectedPaths:    []string{subresourcePath(freezeSubresource)},
+		},
+		{
+			name:          "guest agent not connected",
+			vmi:           newFreezeTestVMI(false, ""),
+			expectedEvent: "guest agent is not connected",
+		},
+		{
+			name:          "vm not running",
+			expectedEvent: "VM is not running",
+		},
+	}
+
+	for _, tc := range testCases {
+		clientset := fake.NewSimpleClientset(newFreezeTestVMBackup(nil, ""))
+		if tc.vmi != nil {
+			assert.Nil(t, clientset.Tracker().Add(tc.vmi), tc.name)
+		}
+		server := &fakeSubresourceServer{statusCode: http.StatusOK}
+		recorder := record.NewFakeRecorder(10)
+		h := newFreezeTestHandler(clientset, server, recorder)
+
+		assert.Nil(t, h.freezeGuest(newFreezeTestVMBackup(nil, "")), tc.name)
+
+		vmBackup, err := h.vmBackups.Get(freezeTestNamespace, "backup", metav1.GetOptions{})
+		assert.Nil(t, err, tc.name)
+		assert.Equal(t, pointer.BoolPtr(tc.expectedQuiesced), vmBackup.Status.Quiesced, tc.name)
+		assert.Equal(t, tc.expectedQuiesced, vmBackup.Status.FreezeTime != nil, tc.name)
+		assert.Equal(t, tc.expectedPaths, server.paths, tc.name)
+		if tc.expectedEvent == "" {
+			assert.Empty(t, recorder.Events, tc.name)
+		} else {
+			assert.Contains(t, <-recorder.Events, tc.expectedEvent, tc.name)
+		}
+	}
+}
+
+func TestReconcileThaw(t *testing.T) {
+	var testCases = []struct {
+		name             string
+		freezeTime       time.Time
+		snapshot         *snapshotv1.VolumeSnapshot
+		expectedQuiesced bool
+	}{
+		{
+			name:       "volume snapshot cut",
+			freezeTime: time.Now(),
+			snapshot: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: freezeTestNamespace, Name: "snapshot"},
+				Status:     &snapshotv1.VolumeSnapshotStatus{CreationTime: &metav1.Time{Time: time.Now()}},
+			},
+			expectedQuiesced: true,
+		},
+		{
+			name:       "freeze timeout",
+			freezeTime: time.Now().Add(-time.Hour),
+			snapshot: &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{Namespace: freezeTestNamespace, Name: "snapshot"},
+			},
+			expectedQuiesced: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		vmBackup := newFreezeTestVMBackup(&metav1.Time{Time: tc.freezeTime}, "snapshot")
+		clientset := fake.NewSimpleClientset(vmBackup, newFreezeTestVMI(true, fsFreezeStatusFrozen), tc.snapshot)
+		server := &fakeSubresourceServer{statusCode: http.StatusOK}
+		h := newFreezeTestHandler(clientset, server, record.NewFakeRecorder(10))
+
+		updated, err := h.reconcileThaw(vmBackup)
+		assert.Nil(t, err, tc.name)
+		assert.True(t, updated, tc.name)
+
+		vmBackup, err = h.vmBackups.Get(freezeTestNamespace, "backup", metav1.GetOptions{})
+		assert.Nil(t, err, tc.name)
+		assert.Nil(t, vmBackup.Status.FreezeTime, tc.name)
+		assert.Equal(t, pointer.BoolPtr(tc.expectedQuiesced), vmBackup.Status.Quiesced, tc.name)
+		assert.Equal(t, []string{subresourcePath(unfreezeSubresource)}, server.paths, tc.name)
+	}
+}
+
+func TestThawOnError(t *testing.T) {
+	var testCases = []struct {
+		name           string
+		freezeTime     *metav1.Time
+		unfreezeStatus int
+		expectedThawed bool
+		expectedPaths  []string
+	}{
+		{
+			name:           "snapshot error while frozen",
+			freezeTime:     &metav1.Time{Time: time.Now()},
+			unfreezeStatus: http.StatusOK,
+			expectedThawed: true,
+			expectedPaths:  []string{subresourcePath(unfreezeSubresource)},
+		},
+		{
+			name:           "snapshot error while frozen and unfreeze failed",
+			freezeTime:     &metav1.Time{Time: time.Now()},
+			unfreezeStatus: http.StatusInternalServerError,
+			expectedPaths:  []string{subresourcePath(unfreezeSubresource)},
+		},
+		{
+			name:           "snapshot error while not frozen",
+			unfreezeStatus: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		vmBackup := newFreezeTestVMBackup(tc.freezeTime, "snapshot")
+		clientset := fake.NewSimpleClientset(newFreezeTestVMI(true, fsFreezeStatusFrozen))
+		server := &fakeSubresourceServer{statusCode: tc.unfreezeStatus}
+		h := newFreezeTestHandler(clientset, server, record.NewFakeRecorder(10))
+
+		result := h.thawOnError(vmBackup)
+		assert.Equal(t, tc.expectedPaths, server.paths, tc.name)
+		if tc.expectedThawed {
+			assert.Nil(t, result.Status.FreezeTime, tc.name)
+			assert.Equal(t, pointer.BoolPtr(false), result.Status.Quiesced, tc.name)
+		} else {
+			assert.Equal(t, vmBackup, result, tc.name)
+		}
+	}
+}
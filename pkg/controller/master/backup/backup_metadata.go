@@ -2,9 +2,9 @@ package backup
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"io/ioutil"
 	"path/filepath"
 	"time"
 
@@ -24,12 +24,13 @@ import (
 	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
 	ctllonghornv1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
 	"github.com/harvester/harvester/pkg/settings"
-	"github.com/harvester/harvester/pkg/util"
 )
 
 const (
 	metadataFolderPath           = "harvester/vmbackups/"
 	backupMetadataControllerName = "harvester-backup-metadata-controller"
+
+	backupTargetMetadataControllerName = "harvester-backup-target-metadata-controller"
 )
 
 type VirtualMachineBackupMetadata struct {
@@ -49,6 +50,7 @@ type MetadataHandler struct {
 	vms                  ctlkubevirtv1.VirtualMachineController
 	longhornSettingCache ctllonghornv1.SettingCache
 	settings             ctlharvesterv1.SettingController
+	backupTargets        ctlharvesterv1.BackupTargetController
 	vmBackups            ctlharvesterv1.VirtualMachineBackupClient
 	vmBackupCache        ctlharvesterv1.VirtualMachineBackupCache
 }
@@ -61,6 +63,7 @@ func RegisterBackupMetadata(ctx context.Context, management *config.Management,
 	secrets := management.CoreFactory.Core().V1().Secret()
 	longhornSettings := management.LonghornFactory.Longhorn().V1beta1().Setting()
 	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
+	backupTargets := management.HarvesterFactory.Harvesterhci().V1beta1().BackupTarget()
 
 	backupMetadataController := &MetadataHandler{
 		ctx:                  ctx,
@@ -70,11 +73,13 @@ func RegisterBackupMetadata(ctx context.Context, management *config.Management,
 		vms:                  vms,
 		longhornSettingCache: longhornSettings.Cache(),
 		settings:             settings,
+		backupTargets:        backupTargets,
 		vmBackups:            vmBackups,
 		vmBackupCache:        vmBackups.Cache(),
 	}
 
 	settings.OnChange(ctx, backupMetadataControllerName, backupMetadataController.OnBackupTargetChange)
+	backupTargets.OnChange(ctx, backupTargetMetadataControllerName, backupMetadataController.OnBackupTargetResourceChange)
 	return nil
 }
 
@@ -97,7 +102,7 @@ func (h *MetadataHandler) OnBackupTargetChange(key string, setting *harvesterv1.
 		return nil, nil
 	}
 
-	if err = h.syncVMBackup(target); err != nil {
+	if err = h.syncVMBackup(target, ""); err != nil {
 		logrus.Errorf("can't sync vm backup metadata, target:%s:%s, err: %v", target.Type, target.Endpoint, err)
 		h.settings.EnqueueAfter(setting.Name, 5*time.Second)
 		return nil, nil
@@ -106,20 +111,34 @@ func (h *MetadataHandler) OnBackupTargetChange(key string, setting *harvesterv1.
 	return nil, nil
 }
 
-func (h *MetadataHandler) syncVMBackup(target *settings.BackupTarget) error {
-	if target.Type == settings.S3BackupType {
-		secret, err := h.secretCache.Get(util.LonghornSystemNamespaceName, util.BackupTargetSecretName)
-		if err != nil {
-			return err
-		}
-		os.Setenv(AWSAccessKey, string(secret.Data[AWSAccessKey]))
-		os.Setenv(AWSSecretKey, string(secret.Data[AWSSecretKey]))
-		os.Setenv(AWSEndpoints, string(secret.Data[AWSEndpoints]))
-		os.Setenv(AWSCERT, string(secret.Data[AWSCERT]))
+// OnBackupTargetResourceChange resync vm metadata files of the BackupTarget once it's available
+func (h *MetadataHandler) OnBackupTargetResourceChange(key string, backupTarget *harvesterv1.BackupTarget) (*harvesterv1.BackupTarget, error) {
+	if backupTarget == nil || backupTarget.DeletionTimestamp != nil ||
+		!harvesterv1.BackupTargetConditionAvailable.IsTrue(backupTarget) {
+		return nil, nil
 	}
 
-	endpoint := ConstructEndpoint(target)
-	bsDriver, err := backupstore.GetBackupStoreDriver(endpoint)
+	target, err := getBackupTargetFromResource(h.secretCache, backupTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = h.syncVMBackup(target, backupTarget.Name); err != nil {
+		logrus.Errorf("can't sync vm backup metadata, backup target:%s, err: %v", backupTarget.Name, err)
+		h.backupTargets.EnqueueAfter(backupTarget.Name, 5*time.Second)
+	}
+	return nil, nil
+}
+
+// syncVMBackup creates the VM backups found in the backup target, backupTargetName is the name of
+// the BackupTarget or empty for the backup-target setting.
+func (h *MetadataHandler) syncVMBackup(target *settings.BackupTarget, backupTargetName string) error {
+	bsDriver, err := newBackupStoreDriver(h.secretCache, target)
+	if err != nil {
+		return err
+	}
+
+	key, err := getBackupEncryptionKey(h.secretCache, target)
 	if err != nil {
 		return err
 	}
@@ -130,21 +149,25 @@ func (h *MetadataHandler) syncVMBackup(target *settings.BackupTarget) error {
 	}
 
 	for _, fileName := range fileNames {
-		backupMetadata, err := loadBackupMetadataInBackupTarget(filepath.Join(metadataFolderPath, fileName), bsDriver)
-		if err != nil {
+		backupMetadata, err := loadBackupMetadataInBackupTarget(filepath.Join(metadataFolderPath, fileName), bsDriver, key)
+		if errors.Is(err, errBackupMetadataDecryption) {
+			// the metadata may be encrypted by another cluster with a different key
+			logrus.Warnf("skip syncing vm backup metadata %s: %v", fileName, err)
+			continue
+		} else if err != nil {
 			return err
 		}
 		if backupMetadata.Namespace == "" {
 			backupMetadata.Namespace = metav1.NamespaceDefault
 		}
-		if err := h.createVMBackupIfNotExist(*backupMetadata, target); err != nil {
+		if err := h.createVMBackupIfNotExist(*backupMetadata, target, backupTargetName); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (h *MetadataHandler) createVMBackupIfNotExist(backupMetadata VirtualMachineBackupMetadata, target *settings.BackupTarget) error {
+func (h *MetadataHandler) createVMBackupIfNotExist(backupMetadata VirtualMachineBackupMetadata, target *settings.BackupTarget, backupTargetName string) error {
 	if _, err := h.vmBackupCache.Get(backupMetadata.Namespace, backupMetadata.Name); err != nil && !apierrors.IsNotFound(err) {
 		return err
 	} else if err == nil {
@@ -162,7 +185,8 @@ func (h *MetadataHandler) createVMBackupIfNotExist(backupMetadata VirtualMachine
 		Spec: backupMetadata.BackupSpec,
 		Status: &harvesterv1.VirtualMachineBackupStatus{
 			ReadyToUse: pointer.BoolPtr(false),
-			BackupTarget: &harvesterv1.BackupTarget{
+			BackupTarget: &harvesterv1.BackupTargetLocation{
+				Name:         backupTargetName,
 				Endpoint:     target.Endpoint,
 				BucketName:   target.BucketName,
 				BucketRegion: target.BucketRegion,
@@ -192,20 +216,27 @@ func (h *MetadataHandler) createNamespaceIfNotExist(namespace string) error {
 	return err
 }
 
-func loadBackupMetadataInBackupTarget(filePath string, bsDriver backupstore.BackupStoreDriver) (*VirtualMachineBackupMetadata, error) {
+func loadBackupMetadataInBackupTarget(filePath string, bsDriver backupstore.BackupStoreDriver, key []byte) (*VirtualMachineBackupMetadata, error) {
+	backupMetadata, _, err := readBackupMetadataInBackupTarget(filePath, bsDriver, key)
+	return backupMetadata, err
+}
+
+// readBackupMetadataInBackupTarget loads the metadata file and decrypts it if it's encrypted,
+// it also returns whether the metadata file is encrypted.
+func readBackupMetadataInBackupTarget(filePath string, bsDriver backupstore.BackupStoreDriver, key []byte) (*VirtualMachineBackupMetadata, bool, error) {
 	if !bsDriver.FileExists(filePath) {
-		return nil, fmt.Errorf("cannot find %v in backupstore", filePath)
+		return nil, false, fmt.Errorf("cannot find %v in backupstore", filePath)
 	}
 
 	rc, err := bsDriver.Read(filePath)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	defer rc.Close()
 
-	backupMetadata := &VirtualMachineBackupMetadata{}
-	if err := json.NewDecoder(rc).Decode(backupMetadata); err != nil {
-		return nil, err
+	data, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, false, err
 	}
-	return backupMetadata, nil
+	return decodeBackupMetadata(data, key, filePath)
 }
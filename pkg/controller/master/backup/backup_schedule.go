@@ -0,0 +1,400 @@
+package backup
+
+// Harvester VM backup schedule controller creates VM backups of the selected VMs on a cron schedule,
+// and prunes the outdated backups created by the schedule according to the retention policy.
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	wranglername "github.com/rancher/wrangler/pkg/name"
+	"github.com/robfig/cron"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/settings"
+)
+
+const (
+	backupScheduleControllerName          = "harvester-vm-backup-schedule-controller"
+	backupScheduleBackupControllerName    = "harvester-vm-backup-schedule-backup-controller"
+	backupScheduleLabel                   = "backup.harvesterhci.io/schedule"
+	backupScheduleTimeFormat              = "20060102150405"
+	scheduledBackupCreateEvent            = "ScheduledBackupCreated"
+	scheduledBackupFailedEvent            = "ScheduledBackupFailed"
+	scheduledBackupPrunedEvent            = "ScheduledBackupPruned"
+	backupScheduleInvalidScheduleReason   = "InvalidSchedule"
+	backupScheduleBackupFailedReason      = "BackupFailed"
+	backupScheduleBackupTargetNotSetError = "backup target is not configured"
+)
+
+// RegisterBackupSchedule register the vm backup schedule controller
+func RegisterBackupSchedule(ctx context.Context, management *config.Management, opts config.Options) error {
+	schedules := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackupSchedule()
+	vmBackups := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
+	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
+	settings := management.HarvesterFactory.Harvesterhci().V1beta1().Setting()
+
+	scheduleHandler := &ScheduleHandler{
+		schedules:          schedules,
+		scheduleController: schedules,
+		vmBackups:          vmBackups,
+		vmBackupCache:      vmBackups.Cache(),
+		vmCache:            vms.Cache(),
+		settingCache:       settings.Cache(),
+		recorder:           management.NewRecorder(backupScheduleControllerName, "", ""),
+	}
+
+	schedules.OnChange(ctx, backupScheduleControllerName, scheduleHandler.OnBackupScheduleChange)
+	vmBackups.OnChange(ctx, backupScheduleBackupControllerName, scheduleHandler.OnScheduledBackupChange)
+	return nil
+}
+
+type ScheduleHandler struct {
+	schedules          ctlharvesterv1.VirtualMachineBackupScheduleClient
+	scheduleController ctlharvesterv1.VirtualMachineBackupScheduleController
+	vmBackups          ctlharvesterv1.VirtualMachineBackupClient
+	vmBackupCache      ctlharvesterv1.VirtualMachineBackupCache
+	vmCache            ctlkubevirtv1.VirtualMachineCache
+	settingCache       ctlharvesterv1.SettingCache
+	recorder           record.EventRecorder
+}
+
+// OnBackupScheduleChange creates VM backups when the schedule is due, prunes the outdated backups
+// and requeues the schedule at its next run.
+func (h *ScheduleHandler) OnBackupScheduleChange(key string, schedule *harvesterv1.VirtualMachineBackupSchedule) (*harvesterv1.VirtualMachineBackupSchedule, error) {
+	if schedule == nil || schedule.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	scheduleCpy := schedule.DeepCopy()
+	if scheduleCpy.Status == nil {
+		scheduleCpy.Status = &harvesterv1.VirtualMachineBackupScheduleStatus{}
+	}
+
+	cronSchedule, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		scheduleCpy.Status.NextScheduleTime = nil
+		updateBackupScheduleCondition(scheduleCpy, newBackupScheduleReadyCondition(corev1.ConditionFalse, backupScheduleInvalidScheduleReason,
+			fmt.Sprintf("invalid schedule %q: %v", schedule.Spec.Schedule, err)))
+		return h.updateStatus(schedule, scheduleCpy)
+	}
+
+	now := currentTime()
+	if !schedule.Spec.Suspend && !now.Time.Before(getNextScheduleTime(cronSchedule, schedule).Time) {
+		scheduleCpy.Status.LastScheduleTime = now
+		if err := h.createScheduledBackups(schedule, now); err != nil {
+			logrus.Debugf("schedule %s/%s failed to create backups: %v", schedule.Namespace, schedule.Name, err)
+			h.recorder.Event(schedule, corev1.EventTypeWarning, scheduledBackupFailedEvent, err.Error())
+			setBackupScheduleFailure(scheduleCpy, now, err.Error())
+		}
+	}
+
+	backups, err := h.vmBackupCache.List(schedule.Namespace, labels.SelectorFromSet(map[string]string{
+		backupScheduleLabel: schedule.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	syncBackupScheduleStatus(scheduleCpy, backups)
+
+	if err := h.pruneBackups(schedule, getBackupsToPrune(backups, schedule.Spec.Retain, schedule.Spec.MaxAge, now.Time)); err != nil {
+		return nil, err
+	}
+
+	if schedule.Spec.Suspend {
+		scheduleCpy.Status.NextScheduleTime = nil
+	} else {
+		scheduleCpy.Status.NextScheduleTime = getNextScheduleTime(cronSchedule, scheduleCpy)
+		h.scheduleController.EnqueueAfter(schedule.Namespace, schedule.Name, scheduleCpy.Status.NextScheduleTime.Sub(now.Time))
+	}
+
+	if scheduleCpy.Status.LastFailure == nil || (scheduleCpy.Status.LastSuccessfulTime != nil && !scheduleCpy.Status.LastSuccessfulTime.Before(scheduleCpy.Status.LastFailure.Time)) {
+		updateBackupScheduleCondition(scheduleCpy, newBackupScheduleReadyCondition(corev1.ConditionTrue, "", ""))
+	} else {
+		updateBackupScheduleCondition(scheduleCpy, newBackupScheduleReadyCondition(corev1.ConditionFalse, backupScheduleBackupFailedReason, *scheduleCpy.Status.LastFailure.Message))
+	}
+
+	return h.updateStatus(schedule, scheduleCpy)
+}
+
+// OnScheduledBackupChange enqueues the schedule which created the vm backup, so the schedule status
+// is refreshed as soon as the backup completes or fails.
+func (h *ScheduleHandler) OnScheduledBackupChange(key string, vmBackup *harvesterv1.VirtualMachineBackup) (*harvesterv1.VirtualMachineBackup, error) {
+	if vmBackup == nil || vmBackup.Labels[backupScheduleLabel] == "" {
+		return nil, nil
+	}
+
+	if isBackupReady(vmBackup) || GetVMBackupError(vmBackup) != nil {
+		h.scheduleController.Enqueue(vmBackup.Namespace, vmBackup.Labels[backupScheduleLabel])
+	}
+	return nil, nil
+}
+
+func (h *ScheduleHandler) updateStatus(schedule, scheduleCpy *harvesterv1.VirtualMachineBackupSchedule) (*harvesterv1.VirtualMachineBackupSchedule, error) {
+	if reflect.DeepEqual(schedule.Status, scheduleCpy.Status) {
+		return schedule, nil
+	}
+	return h.schedules.Update(scheduleCpy)
+}
+
+// createScheduledBackups creates a backup for each VM selected by the schedule, VMs whose previous
+// scheduled backup is still in progress are skipped.
+func (h *ScheduleHandler) createScheduledBackups(schedule *harvesterv1.VirtualMachineBackupSchedule, now *metav1.Time) error {
+	if err := h.checkBackupTargetConfigured(); err != nil {
+		return err
+	}
+
+	vms, err := h.getScheduledVMs(schedule)
+	if err != nil {
+		return err
+	}
+	if len(vms) == 0 {
+		return fmt.Errorf("no VM is selected by the schedule")
+	}
+
+	var errs []string
+	for _, vm := range vms {
+		inProgress, err := h.hasBackupInProgress(schedule, vm.Name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if inProgress {
+			errs = append(errs, fmt.Sprintf("previous backup of VM %s is still in progress", vm.Name))
+			continue
+		}
+
+		backup, err := h.vmBackups.Create(newScheduledBackup(schedule, vm, now))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to create backup of VM %s: %v", vm.Name, err))
+			continue
+		}
+		h.recorder.Eventf(schedule, corev1.EventTypeNormal, scheduledBackupCreateEvent, "Successfully created VM backup %s", backup.Name)
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// checkBackupTargetConfigured checks the backup-target setting, the backups are always taken to it
+func (h *ScheduleHandler) checkBackupTargetConfigured() error {
+	targetSetting, err := h.settingCache.Get(settings.BackupTargetSettingName)
+	if err != nil {
+		return err
+	}
+	target, err := settings.DecodeBackupTarget(targetSetting.Value)
+	if err != nil {
+		return err
+	}
+	if !harvesterv1.SettingConfigured.IsTrue(targetSetting) || target.IsDefaultBackupTarget() {
+		return errors.New(backupScheduleBackupTargetNotSetError)
+	}
+	return nil
+}
+
+// getScheduledVMs returns the VMs matched by either spec.vmNames or spec.selector
+func (h *ScheduleHandler) getScheduledVMs(schedule *harvesterv1.VirtualMachineBackupSchedule) ([]*kv1.VirtualMachine, error) {
+	vmMap := map[string]*kv1.VirtualMachine{}
+	for _, name := range schedule.Spec.VMNames {
+		vm, err := h.vmCache.Get(schedule.Namespace, name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				logrus.Debugf("schedule %s/%s: VM %s not found, skip", schedule.Namespace, schedule.Name, name)
+				continue
+			}
+			return nil, err
+		}
+		vmMap[vm.Name] = vm
+	}
+
+	if schedule.Spec.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(schedule.Spec.Selector)
+		if err != nil {
+			return nil, err
+		}
+		vms, err := h.vmCache.List(schedule.Namespace, selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, vm := range vms {
+			vmMap[vm.Name] = vm
+		}
+	}
+
+	result := make([]*kv1.VirtualMachine, 0, len(vmMap))
+	for _, vm := range vmMap {
+		if vm.DeletionTimestamp != nil {
+			continue
+		}
+		result = append(result, vm)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (h *ScheduleHandler) hasBackupInProgress(schedule *harvesterv1.VirtualMachineBackupSchedule, vmName string) (bool, error) {
+	backups, err := h.vmBackupCache.List(schedule.Namespace, labels.SelectorFromSet(map[string]string{
+		backupScheduleLabel: schedule.Name,
+	}))
+	if err != nil {
+		return false, err
+	}
+	for _, backup := range backups {
+		if backup.Spec.Source.Name == vmName && backup.DeletionTimestamp == nil && IsBackupProgressing(backup) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h *ScheduleHandler) pruneBackups(schedule *harvesterv1.VirtualMachineBackupSchedule, backups []*harvesterv1.VirtualMachineBackup) error {
+	for _, backup := range backups {
+		logrus.Debugf("schedule %s/%s prunes vm backup %s", schedule.Namespace, schedule.Name, backup.Name)
+		if err := h.vmBackups.Delete(backup.Namespace, backup.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		h.recorder.Eventf(schedule, corev1.EventTypeNormal, scheduledBackupPrunedEvent, "Pruned VM backup %s", backup.Name)
+	}
+	return nil
+}
+
+func newScheduledBackup(schedule *harvesterv1.VirtualMachineBackupSchedule, vm *kv1.VirtualMachine, now *metav1.Time) *harvesterv1.VirtualMachineBackup {
+	apiGroup := kv1.SchemeGroupVersion.Group
+	return &harvesterv1.VirtualMachineBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      wranglername.SafeConcatName(schedule.Name, vm.Name, now.UTC().Format(backupScheduleTimeFormat)),
+			Namespace: schedule.Namespace,
+			Labels: map[string]string{
+				backupScheduleLabel: schedule.Name,
+			},
+		},
+		Spec: harvesterv1.VirtualMachineBackupSpec{
+			Source: corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     kv1.VirtualMachineGroupVersionKind.Kind,
+				Name:     vm.Name,
+			},
+		},
+	}
+}
+
+// getNextScheduleTime returns the first scheduled time after the last run,
+// or after the creation of the schedule if it has never run.
+func getNextScheduleTime(cronSchedule cron.Schedule, schedule *harvesterv1.VirtualMachineBackupSchedule) *metav1.Time {
+	last := schedule.CreationTimestamp
+	if schedule.Status != nil && schedule.Status.LastScheduleTime != nil {
+		last = *schedule.Status.LastScheduleTime
+	}
+	return &metav1.Time{Time: cronSchedule.Next(last.Time)}
+}
+
+// syncBackupScheduleStatus records the newest successful backup and the newest failed backup of the schedule.
+func syncBackupScheduleStatus(schedule *harvesterv1.VirtualMachineBackupSchedule, backups []*harvesterv1.VirtualMachineBackup) {
+	for _, backup := range backups {
+		if isBackupReady(backup) && backup.Status.CreationTime != nil {
+			if schedule.Status.LastSuccessfulTime == nil || schedule.Status.LastSuccessfulTime.Before(backup.Status.CreationTime) {
+				schedule.Status.LastSuccessfulTime = backup.Status.CreationTime.DeepCopy()
+			}
+			continue
+		}
+
+		backupErr := GetVMBackupError(backup)
+		if backupErr == nil || backupErr.Time == nil || backupErr.Message == nil {
+			continue
+		}
+		if schedule.Status.LastFailure == nil || schedule.Status.LastFailure.Time.Before(backupErr.Time) {
+			setBackupScheduleFailure(schedule, backupErr.Time, fmt.Sprintf("backup %s failed: %s", backup.Name, *backupErr.Message))
+		}
+	}
+}
+
+func setBackupScheduleFailure(schedule *harvesterv1.VirtualMachineBackupSchedule, t *metav1.Time, message string) {
+	schedule.Status.LastFailure = &harvesterv1.Error{
+		Time:    t.DeepCopy(),
+		Message: pointer.StringPtr(message),
+	}
+}
+
+// getBackupsToPrune returns the backups that should be deleted by the retention policy.
+// For each VM, ready backups beyond the retain count or older than maxAge are pruned,
+// but the newest ready backup is always kept. Failed backups older than the newest ready
+// backup are pruned as well. Backups in progress are never touched.
+func getBackupsToPrune(backups []*harvesterv1.VirtualMachineBackup, retain int, maxAge *metav1.Duration, now time.Time) []*harvesterv1.VirtualMachineBackup {
+	backupsByVM := map[string][]*harvesterv1.VirtualMachineBackup{}
+	for _, backup := range backups {
+		if backup.DeletionTimestamp != nil {
+			continue
+		}
+		backupsByVM[backup.Spec.Source.Name] = append(backupsByVM[backup.Spec.Source.Name], backup)
+	}
+
+	var toPrune []*harvesterv1.VirtualMachineBackup
+	for _, vmBackups := range backupsByVM {
+		var ready, failed []*harvesterv1.VirtualMachineBackup
+		for _, backup := range vmBackups {
+			if isBackupReady(backup) {
+				ready = append(ready, backup)
+			} else if GetVMBackupError(backup) != nil {
+				failed = append(failed, backup)
+			}
+		}
+		if len(ready) == 0 {
+			continue
+		}
+
+		// newest first
+		sort.Slice(ready, func(i, j int) bool {
+			return ready[j].CreationTimestamp.Before(&ready[i].CreationTimestamp)
+		})
+		for i, backup := range ready[1:] {
+			if (retain > 0 && i+1 >= retain) || (maxAge != nil && now.Sub(backup.CreationTimestamp.Time) > maxAge.Duration) {
+				toPrune = append(toPrune, backup)
+			}
+		}
+
+		for _, backup := range failed {
+			if backup.CreationTimestamp.Before(&ready[0].CreationTimestamp) {
+				toPrune = append(toPrune, backup)
+			}
+		}
+	}
+
+	sort.Slice(toPrune, func(i, j int) bool {
+		return toPrune[i].Name < toPrune[j].Name
+	})
+	return toPrune
+}
+
+func newBackupScheduleReadyCondition(status corev1.ConditionStatus, reason string, message string) harvesterv1.Condition {
+	return harvesterv1.Condition{
+		Type:               harvesterv1.BackupScheduleConditionReady,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastTransitionTime: currentTime().Format(time.RFC3339),
+	}
+}
+
+func updateBackupScheduleCondition(schedule *harvesterv1.VirtualMachineBackupSchedule, c harvesterv1.Condition) {
+	schedule.Status.Conditions = updateCondition(schedule.Status.Conditions, c)
+}
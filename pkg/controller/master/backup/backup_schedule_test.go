@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+var testScheduleNow = time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestScheduledBackup(name, vmName string, age time.Duration, ready bool, failed bool) *harvesterv1.VirtualMachineBackup {
+	backup := &harvesterv1.VirtualMachineBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(testScheduleNow.Add(-age)),
+		},
+		Spec: harvesterv1.VirtualMachineBackupSpec{},
+		Status: &harvesterv1.VirtualMachineBackupStatus{
+			ReadyToUse: pointer.BoolPtr(ready),
+		},
+	}
+	backup.Spec.Source.Name = vmName
+	if failed {
+		backup.Status.Error = &harvesterv1.Error{
+			Time:    &backup.CreationTimestamp,
+			Message: pointer.StringPtr("failed"),
+		}
+	}
+	return backup
+}
+
+func backupNames(backups []*harvesterv1.VirtualMachineBackup) []string {
+	names := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		names = append(names, backup.Name)
+	}
+	return names
+}
+
+func TestGetBackupsToPrune(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		backups  []*harvesterv1.VirtualMachineBackup
+		retain   int
+		maxAge   *metav1.Duration
+		expected []string
+	}{
+		{
+			name: "no retention policy",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 3*time.Hour, true, false),
+				newTestScheduledBackup("vm1-2", "vm1", 2*time.Hour, true, false),
+			},
+			expected: []string{},
+		},
+		{
+			name: "retain count per VM",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 3*time.Hour, true, false),
+				newTestScheduledBackup("vm1-2", "vm1", 2*time.Hour, true, false),
+				newTestScheduledBackup("vm1-3", "vm1", 1*time.Hour, true, false),
+				newTestScheduledBackup("vm2-1", "vm2", 3*time.Hour, true, false),
+				newTestScheduledBackup("vm2-2", "vm2", 2*time.Hour, true, false),
+			},
+			retain:   2,
+			expected: []string{"vm1-1"},
+		},
+		{
+			name: "max age keeps the newest ready backup",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 72*time.Hour, true, false),
+				newTestScheduledBackup("vm1-2", "vm1", 48*time.Hour, true, false),
+			},
+			maxAge:   &metav1.Duration{Duration: 24 * time.Hour},
+			expected: []string{"vm1-1"},
+		},
+		{
+			name: "in progress backups are not counted nor pruned",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 3*time.Hour, true, false),
+				newTestScheduledBackup("vm1-2", "vm1", 2*time.Hour, true, false),
+				newTestScheduledBackup("vm1-3", "vm1", 1*time.Hour, false, false),
+			},
+			retain:   1,
+			expected: []string{"vm1-1"},
+		},
+		{
+			name: "failed backups older than the newest ready backup are pruned",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 3*time.Hour, false, true),
+				newTestScheduledBackup("vm1-2", "vm1", 2*time.Hour, true, false),
+				newTestScheduledBackup("vm1-3", "vm1", 1*time.Hour, false, true),
+			},
+			expected: []string{"vm1-1"},
+		},
+		{
+			name: "failed backups are kept when there is no ready backup",
+			backups: []*harvesterv1.VirtualMachineBackup{
+				newTestScheduledBackup("vm1-1", "vm1", 3*time.Hour, false, true),
+			},
+			retain:   1,
+			expected: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := getBackupsToPrune(tc.backups, tc.retain, tc.maxAge, testScheduleNow)
+		assert.Equal(t, tc.expected, backupNames(actual), "case %q", tc.name)
+	}
+}
+
+func TestGetNextScheduleTime(t *testing.T) {
+	cronSchedule, err := cron.ParseStandard("0 2 * * *")
+	assert.Nil(t, err)
+
+	schedule := &harvesterv1.VirtualMachineBackupSchedule{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(testScheduleNow),
+		},
+	}
+	assert.Equal(t, time.Date(2022, 3, 11, 2, 0, 0, 0, time.UTC), getNextScheduleTime(cronSchedule, schedule).UTC())
+
+	lastScheduleTime := metav1.NewTime(time.Date(2022, 3, 12, 2, 0, 0, 0, time.UTC))
+	schedule.Status = &harvesterv1.VirtualMachineBackupScheduleStatus{
+		LastScheduleTime: &lastScheduleTime,
+	}
+	assert.Equal(t, time.Date(2022, 3, 13, 2, 0, 0, 0, time.UTC), getNextScheduleTime(cronSchedule, schedule).UTC())
+}
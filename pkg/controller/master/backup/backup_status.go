@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	lhv1beta1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta1"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -15,6 +17,7 @@ import (
 	kubevirtv1 "kubevirt.io/client-go/api/v1"
 
 	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctllonghornv1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
 	"github.com/harvester/harvester/pkg/util"
 )
 
@@ -105,19 +108,24 @@ func (h *Handler) resolveVolSnapshotRef(namespace string, controllerRef *metav1.
 }
 
 // mountLonghornVolumes helps to mount the volumes to host if it is detached
-func (h *Handler) mountLonghornVolumes(vm *kubevirtv1.VirtualMachine) error {
+func mountLonghornVolumes(
+	pvcCache ctlcorev1.PersistentVolumeClaimCache,
+	volumeCache ctllonghornv1.VolumeCache,
+	volumes ctllonghornv1.VolumeClient,
+	vm *kubevirtv1.VirtualMachine,
+) error {
 	for _, vol := range vm.Spec.Template.Spec.Volumes {
 		if vol.PersistentVolumeClaim == nil {
 			continue
 		}
 		name := vol.PersistentVolumeClaim.ClaimName
 
-		pvc, err := h.pvcCache.Get(vm.Namespace, name)
+		pvc, err := pvcCache.Get(vm.Namespace, name)
 		if err != nil {
 			return fmt.Errorf("failed to get pvc %s/%s, error: %s", name, vm.Namespace, err.Error())
 		}
 
-		volume, err := h.volumeCache.Get(util.LonghornSystemNamespaceName, pvc.Spec.VolumeName)
+		volume, err := volumeCache.Get(util.LonghornSystemNamespaceName, pvc.Spec.VolumeName)
 		if err != nil {
 			return fmt.Errorf("failed to get volume %s/%s, error: %s", name, vm.Namespace, err.Error())
 		}
@@ -129,7 +137,7 @@ func (h *Handler) mountLonghornVolumes(vm *kubevirtv1.VirtualMachine) error {
 
 		if !reflect.DeepEqual(volCpy, volume) {
 			logrus.Infof("mount detached volume %s to the node %s", volCpy.Name, volCpy.Spec.NodeID)
-			if _, err = h.volumes.Update(volCpy); err != nil {
+			if _, err = volumes.Update(volCpy); err != nil {
 				return err
 			}
 		}
@@ -167,12 +175,17 @@ func (h *Handler) OnLHBackupChanged(key string, lhBackup *lhv1beta1.Backup) (*lh
 			return nil, nil
 		}
 
+		now := time.Now()
 		vmBackupCpy := vmBackup.DeepCopy()
+		progresses := make([]*harvesterv1.TransferProgress, 0, len(vmBackupCpy.Status.VolumeBackups))
 		for i, volumeBackup := range vmBackupCpy.Status.VolumeBackups {
 			if *volumeBackup.Name == snapshot.Name {
 				vmBackupCpy.Status.VolumeBackups[i].LonghornBackupName = pointer.StringPtr(lhBackup.Name)
+				vmBackupCpy.Status.VolumeBackups[i].Progress = getBackupProgress(lhBackup, volumeBackup.Progress, now)
 			}
+			progresses = append(progresses, vmBackupCpy.Status.VolumeBackups[i].Progress)
 		}
+		vmBackupCpy.Status.Progress = aggregateProgress(progresses, vmBackup.Status.Progress, now)
 
 		if !reflect.DeepEqual(vmBackup.Status, vmBackupCpy.Status) {
 			if _, err := h.vmBackups.Update(vmBackupCpy); err != nil {
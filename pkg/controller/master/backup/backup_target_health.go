@@ -0,0 +1,147 @@
+package backup
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/longhorn/backupstore"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	backupTargetHealthControllerName = "harvester-backup-target-health-controller"
+
+	backupTargetHealthCheckInterval = time.Minute
+	backupTargetUnavailableReason   = "Unreachable"
+)
+
+// RegisterBackupTargetHealth register the BackupTarget controller which checks the reachability of the backup targets periodically
+func RegisterBackupTargetHealth(ctx context.Context, management *config.Management, opts config.Options) error {
+	backupTargets := management.HarvesterFactory.Harvesterhci().V1beta1().BackupTarget()
+	secrets := management.CoreFactory.Core().V1().Secret()
+
+	backupTargetHealthController := &TargetHealthHandler{
+		backupTargets:          backupTargets,
+		backupTargetController: backupTargets,
+		secretCache:            secrets.Cache(),
+	}
+
+	backupTargets.OnChange(ctx, backupTargetHealthControllerName, backupTargetHealthController.OnBackupTargetChange)
+	return nil
+}
+
+type TargetHealthHandler struct {
+	backupTargets          ctlharvesterv1.BackupTargetClient
+	backupTargetController ctlharvesterv1.BackupTargetController
+	secretCache            ctlcorev1.SecretCache
+}
+
+// OnBackupTargetChange checks if the backup target is reachable and requeues it for the next check
+func (h *TargetHealthHandler) OnBackupTargetChange(key string, backupTarget *harvesterv1.BackupTarget) (*harvesterv1.BackupTarget, error) {
+	if backupTarget == nil || backupTarget.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	defer h.backupTargetController.EnqueueAfter(backupTarget.Name, backupTargetHealthCheckInterval)
+
+	backupTargetCpy := backupTarget.DeepCopy()
+	err := checkBackupTargetReachable(h.secretCache, backupTarget)
+	if err != nil {
+		logrus.Debugf("backup target %s is unavailable: %v", backupTarget.Name, err)
+		harvesterv1.BackupTargetConditionAvailable.SetError(backupTargetCpy, backupTargetUnavailableReason, err)
+	} else {
+		harvesterv1.BackupTargetConditionAvailable.SetError(backupTargetCpy, "", nil)
+	}
+
+	if !reflect.DeepEqual(backupTarget.Status, backupTargetCpy.Status) {
+		return h.backupTargets.Update(backupTargetCpy)
+	}
+	return backupTarget, nil
+}
+
+func checkBackupTargetReachable(secretCache ctlcorev1.SecretCache, backupTarget *harvesterv1.BackupTarget) error {
+	target, err := getBackupTargetFromResource(secretCache, backupTarget)
+	if err != nil {
+		return err
+	}
+
+	bsDriver, err := newBackupStoreDriver(secretCache, target)
+	if err != nil {
+		return err
+	}
+
+	_, err = bsDriver.List("")
+	return err
+}
+
+// getBackupTargetFromResource converts the BackupTarget to the form of the backup-target setting,
+// with the S3 credentials read from its credential secret.
+func getBackupTargetFromResource(secretCache ctlcorev1.SecretCache, backupTarget *harvesterv1.BackupTarget) (*settings.BackupTarget, error) {
+	target := backupTargetFromSpec(backupTarget)
+	if secretRef := backupTarget.Spec.CredentialSecret; secretRef != nil {
+		secret, err := secretCache.Get(secretRef.Namespace, secretRef.Name)
+		if err != nil {
+			return nil, err
+		}
+		target.AccessKeyID = string(secret.Data[AWSAccessKey])
+		target.SecretAccessKey = string(secret.Data[AWSSecretKey])
+		target.Cert = string(secret.Data[AWSCERT])
+	}
+	return target, nil
+}
+
+func backupTargetFromSpec(backupTarget *harvesterv1.BackupTarget) *settings.BackupTarget {
+	return &settings.BackupTarget{
+		Type:                settings.TargetType(backupTarget.Spec.Type),
+		Endpoint:            backupTarget.Spec.Endpoint,
+		BucketName:          backupTarget.Spec.BucketName,
+		BucketRegion:        backupTarget.Spec.BucketRegion,
+		VirtualHostedStyle:  backupTarget.Spec.VirtualHostedStyle,
+		EncryptionKeySecret: backupTarget.Spec.EncryptionKeySecret,
+	}
+}
+
+// newBackupStoreDriver returns the backupstore driver of the target. The S3 credentials of the backup-target
+// setting are stripped once saved to the longhorn backup target secret, so they are read from the secret then.
+// The S3 driver takes the credentials explicitly rather than from the process environment, since the targets
+// are accessed concurrently with different credentials.
+func newBackupStoreDriver(secretCache ctlcorev1.SecretCache, target *settings.BackupTarget) (backupstore.BackupStoreDriver, error) {
+	if target.Type != settings.S3BackupType {
+		return backupstore.GetBackupStoreDriver(ConstructEndpoint(target))
+	}
+
+	var data map[string]string
+	if target.AccessKeyID == "" && target.SecretAccessKey == "" {
+		secret, err := secretCache.Get(util.LonghornSystemNamespaceName, util.BackupTargetSecretName)
+		if err != nil {
+			return nil, err
+		}
+		data = make(map[string]string, len(secret.Data))
+		for key, value := range secret.Data {
+			data[key] = string(value)
+		}
+	} else {
+		var err error
+		if data, err = getBackupSecretData(target); err != nil {
+			return nil, err
+		}
+	}
+	return newS3BackupStoreDriver(target.BucketName, target.BucketRegion, data)
+}
+
+// isSameBackupStore checks if the two backup targets point to the same backup store
+func isSameBackupStore(a, b *settings.BackupTarget) bool {
+	if a.Type != b.Type || ConstructEndpoint(a) != ConstructEndpoint(b) {
+		return false
+	}
+	// the endpoint of S3 isn't part of the longhorn backup target URL
+	return a.Type != settings.S3BackupType || a.Endpoint == b.Endpoint
+}
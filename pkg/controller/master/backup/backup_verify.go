@@ -0,0 +1,296 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/longhorn/backupstore"
+	bsutil "github.com/longhorn/backupstore/util"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctllonghornv1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	backupVerifyControllerName = "harvester-vm-backup-verify-controller"
+
+	// VerifyRequestedAnnotation is set to the time when the verification of a VM backup is requested
+	VerifyRequestedAnnotation = "backup.harvesterhci.io/verify-requested"
+
+	backupVerifyFailedReason = "VerificationFailed"
+	backupVerifyFailedEvent  = "VirtualMachineBackupVerifyFailed"
+)
+
+// RegisterBackupVerify register the VM backup verify controller which checks the ready VM backups are restorable
+// from the backup target, when the verification is requested or periodically.
+func RegisterBackupVerify(ctx context.Context, management *config.Management, opts config.Options) error {
+	vmBackups := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
+	backupTargets := management.HarvesterFactory.Harvesterhci().V1beta1().BackupTarget()
+	secrets := management.CoreFactory.Core().V1().Secret()
+	lhbackups := management.LonghornFactory.Longhorn().V1beta1().Backup()
+
+	backupVerifyController := &VerifyHandler{
+		vmBackups:          vmBackups,
+		vmBackupController: vmBackups,
+		backupTargetCache:  backupTargets.Cache(),
+		secretCache:        secrets.Cache(),
+		lhbackupCache:      lhbackups.Cache(),
+		recorder:           management.NewRecorder(backupVerifyControllerName, "", ""),
+	}
+
+	vmBackups.OnChange(ctx, backupVerifyControllerName, backupVerifyController.OnBackupChange)
+	return nil
+}
+
+type VerifyHandler struct {
+	vmBackups          ctlharvesterv1.VirtualMachineBackupClient
+	vmBackupController ctlharvesterv1.VirtualMachineBackupController
+	backupTargetCache  ctlharvesterv1.BackupTargetCache
+	secretCache        ctlcorev1.SecretCache
+	lhbackupCache      ctllonghornv1.BackupCache
+	recorder           record.EventRecorder
+
+	// spreadStart is the time from which the first verifications of the backups are spread over the interval,
+	// it's reset when the periodic verification is disabled.
+	spreadMutex sync.Mutex
+	spreadStart time.Time
+}
+
+// OnBackupChange verifies the VM backup if it's requested or the backup-verify-interval has passed since the last verification
+func (h *VerifyHandler) OnBackupChange(key string, vmBackup *harvesterv1.VirtualMachineBackup) (*harvesterv1.VirtualMachineBackup, error) {
+	if vmBackup == nil || vmBackup.DeletionTimestamp != nil || !isBackupReady(vmBackup) {
+		return nil, nil
+	}
+
+	interval := time.Duration(settings.BackupVerifyInterval.GetInt()) * time.Hour
+	spreadStart := h.getSpreadStart(interval)
+	if !isVerifyRequested(vmBackup) {
+		if interval <= 0 {
+			return nil, nil
+		}
+		if vmBackup.Status.VerifyTime != nil {
+			if elapsed := time.Since(vmBackup.Status.VerifyTime.Time); elapsed < interval {
+				h.vmBackupController.EnqueueAfter(vmBackup.Namespace, vmBackup.Name, interval-elapsed)
+				return nil, nil
+			}
+		} else if delay := time.Until(spreadStart.Add(initialVerifyDelay(vmBackup, interval))); delay > 0 {
+			// all the existing backups are never verified when the controller starts or the verification is enabled,
+			// spread them over the interval instead of verifying all of them at once.
+			h.vmBackupController.EnqueueAfter(vmBackup.Namespace, vmBackup.Name, delay)
+			return nil, nil
+		}
+	}
+
+	vmBackupCpy := vmBackup.DeepCopy()
+	if err := h.verifyBackup(vmBackup); err != nil {
+		logrus.Infof("vm backup %s/%s is not verified: %v", vmBackup.Namespace, vmBackup.Name, err)
+		h.recorder.Eventf(vmBackup, corev1.EventTypeWarning, backupVerifyFailedEvent, "Failed to verify the backup: %v", err)
+		updateBackupCondition(vmBackupCpy, newVerifiedCondition(corev1.ConditionFalse, backupVerifyFailedReason, err.Error()))
+	} else {
+		updateBackupCondition(vmBackupCpy, newVerifiedCondition(corev1.ConditionTrue, "", "Backup is restorable"))
+	}
+	now := metav1.Now()
+	vmBackupCpy.Status.VerifyTime = &now
+
+	if interval > 0 {
+		h.vmBackupController.EnqueueAfter(vmBackup.Namespace, vmBackup.Name, interval)
+	}
+	return h.vmBackups.Update(vmBackupCpy)
+}
+
+// verifyBackup checks the VM backup can be restored from its backup target without creating any resource,
+// i.e. the uploaded metadata matches the status and all the Longhorn backups are completed in the backup target.
+func (h *VerifyHandler) verifyBackup(vmBackup *harvesterv1.VirtualMachineBackup) error {
+	target, err := getVMBackupTarget(h.backupTargetCache, h.secretCache, vmBackup)
+	if err != nil {
+		return fmt.Errorf("failed to get backup target: %w", err)
+	}
+	if target.IsDefaultBackupTarget() {
+		return fmt.Errorf("backup target is not set")
+	}
+	if vmBackup.Status.BackupTarget == nil || !IsBackupTargetSame(vmBackup.Status.BackupTarget, target) {
+		return fmt.Errorf("backup isn't in the backup target %s", target.Endpoint)
+	}
+
+	bsDriver, err := newBackupStoreDriver(h.secretCache, target)
+	if err != nil {
+		return fmt.Errorf("failed to access backup target: %w", err)
+	}
+	key, err := getBackupEncryptionKey(h.secretCache, target)
+	if err != nil {
+		return err
+	}
+	if err := verifyBackupMetadata(vmBackup, bsDriver, key); err != nil {
+		return err
+	}
+
+	for _, volumeBackup := range vmBackup.Status.VolumeBackups {
+		if err := h.verifyVolumeBackup(volumeBackup, bsDriver); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verifyBackupMetadata(vmBackup *harvesterv1.VirtualMachineBackup, bsDriver backupstore.BackupStoreDriver, key []byte) error {
+	destURL := filepath.Join(metadataFolderPath, getVMBackupMetadataFileName(vmBackup.Namespace, vmBackup.Name))
+	remoteMetadata, err := loadBackupMetadataInBackupTarget(destURL, bsDriver, key)
+	if err != nil {
+		return fmt.Errorf("failed to load metadata: %w", err)
+	}
+
+	metadata := newVMBackupMetadata(vmBackup)
+	if !isJSONEqual(metadata.VMSourceSpec, remoteMetadata.VMSourceSpec) {
+		return fmt.Errorf("VM spec in the metadata doesn't match the backup")
+	}
+	if !isJSONEqual(metadata.VolumeBackups, remoteMetadata.VolumeBackups) {
+		return fmt.Errorf("volume backups in the metadata don't match the backup")
+	}
+	if err := verifySecretBackups(metadata.SecretBackups, remoteMetadata.SecretBackups); err != nil {
+		return fmt.Errorf("secrets in the metadata don't match the backup: %w", err)
+	}
+	return nil
+}
+
+// verifySecretBackups compares the data of each backed up secret, regardless of the order of the secrets
+func verifySecretBackups(expected, actual []harvesterv1.SecretBackup) error {
+	actualSecrets := make(map[string]map[string][]byte, len(actual))
+	for _, secretBackup := range actual {
+		actualSecrets[secretBackup.Name] = secretBackup.Data
+	}
+	if len(actualSecrets) != len(expected) {
+		return fmt.Errorf("expected %d secrets, got %d", len(expected), len(actualSecrets))
+	}
+
+	for _, secretBackup := range expected {
+		data, ok := actualSecrets[secretBackup.Name]
+		if !ok {
+			return fmt.Errorf("secret %s is missing", secretBackup.Name)
+		}
+		if len(data) != len(secretBackup.Data) {
+			return fmt.Errorf("data of secret %s doesn't match", secretBackup.Name)
+		}
+		for key, value := range secretBackup.Data {
+			if actualValue, ok := data[key]; !ok || !bytes.Equal(value, actualValue) {
+				return fmt.Errorf("data of secret %s doesn't match", secretBackup.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// verifyVolumeBackup makes sure the Longhorn backup of the volume is completed in the backup target
+func (h *VerifyHandler) verifyVolumeBackup(volumeBackup harvesterv1.VolumeBackup, bsDriver backupstore.BackupStoreDriver) error {
+	if volumeBackup.LonghornBackupName == nil {
+		return fmt.Errorf("volume %s has no Longhorn backup", volumeBackup.VolumeName)
+	}
+
+	volumeName := volumeBackup.PersistentVolumeClaim.Spec.VolumeName
+	if lhBackup, err := h.lhbackupCache.Get(util.LonghornSystemNamespaceName, *volumeBackup.LonghornBackupName); err == nil && lhBackup.Status.VolumeName != "" {
+		volumeName = lhBackup.Status.VolumeName
+	}
+
+	if err := inspectLonghornBackup(bsDriver, *volumeBackup.LonghornBackupName, volumeName); err != nil {
+		return fmt.Errorf("Longhorn backup %s of volume %s isn't restorable: %w", *volumeBackup.LonghornBackupName, volumeBackup.VolumeName, err)
+	}
+	return nil
+}
+
+// inspectLonghornBackup loads the Longhorn backup config with the given driver and checks the backup is completed.
+// backupstore.InspectBackup isn't used since it builds the driver from the S3 credentials in the process environment.
+func inspectLonghornBackup(bsDriver backupstore.BackupStoreDriver, backupName, volumeName string) error {
+	checksum := bsutil.GetChecksum([]byte(volumeName))
+	volumePath := filepath.Join(backupstore.GetBackupstoreBase(), backupstore.VOLUME_DIRECTORY,
+		checksum[0:backupstore.VOLUME_SEPARATE_LAYER1],
+		checksum[backupstore.VOLUME_SEPARATE_LAYER1:backupstore.VOLUME_SEPARATE_LAYER2], volumeName)
+	if !bsDriver.FileExists(filepath.Join(volumePath, backupstore.VOLUME_CONFIG_FILE)) {
+		return fmt.Errorf("cannot find volume %s in backupstore", volumeName)
+	}
+
+	backupPath := filepath.Join(volumePath, backupstore.BACKUP_DIRECTORY, backupstore.BACKUP_CONFIG_PREFIX+backupName+backupstore.CFG_SUFFIX)
+	if !bsDriver.FileExists(backupPath) {
+		return fmt.Errorf("cannot find %s in backupstore", backupPath)
+	}
+	rc, err := bsDriver.Read(backupPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	backup := &backupstore.Backup{}
+	if err := json.NewDecoder(rc).Decode(backup); err != nil {
+		return err
+	}
+	if backup.CreatedTime == "" {
+		return fmt.Errorf("backup is still in progress")
+	}
+	return nil
+}
+
+// getSpreadStart returns the time from which the first verifications are spread,
+// it's reset when the verification is disabled so enabling it again spreads the verifications from then on.
+func (h *VerifyHandler) getSpreadStart(interval time.Duration) time.Time {
+	h.spreadMutex.Lock()
+	defer h.spreadMutex.Unlock()
+	if interval <= 0 {
+		h.spreadStart = time.Time{}
+	} else if h.spreadStart.IsZero() {
+		h.spreadStart = time.Now()
+	}
+	return h.spreadStart
+}
+
+// initialVerifyDelay returns a stable offset within the interval for the first verification of the VM backup
+func initialVerifyDelay(vmBackup *harvesterv1.VirtualMachineBackup, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(vmBackup.Namespace + "/" + vmBackup.Name))
+	return time.Duration(hash.Sum64() % uint64(interval))
+}
+
+// isVerifyRequested checks if the verification is requested after the last verification
+func isVerifyRequested(vmBackup *harvesterv1.VirtualMachineBackup) bool {
+	requested, ok := vmBackup.Annotations[VerifyRequestedAnnotation]
+	if !ok {
+		return false
+	}
+	if vmBackup.Status.VerifyTime == nil {
+		return true
+	}
+	requestedTime, err := time.Parse(time.RFC3339, requested)
+	if err != nil {
+		return false
+	}
+	return requestedTime.After(vmBackup.Status.VerifyTime.Time)
+}
+
+// isJSONEqual compares the objects in their serialized form, since the metadata goes through a JSON round trip
+func isJSONEqual(a, b interface{}) bool {
+	aj, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bj, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(aj, bj)
+}
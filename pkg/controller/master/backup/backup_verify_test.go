@@ -0,0 +1,160 @@
+package backup
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/longhorn/backupstore"
+	bsutil "github.com/longhorn/backupstore/util"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+func TestIsVerifyRequested(t *testing.T) {
+	verifyTime := metav1.NewTime(time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC))
+	var testCases = []struct {
+		name       string
+		requested  string
+		verifyTime *metav1.Time
+		expected   bool
+	}{
+		{
+			name:     "not requested",
+			expected: false,
+		},
+		{
+			name:      "never verified",
+			requested: "2022-03-10T11:00:00Z",
+			expected:  true,
+		},
+		{
+			name:       "requested after the last verification",
+			requested:  "2022-03-10T13:00:00Z",
+			verifyTime: &verifyTime,
+			expected:   true,
+		},
+		{
+			name:       "requested before the last verification",
+			requested:  "2022-03-10T11:00:00Z",
+			verifyTime: &verifyTime,
+			expected:   false,
+		},
+		{
+			name:       "invalid request time",
+			requested:  "yesterday",
+			verifyTime: &verifyTime,
+			expected:   false,
+		},
+	}
+
+	for _, tc := range testCases {
+		vmBackup := &harvesterv1.VirtualMachineBackup{
+			Status: &harvesterv1.VirtualMachineBackupStatus{
+				VerifyTime: tc.verifyTime,
+			},
+		}
+		if tc.requested != "" {
+			vmBackup.Annotations = map[string]string{VerifyRequestedAnnotation: tc.requested}
+		}
+		assert.Equal(t, tc.expected, isVerifyRequested(vmBackup), "case %q", tc.name)
+	}
+}
+
+func TestVerifySecretBackups(t *testing.T) {
+	expected := []harvesterv1.SecretBackup{
+		{Name: "cloud-init", Data: map[string][]byte{"userdata": []byte("#cloud-config")}},
+		{Name: "ssh-key", Data: map[string][]byte{"key": []byte("ssh-rsa")}},
+	}
+	var testCases = []struct {
+		name      string
+		actual    []harvesterv1.SecretBackup
+		expectErr bool
+	}{
+		{
+			name: "same data in another order",
+			actual: []harvesterv1.SecretBackup{
+				{Name: "ssh-key", Data: map[string][]byte{"key": []byte("ssh-rsa")}},
+				{Name: "cloud-init", Data: map[string][]byte{"userdata": []byte("#cloud-config")}},
+			},
+		},
+		{
+			name: "missing secret",
+			actual: []harvesterv1.SecretBackup{
+				{Name: "cloud-init", Data: map[string][]byte{"userdata": []byte("#cloud-config")}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "different value",
+			actual: []harvesterv1.SecretBackup{
+				{Name: "cloud-init", Data: map[string][]byte{"userdata": []byte("#cloud-config\n")}},
+				{Name: "ssh-key", Data: map[string][]byte{"key": []byte("ssh-rsa")}},
+			},
+			expectErr: true,
+		},
+		{
+			name: "different keys",
+			actual: []harvesterv1.SecretBackup{
+				{Name: "cloud-init", Data: map[string][]byte{"networkdata": []byte("#cloud-config")}},
+				{Name: "ssh-key", Data: map[string][]byte{"key": []byte("ssh-rsa")}},
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		err := verifySecretBackups(expected, tc.actual)
+		assert.Equal(t, tc.expectErr, err != nil, "case %q", tc.name)
+	}
+}
+
+func TestInitialVerifyDelay(t *testing.T) {
+	interval := 24 * time.Hour
+	backup1 := &harvesterv1.VirtualMachineBackup{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-1"}}
+	backup2 := &harvesterv1.VirtualMachineBackup{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-2"}}
+
+	delay := initialVerifyDelay(backup1, interval)
+	assert.True(t, delay >= 0 && delay < interval)
+	assert.Equal(t, delay, initialVerifyDelay(backup1, interval))
+	assert.NotEqual(t, delay, initialVerifyDelay(backup2, interval))
+	assert.Equal(t, time.Duration(0), initialVerifyDelay(backup1, 0))
+
+	h := &VerifyHandler{}
+	start := h.getSpreadStart(interval)
+	assert.False(t, start.IsZero())
+	assert.Equal(t, start, h.getSpreadStart(interval))
+	assert.True(t, h.getSpreadStart(0).IsZero())
+}
+
+func TestInspectLonghornBackup(t *testing.T) {
+	bucket := &fakeS3{bucket: "backups", accessKey: "access-key", objects: map[string][]byte{}}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	driver, err := newS3BackupStoreDriver("backups", "us-east-1", map[string]string{
+		AWSAccessKey: "access-key",
+		AWSSecretKey: "secret-key",
+		AWSEndpoints: server.URL,
+	})
+	assert.NoError(t, err)
+
+	volumeName := "pvc-1"
+	checksum := bsutil.GetChecksum([]byte(volumeName))
+	volumePath := filepath.Join(backupstore.GetBackupstoreBase(), "volumes", checksum[0:2], checksum[2:4], volumeName)
+	assert.Error(t, inspectLonghornBackup(driver, "backup-1", volumeName))
+
+	assert.NoError(t, driver.Write(filepath.Join(volumePath, "volume.cfg"), bytes.NewReader([]byte(`{"Name":"pvc-1"}`))))
+	assert.Error(t, inspectLonghornBackup(driver, "backup-1", volumeName))
+
+	backupPath := filepath.Join(volumePath, "backups", "backup_backup-1.cfg")
+	assert.NoError(t, driver.Write(backupPath, bytes.NewReader([]byte(`{"Name":"backup-1","VolumeName":"pvc-1"}`))))
+	assert.Error(t, inspectLonghornBackup(driver, "backup-1", volumeName), "in progress backup")
+
+	assert.NoError(t, driver.Write(backupPath, bytes.NewReader([]byte(`{"Name":"backup-1","VolumeName":"pvc-1","CreatedTime":"2022-03-10T12:00:00Z"}`))))
+	assert.NoError(t, inspectLonghornBackup(driver, "backup-1", volumeName))
+}
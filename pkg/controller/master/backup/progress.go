@@ -0,0 +1,167 @@
+package backup
+
+import (
+	"strconv"
+	"time"
+
+	lhv1beta1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+const progressComplete = 100
+
+// getBackupProgress returns the upload progress of a Longhorn backup
+func getBackupProgress(lhBackup *lhv1beta1.Backup, oldProgress *harvesterv1.TransferProgress, now time.Time) *harvesterv1.TransferProgress {
+	startTime := lhBackup.CreationTimestamp
+	progress := &harvesterv1.TransferProgress{
+		Percentage: lhBackup.Status.Progress,
+		TotalBytes: parseSize(lhBackup.Status.VolumeSize),
+		StartTime:  &startTime,
+	}
+
+	if lhBackup.Status.State == lhv1beta1.BackupStateCompleted {
+		progress.Percentage = progressComplete
+		// only the changed blocks are uploaded, the backup size is known once it's completed
+		if size := parseSize(lhBackup.Status.Size); size != 0 {
+			progress.TotalBytes = size
+		}
+		progress.FinishTime = getFinishTime(oldProgress, now)
+		if t, err := time.Parse(time.RFC3339, lhBackup.Status.BackupCreatedAt); err == nil {
+			progress.FinishTime = &metav1.Time{Time: t}
+		}
+	}
+
+	progress.BytesTransferred = progress.TotalBytes * int64(progress.Percentage) / progressComplete
+	progress.EstimatedFinishTime = estimateFinishTime(progress, oldProgress, now)
+	return progress
+}
+
+// isLonghornRestoreFinished tells if Longhorn finishes restoring the volume from the backup, the volume keeps
+// RestoreRequired until the engines restore the last backup and none of them is still restoring.
+func isLonghornRestoreFinished(volume *lhv1beta1.Volume, engines []*lhv1beta1.Engine) bool {
+	if !volume.Status.RestoreInitiated || volume.Status.RestoreRequired {
+		return false
+	}
+	for _, engine := range engines {
+		for _, status := range engine.Status.RestoreStatus {
+			if status != nil && status.IsRestoring {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// getRestoreProgress returns the progress of restoring a Longhorn volume from the backup.
+// The progress of the replica which is the slowest to restore is used.
+func getRestoreProgress(volume *lhv1beta1.Volume, engines []*lhv1beta1.Engine, totalBytes int64, oldProgress *harvesterv1.TransferProgress, now time.Time) *harvesterv1.TransferProgress {
+	startTime := volume.CreationTimestamp
+	progress := &harvesterv1.TransferProgress{
+		TotalBytes: totalBytes,
+		StartTime:  &startTime,
+	}
+
+	if volume.Status.RestoreInitiated && !volume.Status.RestoreRequired {
+		progress.Percentage = progressComplete
+		progress.FinishTime = getFinishTime(oldProgress, now)
+	} else {
+		percentage := -1
+		for _, engine := range engines {
+			for _, status := range engine.Status.RestoreStatus {
+				if status == nil {
+					continue
+				}
+				if percentage == -1 || status.Progress < percentage {
+					percentage = status.Progress
+				}
+			}
+		}
+		if percentage > 0 {
+			progress.Percentage = percentage
+		}
+	}
+
+	progress.BytesTransferred = progress.TotalBytes * int64(progress.Percentage) / progressComplete
+	progress.EstimatedFinishTime = estimateFinishTime(progress, oldProgress, now)
+	return progress
+}
+
+// aggregateProgress sums up the progress of all the volumes, it returns nil if there is no progress of any volume
+func aggregateProgress(progresses []*harvesterv1.TransferProgress, oldProgress *harvesterv1.TransferProgress, now time.Time) *harvesterv1.TransferProgress {
+	var (
+		result         = &harvesterv1.TransferProgress{}
+		finished       = true
+		count          int
+		sumPercentages int
+	)
+	for _, progress := range progresses {
+		if progress == nil {
+			finished = false
+			continue
+		}
+		count++
+		sumPercentages += progress.Percentage
+		result.BytesTransferred += progress.BytesTransferred
+		result.TotalBytes += progress.TotalBytes
+		if progress.StartTime != nil && (result.StartTime == nil || progress.StartTime.Before(result.StartTime)) {
+			result.StartTime = progress.StartTime.DeepCopy()
+		}
+		if progress.FinishTime == nil {
+			finished = false
+		} else if result.FinishTime == nil || result.FinishTime.Before(progress.FinishTime) {
+			result.FinishTime = progress.FinishTime.DeepCopy()
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+
+	// the size of the volumes without progress is unknown, weight the volumes equally then
+	if result.TotalBytes > 0 && count == len(progresses) {
+		result.Percentage = int(result.BytesTransferred * progressComplete / result.TotalBytes)
+	} else {
+		result.Percentage = sumPercentages / len(progresses)
+	}
+	if finished {
+		result.Percentage = progressComplete
+	} else {
+		result.FinishTime = nil
+	}
+	result.EstimatedFinishTime = estimateFinishTime(result, oldProgress, now)
+	return result
+}
+
+// estimateFinishTime assumes the remaining data is transferred at the same rate as the transferred data.
+// The estimation is only refreshed when the percentage changes, so that the status isn't updated on every reconciliation.
+func estimateFinishTime(progress, oldProgress *harvesterv1.TransferProgress, now time.Time) *metav1.Time {
+	if progress.FinishTime != nil || progress.StartTime == nil || progress.Percentage <= 0 || progress.Percentage >= progressComplete {
+		return nil
+	}
+	if oldProgress != nil && oldProgress.Percentage == progress.Percentage && oldProgress.EstimatedFinishTime != nil {
+		return oldProgress.EstimatedFinishTime.DeepCopy()
+	}
+	elapsed := now.Sub(progress.StartTime.Time)
+	if elapsed <= 0 {
+		return nil
+	}
+	remaining := elapsed * time.Duration(progressComplete-progress.Percentage) / time.Duration(progress.Percentage)
+	eta := metav1.NewTime(now.Add(remaining).Truncate(time.Second))
+	return &eta
+}
+
+func getFinishTime(oldProgress *harvesterv1.TransferProgress, now time.Time) *metav1.Time {
+	if oldProgress != nil && oldProgress.FinishTime != nil {
+		return oldProgress.FinishTime.DeepCopy()
+	}
+	return &metav1.Time{Time: now.Truncate(time.Second)}
+}
+
+func parseSize(size string) int64 {
+	i, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return i
+}
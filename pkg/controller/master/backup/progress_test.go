@@ -0,0 +1,165 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	lhv1beta1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+)
+
+var testProgressNow = time.Date(2022, 3, 10, 12, 0, 0, 0, time.UTC)
+
+func newTestProgress(percentage int, totalBytes int64, elapsed time.Duration, finished bool) *harvesterv1.TransferProgress {
+	startTime := metav1.NewTime(testProgressNow.Add(-elapsed))
+	progress := &harvesterv1.TransferProgress{
+		Percentage:       percentage,
+		BytesTransferred: totalBytes * int64(percentage) / 100,
+		TotalBytes:       totalBytes,
+		StartTime:        &startTime,
+	}
+	if finished {
+		progress.FinishTime = &metav1.Time{Time: testProgressNow}
+	}
+	return progress
+}
+
+func TestGetBackupProgress(t *testing.T) {
+	lhBackup := &lhv1beta1.Backup{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(testProgressNow.Add(-10 * time.Minute)),
+		},
+		Status: lhv1beta1.BackupStatus{
+			State:      lhv1beta1.BackupStateInProgress,
+			Progress:   25,
+			VolumeSize: "4096",
+		},
+	}
+
+	progress := getBackupProgress(lhBackup, nil, testProgressNow)
+	assert.Equal(t, 25, progress.Percentage)
+	assert.Equal(t, int64(1024), progress.BytesTransferred)
+	assert.Equal(t, int64(4096), progress.TotalBytes)
+	assert.Nil(t, progress.FinishTime)
+	assert.Equal(t, testProgressNow.Add(30*time.Minute), progress.EstimatedFinishTime.Time)
+
+	// the estimation isn't refreshed until the percentage changes
+	assert.Equal(t, progress.EstimatedFinishTime, getBackupProgress(lhBackup, progress, testProgressNow.Add(time.Minute)).EstimatedFinishTime)
+
+	lhBackup.Status.State = lhv1beta1.BackupStateCompleted
+	lhBackup.Status.Size = "2048"
+	lhBackup.Status.BackupCreatedAt = testProgressNow.Format(time.RFC3339)
+	progress = getBackupProgress(lhBackup, progress, testProgressNow.Add(time.Minute))
+	assert.Equal(t, 100, progress.Percentage)
+	assert.Equal(t, int64(2048), progress.BytesTransferred)
+	assert.Equal(t, testProgressNow, progress.FinishTime.Time.UTC())
+	assert.Nil(t, progress.EstimatedFinishTime)
+}
+
+func TestGetRestoreProgress(t *testing.T) {
+	volume := &lhv1beta1.Volume{
+		ObjectMeta: metav1.ObjectMeta{
+			CreationTimestamp: metav1.NewTime(testProgressNow.Add(-10 * time.Minute)),
+		},
+		Spec: lhv1beta1.VolumeSpec{
+			FromBackup: "s3://backupbucket@us-east-1/?backup=backup-1&volume=volume-1",
+		},
+		Status: lhv1beta1.VolumeStatus{
+			RestoreRequired:  true,
+			RestoreInitiated: true,
+		},
+	}
+	engines := []*lhv1beta1.Engine{
+		{
+			Status: lhv1beta1.EngineStatus{
+				RestoreStatus: map[string]*lhv1beta1.RestoreStatus{
+					"replica-1": {IsRestoring: true, Progress: 80},
+					"replica-2": {IsRestoring: true, Progress: 50},
+				},
+			},
+		},
+	}
+
+	assert.False(t, isLonghornRestoreFinished(volume, engines))
+
+	progress := getRestoreProgress(volume, engines, 1000, nil, testProgressNow)
+	assert.Equal(t, 50, progress.Percentage)
+	assert.Equal(t, int64(500), progress.BytesTransferred)
+	assert.Equal(t, testProgressNow.Add(10*time.Minute), progress.EstimatedFinishTime.Time)
+
+	// the engines may still be restoring the last backup when the volume clears RestoreRequired
+	volume.Status.RestoreRequired = false
+	assert.False(t, isLonghornRestoreFinished(volume, engines))
+	assert.True(t, isLonghornRestoreFinished(volume, nil))
+	progress = getRestoreProgress(volume, nil, 1000, progress, testProgressNow)
+	assert.Equal(t, 100, progress.Percentage)
+	assert.Equal(t, int64(1000), progress.BytesTransferred)
+	assert.NotNil(t, progress.FinishTime)
+	assert.Nil(t, progress.EstimatedFinishTime)
+}
+
+func TestAggregateProgress(t *testing.T) {
+	var testCases = []struct {
+		name               string
+		progresses         []*harvesterv1.TransferProgress
+		expectedNil        bool
+		expectedPercentage int
+		expectedFinished   bool
+		expectedETA        *time.Time
+	}{
+		{
+			name:        "no progress",
+			progresses:  []*harvesterv1.TransferProgress{nil, nil},
+			expectedNil: true,
+		},
+		{
+			name: "weighted by size",
+			progresses: []*harvesterv1.TransferProgress{
+				newTestProgress(100, 1000, 20*time.Minute, true),
+				newTestProgress(0, 3000, 20*time.Minute, false),
+			},
+			expectedPercentage: 25,
+			expectedETA:        timePtr(testProgressNow.Add(60 * time.Minute)),
+		},
+		{
+			name: "volume without progress isn't finished",
+			progresses: []*harvesterv1.TransferProgress{
+				newTestProgress(100, 1000, 20*time.Minute, true),
+				nil,
+			},
+			expectedPercentage: 50,
+			expectedETA:        timePtr(testProgressNow.Add(20 * time.Minute)),
+		},
+		{
+			name: "all finished",
+			progresses: []*harvesterv1.TransferProgress{
+				newTestProgress(100, 1000, 20*time.Minute, true),
+				newTestProgress(100, 3000, 10*time.Minute, true),
+			},
+			expectedPercentage: 100,
+			expectedFinished:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		actual := aggregateProgress(tc.progresses, nil, testProgressNow)
+		if tc.expectedNil {
+			assert.Nil(t, actual, "case %q", tc.name)
+			continue
+		}
+		assert.Equal(t, tc.expectedPercentage, actual.Percentage, "case %q", tc.name)
+		assert.Equal(t, tc.expectedFinished, actual.FinishTime != nil, "case %q", tc.name)
+		if tc.expectedETA == nil {
+			assert.Nil(t, actual.EstimatedFinishTime, "case %q", tc.name)
+		} else {
+			assert.Equal(t, *tc.expectedETA, actual.EstimatedFinishTime.Time, "case %q", tc.name)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -13,12 +13,15 @@ import (
 	"time"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	lhv1beta1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta1"
+	"github.com/longhorn/longhorn-manager/types"
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/pkg/name"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	k8sschema "k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
@@ -53,6 +56,8 @@ const (
 
 	restoreNameAnnotation = "restore.harvesterhci.io/name"
 	lastRestoreAnnotation = "restore.harvesterhci.io/last-restore-uid"
+	// replacedVolumeAnnotation marks the volumes replaced by reverting a VM, which are kept for the other VM snapshots
+	replacedVolumeAnnotation = "restore.harvesterhci.io/replaced-by"
 
 	vmCreatorLabel = "harvesterhci.io/creator"
 	vmNameLabel    = "harvesterhci.io/vm-name"
@@ -67,6 +72,8 @@ type RestoreHandler struct {
 	restores             ctlharvesterv1.VirtualMachineRestoreClient
 	restoreController    ctlharvesterv1.VirtualMachineRestoreController
 	backupCache          ctlharvesterv1.VirtualMachineBackupCache
+	vmSnapshotCache      ctlharvesterv1.VirtualMachineSnapshotCache
+	imageCache           ctlharvesterv1.VirtualMachineImageCache
 	vms                  ctlkubevirtv1.VirtualMachineClient
 	vmCache              ctlkubevirtv1.VirtualMachineCache
 	pvcClient            ctlcorev1.PersistentVolumeClaimClient
@@ -78,6 +85,8 @@ type RestoreHandler struct {
 	snapshotContents     ctlsnapshotv1.VolumeSnapshotContentClient
 	snapshotContentCache ctlsnapshotv1.VolumeSnapshotContentCache
 	lhbackupCache        ctllonghornv1.BackupCache
+	volumeCache          ctllonghornv1.VolumeCache
+	engineCache          ctllonghornv1.EngineCache
 
 	recorder   record.EventRecorder
 	restClient *rest.RESTClient
@@ -86,12 +95,16 @@ type RestoreHandler struct {
 func RegisterRestore(ctx context.Context, management *config.Management, opts config.Options) error {
 	restores := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineRestore()
 	backups := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup()
+	vmSnapshots := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineSnapshot()
+	images := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage()
 	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
 	pvcs := management.CoreFactory.Core().V1().PersistentVolumeClaim()
 	secrets := management.CoreFactory.Core().V1().Secret()
 	snapshots := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshot()
 	snapshotContents := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshotContent()
 	lhbackups := management.LonghornFactory.Longhorn().V1beta1().Backup()
+	volumes := management.LonghornFactory.Longhorn().V1beta1().Volume()
+	engines := management.LonghornFactory.Longhorn().V1beta1().Engine()
 
 	copyConfig := rest.CopyConfig(management.RestConfig)
 	copyConfig.GroupVersion = &k8sschema.GroupVersion{Group: kv1.SubresourceGroupName, Version: kv1.ApiLatestVersion}
@@ -107,6 +120,8 @@ func RegisterRestore(ctx context.Context, management *config.Management, opts co
 		restores:             restores,
 		restoreController:    restores,
 		backupCache:          backups.Cache(),
+		vmSnapshotCache:      vmSnapshots.Cache(),
+		imageCache:           images.Cache(),
 		vms:                  vms,
 		vmCache:              vms.Cache(),
 		pvcClient:            pvcs,
@@ -118,6 +133,8 @@ func RegisterRestore(ctx context.Context, management *config.Management, opts co
 		snapshotContents:     snapshotContents,
 		snapshotContentCache: snapshotContents.Cache(),
 		lhbackupCache:        lhbackups.Cache(),
+		volumeCache:          volumes.Cache(),
+		engineCache:          engines.Cache(),
 		recorder:             management.NewRecorder(restoreControllerName, "", ""),
 		restClient:           restClient,
 	}
@@ -126,6 +143,7 @@ func RegisterRestore(ctx context.Context, management *config.Management, opts co
 	restores.OnRemove(ctx, restoreControllerName, handler.RestoreOnRemove)
 	pvcs.OnChange(ctx, restoreControllerName, handler.PersistentVolumeClaimOnChange)
 	vms.OnChange(ctx, restoreControllerName, handler.VMOnChange)
+	engines.OnChange(ctx, restoreControllerName, handler.EngineOnChange)
 	return nil
 }
 
@@ -158,11 +176,16 @@ func (h *RestoreHandler) RestoreOnChanged(key string, restore *harvesterv1.Virtu
 		return nil, h.updateStatusError(restore, err, true)
 	}
 
-	// set vmRestore owner reference to the target VM
-	if len(restore.OwnerReferences) == 0 {
+	// set vmRestore owner reference to the target VM, the standalone volumes outlive the VM
+	if vm != nil && len(restore.OwnerReferences) == 0 {
 		return nil, h.updateOwnerRefAndTargetUID(restore, vm)
 	}
 
+	// surface the progress of restoring the volumes from the backup target
+	if updated, err := h.updateRestoreProgress(restore); err != nil || updated {
+		return nil, err
+	}
+
 	return nil, h.updateStatus(restore, backup, vm, isVolumesReady)
 }
 
@@ -175,13 +198,9 @@ func (h *RestoreHandler) RestoreOnRemove(key string, restore *harvesterv1.Virtua
 		return nil, nil
 	}
 
-	backup, err := h.getVMBackup(restore)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, volumeBackup := range backup.Status.VolumeBackups {
-		if volumeSnapshotContent, err := h.snapshotContentCache.Get(h.constructVolumeSnapshotContentName(restore.Namespace, restore.Name, *volumeBackup.Name)); err != nil {
+	// the volume restores tell the VolumeSnapshotContents created, the backup or snapshot may be gone already
+	for _, volumeRestore := range restore.Status.VolumeRestores {
+		if volumeSnapshotContent, err := h.snapshotContentCache.Get(h.constructVolumeSnapshotContentName(restore.Namespace, restore.Name, volumeRestore.VolumeBackupName)); err != nil {
 			if err != nil && !apierrors.IsNotFound(err) {
 				return nil, err
 			}
@@ -227,6 +246,42 @@ func (h *RestoreHandler) VMOnChange(key string, vm *kv1.VirtualMachine) (*kv1.Vi
 	return nil, nil
 }
 
+// EngineOnChange watching the Longhorn engines on change and enqueue the vmRestore if the engine is restoring a volume of it
+func (h *RestoreHandler) EngineOnChange(key string, engine *lhv1beta1.Engine) (*lhv1beta1.Engine, error) {
+	if engine == nil || engine.DeletionTimestamp != nil || len(engine.Status.RestoreStatus) == 0 {
+		return nil, nil
+	}
+
+	volume, err := h.volumeCache.Get(engine.Namespace, engine.Spec.VolumeName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	kubernetesStatus := volume.Status.KubernetesStatus
+	if kubernetesStatus.PVCName == "" {
+		return nil, nil
+	}
+	pvc, err := h.pvcCache.Get(kubernetesStatus.Namespace, kubernetesStatus.PVCName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	restoreName, ok := pvc.Annotations[restoreNameAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	logrus.Debugf("handling engine updating %s/%s", engine.Namespace, engine.Name)
+	h.restoreController.EnqueueAfter(pvc.Namespace, restoreName, 5*time.Second)
+	return nil, nil
+}
+
 func (h *RestoreHandler) initStatus(restore *harvesterv1.VirtualMachineRestore) error {
 	restoreCpy := restore.DeepCopy()
 
@@ -251,10 +306,22 @@ func (h *RestoreHandler) initVolumesStatus(vmRestore *harvesterv1.VirtualMachine
 		restoreCpy.Status.VolumeRestores = volumeRestores
 	}
 
-	if !isNewVMOrHasRetainPolicy(vmRestore) && vmRestore.Status.DeletedVolumes == nil {
+	if !isOldVolumesRetained(vmRestore) && vmRestore.Status.DeletedVolumes == nil {
 		var deletedVolumes []string
-		for _, vol := range backup.Status.VolumeBackups {
-			deletedVolumes = append(deletedVolumes, vol.PersistentVolumeClaim.ObjectMeta.Name)
+		if isPartialRestore(vmRestore) {
+			// only the replaced disks of the target VM are deleted
+			vm, err := h.getVM(vmRestore)
+			if err != nil {
+				return err
+			}
+			if vm == nil {
+				return fmt.Errorf("target vm %s/%s not found", vmRestore.Namespace, vmRestore.Spec.Target.Name)
+			}
+			deletedVolumes = getReplacedPVCNames(vm, restoreCpy)
+		} else {
+			for _, vol := range backup.Status.VolumeBackups {
+				deletedVolumes = append(deletedVolumes, vol.PersistentVolumeClaim.ObjectMeta.Name)
+			}
 		}
 		restoreCpy.Status.DeletedVolumes = deletedVolumes
 	}
@@ -287,6 +354,10 @@ func (h *RestoreHandler) getVM(vmRestore *harvesterv1.VirtualMachineRestore) (*k
 func getVolumeRestores(vmRestore *harvesterv1.VirtualMachineRestore, backup *harvesterv1.VirtualMachineBackup) ([]harvesterv1.VolumeRestore, error) {
 	restores := make([]harvesterv1.VolumeRestore, 0, len(backup.Status.VolumeBackups))
 	for _, vb := range backup.Status.VolumeBackups {
+		if !isVolumeSelected(vmRestore, vb.VolumeName) {
+			continue
+		}
+
 		found := false
 		for _, vr := range vmRestore.Status.VolumeRestores {
 			if vb.VolumeName == vr.VolumeName {
@@ -334,9 +405,11 @@ func (h *RestoreHandler) reconcileResources(
 		return nil, false, err
 	}
 
-	//restore referenced secrets
-	if err := h.reconcileSecretBackups(vmRestore, backup, vm); err != nil {
-		return nil, false, err
+	//restore referenced secrets, a partial restore leaves the VM configuration alone
+	if !isPartialRestore(vmRestore) {
+		if err := h.reconcileSecretBackups(vmRestore, backup, vm); err != nil {
+			return nil, false, err
+		}
 	}
 
 	return vm, isVolumesReady, nil
@@ -347,10 +420,13 @@ func (h *RestoreHandler) reconcileVolumeRestores(
 	backup *harvesterv1.VirtualMachineBackup,
 ) (bool, error) {
 	isVolumesReady := true
-	for i, volumeRestore := range vmRestore.Status.VolumeRestores {
+	for _, volumeRestore := range vmRestore.Status.VolumeRestores {
 		pvc, err := h.pvcCache.Get(vmRestore.Namespace, volumeRestore.PersistentVolumeClaim.ObjectMeta.Name)
 		if apierrors.IsNotFound(err) {
-			volumeBackup := backup.Status.VolumeBackups[i]
+			volumeBackup, err := getVolumeBackup(backup, volumeRestore.VolumeBackupName)
+			if err != nil {
+				return false, err
+			}
 			if err = h.createRestoredPVC(vmRestore, volumeBackup, volumeRestore); err != nil {
 				return false, err
 			}
@@ -374,6 +450,10 @@ func (h *RestoreHandler) reconcileVM(
 	vmRestore *harvesterv1.VirtualMachineRestore,
 	backup *harvesterv1.VirtualMachineBackup,
 ) (*kv1.VirtualMachine, error) {
+	if isPartialRestore(vmRestore) {
+		return h.reconcileVMVolumes(vmRestore)
+	}
+
 	// create new VM if it's not exist
 	vm, err := h.getVM(vmRestore)
 	if err != nil {
@@ -401,6 +481,7 @@ func (h *RestoreHandler) reconcileVM(
 	vmCpy := vm.DeepCopy()
 	vmCpy.Spec = backup.Status.SourceSpec.Spec
 	vmCpy.Spec.Template.Spec.Volumes = newVolumes
+	mapRestoreNetworks(vmRestore, &vmCpy.Spec.Template.Spec)
 	if vmCpy.Annotations == nil {
 		vmCpy.Annotations = make(map[string]string)
 	}
@@ -415,6 +496,46 @@ func (h *RestoreHandler) reconcileVM(
 	return vm, nil
 }
 
+// reconcileVMVolumes only replaces the restored disks of the target VM for a partial restore,
+// the target VM isn't touched if the volumes are restored as standalone PVCs.
+func (h *RestoreHandler) reconcileVMVolumes(vmRestore *harvesterv1.VirtualMachineRestore) (*kv1.VirtualMachine, error) {
+	// standalone volumes are restored without the target VM, which may be removed already
+	if isStandaloneVolumeRestore(vmRestore) {
+		return nil, nil
+	}
+
+	vm, err := h.getVM(vmRestore)
+	if err != nil {
+		return nil, err
+	}
+	if vm == nil {
+		return nil, fmt.Errorf("target vm %s/%s not found", vmRestore.Namespace, vmRestore.Spec.Target.Name)
+	}
+
+	restoreID := getRestoreID(vmRestore)
+	if vm.Annotations[lastRestoreAnnotation] == restoreID {
+		return vm, nil
+	}
+
+	newVolumes, err := getNewVolumes(&vm.Spec, vmRestore)
+	if err != nil {
+		return nil, err
+	}
+
+	vmCpy := vm.DeepCopy()
+	vmCpy.Spec.Template.Spec.Volumes = newVolumes
+	if vmCpy.Annotations == nil {
+		vmCpy.Annotations = make(map[string]string)
+	}
+	vmCpy.Annotations[lastRestoreAnnotation] = restoreID
+	vmCpy.Annotations[restoreNameAnnotation] = vmRestore.Name
+	if err := removeVolumeClaimTemplates(vmCpy.Annotations, getReplacedPVCNames(vm, vmRestore)); err != nil {
+		return nil, err
+	}
+
+	return h.vms.Update(vmCpy)
+}
+
 func (h *RestoreHandler) reconcileSecretBackups(
 	vmRestore *harvesterv1.VirtualMachineRestore,
 	backup *harvesterv1.VirtualMachineBackup,
@@ -475,6 +596,10 @@ func (h *RestoreHandler) createOrUpdateSecret(namespace, name string, data map[s
 }
 
 func (h *RestoreHandler) getVMBackup(vmRestore *harvesterv1.VirtualMachineRestore) (*harvesterv1.VirtualMachineBackup, error) {
+	if vmRestore.Spec.VirtualMachineSnapshotName != "" {
+		return h.getVMSnapshotAsBackup(vmRestore)
+	}
+
 	backup, err := h.backupCache.Get(vmRestore.Spec.VirtualMachineBackupNamespace, vmRestore.Spec.VirtualMachineBackupName)
 	if err != nil {
 		return nil, err
@@ -491,6 +616,38 @@ func (h *RestoreHandler) getVMBackup(vmRestore *harvesterv1.VirtualMachineRestor
 	return backup, nil
 }
 
+// getVMSnapshotAsBackup presents the VM snapshot as a VM backup, so that reverting a VM to a snapshot
+// goes through the same restore flow as restoring a backup.
+func (h *RestoreHandler) getVMSnapshotAsBackup(vmRestore *harvesterv1.VirtualMachineRestore) (*harvesterv1.VirtualMachineBackup, error) {
+	vmSnapshot, err := h.vmSnapshotCache.Get(vmRestore.Namespace, vmRestore.Spec.VirtualMachineSnapshotName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isVMSnapshotReady(vmSnapshot) {
+		return nil, fmt.Errorf("VMSnapshot %s is not ready", vmSnapshot.Name)
+	}
+
+	if vmSnapshot.Status.SourceSpec == nil {
+		return nil, fmt.Errorf("empty vm snapshot source spec of %s", vmSnapshot.Name)
+	}
+
+	return &harvesterv1.VirtualMachineBackup{
+		ObjectMeta: vmSnapshot.ObjectMeta,
+		Spec: harvesterv1.VirtualMachineBackupSpec{
+			Source: vmSnapshot.Spec.Source,
+		},
+		Status: &harvesterv1.VirtualMachineBackupStatus{
+			SourceUID:     vmSnapshot.Status.SourceUID,
+			CreationTime:  vmSnapshot.Status.CreationTime,
+			SourceSpec:    vmSnapshot.Status.SourceSpec,
+			VolumeBackups: vmSnapshot.Status.VolumeSnapshots,
+			SecretBackups: vmSnapshot.Status.SecretBackups,
+			ReadyToUse:    vmSnapshot.Status.ReadyToUse,
+		},
+	}, nil
+}
+
 // createNewVM helps to create new target VM and set the associated owner reference
 func (h *RestoreHandler) createNewVM(restore *harvesterv1.VirtualMachineRestore, backup *harvesterv1.VirtualMachineBackup) (*kv1.VirtualMachine, error) {
 	vmName := restore.Spec.Target.Name
@@ -533,6 +690,7 @@ func (h *RestoreHandler) createNewVM(restore *harvesterv1.VirtualMachineRestore,
 		return nil, err
 	}
 	vm.Spec.Template.Spec.Volumes = newVolumes
+	mapRestoreNetworks(restore, &vm.Spec.Template.Spec)
 
 	for i := range vm.Spec.Template.Spec.Domain.Devices.Interfaces {
 		// remove the copied mac address of the new VM
@@ -600,6 +758,21 @@ func (h *RestoreHandler) createRestoredPVC(
 	}
 	annotations[restoreNameAnnotation] = vmRestore.Name
 
+	// standalone PVCs outlive the restore
+	var ownerRefs []metav1.OwnerReference
+	if !isStandaloneVolumeRestore(vmRestore) {
+		ownerRefs = []metav1.OwnerReference{
+			{
+				APIVersion:         harvesterv1.SchemeGroupVersion.String(),
+				Kind:               vmRestoreKindName,
+				Name:               vmRestore.Name,
+				UID:                vmRestore.UID,
+				Controller:         pointer.BoolPtr(true),
+				BlockOwnerDeletion: pointer.BoolPtr(true),
+			},
+		}
+	}
+
 	sourcePVC := volumeBackup.PersistentVolumeClaim
 	spec := *sourcePVC.Spec.DeepCopy()
 	spec.VolumeName = ""
@@ -608,29 +781,52 @@ func (h *RestoreHandler) createRestoredPVC(
 		Kind:     volumeSnapshotKindName,
 		Name:     dataSourceName,
 	}
+	if err := h.mapRestoredPVC(vmRestore, annotations, &spec); err != nil {
+		return err
+	}
 
 	_, err := h.pvcClient.Create(&corev1.PersistentVolumeClaim{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        volumeRestore.PersistentVolumeClaim.ObjectMeta.Name,
-			Namespace:   vmRestore.Namespace,
-			Labels:      sourcePVC.ObjectMeta.Labels,
-			Annotations: annotations,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion:         harvesterv1.SchemeGroupVersion.String(),
-					Kind:               vmRestoreKindName,
-					Name:               vmRestore.Name,
-					UID:                vmRestore.UID,
-					Controller:         pointer.BoolPtr(true),
-					BlockOwnerDeletion: pointer.BoolPtr(true),
-				},
-			},
+			Name:            volumeRestore.PersistentVolumeClaim.ObjectMeta.Name,
+			Namespace:       vmRestore.Namespace,
+			Labels:          sourcePVC.ObjectMeta.Labels,
+			Annotations:     annotations,
+			OwnerReferences: ownerRefs,
 		},
 		Spec: spec,
 	})
 	return err
 }
 
+// mapRestoredPVC points the restored PVC to the mapped image or storage class of the restoring cluster
+func (h *RestoreHandler) mapRestoredPVC(
+	vmRestore *harvesterv1.VirtualMachineRestore,
+	annotations map[string]string,
+	spec *corev1.PersistentVolumeClaimSpec,
+) error {
+	if imageID, ok := annotations[util.AnnotationImageID]; ok {
+		if targetImageID, mapped := GetRestoreImageID(vmRestore, imageID); mapped {
+			// the storage class of an image volume references the backing image, so it follows the image
+			imageNamespace, imageName := ref.Parse(targetImageID)
+			image, err := h.imageCache.Get(imageNamespace, imageName)
+			if err != nil {
+				return fmt.Errorf("failed to get image %s, error: %w", targetImageID, err)
+			}
+			if image.Status.StorageClassName == "" {
+				return fmt.Errorf("image %s has no storage class", targetImageID)
+			}
+			annotations[util.AnnotationImageID] = targetImageID
+			spec.StorageClassName = pointer.StringPtr(image.Status.StorageClassName)
+			return nil
+		}
+	}
+
+	if spec.StorageClassName != nil {
+		spec.StorageClassName = pointer.StringPtr(GetRestoreStorageClassName(vmRestore, *spec.StorageClassName))
+	}
+	return nil
+}
+
 func (h *RestoreHandler) getOrCreateVolumeSnapshotContent(
 	vmRestore *harvesterv1.VirtualMachineRestore,
 	volumeBackup harvesterv1.VolumeBackup,
@@ -644,6 +840,9 @@ func (h *RestoreHandler) getOrCreateVolumeSnapshotContent(
 		return volumeSnapshotContent, nil
 	}
 
+	if volumeBackup.LonghornBackupName == nil {
+		return nil, fmt.Errorf("volume backup %s has no Longhorn backup", volumeBackup.VolumeName)
+	}
 	lhBackup, err := h.lhbackupCache.Get(util.LonghornSystemNamespaceName, *volumeBackup.LonghornBackupName)
 	if err != nil {
 		return nil, err
@@ -724,8 +923,8 @@ func (h *RestoreHandler) getOrCreateVolumeSnapshot(
 }
 
 func (h *RestoreHandler) deleteOldPVC(vmRestore *harvesterv1.VirtualMachineRestore, vm *kv1.VirtualMachine) error {
-	if isNewVMOrHasRetainPolicy(vmRestore) {
-		logrus.Infof("skip deleting old PVC of vm %s/%s", vm.Name, vm.Namespace)
+	if isOldVolumesRetained(vmRestore) {
+		logrus.Infof("skip deleting old PVC of vm %s/%s", vmRestore.Namespace, vmRestore.Spec.Target.Name)
 		return nil
 	}
 
@@ -736,17 +935,44 @@ func (h *RestoreHandler) deleteOldPVC(vmRestore *harvesterv1.VirtualMachineResto
 			return err
 		}
 
-		if vol != nil {
-			err = h.pvcClient.Delete(vol.Namespace, vol.Name, &metav1.DeleteOptions{})
-			if err != nil {
+		if vol == nil {
+			continue
+		}
+
+		// the volumes with snapshots are deleted along with their snapshots, the last snapshot deletes them
+		referenced, err := isVolumeInVMSnapshots(h.vmSnapshotCache, vol.Namespace, vol.Name, "")
+		if err != nil {
+			return err
+		}
+		if referenced {
+			if err := h.markVolumeReplaced(vmRestore, vol); err != nil {
 				return err
 			}
+			continue
+		}
+
+		err = h.pvcClient.Delete(vol.Namespace, vol.Name, &metav1.DeleteOptions{})
+		if err != nil {
+			return err
 		}
 	}
 
 	return nil
 }
 
+func (h *RestoreHandler) markVolumeReplaced(vmRestore *harvesterv1.VirtualMachineRestore, pvc *corev1.PersistentVolumeClaim) error {
+	if _, ok := pvc.Annotations[replacedVolumeAnnotation]; ok {
+		return nil
+	}
+	pvcCpy := pvc.DeepCopy()
+	if pvcCpy.Annotations == nil {
+		pvcCpy.Annotations = map[string]string{}
+	}
+	pvcCpy.Annotations[replacedVolumeAnnotation] = vmRestore.Name
+	_, err := h.pvcClient.Update(pvcCpy)
+	return err
+}
+
 func (h *RestoreHandler) startVM(vm *kv1.VirtualMachine) error {
 	logrus.Infof("starting the vm %s, current state running:%v", vm.Name, *vm.Spec.Running)
 	if vm.Spec.Running == nil || !*vm.Spec.Running {
@@ -773,7 +999,24 @@ func (h *RestoreHandler) updateStatus(
 		return nil
 	}
 
-	if !vm.Status.Ready {
+	// a partial restore leaves the target VM stopped or running as it is, it's complete once the volumes are restored
+	if isPartialRestore(vmRestore) {
+		finished, err := h.isVolumeRestoresFinished(vmRestore)
+		if err != nil {
+			return err
+		}
+		if !finished {
+			message := "Waiting for volumes to be restored"
+			updateRestoreCondition(restoreCpy, newProgressingCondition(corev1.ConditionTrue, "", message))
+			updateRestoreCondition(restoreCpy, newReadyCondition(corev1.ConditionFalse, "", message))
+			if !reflect.DeepEqual(vmRestore, restoreCpy) {
+				if _, err := h.restores.Update(restoreCpy); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	} else if !vm.Status.Ready {
 		message := "Waiting for target vm to be ready"
 		updateRestoreCondition(restoreCpy, newProgressingCondition(corev1.ConditionFalse, "", message))
 		updateRestoreCondition(restoreCpy, newReadyCondition(corev1.ConditionFalse, "", message))
@@ -789,8 +1032,10 @@ func (h *RestoreHandler) updateStatus(
 		return h.updateStatusError(vmRestore, fmt.Errorf("error cleaning up, err:%s", err.Error()), false)
 	}
 
-	if err := h.startVM(vm); err != nil {
-		return h.updateStatusError(vmRestore, fmt.Errorf("failed to start vm, err:%s", err.Error()), false)
+	if !isPartialRestore(vmRestore) {
+		if err := h.startVM(vm); err != nil {
+			return h.updateStatusError(vmRestore, fmt.Errorf("failed to start vm, err:%s", err.Error()), false)
+		}
 	}
 
 	h.recorder.Eventf(
@@ -811,6 +1056,102 @@ func (h *RestoreHandler) updateStatus(
 	return nil
 }
 
+// updateRestoreProgress updates the progress of each volume and the aggregated progress, it returns true if the vmRestore is updated
+func (h *RestoreHandler) updateRestoreProgress(vmRestore *harvesterv1.VirtualMachineRestore) (bool, error) {
+	now := time.Now()
+	restoreCpy := vmRestore.DeepCopy()
+	progresses := make([]*harvesterv1.TransferProgress, 0, len(restoreCpy.Status.VolumeRestores))
+	for i, volumeRestore := range restoreCpy.Status.VolumeRestores {
+		progress, err := h.getVolumeRestoreProgress(vmRestore.Namespace, volumeRestore, now)
+		if err != nil {
+			return false, err
+		}
+		restoreCpy.Status.VolumeRestores[i].Progress = progress
+		progresses = append(progresses, progress)
+	}
+	restoreCpy.Status.Progress = aggregateProgress(progresses, vmRestore.Status.Progress, now)
+
+	if reflect.DeepEqual(vmRestore.Status, restoreCpy.Status) {
+		return false, nil
+	}
+	if _, err := h.restores.Update(restoreCpy); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isVolumeRestoresFinished tells if all the volumes are restored by the status of the Longhorn volumes and engines,
+// the volumes which are not restored from a Longhorn backup are ready once they are bound.
+func (h *RestoreHandler) isVolumeRestoresFinished(vmRestore *harvesterv1.VirtualMachineRestore) (bool, error) {
+	for _, volumeRestore := range vmRestore.Status.VolumeRestores {
+		pvc, err := h.pvcCache.Get(vmRestore.Namespace, volumeRestore.PersistentVolumeClaim.ObjectMeta.Name)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if pvc.Spec.VolumeName == "" {
+			return false, nil
+		}
+
+		volume, err := h.volumeCache.Get(util.LonghornSystemNamespaceName, pvc.Spec.VolumeName)
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		if volume.Spec.FromBackup == "" {
+			continue
+		}
+
+		engines, err := h.engineCache.List(util.LonghornSystemNamespaceName, labels.SelectorFromSet(labels.Set{
+			types.LonghornLabelVolume: volume.Name,
+		}))
+		if err != nil {
+			return false, err
+		}
+		if !isLonghornRestoreFinished(volume, engines) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (h *RestoreHandler) getVolumeRestoreProgress(namespace string, volumeRestore harvesterv1.VolumeRestore, now time.Time) (*harvesterv1.TransferProgress, error) {
+	pvc, err := h.pvcCache.Get(namespace, volumeRestore.PersistentVolumeClaim.ObjectMeta.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return volumeRestore.Progress, nil
+		}
+		return nil, err
+	}
+	if pvc.Spec.VolumeName == "" {
+		return volumeRestore.Progress, nil
+	}
+
+	volume, err := h.volumeCache.Get(util.LonghornSystemNamespaceName, pvc.Spec.VolumeName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return volumeRestore.Progress, nil
+		}
+		return nil, err
+	}
+	// the volume isn't restored from a Longhorn backup
+	if volume.Spec.FromBackup == "" {
+		return volumeRestore.Progress, nil
+	}
+
+	engines, err := h.engineCache.List(util.LonghornSystemNamespaceName, labels.SelectorFromSet(labels.Set{
+		types.LonghornLabelVolume: volume.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	totalBytes := pvc.Spec.Resources.Requests.Storage().Value()
+	return getRestoreProgress(volume, engines, totalBytes, volumeRestore.Progress, now), nil
+}
+
 func (h *RestoreHandler) updateStatusError(restore *harvesterv1.VirtualMachineRestore, err error, createEvent bool) error {
 	restoreCpy := restore.DeepCopy()
 	updateRestoreCondition(restoreCpy, newProgressingCondition(corev1.ConditionFalse, "Error", err.Error()))
@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/longhorn/backupstore"
+	lhhttp "github.com/longhorn/backupstore/http"
+)
+
+const s3Kind = "s3"
+
+// s3BackupStoreDriver is a backupstore driver of S3 built from the credentials of a backup target. The S3 driver of
+// Longhorn backupstore reads the credentials from the process environment, which is shared by all the backup targets.
+type s3BackupStoreDriver struct {
+	client  *s3.S3
+	bucket  string
+	destURL string
+}
+
+var _ backupstore.BackupStoreDriver = &s3BackupStoreDriver{}
+
+// newS3BackupStoreDriver builds the S3 driver of the bucket with the data of the longhorn backup target secret
+func newS3BackupStoreDriver(bucket, region string, data map[string]string) (*s3BackupStoreDriver, error) {
+	if bucket == "" {
+		return nil, fmt.Errorf("the bucket name of the S3 backup target is empty")
+	}
+
+	config := &aws.Config{
+		Region:      aws.String(region),
+		MaxRetries:  aws.Int(3),
+		Credentials: credentials.NewStaticCredentials(data[AWSAccessKey], data[AWSSecretKey], ""),
+	}
+	if endpoint := data[AWSEndpoints]; endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	if virtualHostedStyle, err := strconv.ParseBool(data[VirtualHostedStyle]); err == nil {
+		config.S3ForcePathStyle = aws.Bool(!virtualHostedStyle)
+	}
+	if cert := data[AWSCERT]; cert != "" {
+		client, err := lhhttp.GetClientWithCustomCerts([]byte(cert))
+		if err != nil {
+			return nil, err
+		}
+		config.HTTPClient = client
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
+	}
+
+	destURL := s3Kind + "://" + bucket
+	if region != "" {
+		destURL += "@" + region
+	}
+	return &s3BackupStoreDriver{
+		client:  s3.New(sess),
+		bucket:  bucket,
+		destURL: destURL + "/",
+	}, nil
+}
+
+func (d *s3BackupStoreDriver) Kind() string {
+	return s3Kind
+}
+
+func (d *s3BackupStoreDriver) GetURL() string {
+	return d.destURL
+}
+
+// updatePath returns the key of the path, the backup targets are always at the root of the bucket
+func (d *s3BackupStoreDriver) updatePath(path string) string {
+	// leading "/" can cause mystery problems for s3
+	return strings.TrimLeft(path, "/")
+}
+
+func (d *s3BackupStoreDriver) List(listPath string) ([]string, error) {
+	prefix := d.updatePath(listPath)
+	if prefix != "" {
+		// the directory must end in "/" in S3, otherwise it may match unintentionally
+		prefix += "/"
+	}
+
+	result := []string{}
+	params := &s3.ListObjectsInput{
+		Bucket:    aws.String(d.bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+	err := d.client.ListObjectsPages(params, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if name := strings.TrimPrefix(aws.StringValue(object.Key), prefix); name != "" {
+				result = append(result, name)
+			}
+		}
+		for _, commonPrefix := range page.CommonPrefixes {
+			if name := strings.TrimSuffix(strings.TrimPrefix(aws.StringValue(commonPrefix.Prefix), prefix), "/"); name != "" {
+				result = append(result, name)
+			}
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects with prefix %s in bucket %s: %w", prefix, d.bucket, err)
+	}
+	return result, nil
+}
+
+func (d *s3BackupStoreDriver) headObject(filePath string) (*s3.HeadObjectOutput, error) {
+	return d.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(d.updatePath(filePath)),
+	})
+}
+
+func (d *s3BackupStoreDriver) FileExists(filePath string) bool {
+	return d.FileSize(filePath) >= 0
+}
+
+func (d *s3BackupStoreDriver) FileSize(filePath string) int64 {
+	head, err := d.headObject(filePath)
+	if err != nil || head.ContentLength == nil {
+		return -1
+	}
+	return *head.ContentLength
+}
+
+func (d *s3BackupStoreDriver) FileTime(filePath string) time.Time {
+	head, err := d.headObject(filePath)
+	if err != nil || head.ContentLength == nil {
+		return time.Time{}
+	}
+	return aws.TimeValue(head.LastModified).UTC()
+}
+
+// Remove deletes all the objects with the path as the prefix like the Longhorn driver does
+func (d *s3BackupStoreDriver) Remove(path string) error {
+	prefix := d.updatePath(path)
+	var keys []*string
+	err := d.client.ListObjectsPages(&s3.ListObjectsInput{
+		Bucket: aws.String(d.bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsOutput, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, object.Key)
+		}
+		return !lastPage
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects with prefix %s in bucket %s: %w", prefix, d.bucket, err)
+	}
+
+	var failures []string
+	for _, key := range keys {
+		if _, err := d.client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(d.bucket),
+			Key:    key,
+		}); err != nil {
+			failures = append(failures, aws.StringValue(key))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to delete objects %v", failures)
+	}
+	return nil
+}
+
+func (d *s3BackupStoreDriver) Read(src string) (io.ReadCloser, error) {
+	resp, err := d.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(d.updatePath(src)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object %s: %w", src, err)
+	}
+	return resp.Body, nil
+}
+
+func (d *s3BackupStoreDriver) Write(dst string, rs io.ReadSeeker) error {
+	if _, err := d.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(d.bucket),
+		Key:    aws.String(d.updatePath(dst)),
+		Body:   rs,
+	}); err != nil {
+		return fmt.Errorf("failed to put object %s: %w", dst, err)
+	}
+	return nil
+}
+
+func (d *s3BackupStoreDriver) Upload(src, dst string) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return d.Write(dst, file)
+}
+
+func (d *s3BackupStoreDriver) Download(src, dst string) error {
+	rc, err := d.Read(src)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	file, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, rc)
+	return err
+}
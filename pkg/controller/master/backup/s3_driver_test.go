@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 is an in-memory S3 bucket serving the path style requests the driver sends
+type fakeS3 struct {
+	mutex     sync.Mutex
+	bucket    string
+	accessKey string
+	objects   map[string][]byte
+}
+
+type listBucketResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	Contents       []listObject
+	CommonPrefixes []listPrefix
+	IsTruncated    bool
+}
+
+type listObject struct {
+	Key  string
+	Size int
+}
+
+type listPrefix struct {
+	Prefix string
+}
+
+func (f *fakeS3) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+f.accessKey+"/") {
+		rw.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	if key == "" || key == "/" {
+		f.list(rw, r.URL.Query().Get("prefix"), r.URL.Query().Get("delimiter"))
+		return
+	}
+	key = strings.TrimPrefix(key, "/")
+
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := ioutil.ReadAll(r.Body)
+		f.objects[key] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		rw.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			_, _ = rw.Write(data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, key)
+		rw.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) list(rw http.ResponseWriter, prefix, delimiter string) {
+	result := listBucketResult{}
+	prefixes := map[string]bool{}
+	for key, data := range f.objects {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			prefixes[prefix+rest[:i+1]] = true
+			continue
+		}
+		result.Contents = append(result.Contents, listObject{Key: key, Size: len(data)})
+	}
+	for p := range prefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, listPrefix{Prefix: p})
+	}
+	_ = xml.NewEncoder(rw).Encode(result)
+}
+
+func TestS3BackupStoreDriver(t *testing.T) {
+	bucket := &fakeS3{bucket: "backups", accessKey: "access-key", objects: map[string][]byte{}}
+	server := httptest.NewServer(bucket)
+	defer server.Close()
+
+	driver, err := newS3BackupStoreDriver("backups", "us-east-1", map[string]string{
+		AWSAccessKey: "access-key",
+		AWSSecretKey: "secret-key",
+		AWSEndpoints: server.URL,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://backups@us-east-1/", driver.GetURL())
+
+	assert.NoError(t, driver.Write("harvester/vmbackups/default-backup-1.cfg", bytes.NewReader([]byte("metadata"))))
+	assert.NoError(t, driver.Write("harvester/vmbackups/default-backup-2.cfg", bytes.NewReader([]byte("metadata"))))
+	assert.NoError(t, driver.Write("backupstore/volumes/volume.cfg", bytes.NewReader([]byte("volume"))))
+
+	names, err := driver.List("")
+	assert.NoError(t, err)
+	sort.Strings(names)
+	assert.Equal(t, []string{"backupstore", "harvester"}, names)
+
+	names, err = driver.List("harvester/vmbackups")
+	assert.NoError(t, err)
+	sort.Strings(names)
+	assert.Equal(t, []string{"default-backup-1.cfg", "default-backup-2.cfg"}, names)
+
+	assert.True(t, driver.FileExists("harvester/vmbackups/default-backup-1.cfg"))
+	assert.Equal(t, int64(len("metadata")), driver.FileSize("harvester/vmbackups/default-backup-1.cfg"))
+	rc, err := driver.Read("harvester/vmbackups/default-backup-1.cfg")
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(rc)
+	rc.Close()
+	assert.Equal(t, "metadata", string(data))
+
+	assert.NoError(t, driver.Remove("harvester/vmbackups/default-backup-1.cfg"))
+	assert.False(t, driver.FileExists("harvester/vmbackups/default-backup-1.cfg"))
+	assert.True(t, driver.FileExists("harvester/vmbackups/default-backup-2.cfg"))
+
+	// the credentials are the driver's own rather than the ones in the process environment
+	other, err := newS3BackupStoreDriver("backups", "us-east-1", map[string]string{
+		AWSAccessKey: "other-access-key",
+		AWSSecretKey: "secret-key",
+		AWSEndpoints: server.URL,
+	})
+	assert.NoError(t, err)
+	_, err = other.List("")
+	assert.Error(t, err)
+}
@@ -7,13 +7,16 @@ import (
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
 	wranglername "github.com/rancher/wrangler/pkg/name"
+	"github.com/rancher/wrangler/pkg/slice"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/utils/pointer"
 	kv1 "kubevirt.io/client-go/api/v1"
 
 	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
 )
 
 const (
@@ -35,7 +38,7 @@ func isBackupMissingStatus(backup *harvesterv1.VirtualMachineBackup) bool {
 	return backup.Status == nil || backup.Status.SourceSpec == nil || backup.Status.VolumeBackups == nil || backup.Status.BackupTarget == nil
 }
 
-func IsBackupTargetSame(vmBackupTarget *harvesterv1.BackupTarget, target *settings.BackupTarget) bool {
+func IsBackupTargetSame(vmBackupTarget *harvesterv1.BackupTargetLocation, target *settings.BackupTarget) bool {
 	return vmBackupTarget.Endpoint == target.Endpoint && vmBackupTarget.BucketName == target.BucketName && vmBackupTarget.BucketRegion == target.BucketRegion
 }
 
@@ -46,19 +49,103 @@ func isBackupTargetOnAnnotation(backup *harvesterv1.VirtualMachineBackup) bool {
 			backup.Annotations[backupBucketRegionAnnotation] != "")
 }
 
+func isVMSnapshotReady(vmSnapshot *harvesterv1.VirtualMachineSnapshot) bool {
+	return vmSnapshot.Status != nil && vmSnapshot.Status.ReadyToUse != nil && *vmSnapshot.Status.ReadyToUse
+}
+
+func isVMSnapshotMissingStatus(vmSnapshot *harvesterv1.VirtualMachineSnapshot) bool {
+	return vmSnapshot.Status == nil || vmSnapshot.Status.SourceSpec == nil || vmSnapshot.Status.VolumeSnapshots == nil
+}
+
 func isVMRestoreProgressing(vmRestore *harvesterv1.VirtualMachineRestore) bool {
 	return vmRestore.Status == nil || vmRestore.Status.Complete == nil || !*vmRestore.Status.Complete
 }
 
 func isVMRestoreMissingVolumes(vmRestore *harvesterv1.VirtualMachineRestore) bool {
 	return len(vmRestore.Status.VolumeRestores) == 0 ||
-		(!isNewVMOrHasRetainPolicy(vmRestore) && len(vmRestore.Status.DeletedVolumes) == 0)
+		(!isOldVolumesRetained(vmRestore) && len(vmRestore.Status.DeletedVolumes) == 0)
 }
 
 func isNewVMOrHasRetainPolicy(vmRestore *harvesterv1.VirtualMachineRestore) bool {
 	return vmRestore.Spec.NewVM || vmRestore.Spec.DeletionPolicy == harvesterv1.VirtualMachineRestoreRetain
 }
 
+// isOldVolumesRetained tells if the volumes of the target VM are kept after the restore
+func isOldVolumesRetained(vmRestore *harvesterv1.VirtualMachineRestore) bool {
+	return isNewVMOrHasRetainPolicy(vmRestore) || isStandaloneVolumeRestore(vmRestore)
+}
+
+// isPartialRestore tells if only the selected volumes are restored instead of the whole VM
+func isPartialRestore(vmRestore *harvesterv1.VirtualMachineRestore) bool {
+	return len(vmRestore.Spec.Volumes) > 0
+}
+
+// isStandaloneVolumeRestore tells if the selected volumes are restored as new PVCs without touching the target VM
+func isStandaloneVolumeRestore(vmRestore *harvesterv1.VirtualMachineRestore) bool {
+	return isPartialRestore(vmRestore) && vmRestore.Spec.VolumeRestoreMode != harvesterv1.VolumeRestoreReplace
+}
+
+func isVolumeSelected(vmRestore *harvesterv1.VirtualMachineRestore, volumeName string) bool {
+	if !isPartialRestore(vmRestore) {
+		return true
+	}
+	for _, name := range vmRestore.Spec.Volumes {
+		if name == volumeName {
+			return true
+		}
+	}
+	return false
+}
+
+func getVolumeBackup(backup *harvesterv1.VirtualMachineBackup, volumeBackupName string) (harvesterv1.VolumeBackup, error) {
+	for _, volumeBackup := range backup.Status.VolumeBackups {
+		if volumeBackup.Name != nil && *volumeBackup.Name == volumeBackupName {
+			return volumeBackup, nil
+		}
+	}
+	return harvesterv1.VolumeBackup{}, fmt.Errorf("volume backup %s not found in backup %s", volumeBackupName, backup.Name)
+}
+
+// getReplacedPVCNames returns the PVCs of the target VM which are replaced by the restored volumes
+func getReplacedPVCNames(vm *kv1.VirtualMachine, vmRestore *harvesterv1.VirtualMachineRestore) []string {
+	var pvcNames []string
+	for _, vol := range vm.Spec.Template.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+		for _, vr := range vmRestore.Status.VolumeRestores {
+			if vr.VolumeName == vol.Name {
+				pvcNames = append(pvcNames, vol.PersistentVolumeClaim.ClaimName)
+			}
+		}
+	}
+	return pvcNames
+}
+
+// removeVolumeClaimTemplates removes the PVCs from the volumeClaimTemplates annotation,
+// so that the replaced PVCs aren't created again after being deleted.
+func removeVolumeClaimTemplates(annotations map[string]string, pvcNames []string) error {
+	volumeClaimTemplatesStr, ok := annotations[util.AnnotationVolumeClaimTemplates]
+	if !ok {
+		return nil
+	}
+	var volumeClaimTemplates, toUpdateVolumeClaimTemplates []corev1.PersistentVolumeClaim
+	if err := json.Unmarshal([]byte(volumeClaimTemplatesStr), &volumeClaimTemplates); err != nil {
+		return err
+	}
+	for _, volumeClaimTemplate := range volumeClaimTemplates {
+		if !slice.ContainsString(pvcNames, volumeClaimTemplate.Name) {
+			toUpdateVolumeClaimTemplates = append(toUpdateVolumeClaimTemplates, volumeClaimTemplate)
+		}
+	}
+	toUpdateVolumeClaimTemplatesBytes, err := json.Marshal(toUpdateVolumeClaimTemplates)
+	if err != nil {
+		return err
+	}
+	annotations[util.AnnotationVolumeClaimTemplates] = string(toUpdateVolumeClaimTemplatesBytes)
+	return nil
+}
+
 func GetVMBackupError(vmBackup *harvesterv1.VirtualMachineBackup) *harvesterv1.Error {
 	if vmBackup.Status != nil && vmBackup.Status.Error != nil {
 		return vmBackup.Status.Error
@@ -88,6 +175,16 @@ func newProgressingCondition(status corev1.ConditionStatus, reason string, messa
 	}
 }
 
+func newVerifiedCondition(status corev1.ConditionStatus, reason string, message string) harvesterv1.Condition {
+	return harvesterv1.Condition{
+		Type:               harvesterv1.BackupConditionVerified,
+		Status:             status,
+		Message:            message,
+		Reason:             reason,
+		LastTransitionTime: currentTime().Format(time.RFC3339),
+	}
+}
+
 func updateBackupCondition(ss *harvesterv1.VirtualMachineBackup, c harvesterv1.Condition) {
 	ss.Status.Conditions = updateCondition(ss.Status.Conditions, c)
 }
@@ -111,6 +208,10 @@ func updateCondition(conditions []harvesterv1.Condition, c harvesterv1.Condition
 	return conditions
 }
 
+func updateVMSnapshotCondition(vmSnapshot *harvesterv1.VirtualMachineSnapshot, c harvesterv1.Condition) {
+	vmSnapshot.Status.Conditions = updateCondition(vmSnapshot.Status.Conditions, c)
+}
+
 func translateError(e *snapshotv1.VolumeSnapshotError) *harvesterv1.Error {
 	if e == nil {
 		return nil
@@ -157,7 +258,11 @@ func getNewVolumes(vm *kv1.VirtualMachineSpec, vmRestore *harvesterv1.VirtualMac
 }
 
 func getRestorePVCName(vmRestore *harvesterv1.VirtualMachineRestore, name string) string {
-	s := fmt.Sprintf("restore-%s-%s-%s", vmRestore.Spec.VirtualMachineBackupName, vmRestore.UID, name)
+	sourceName := vmRestore.Spec.VirtualMachineBackupName
+	if vmRestore.Spec.VirtualMachineSnapshotName != "" {
+		sourceName = vmRestore.Spec.VirtualMachineSnapshotName
+	}
+	s := fmt.Sprintf("restore-%s-%s-%s", sourceName, vmRestore.UID, name)
 	return s
 }
 
@@ -228,3 +333,56 @@ func getSecretRefName(vmName string, secretName string) string {
 func getVMBackupMetadataFileName(vmBackupNamespace, vmBackupName string) string {
 	return fmt.Sprintf("%s-%s.cfg", vmBackupNamespace, vmBackupName)
 }
+
+// GetRestoreStorageClassName returns the storage class to restore a volume of the source storage class to
+func GetRestoreStorageClassName(vmRestore *harvesterv1.VirtualMachineRestore, storageClassName string) string {
+	if vmRestore.Spec.Mapping == nil {
+		return storageClassName
+	}
+	return getMappedName(vmRestore.Spec.Mapping.StorageClasses, storageClassName)
+}
+
+// GetRestoreNetworkName returns the multus network to attach the restored VM to instead of the source network
+func GetRestoreNetworkName(vmRestore *harvesterv1.VirtualMachineRestore, networkName string) string {
+	if vmRestore.Spec.Mapping == nil {
+		return networkName
+	}
+	target, _ := getMappedNamespacedName(vmRestore.Spec.Mapping, vmRestore.Spec.Mapping.Networks, networkName)
+	return target
+}
+
+// GetRestoreImageID returns the image ID mapped from the source image ID and whether there is a mapping for it
+func GetRestoreImageID(vmRestore *harvesterv1.VirtualMachineRestore, imageID string) (string, bool) {
+	if vmRestore.Spec.Mapping == nil {
+		return imageID, false
+	}
+	return getMappedNamespacedName(vmRestore.Spec.Mapping, vmRestore.Spec.Mapping.Images, imageID)
+}
+
+// getMappedNamespacedName maps the <namespace>/<name> by the mapping of its name, or else by the mapping of its namespace
+func getMappedNamespacedName(mapping *harvesterv1.RestoreMapping, names map[string]string, namespacedName string) (string, bool) {
+	if target, ok := names[namespacedName]; ok && target != "" {
+		return target, true
+	}
+	namespace, name := ref.Parse(namespacedName)
+	if target, ok := mapping.Namespaces[namespace]; ok && target != "" && namespace != "" {
+		return ref.Construct(target, name), true
+	}
+	return namespacedName, false
+}
+
+func getMappedName(mapping map[string]string, name string) string {
+	if target, ok := mapping[name]; ok && target != "" {
+		return target
+	}
+	return name
+}
+
+func mapRestoreNetworks(vmRestore *harvesterv1.VirtualMachineRestore, spec *kv1.VirtualMachineInstanceSpec) {
+	for i, network := range spec.Networks {
+		if network.Multus == nil {
+			continue
+		}
+		spec.Networks[i].Multus.NetworkName = GetRestoreNetworkName(vmRestore, network.Multus.NetworkName)
+	}
+}
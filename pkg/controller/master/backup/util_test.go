@@ -0,0 +1,230 @@
+package backup
+
+import (
+	"testing"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+func TestRestoreMapping(t *testing.T) {
+	var mappedRestore = &harvesterv1.VirtualMachineRestore{
+		Spec: harvesterv1.VirtualMachineRestoreSpec{
+			Mapping: &harvesterv1.RestoreMapping{
+				StorageClasses: map[string]string{"longhorn": "longhorn-ssd"},
+				Networks:       map[string]string{"default/vlan1": "dr/vlan100"},
+				Images:         map[string]string{"default/image-abcde": "dr/image-fghij"},
+			},
+		},
+	}
+	var unmappedRestore = &harvesterv1.VirtualMachineRestore{}
+
+	assert.Equal(t, "longhorn-ssd", GetRestoreStorageClassName(mappedRestore, "longhorn"))
+	assert.Equal(t, "longhorn-hdd", GetRestoreStorageClassName(mappedRestore, "longhorn-hdd"))
+	assert.Equal(t, "longhorn", GetRestoreStorageClassName(unmappedRestore, "longhorn"))
+
+	imageID, mapped := GetRestoreImageID(mappedRestore, "default/image-abcde")
+	assert.True(t, mapped)
+	assert.Equal(t, "dr/image-fghij", imageID)
+	imageID, mapped = GetRestoreImageID(mappedRestore, "default/image-other")
+	assert.False(t, mapped)
+	assert.Equal(t, "default/image-other", imageID)
+	_, mapped = GetRestoreImageID(unmappedRestore, "default/image-abcde")
+	assert.False(t, mapped)
+
+	var spec = kv1.VirtualMachineInstanceSpec{
+		Networks: []kv1.Network{
+			{Name: "default", NetworkSource: kv1.NetworkSource{Pod: &kv1.PodNetwork{}}},
+			{Name: "nic-1", NetworkSource: kv1.NetworkSource{Multus: &kv1.MultusNetwork{NetworkName: "default/vlan1"}}},
+			{Name: "nic-2", NetworkSource: kv1.NetworkSource{Multus: &kv1.MultusNetwork{NetworkName: "default/vlan2"}}},
+		},
+	}
+	mapRestoreNetworks(mappedRestore, &spec)
+	assert.Equal(t, "dr/vlan100", spec.Networks[1].Multus.NetworkName)
+	assert.Equal(t, "default/vlan2", spec.Networks[2].Multus.NetworkName)
+
+	// the namespaces are mapped unless the names are
+	mappedRestore.Spec.Mapping.Namespaces = map[string]string{"default": "dr"}
+	assert.Equal(t, "dr/vlan100", GetRestoreNetworkName(mappedRestore, "default/vlan1"))
+	assert.Equal(t, "dr/vlan2", GetRestoreNetworkName(mappedRestore, "default/vlan2"))
+	assert.Equal(t, "other/vlan3", GetRestoreNetworkName(mappedRestore, "other/vlan3"))
+	assert.Equal(t, "vlan4", GetRestoreNetworkName(mappedRestore, "vlan4"))
+	imageID, mapped = GetRestoreImageID(mappedRestore, "default/image-abcde")
+	assert.True(t, mapped)
+	assert.Equal(t, "dr/image-fghij", imageID)
+	imageID, mapped = GetRestoreImageID(mappedRestore, "default/image-other")
+	assert.True(t, mapped)
+	assert.Equal(t, "dr/image-other", imageID)
+	_, mapped = GetRestoreImageID(mappedRestore, "other/image-other")
+	assert.False(t, mapped)
+}
+
+func TestIsSameBackupStore(t *testing.T) {
+	var tests = []struct {
+		name     string
+		a        *settings.BackupTarget
+		b        *settings.BackupTarget
+		expected bool
+	}{
+		{
+			name:     "nfs with and without prefix",
+			a:        &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "nfs://10.0.0.1:/backups"},
+			b:        &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "10.0.0.1:/backups"},
+			expected: true,
+		},
+		{
+			name:     "different nfs shares",
+			a:        &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "10.0.0.1:/backups"},
+			b:        &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "10.0.0.2:/backups"},
+			expected: false,
+		},
+		{
+			name:     "same s3 bucket",
+			a:        &settings.BackupTarget{Type: settings.S3BackupType, Endpoint: "https://s3.example.com", BucketName: "backups", BucketRegion: "us-east-1"},
+			b:        &settings.BackupTarget{Type: settings.S3BackupType, Endpoint: "https://s3.example.com", BucketName: "backups", BucketRegion: "us-east-1", AccessKeyID: "key"},
+			expected: true,
+		},
+		{
+			name:     "same s3 bucket on different endpoints",
+			a:        &settings.BackupTarget{Type: settings.S3BackupType, Endpoint: "https://s3.example.com", BucketName: "backups", BucketRegion: "us-east-1"},
+			b:        &settings.BackupTarget{Type: settings.S3BackupType, Endpoint: "https://minio.example.com", BucketName: "backups", BucketRegion: "us-east-1"},
+			expected: false,
+		},
+		{
+			name:     "different types",
+			a:        &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "10.0.0.1:/backups"},
+			b:        &settings.BackupTarget{},
+			expected: false,
+		},
+	}
+
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, isSameBackupStore(tc.a, tc.b), tc.name)
+	}
+}
+
+func TestGetBackupTargetName(t *testing.T) {
+	newBackupTarget := func(name, endpoint string) *harvesterv1.BackupTarget {
+		return &harvesterv1.BackupTarget{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       harvesterv1.BackupTargetSpec{Type: harvesterv1.BackupTargetTypeNFS, Endpoint: endpoint},
+		}
+	}
+	clientset := fake.NewSimpleClientset(
+		newBackupTarget("offsite", "10.0.0.2:/backups"),
+		newBackupTarget("daily", "10.0.0.1:/backups"),
+		newBackupTarget("nfs", "10.0.0.1:/backups"),
+	)
+	backupTargetCache := fakeclients.BackupTargetCache(clientset.HarvesterhciV1beta1().BackupTargets)
+
+	name, err := getBackupTargetName(backupTargetCache, &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "nfs://10.0.0.1:/backups"})
+	assert.NoError(t, err)
+	assert.Equal(t, "daily", name)
+
+	name, err = getBackupTargetName(backupTargetCache, &settings.BackupTarget{Type: settings.NFSBackupType, Endpoint: "nfs://10.0.0.3:/backups"})
+	assert.NoError(t, err)
+	assert.Empty(t, name)
+}
+
+func TestCheckVMSnapshotClass(t *testing.T) {
+	snapClass := &snapshotv1.VolumeSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "longhorn-snapshot"},
+		Driver:     "driver.longhorn.io",
+		Parameters: map[string]string{"type": "snap"},
+	}
+	backupClass := &snapshotv1.VolumeSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "longhorn"},
+		Driver:     "driver.longhorn.io",
+	}
+	otherClass := &snapshotv1.VolumeSnapshotClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "rbd"},
+		Driver:     "rbd.csi.ceph.com",
+	}
+
+	assert.NoError(t, checkVMSnapshotClass(snapClass, "v1.3.2"))
+	assert.NoError(t, checkVMSnapshotClass(otherClass, ""))
+	// the classes without the snap type take backups
+	assert.Error(t, checkVMSnapshotClass(backupClass, "v1.3.2"))
+	// Longhorn v1.2 ignores the snap type and has no current-longhorn-version setting
+	assert.Error(t, checkVMSnapshotClass(snapClass, ""))
+	assert.Error(t, checkVMSnapshotClass(snapClass, "v1.2.3"))
+}
+
+func TestPartialRestore(t *testing.T) {
+	backup := &harvesterv1.VirtualMachineBackup{
+		Status: &harvesterv1.VirtualMachineBackupStatus{
+			VolumeBackups: []harvesterv1.VolumeBackup{
+				{Name: pointer.StringPtr("backup-rootdisk"), VolumeName: "rootdisk"},
+				{Name: pointer.StringPtr("backup-datadisk"), VolumeName: "datadisk"},
+			},
+		},
+	}
+	vmRestore := &harvesterv1.VirtualMachineRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "default", UID: "uid"},
+		Spec: harvesterv1.VirtualMachineRestoreSpec{
+			VirtualMachineBackupName: "backup",
+			Volumes:                  []string{"datadisk"},
+			VolumeRestoreMode:        harvesterv1.VolumeRestoreReplace,
+		},
+		Status: &harvesterv1.VirtualMachineRestoreStatus{},
+	}
+
+	volumeRestores, err := getVolumeRestores(vmRestore, backup)
+	assert.Nil(t, err)
+	assert.Len(t, volumeRestores, 1)
+	assert.Equal(t, "datadisk", volumeRestores[0].VolumeName)
+	assert.Equal(t, "backup-datadisk", volumeRestores[0].VolumeBackupName)
+	vmRestore.Status.VolumeRestores = volumeRestores
+
+	volumeBackup, err := getVolumeBackup(backup, "backup-datadisk")
+	assert.Nil(t, err)
+	assert.Equal(t, "datadisk", volumeBackup.VolumeName)
+
+	vm := &kv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				util.AnnotationVolumeClaimTemplates: `[{"metadata":{"name":"vm-rootdisk"}},{"metadata":{"name":"vm-datadisk"}}]`,
+			},
+		},
+		Spec: kv1.VirtualMachineSpec{
+			Template: &kv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kv1.VirtualMachineInstanceSpec{
+					Volumes: []kv1.Volume{
+						{Name: "rootdisk", VolumeSource: kv1.VolumeSource{PersistentVolumeClaim: &kv1.PersistentVolumeClaimVolumeSource{
+							PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: "vm-rootdisk"},
+						}}},
+						{Name: "datadisk", VolumeSource: kv1.VolumeSource{PersistentVolumeClaim: &kv1.PersistentVolumeClaimVolumeSource{
+							PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: "vm-datadisk"},
+						}}},
+					},
+				},
+			},
+		},
+	}
+
+	replaced := getReplacedPVCNames(vm, vmRestore)
+	assert.Equal(t, []string{"vm-datadisk"}, replaced)
+
+	newVolumes, err := getNewVolumes(&vm.Spec, vmRestore)
+	assert.Nil(t, err)
+	assert.Equal(t, "vm-rootdisk", newVolumes[0].PersistentVolumeClaim.ClaimName)
+	assert.Equal(t, volumeRestores[0].PersistentVolumeClaim.ObjectMeta.Name, newVolumes[1].PersistentVolumeClaim.ClaimName)
+
+	assert.Nil(t, removeVolumeClaimTemplates(vm.Annotations, replaced))
+	assert.JSONEq(t, `[{"metadata":{"name":"vm-rootdisk","creationTimestamp":null},"spec":{"resources":{}},"status":{}}]`, vm.Annotations[util.AnnotationVolumeClaimTemplates])
+
+	assert.False(t, isOldVolumesRetained(vmRestore))
+	vmRestore.Spec.VolumeRestoreMode = ""
+	assert.True(t, isStandaloneVolumeRestore(vmRestore))
+	assert.True(t, isOldVolumesRetained(vmRestore))
+}
@@ -0,0 +1,428 @@
+package backup
+
+// Harvester VM snapshot controller takes in-cluster snapshots of the VM volumes with the VolumeSnapshotClass
+// of the vm-snapshot-class setting, the snapshots are kept by the storage driver and no backup target is needed.
+// A VM is reverted to a snapshot by a VirtualMachineRestore referring to the VirtualMachineSnapshot.
+// Longhorn takes in-cluster CSI snapshots since v1.3.0 with the "type: snap" class parameter, the older versions
+// upload a backup to the backup target for every CSI snapshot instead.
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	"github.com/longhorn/longhorn-manager/types"
+	gversion "github.com/mcuadros/go-version"
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	ctllonghornv1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
+	ctlsnapshotv1 "github.com/harvester/harvester/pkg/generated/controllers/snapshot.storage.k8s.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	vmSnapshotControllerName               = "harvester-vm-snapshot-controller"
+	vmSnapshotVolumeSnapshotControllerName = "harvester-vm-snapshot-volume-snapshot-controller"
+	vmSnapshotKindName                     = "VirtualMachineSnapshot"
+
+	longhornSnapshotTypeParameter  = "type"
+	longhornSnapshotTypeSnap       = "snap"
+	longhornVersionSettingName     = "current-longhorn-version"
+	longhornMinSnapshotTypeVersion = "v1.3.0"
+)
+
+var vmSnapshotKind = harvesterv1.SchemeGroupVersion.WithKind(vmSnapshotKindName)
+
+// RegisterVMSnapshot register the vm snapshot controller
+func RegisterVMSnapshot(ctx context.Context, management *config.Management, opts config.Options) error {
+	vmSnapshots := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineSnapshot()
+	pvc := management.CoreFactory.Core().V1().PersistentVolumeClaim()
+	secrets := management.CoreFactory.Core().V1().Secret()
+	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
+	volumes := management.LonghornFactory.Longhorn().V1beta1().Volume()
+	snapshots := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshot()
+	snapshotClass := management.SnapshotFactory.Snapshot().V1beta1().VolumeSnapshotClass()
+	longhornSettings := management.LonghornFactory.Longhorn().V1beta1().Setting()
+
+	vmSnapshotHandler := &VMSnapshotHandler{
+		vmSnapshots:          vmSnapshots,
+		vmSnapshotController: vmSnapshots,
+		vmSnapshotCache:      vmSnapshots.Cache(),
+		pvcs:                 pvc,
+		pvcCache:             pvc.Cache(),
+		secretCache:          secrets.Cache(),
+		vmsCache:             vms.Cache(),
+		volumeCache:          volumes.Cache(),
+		volumes:              volumes,
+		snapshots:            snapshots,
+		snapshotCache:        snapshots.Cache(),
+		snapshotClassCache:   snapshotClass.Cache(),
+		longhornSettingCache: longhornSettings.Cache(),
+		recorder:             management.NewRecorder(vmSnapshotControllerName, "", ""),
+	}
+
+	vmSnapshots.OnChange(ctx, vmSnapshotControllerName, vmSnapshotHandler.OnVMSnapshotChange)
+	vmSnapshots.OnRemove(ctx, vmSnapshotControllerName, vmSnapshotHandler.OnVMSnapshotRemove)
+	snapshots.OnChange(ctx, vmSnapshotVolumeSnapshotControllerName, vmSnapshotHandler.OnVolumeSnapshotChange)
+	return nil
+}
+
+type VMSnapshotHandler struct {
+	vmSnapshots          ctlharvesterv1.VirtualMachineSnapshotClient
+	vmSnapshotController ctlharvesterv1.VirtualMachineSnapshotController
+	vmSnapshotCache      ctlharvesterv1.VirtualMachineSnapshotCache
+	pvcs                 ctlcorev1.PersistentVolumeClaimClient
+	pvcCache             ctlcorev1.PersistentVolumeClaimCache
+	secretCache          ctlcorev1.SecretCache
+	vmsCache             ctlkubevirtv1.VirtualMachineCache
+	volumeCache          ctllonghornv1.VolumeCache
+	volumes              ctllonghornv1.VolumeClient
+	snapshots            ctlsnapshotv1.VolumeSnapshotClient
+	snapshotCache        ctlsnapshotv1.VolumeSnapshotCache
+	snapshotClassCache   ctlsnapshotv1.VolumeSnapshotClassCache
+	longhornSettingCache ctllonghornv1.SettingCache
+	recorder             record.EventRecorder
+}
+
+// OnVMSnapshotChange handles vm snapshot object on change and reconcile vm snapshot status
+func (h *VMSnapshotHandler) OnVMSnapshotChange(key string, vmSnapshot *harvesterv1.VirtualMachineSnapshot) (*harvesterv1.VirtualMachineSnapshot, error) {
+	if vmSnapshot == nil || vmSnapshot.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	if isVMSnapshotReady(vmSnapshot) {
+		return nil, nil
+	}
+
+	if isVMSnapshotMissingStatus(vmSnapshot) {
+		sourceVM, err := h.getVMSnapshotSource(vmSnapshot)
+		if err != nil {
+			return nil, h.setStatusError(vmSnapshot, err)
+		}
+		if sourceVM.DeletionTimestamp != nil {
+			return nil, h.setStatusError(vmSnapshot, fmt.Errorf("vm %s/%s is being deleted", vmSnapshot.Namespace, vmSnapshot.Spec.Source.Name))
+		}
+
+		// check if the VM is running, if not make sure the volumes are mounted to the host
+		if !sourceVM.Status.Ready || !sourceVM.Status.Created {
+			if err := mountLonghornVolumes(h.pvcCache, h.volumeCache, h.volumes, sourceVM); err != nil {
+				return nil, h.setStatusError(vmSnapshot, err)
+			}
+		}
+
+		return nil, h.initVMSnapshot(vmSnapshot, sourceVM)
+	}
+
+	if err := h.reconcileVolumeSnapshots(vmSnapshot); err != nil {
+		return nil, h.setStatusError(vmSnapshot, err)
+	}
+
+	return nil, nil
+}
+
+// OnVMSnapshotRemove deletes the volumes replaced by reverting the VM which are kept only for the snapshot
+func (h *VMSnapshotHandler) OnVMSnapshotRemove(key string, vmSnapshot *harvesterv1.VirtualMachineSnapshot) (*harvesterv1.VirtualMachineSnapshot, error) {
+	if vmSnapshot == nil || vmSnapshot.Status == nil {
+		return nil, nil
+	}
+
+	for _, volumeSnapshot := range vmSnapshot.Status.VolumeSnapshots {
+		pvc, err := h.pvcCache.Get(vmSnapshot.Namespace, volumeSnapshot.PersistentVolumeClaim.ObjectMeta.Name)
+		if apierrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if _, ok := pvc.Annotations[replacedVolumeAnnotation]; !ok || pvc.DeletionTimestamp != nil {
+			continue
+		}
+		referenced, err := isVolumeInVMSnapshots(h.vmSnapshotCache, pvc.Namespace, pvc.Name, vmSnapshot.Name)
+		if err != nil {
+			return nil, err
+		}
+		if referenced {
+			continue
+		}
+		logrus.Infof("deleting volume %s/%s replaced by reverting the VM", pvc.Namespace, pvc.Name)
+		if err := h.pvcs.Delete(pvc.Namespace, pvc.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// isVolumeInVMSnapshots tells if the volume is referenced by any VM snapshot other than the excluded one
+func isVolumeInVMSnapshots(vmSnapshotCache ctlharvesterv1.VirtualMachineSnapshotCache, namespace, pvcName, excludedName string) (bool, error) {
+	vmSnapshots, err := vmSnapshotCache.List(namespace, labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	for _, vmSnapshot := range vmSnapshots {
+		if vmSnapshot.Name == excludedName || vmSnapshot.DeletionTimestamp != nil || vmSnapshot.Status == nil {
+			continue
+		}
+		for _, volumeSnapshot := range vmSnapshot.Status.VolumeSnapshots {
+			if volumeSnapshot.PersistentVolumeClaim.ObjectMeta.Name == pvcName {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// OnVolumeSnapshotChange enqueues the vm snapshot owning the volume snapshot
+func (h *VMSnapshotHandler) OnVolumeSnapshotChange(key string, snapshot *snapshotv1.VolumeSnapshot) (*snapshotv1.VolumeSnapshot, error) {
+	if snapshot == nil || snapshot.DeletionTimestamp != nil {
+		return nil, nil
+	}
+
+	controllerRef := metav1.GetControllerOf(snapshot)
+	if controllerRef == nil || controllerRef.Kind != vmSnapshotKind.Kind {
+		return nil, nil
+	}
+
+	vmSnapshot, err := h.vmSnapshotCache.Get(snapshot.Namespace, controllerRef.Name)
+	if err != nil || vmSnapshot.UID != controllerRef.UID {
+		return nil, nil
+	}
+	h.vmSnapshotController.Enqueue(vmSnapshot.Namespace, vmSnapshot.Name)
+	return nil, nil
+}
+
+func (h *VMSnapshotHandler) getVMSnapshotSource(vmSnapshot *harvesterv1.VirtualMachineSnapshot) (*kv1.VirtualMachine, error) {
+	switch vmSnapshot.Spec.Source.Kind {
+	case kv1.VirtualMachineGroupVersionKind.Kind:
+		return h.vmsCache.Get(vmSnapshot.Namespace, vmSnapshot.Spec.Source.Name)
+	}
+
+	return nil, fmt.Errorf("unsupported source: %+v", vmSnapshot.Spec.Source)
+}
+
+// initVMSnapshot initialize VM snapshot status with the VM spec, volumes and secrets
+func (h *VMSnapshotHandler) initVMSnapshot(vmSnapshot *harvesterv1.VirtualMachineSnapshot, vm *kv1.VirtualMachine) error {
+	var err error
+	vmSnapshotCpy := vmSnapshot.DeepCopy()
+	vmSnapshotCpy.Status = &harvesterv1.VirtualMachineSnapshotStatus{
+		ReadyToUse: pointer.BoolPtr(false),
+		SourceUID:  &vm.UID,
+		SourceSpec: &harvesterv1.VirtualMachineSourceSpec{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        vm.ObjectMeta.Name,
+				Namespace:   vm.ObjectMeta.Namespace,
+				Annotations: vm.ObjectMeta.Annotations,
+				Labels:      vm.ObjectMeta.Labels,
+			},
+			Spec: vm.Spec,
+		},
+	}
+
+	if vmSnapshotCpy.Status.VolumeSnapshots, err = getVolumeBackups(h.pvcCache, vmSnapshot.Name, vm); err != nil {
+		return err
+	}
+
+	if vmSnapshotCpy.Status.SecretBackups, err = getSecretBackups(h.secretCache, vm); err != nil {
+		return err
+	}
+
+	updateVMSnapshotCondition(vmSnapshotCpy, newProgressingCondition(corev1.ConditionTrue, "", "Operation in progress"))
+	updateVMSnapshotCondition(vmSnapshotCpy, newReadyCondition(corev1.ConditionFalse, "", "Not ready"))
+
+	_, err = h.vmSnapshots.Update(vmSnapshotCpy)
+	return err
+}
+
+// reconcileVolumeSnapshots creates the volume snapshots if not exist and updates the vm snapshot status
+// upon the status of the volume snapshots
+func (h *VMSnapshotHandler) reconcileVolumeSnapshots(vmSnapshot *harvesterv1.VirtualMachineSnapshot) error {
+	vmSnapshotCpy := vmSnapshot.DeepCopy()
+	ready := true
+	errorMessage := ""
+	for i, volumeSnapshot := range vmSnapshotCpy.Status.VolumeSnapshots {
+		snapshot, err := h.snapshotCache.Get(vmSnapshot.Namespace, *volumeSnapshot.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		if snapshot != nil && !metav1.IsControlledBy(snapshot, vmSnapshot) {
+			return fmt.Errorf("VolumeSnapshot %s/%s already exists and is not owned by vm snapshot %s",
+				snapshot.Namespace, snapshot.Name, vmSnapshot.Name)
+		}
+
+		if snapshot != nil && snapshot.DeletionTimestamp != nil {
+			logrus.Debugf("volumeSnapshot %s/%s is being deleted, requeue vm snapshot %s/%s again",
+				snapshot.Namespace, snapshot.Name, vmSnapshot.Namespace, vmSnapshot.Name)
+			h.vmSnapshotController.EnqueueAfter(vmSnapshot.Namespace, vmSnapshot.Name, 5*time.Second)
+			return nil
+		}
+
+		if snapshot == nil {
+			if snapshot, err = h.createVolumeSnapshot(vmSnapshotCpy, volumeSnapshot); err != nil {
+				return err
+			}
+		}
+
+		if snapshot.Status != nil {
+			vmSnapshotCpy.Status.VolumeSnapshots[i].ReadyToUse = snapshot.Status.ReadyToUse
+			vmSnapshotCpy.Status.VolumeSnapshots[i].CreationTime = snapshot.Status.CreationTime
+			vmSnapshotCpy.Status.VolumeSnapshots[i].Error = translateError(snapshot.Status.Error)
+		}
+
+		if vs := vmSnapshotCpy.Status.VolumeSnapshots[i]; vs.ReadyToUse == nil || !*vs.ReadyToUse {
+			ready = false
+			if vs.Error != nil && errorMessage == "" {
+				errorMessage = fmt.Sprintf("VolumeSnapshot %s in error state", *vs.Name)
+			}
+		}
+	}
+
+	// check if the status need to update the error status
+	if errorMessage != "" && (vmSnapshotCpy.Status.Error == nil || vmSnapshotCpy.Status.Error.Message == nil || *vmSnapshotCpy.Status.Error.Message != errorMessage) {
+		vmSnapshotCpy.Status.Error = &harvesterv1.Error{
+			Time:    currentTime(),
+			Message: pointer.StringPtr(errorMessage),
+		}
+		updateVMSnapshotCondition(vmSnapshotCpy, newProgressingCondition(corev1.ConditionFalse, "Error", errorMessage))
+		updateVMSnapshotCondition(vmSnapshotCpy, newReadyCondition(corev1.ConditionFalse, "", "Not Ready"))
+	}
+
+	if ready {
+		vmSnapshotCpy.Status.ReadyToUse = pointer.BoolPtr(true)
+		vmSnapshotCpy.Status.CreationTime = currentTime()
+		vmSnapshotCpy.Status.Error = nil
+		updateVMSnapshotCondition(vmSnapshotCpy, newProgressingCondition(corev1.ConditionFalse, "", "Operation complete"))
+		updateVMSnapshotCondition(vmSnapshotCpy, newReadyCondition(corev1.ConditionTrue, "", "Operation complete"))
+	}
+
+	if !reflect.DeepEqual(vmSnapshot.Status, vmSnapshotCpy.Status) {
+		if _, err := h.vmSnapshots.Update(vmSnapshotCpy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *VMSnapshotHandler) createVolumeSnapshot(vmSnapshot *harvesterv1.VirtualMachineSnapshot, volumeSnapshot harvesterv1.VolumeBackup) (*snapshotv1.VolumeSnapshot, error) {
+	if settings.VMSnapshotClass.Get() == "" {
+		return nil, fmt.Errorf("the %s setting is not configured", settings.VMSnapshotClassSettingName)
+	}
+	sc, err := h.snapshotClassCache.Get(settings.VMSnapshotClass.Get())
+	if err != nil {
+		return nil, fmt.Errorf("%s/%s VolumeSnapshot requested but no snapshot class, err: %s",
+			vmSnapshot.Namespace, volumeSnapshot.PersistentVolumeClaim.ObjectMeta.Name, err.Error())
+	}
+	if err := h.checkLonghornSnapshotSupported(sc); err != nil {
+		return nil, err
+	}
+
+	if _, err := h.pvcCache.Get(vmSnapshot.Namespace, volumeSnapshot.PersistentVolumeClaim.ObjectMeta.Name); err != nil {
+		return nil, err
+	}
+
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      *volumeSnapshot.Name,
+			Namespace: vmSnapshot.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion:         harvesterv1.SchemeGroupVersion.String(),
+					Kind:               vmSnapshotKind.Kind,
+					Name:               vmSnapshot.Name,
+					UID:                vmSnapshot.UID,
+					Controller:         pointer.BoolPtr(true),
+					BlockOwnerDeletion: pointer.BoolPtr(true),
+				},
+			},
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &volumeSnapshot.PersistentVolumeClaim.ObjectMeta.Name,
+			},
+			VolumeSnapshotClassName: pointer.StringPtr(sc.Name),
+		},
+	}
+
+	logrus.Debugf("create VolumeSnapshot %s/%s", vmSnapshot.Namespace, *volumeSnapshot.Name)
+	if snapshot, err = h.snapshots.Create(snapshot); err != nil {
+		return nil, err
+	}
+
+	h.recorder.Eventf(
+		vmSnapshot,
+		corev1.EventTypeNormal,
+		volumeSnapshotCreateEvent,
+		"Successfully created VolumeSnapshot %s",
+		snapshot.Name,
+	)
+	return snapshot, nil
+}
+
+// checkLonghornSnapshotSupported makes sure Longhorn keeps the snapshots of the class in the cluster
+func (h *VMSnapshotHandler) checkLonghornSnapshotSupported(sc *snapshotv1.VolumeSnapshotClass) error {
+	if sc.Driver != types.LonghornDriverName {
+		return nil
+	}
+	longhornVersion := ""
+	if setting, err := h.longhornSettingCache.Get(util.LonghornSystemNamespaceName, longhornVersionSettingName); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	} else if err == nil {
+		longhornVersion = setting.Value
+	}
+	return checkVMSnapshotClass(sc, longhornVersion)
+}
+
+// checkVMSnapshotClass checks if the class takes in-cluster snapshots. The Longhorn versions without the
+// current-longhorn-version setting are older than v1.3.0.
+func checkVMSnapshotClass(sc *snapshotv1.VolumeSnapshotClass, longhornVersion string) error {
+	if sc.Driver != types.LonghornDriverName {
+		return nil
+	}
+	if err := CheckVMSnapshotClassParameters(sc); err != nil {
+		return err
+	}
+	if longhornVersion == "" || gversion.Compare(longhornVersion, longhornMinSnapshotTypeVersion, "<") {
+		return fmt.Errorf("longhorn %s doesn't support in-cluster snapshots, %s or later is required",
+			longhornVersion, longhornMinSnapshotTypeVersion)
+	}
+	return nil
+}
+
+// CheckVMSnapshotClassParameters checks if a Longhorn class takes snapshots rather than backups
+func CheckVMSnapshotClassParameters(sc *snapshotv1.VolumeSnapshotClass) error {
+	if sc.Driver == types.LonghornDriverName && sc.Parameters[longhornSnapshotTypeParameter] != longhornSnapshotTypeSnap {
+		return fmt.Errorf("VolumeSnapshotClass %s of %s takes backups to the backup target, the %s parameter must be %q",
+			sc.Name, sc.Driver, longhornSnapshotTypeParameter, longhornSnapshotTypeSnap)
+	}
+	return nil
+}
+
+func (h *VMSnapshotHandler) setStatusError(vmSnapshot *harvesterv1.VirtualMachineSnapshot, err error) error {
+	vmSnapshotCpy := vmSnapshot.DeepCopy()
+	if vmSnapshotCpy.Status == nil {
+		vmSnapshotCpy.Status = &harvesterv1.VirtualMachineSnapshotStatus{}
+	}
+	vmSnapshotCpy.Status.Error = &harvesterv1.Error{
+		Time:    currentTime(),
+		Message: pointer.StringPtr(err.Error()),
+	}
+	updateVMSnapshotCondition(vmSnapshotCpy, newProgressingCondition(corev1.ConditionFalse, "Error", err.Error()))
+	updateVMSnapshotCondition(vmSnapshotCpy, newReadyCondition(corev1.ConditionFalse, "", "Not Ready"))
+
+	if _, updateErr := h.vmSnapshots.Update(vmSnapshotCpy); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
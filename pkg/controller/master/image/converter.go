@@ -0,0 +1,304 @@
+package image
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util/diskimage"
+)
+
+const (
+	// conversionWorkDir is where the sources are downloaded and converted, the chart mounts an ephemeral volume
+	// sized for the images to convert there when the conversion is enabled, so the conversions don't fill up the disk
+	// of the node
+	conversionWorkDir = "/var/lib/harvester/harvester/image-conversion"
+
+	// defaultConversionConcurrency is the max number of the images converted at the same time by default
+	defaultConversionConcurrency = 2
+
+	// conversionProgressInterval is the minimal interval of updating the conversion progress of an image
+	conversionProgressInterval = 5 * time.Second
+
+	probeTimeout = 15 * time.Second
+
+	// maxUnpackDepth limits the archives in archives, e.g. an OVA compressed by gzip
+	maxUnpackDepth = 3
+)
+
+// imageConverter downloads the images Longhorn can't import as is, converts them to qcow2 by qemu-img,
+// and uploads the results to the backing images. The download takes the first half of the conversion progress.
+type imageConverter struct {
+	*backingImageUploader
+	downloadClient http.Client
+	workDir        string
+	// slots bounds the conversions running at the same time, as each of them takes the disk space of the image twice
+	slots chan struct{}
+
+	// converting has the keys of the images being converted by this process
+	converting sync.Map
+}
+
+func newImageConverter(uploader *backingImageUploader, workDir string, concurrency int) *imageConverter {
+	if concurrency <= 0 {
+		concurrency = defaultConversionConcurrency
+	}
+	return &imageConverter{
+		backingImageUploader: uploader,
+		workDir:              workDir,
+		slots:                make(chan struct{}, concurrency),
+	}
+}
+
+// probe detects the format of the image to download by its header, as the URLs don't always tell it
+func (c *imageConverter) probe(rawURL string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", diskimage.HeaderSize-1))
+	resp, err := c.downloadClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("got %d status code from %s", resp.StatusCode, rawURL)
+	}
+	header := make([]byte, diskimage.HeaderSize)
+	n, err := io.ReadFull(resp.Body, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return diskimage.DetectFormat(header[:n], urlFileName(rawURL)), nil
+}
+
+// start starts converting the image in background if it's neither being converted nor has been uploaded
+func (c *imageConverter) start(image *harvesterv1.VirtualMachineImage) {
+	if ok, err := c.canUpload(getBackingImageName(image)); err != nil || !ok {
+		if err != nil {
+			logrus.Errorf("failed to check the backing image of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+		return
+	}
+
+	key := ref.Construct(image.Namespace, image.Name)
+	if _, loaded := c.converting.LoadOrStore(key, true); loaded {
+		return
+	}
+	go func() {
+		defer c.converting.Delete(key)
+		// the image stays pending until a slot is free
+		c.slots <- struct{}{}
+		defer func() { <-c.slots }()
+
+		if err := c.convertImage(context.Background(), image); err != nil {
+			logrus.Errorf("failed to convert image %s from %s: %v", key, image.Spec.URL, err)
+			if updateErr := c.updateImage(image, func(toUpdate *harvesterv1.VirtualMachineImage) {
+				if harvesterv1.ImageConverting.IsUnknown(toUpdate) {
+					harvesterv1.ImageConverting.False(toUpdate)
+					harvesterv1.ImageConverting.Reason(toUpdate, "ConversionFailed")
+					harvesterv1.ImageConverting.Message(toUpdate, err.Error())
+				}
+				harvesterv1.ImageImported.False(toUpdate)
+				harvesterv1.ImageImported.Reason(toUpdate, "ImportFailed")
+				harvesterv1.ImageImported.Message(toUpdate, err.Error())
+			}); updateErr != nil {
+				logrus.Errorf("failed to update the conditions of image %s: %v", key, updateErr)
+			}
+		}
+	}()
+}
+
+func (c *imageConverter) convertImage(ctx context.Context, image *harvesterv1.VirtualMachineImage) error {
+	dir := filepath.Join(c.workDir, getBackingImageName(image))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	report := c.progressReporter(image)
+	report("Downloading", 0)
+	file := filepath.Join(dir, "source")
+	if err := c.download(ctx, image.Spec.URL, image.Spec.Checksum, file, func(progress int) {
+		report("Downloading", progress/2)
+	}); err != nil {
+		return err
+	}
+
+	name := urlFileName(image.Spec.URL)
+	format, err := diskimage.DetectFileFormat(file, name)
+	if err != nil {
+		return err
+	}
+	for depth := 0; depth < maxUnpackDepth && (diskimage.IsCompressed(format) || format == diskimage.FormatOVA); depth++ {
+		report("Unpacking", 50)
+		unpacked := filepath.Join(dir, fmt.Sprintf("unpacked-%d", depth))
+		if format == diskimage.FormatOVA {
+			name, err = diskimage.ExtractOVADisk(file, unpacked)
+		} else {
+			name = strings.TrimSuffix(name, path.Ext(name))
+			err = diskimage.Decompress(ctx, format, file, unpacked)
+		}
+		if err != nil {
+			return err
+		}
+		_ = os.Remove(file)
+		file = unpacked
+		if format, err = diskimage.DetectFileFormat(file, name); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case format == diskimage.FormatVMDKDescriptor:
+		return fmt.Errorf("the VMDK is a descriptor without the data, please use a monolithic or stream-optimized VMDK")
+	case diskimage.IsCompressed(format) || format == diskimage.FormatOVA:
+		return fmt.Errorf("too many nested archives")
+	case diskimage.NeedConversion(format):
+		report("Converting", 50)
+		converted := filepath.Join(dir, "converted")
+		if err := diskimage.Convert(ctx, format, file, converted, func(progress int) {
+			report("Converting", 50+progress/2)
+		}); err != nil {
+			return err
+		}
+		_ = os.Remove(file)
+		file = converted
+	}
+
+	if err := c.updateImage(image, func(toUpdate *harvesterv1.VirtualMachineImage) {
+		harvesterv1.ImageConverting.True(toUpdate)
+		harvesterv1.ImageConverting.Reason(toUpdate, "Converted")
+		harvesterv1.ImageConverting.Message(toUpdate, fmt.Sprintf("converted from %s", format))
+		toUpdate.Status.ConversionProgress = 100
+	}); err != nil {
+		return err
+	}
+
+	if err := c.waitForDataSourceStarting(getBackingImageName(image)); err != nil {
+		return err
+	}
+	disk, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer disk.Close()
+	info, err := disk.Stat()
+	if err != nil {
+		return err
+	}
+	return c.upload(ctx, getBackingImageName(image), disk, info.Size())
+}
+
+// progressReporter returns a function to update the conversion progress of the image, at most once per interval
+// unless the phase changes
+func (c *imageConverter) progressReporter(image *harvesterv1.VirtualMachineImage) func(phase string, progress int) {
+	var lastPhase string
+	var lastReported time.Time
+	return func(phase string, progress int) {
+		if phase == lastPhase && time.Since(lastReported) < conversionProgressInterval {
+			return
+		}
+		lastPhase, lastReported = phase, time.Now()
+		if err := c.updateImage(image, func(toUpdate *harvesterv1.VirtualMachineImage) {
+			harvesterv1.ImageConverting.Unknown(toUpdate)
+			harvesterv1.ImageConverting.Reason(toUpdate, phase)
+			toUpdate.Status.ConversionProgress = progress
+		}); err != nil {
+			logrus.Warnf("failed to update the conversion progress of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+	}
+}
+
+// download downloads the source to the file, and verifies its SHA512 checksum if it's given
+func (c *imageConverter) download(ctx context.Context, rawURL, checksum, file string, onProgress func(int)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.downloadClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("got %d status code from %s", resp.StatusCode, rawURL)
+	}
+
+	if err := checkFreeSpace(filepath.Dir(file), resp.ContentLength); err != nil {
+		return err
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	counter := &progressWriter{total: resp.ContentLength, onProgress: onProgress}
+	hash := sha512.New()
+	if _, err := io.Copy(io.MultiWriter(out, counter, hash), resp.Body); err != nil {
+		return fmt.Errorf("failed to download %s: %w", rawURL, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); checksum != "" && !strings.EqualFold(actual, checksum) {
+		return fmt.Errorf("the checksum %s of the download doesn't match the expected %s", actual, checksum)
+	}
+	return out.Close()
+}
+
+// checkFreeSpace fails early if the size of the download is known and it doesn't fit in the free space of the directory
+func checkFreeSpace(dir string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return err
+	}
+	if free := int64(stat.Bavail) * stat.Bsize; free < size {
+		return fmt.Errorf("the download of %d bytes doesn't fit in the %d bytes free in the conversion volume", size, free)
+	}
+	return nil
+}
+
+// progressWriter counts the bytes written to report the progress in percentage if the total is known
+type progressWriter struct {
+	total      int64
+	written    int64
+	onProgress func(int)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.written += int64(len(p))
+	if w.total > 0 {
+		w.onProgress(int(w.written * 100 / w.total))
+	}
+	return len(p), nil
+}
+
+func urlFileName(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return path.Base(u.Path)
+}
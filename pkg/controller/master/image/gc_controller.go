@@ -0,0 +1,76 @@
+package image
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util/imagegc"
+	"github.com/harvester/harvester/pkg/util/imageusage"
+)
+
+const (
+	imageGCControllerName = "image-gc-controller"
+
+	imageGCInterval = time.Hour
+)
+
+// imageGCHandler runs the image garbage collector by the image-gc-config setting
+type imageGCHandler struct {
+	settings  ctlharvesterv1.SettingController
+	collector *imagegc.Collector
+}
+
+// GCRegister registers the image garbage collector
+func GCRegister(ctx context.Context, management *config.Management, options config.Options) error {
+	settings := management.HarvesterFactory.Harvesterhci().V1beta1().Setting()
+	images := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage()
+	gcHandler := &imageGCHandler{
+		settings: settings,
+		collector: &imagegc.Collector{
+			Images:     images,
+			ImageCache: images.Cache(),
+			UsageGetter: &imageusage.Getter{
+				PVCCache:             management.CoreFactory.Core().V1().PersistentVolumeClaim().Cache(),
+				VMCache:              management.VirtFactory.Kubevirt().V1().VirtualMachine().Cache(),
+				TemplateVersionCache: management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineTemplateVersion().Cache(),
+				BackupCache:          management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineBackup().Cache(),
+				SnapshotCache:        management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineSnapshot().Cache(),
+			},
+		},
+	}
+
+	settings.OnChange(ctx, imageGCControllerName, gcHandler.OnImageGCConfigChanged)
+	return nil
+}
+
+// OnImageGCConfigChanged collects the unused images and requeues the setting to collect again after the interval
+func (h *imageGCHandler) OnImageGCConfigChanged(key string, setting *harvesterv1.Setting) (*harvesterv1.Setting, error) {
+	if setting == nil || setting.DeletionTimestamp != nil || setting.Name != settings.ImageGCConfigSettingName {
+		return setting, nil
+	}
+	// the collector is disabled by default
+	if setting.Value == "" {
+		return setting, h.collector.UnmarkAll()
+	}
+
+	gcConfig, err := settings.DecodeImageGCConfig(setting.Value)
+	if err != nil {
+		return setting, err
+	}
+	if !gcConfig.Enable {
+		// the marks of a disabled collector are stale
+		return setting, h.collector.UnmarkAll()
+	}
+
+	if err := h.collector.Collect(gcConfig, time.Now()); err != nil {
+		logrus.Errorf("failed to collect unused images: %v", err)
+	}
+	h.settings.EnqueueAfter(setting.Name, imageGCInterval)
+	return setting, nil
+}
@@ -18,6 +18,14 @@ func Register(ctx context.Context, management *config.Management, options config
 	images := management.HarvesterFactory.Harvesterhci().V1beta1().VirtualMachineImage()
 	storageClasses := management.StorageFactory.Storage().V1().StorageClass()
 	pvcs := management.CoreFactory.Core().V1().PersistentVolumeClaim()
+	dataSources := management.LonghornFactory.Longhorn().V1beta1().BackingImageDataSource()
+	serviceAccounts := management.CoreFactory.Core().V1().ServiceAccount()
+	secrets := management.CoreFactory.Core().V1().Secret()
+	uploader := &backingImageUploader{
+		images:          images,
+		imageCache:      images.Cache(),
+		dataSourceCache: dataSources.Cache(),
+	}
 	vmImageHandler := &vmImageHandler{
 		backingImages:  backingImages,
 		storageClasses: storageClasses,
@@ -26,6 +34,13 @@ func Register(ctx context.Context, management *config.Management, options config
 			Timeout: 15 * time.Second,
 		},
 		pvcCache: pvcs.Cache(),
+		registryImporter: &registryImporter{
+			backingImageUploader: uploader,
+			serviceAccountCache:  serviceAccounts.Cache(),
+			secretCache:          secrets.Cache(),
+		},
+		converter:         newImageConverter(uploader, conversionWorkDir, options.ImageConversionConcurrency),
+		conversionEnabled: options.ImageConversionEnabled,
 	}
 	backingImageHandler := &backingImageHandler{
 		vmImages:          images,
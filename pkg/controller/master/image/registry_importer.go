@@ -0,0 +1,116 @@
+package image
+
+import (
+	"context"
+	"sync"
+
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util/registry"
+)
+
+const (
+	// defaultServiceAccountName is the service account whose image pull secrets are used to pull the images
+	defaultServiceAccountName = "default"
+)
+
+// registryImporter pulls the disks of the registry images and uploads them to the backing images,
+// as Longhorn can only download from HTTP URLs. The progress is synced by the backing image controller.
+type registryImporter struct {
+	*backingImageUploader
+	serviceAccountCache ctlcorev1.ServiceAccountCache
+	secretCache         ctlcorev1.SecretCache
+
+	// importing has the keys of the images being imported by this process
+	importing sync.Map
+}
+
+// start starts importing the image in background if it's neither being imported nor has been uploaded
+func (r *registryImporter) start(image *harvesterv1.VirtualMachineImage) {
+	if ok, err := r.canUpload(getBackingImageName(image)); err != nil || !ok {
+		if err != nil {
+			logrus.Errorf("failed to check the backing image of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+		return
+	}
+
+	key := ref.Construct(image.Namespace, image.Name)
+	if _, loaded := r.importing.LoadOrStore(key, true); loaded {
+		return
+	}
+	go func() {
+		defer r.importing.Delete(key)
+		if err := r.importImage(context.Background(), image); err != nil {
+			logrus.Errorf("failed to import image %s from %s: %v", key, image.Spec.URL, err)
+			if updateErr := r.setImportFailed(image, err.Error()); updateErr != nil {
+				logrus.Errorf("failed to update the imported condition of image %s: %v", key, updateErr)
+			}
+		}
+	}()
+}
+
+func (r *registryImporter) importImage(ctx context.Context, image *harvesterv1.VirtualMachineImage) error {
+	reference, err := registry.ParseReference(image.Spec.URL)
+	if err != nil {
+		return err
+	}
+	credentials, err := r.getCredentials(image.Namespace)
+	if err != nil {
+		return err
+	}
+	client, err := registry.NewClient([]byte(settings.AdditionalCA.Get()), credentials)
+	if err != nil {
+		return err
+	}
+
+	if err := r.waitForDataSourceStarting(getBackingImageName(image)); err != nil {
+		return err
+	}
+	disk, err := client.OpenDisk(ctx, reference)
+	if err != nil {
+		return err
+	}
+	defer disk.Close()
+
+	return r.upload(ctx, getBackingImageName(image), disk, disk.Size)
+}
+
+// getCredentials returns the credentials in the image pull secrets of the default service account of the namespace.
+// These are the secrets the kubelet uses for the pods of the namespace, including the virt-launcher pods pulling
+// containerdisk volumes, so an image is pullable as a registry image exactly when it's pullable as a containerdisk.
+// Taking the namespace's own secrets instead of a cluster-level one also keeps a namespace from importing
+// images with the credentials of another namespace.
+func (r *registryImporter) getCredentials(namespace string) (map[string]registry.Credentials, error) {
+	credentials := map[string]registry.Credentials{}
+	sa, err := r.serviceAccountCache.Get(namespace, defaultServiceAccountName)
+	if errors.IsNotFound(err) {
+		return credentials, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, pullSecret := range sa.ImagePullSecrets {
+		secret, err := r.secretCache.Get(namespace, pullSecret.Name)
+		if errors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		auths, err := registry.ParseDockerConfigSecret(secret)
+		if err != nil {
+			logrus.Warnf("skipping image pull secret %s/%s: %v", namespace, pullSecret.Name, err)
+			continue
+		}
+		// the former secrets take precedence as the kubelet does
+		for server, auth := range auths {
+			if _, ok := credentials[server]; !ok {
+				credentials[server] = auth
+			}
+		}
+	}
+	return credentials, nil
+}
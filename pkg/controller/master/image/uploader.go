@@ -0,0 +1,134 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"time"
+
+	lhv1beta1 "github.com/longhorn/longhorn-manager/k8s/pkg/apis/longhorn/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctllhv1beta1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	dataSourceWaitInterval = 2 * time.Second
+	dataSourceWaitRetry    = 30
+)
+
+// backingImageUploader uploads the disks prepared by Harvester to the backing images of the upload source type,
+// which the importers of the sources Longhorn can't download from directly share.
+type backingImageUploader struct {
+	httpClient      http.Client
+	images          ctlharvesterv1.VirtualMachineImageClient
+	imageCache      ctlharvesterv1.VirtualMachineImageCache
+	dataSourceCache ctllhv1beta1.BackingImageDataSourceCache
+}
+
+// canUpload tells whether the backing image is still waiting for the upload, so an import
+// interrupted before it started can be started again
+func (u *backingImageUploader) canUpload(backingImageName string) (bool, error) {
+	ds, err := u.dataSourceCache.Get(util.LonghornSystemNamespaceName, backingImageName)
+	if errors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	switch ds.Status.CurrentState {
+	case "", lhv1beta1.BackingImageStatePending, lhv1beta1.BackingImageStateStarting:
+		return true, nil
+	}
+	return false, nil
+}
+
+// waitForDataSourceStarting waits for the backing image data source to accept the upload
+func (u *backingImageUploader) waitForDataSourceStarting(name string) error {
+	for i := 0; i < dataSourceWaitRetry; i++ {
+		ds, err := u.dataSourceCache.Get(util.LonghornSystemNamespaceName, name)
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed waiting for backing image data source to be ready: %w", err)
+		}
+		if err == nil {
+			if ds.Status.CurrentState == lhv1beta1.BackingImageStateStarting {
+				return nil
+			}
+			if ds.Status.CurrentState == lhv1beta1.BackingImageStateFailed {
+				return fmt.Errorf("backing image data source failed: %s", ds.Status.Message)
+			}
+		}
+		time.Sleep(dataSourceWaitInterval)
+	}
+	return fmt.Errorf("timeout waiting for backing image data source to be ready")
+}
+
+// upload streams the disk to the backing image by the upload API of Longhorn
+func (u *backingImageUploader) upload(ctx context.Context, backingImageName string, disk io.Reader, size int64) error {
+	bodyReader, bodyWriter := io.Pipe()
+	form := multipart.NewWriter(bodyWriter)
+	go func() {
+		part, err := form.CreateFormFile("chunk", "blob")
+		if err == nil {
+			_, err = io.Copy(part, disk)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		bodyWriter.CloseWithError(err)
+	}()
+
+	uploadURL := fmt.Sprintf("http://longhorn-backend.longhorn-system:9500/v1/backingimages/%s?action=upload&size=%d", backingImageName, size)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uploadURL, bodyReader)
+	if err != nil {
+		bodyReader.Close()
+		return fmt.Errorf("failed to create the upload request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		bodyReader.Close()
+		return fmt.Errorf("failed to send the upload request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("upload failed: %s", string(body))
+	}
+	return nil
+}
+
+// updateImage updates the status of the image by the mutate function, unless the image is removed or
+// changed to another source meanwhile
+func (u *backingImageUploader) updateImage(image *harvesterv1.VirtualMachineImage, mutate func(*harvesterv1.VirtualMachineImage)) error {
+	current, err := u.imageCache.Get(image.Namespace, image.Name)
+	if errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if current.DeletionTimestamp != nil || current.Spec.URL != image.Spec.URL {
+		return nil
+	}
+	toUpdate := current.DeepCopy()
+	mutate(toUpdate)
+	if reflect.DeepEqual(current, toUpdate) {
+		return nil
+	}
+	_, err = u.images.Update(toUpdate)
+	return err
+}
+
+func (u *backingImageUploader) setImportFailed(image *harvesterv1.VirtualMachineImage, message string) error {
+	return u.updateImage(image, func(toUpdate *harvesterv1.VirtualMachineImage) {
+		harvesterv1.ImageImported.False(toUpdate)
+		harvesterv1.ImageImported.Reason(toUpdate, "ImportFailed")
+		harvesterv1.ImageImported.Message(toUpdate, message)
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/longhorn/longhorn-manager/types"
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	v1 "github.com/rancher/wrangler/pkg/generated/controllers/storage/v1"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -21,6 +22,7 @@ import (
 	lhv1beta1 "github.com/harvester/harvester/pkg/generated/controllers/longhorn.io/v1beta1"
 	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/diskimage"
 )
 
 const (
@@ -35,6 +37,12 @@ type vmImageHandler struct {
 	images         ctlharvesterv1.VirtualMachineImageClient
 	backingImages  lhv1beta1.BackingImageClient
 	pvcCache       ctlcorev1.PersistentVolumeClaimCache
+
+	registryImporter *registryImporter
+	converter        *imageConverter
+	// conversionEnabled is if the images Longhorn can't import are converted, qemu-img parses the untrusted downloads
+	// in the apiserver, so it's only enabled with the conversion volume
+	conversionEnabled bool
 }
 
 func (h *vmImageHandler) OnChanged(_ string, image *harvesterv1.VirtualMachineImage) (*harvesterv1.VirtualMachineImage, error) {
@@ -53,6 +61,25 @@ func (h *vmImageHandler) OnChanged(_ string, image *harvesterv1.VirtualMachineIm
 		}
 		return h.initialize(image)
 	}
+	if image.Spec.SourceType == harvesterv1.VirtualMachineImageSourceTypeRegistry &&
+		harvesterv1.ImageInitialized.IsTrue(image) && harvesterv1.ImageImported.IsUnknown(image) {
+		h.registryImporter.start(image)
+	}
+	if image.Spec.SourceType == harvesterv1.VirtualMachineImageSourceTypeDownload &&
+		harvesterv1.ImageInitialized.IsTrue(image) && harvesterv1.ImageConverting.IsUnknown(image) {
+		if !h.conversionEnabled {
+			// the conversion is disabled after the image is initialized
+			toUpdate := image.DeepCopy()
+			harvesterv1.ImageConverting.False(toUpdate)
+			harvesterv1.ImageConverting.Reason(toUpdate, "ConversionDisabled")
+			harvesterv1.ImageConverting.Message(toUpdate, "the image conversion is not enabled")
+			harvesterv1.ImageImported.False(toUpdate)
+			harvesterv1.ImageImported.Reason(toUpdate, "ImportFailed")
+			harvesterv1.ImageImported.Message(toUpdate, "the image conversion is not enabled")
+			return h.images.Update(toUpdate)
+		}
+		h.converter.start(image)
+	}
 	return image, nil
 }
 
@@ -72,7 +99,24 @@ func (h *vmImageHandler) OnRemove(_ string, image *harvesterv1.VirtualMachineIma
 }
 
 func (h *vmImageHandler) initialize(image *harvesterv1.VirtualMachineImage) (*harvesterv1.VirtualMachineImage, error) {
-	if err := h.createBackingImage(image); err != nil && !errors.IsAlreadyExists(err) {
+	// the formats Longhorn doesn't accept are converted by Harvester and uploaded
+	var convert bool
+	if image.Spec.SourceType == harvesterv1.VirtualMachineImageSourceTypeDownload {
+		format, err := h.converter.probe(image.Spec.URL)
+		if err != nil {
+			logrus.Warnf("failed to detect the format of image %s/%s: %v", image.Namespace, image.Name, err)
+		}
+		convert = err == nil && diskimage.NeedConversion(format)
+		if convert && !h.conversionEnabled {
+			toUpdate := image.DeepCopy()
+			toUpdate.Status.AppliedURL = toUpdate.Spec.URL
+			harvesterv1.ImageInitialized.False(toUpdate)
+			harvesterv1.ImageInitialized.Message(toUpdate, fmt.Sprintf("the image of format %s needs to be converted, but the image conversion is not enabled", format))
+			return h.images.Update(toUpdate)
+		}
+	}
+
+	if err := h.createBackingImage(image, convert); err != nil && !errors.IsAlreadyExists(err) {
 		return nil, err
 	}
 	if err := h.createStorageClass(image); err != nil && !errors.IsAlreadyExists(err) {
@@ -105,6 +149,8 @@ func (h *vmImageHandler) initialize(image *harvesterv1.VirtualMachineImage) (*ha
 		toUpdate.Status.Progress = 0
 	}
 
+	setConverting(toUpdate, convert)
+
 	harvesterv1.ImageImported.Unknown(toUpdate)
 	harvesterv1.ImageImported.Reason(toUpdate, "Importing")
 	harvesterv1.ImageInitialized.True(toUpdate)
@@ -113,7 +159,7 @@ func (h *vmImageHandler) initialize(image *harvesterv1.VirtualMachineImage) (*ha
 	return h.images.Update(toUpdate)
 }
 
-func (h *vmImageHandler) createBackingImage(image *harvesterv1.VirtualMachineImage) error {
+func (h *vmImageHandler) createBackingImage(image *harvesterv1.VirtualMachineImage, convert bool) error {
 	bi := &v1beta1.BackingImage{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      getBackingImageName(image),
@@ -132,6 +178,16 @@ func (h *vmImageHandler) createBackingImage(image *harvesterv1.VirtualMachineIma
 		bi.Spec.SourceParameters[v1beta1.DataSourceTypeDownloadParameterURL] = image.Spec.URL
 	}
 
+	// the disk of a registry image is pulled and uploaded by the registry importer,
+	// and the disk to convert is uploaded by the converter
+	if image.Spec.SourceType == harvesterv1.VirtualMachineImageSourceTypeRegistry || convert {
+		bi.Spec.SourceType = v1beta1.BackingImageDataSourceTypeUpload
+	}
+	// the checksum is of the source, it's verified by the converter on download
+	if convert {
+		bi.Spec.Checksum = ""
+	}
+
 	if image.Spec.SourceType == harvesterv1.VirtualMachineImageSourceTypeExportVolume {
 		pvc, err := h.pvcCache.Get(image.Spec.PVCNamespace, image.Spec.PVCName)
 		if err != nil {
@@ -169,6 +225,25 @@ func (h *vmImageHandler) createStorageClass(image *harvesterv1.VirtualMachineIma
 	return err
 }
 
+// setConverting resets the conversion status of an image being initialized, the Converting condition
+// only exists on the images to convert
+func setConverting(image *harvesterv1.VirtualMachineImage, convert bool) {
+	image.Status.ConversionProgress = 0
+	if convert {
+		harvesterv1.ImageConverting.Unknown(image)
+		harvesterv1.ImageConverting.Reason(image, "Pending")
+		harvesterv1.ImageConverting.Message(image, "")
+		return
+	}
+	conditions := image.Status.Conditions[:0]
+	for _, c := range image.Status.Conditions {
+		if c.Type != harvesterv1.ImageConverting {
+			conditions = append(conditions, c)
+		}
+	}
+	image.Status.Conditions = conditions
+}
+
 func getImageStorageClassName(imageName string) string {
 	return fmt.Sprintf("longhorn-%s", imageName)
 }
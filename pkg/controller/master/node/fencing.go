@@ -0,0 +1,17 @@
+package node
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FencedAnnotationKey is the node annotation set to "true" by an administrator or a fencing agent once the down node
+// is powered off or isolated from the storage, so the VMs with RWO volumes on it can be reset without the risk of
+// two instances writing the same volume. It's removed when the node is ready again.
+const FencedAnnotationKey = "harvesterhci.io/fenced"
+
+func isNodeFenced(node *corev1.Node) bool {
+	fenced, err := strconv.ParseBool(node.Annotations[FencedAnnotationKey])
+	return err == nil && fenced
+}
@@ -2,66 +2,364 @@ package node
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
 
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/utils/pointer"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
 
 	"github.com/harvester/harvester/pkg/config"
 	v1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
 )
 
 const (
-	maintainNodeControllerName = "maintain-node-controller"
-	labelNodeNameKey           = "kubevirt.io/nodeName"
+	maintainNodeControllerName      = "maintain-node-controller"
+	maintainMigrationControllerName = "maintain-node-migration-controller"
+	labelNodeNameKey                = "kubevirt.io/nodeName"
 
 	MaintainStatusAnnotationKey = "harvesterhci.io/maintain-status"
 	MaintainStatusComplete      = "completed"
 	MaintainStatusRunning       = "running"
+
+	// MaintainProgressAnnotationKey keeps the MaintainProgress of a node in maintenance mode
+	MaintainProgressAnnotationKey = "harvesterhci.io/maintain-progress"
+	// MaintainNodeLabelKey labels the migrations created to migrate VMs off the node in maintenance mode
+	MaintainNodeLabelKey = "harvesterhci.io/maintain-node"
+
+	// MaintainPolicyAnnotationKey is the VM annotation to decide what to do with the VM when its node enters
+	// maintenance mode but it can't be live migrated, the default is MaintainPolicyBlock.
+	MaintainPolicyAnnotationKey = "harvesterhci.io/maintain-policy"
+	// MaintainPolicyShutdown stops the VM
+	MaintainPolicyShutdown = "shutdown"
+	// MaintainPolicyLeaveRunning keeps the VM running on the node, the maintenance completes without it
+	MaintainPolicyLeaveRunning = "leave-running"
+	// MaintainPolicyBlock keeps the VM running on the node and reports it as failed, the maintenance doesn't complete
+	MaintainPolicyBlock = "block"
+
+	maintainResyncInterval = 10 * time.Second
+	// maintainMigrationAttempts is how many times at most a VM is migrated off the node in maintenance mode
+	// before it's reported as failed
+	maintainMigrationAttempts = 3
 )
 
-// maintainNodeHandler updates maintenance status of a node in its annotations, so that we can tell whether the node is
-// entering maintenance mode(migrating VMs on it) or in maintenance mode(VMs migrated).
+// MaintainProgress reports the VMs on a node in maintenance mode, the VMs are named in the form of namespace/name.
+type MaintainProgress struct {
+	Pending      []string          `json:"pending,omitempty"`
+	Migrating    []string          `json:"migrating,omitempty"`
+	Failed       []MaintainFailure `json:"failed,omitempty"`
+	LeftRunning  []string          `json:"leftRunning,omitempty"`
+	ShuttingDown []string          `json:"shuttingDown,omitempty"`
+}
+
+type MaintainFailure struct {
+	VM     string `json:"vm"`
+	Reason string `json:"reason"`
+}
+
+// maintainNodeHandler migrates VMs off a node in maintenance mode, and updates maintenance status of the node
+// in its annotations, so that we can tell whether the node is entering maintenance mode(migrating VMs on it)
+// or in maintenance mode(VMs migrated).
 type maintainNodeHandler struct {
-	nodes                       ctlcorev1.NodeClient
-	nodeCache                   ctlcorev1.NodeCache
-	virtualMachineInstanceCache v1.VirtualMachineInstanceCache
+	nodes                                ctlcorev1.NodeController
+	nodeCache                            ctlcorev1.NodeCache
+	virtualMachines                      v1.VirtualMachineClient
+	virtualMachineCache                  v1.VirtualMachineCache
+	virtualMachineInstanceCache          v1.VirtualMachineInstanceCache
+	virtualMachineInstanceMigrations     v1.VirtualMachineInstanceMigrationClient
+	virtualMachineInstanceMigrationCache v1.VirtualMachineInstanceMigrationCache
 }
 
 // MaintainRegister registers the node controller
 func MaintainRegister(ctx context.Context, management *config.Management, options config.Options) error {
 	nodes := management.CoreFactory.Core().V1().Node()
+	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
 	vmis := management.VirtFactory.Kubevirt().V1().VirtualMachineInstance()
+	vmims := management.VirtFactory.Kubevirt().V1().VirtualMachineInstanceMigration()
 	maintainNodeHandler := &maintainNodeHandler{
-		nodes:                       nodes,
-		nodeCache:                   nodes.Cache(),
-		virtualMachineInstanceCache: vmis.Cache(),
+		nodes:                                nodes,
+		nodeCache:                            nodes.Cache(),
+		virtualMachines:                      vms,
+		virtualMachineCache:                  vms.Cache(),
+		virtualMachineInstanceCache:          vmis.Cache(),
+		virtualMachineInstanceMigrations:     vmims,
+		virtualMachineInstanceMigrationCache: vmims.Cache(),
 	}
 
 	nodes.OnChange(ctx, maintainNodeControllerName, maintainNodeHandler.OnNodeChanged)
+	vmims.OnChange(ctx, maintainMigrationControllerName, maintainNodeHandler.OnMigrationChanged)
 
 	return nil
 }
 
-// OnNodeChanged updates node maintenance status when all VMs are migrated
+// OnNodeChanged migrates the VMs on the node in maintenance mode and updates node maintenance status
+// when all VMs are migrated
 func (h *maintainNodeHandler) OnNodeChanged(key string, node *corev1.Node) (*corev1.Node, error) {
 	if node == nil || node.DeletionTimestamp != nil {
 		return node, nil
 	}
-	if maintenanceStatus, ok := node.Annotations[MaintainStatusAnnotationKey]; !ok || maintenanceStatus != MaintainStatusRunning {
+	maintenanceStatus, ok := node.Annotations[MaintainStatusAnnotationKey]
+	if !ok {
+		return h.cleanupMaintenance(node)
+	}
+	if maintenanceStatus != MaintainStatusRunning {
+		return node, nil
+	}
+
+	progress, err := h.migrateVMs(node)
+	if err != nil {
+		return node, err
+	}
+
+	toUpdate := node.DeepCopy()
+	if len(progress.Pending) == 0 && len(progress.Migrating) == 0 && len(progress.Failed) == 0 && len(progress.ShuttingDown) == 0 {
+		toUpdate.Annotations[MaintainStatusAnnotationKey] = MaintainStatusComplete
+	} else {
+		h.nodes.EnqueueAfter(node.Name, maintainResyncInterval)
+	}
+	progressBytes, err := json.Marshal(progress)
+	if err != nil {
+		return node, err
+	}
+	toUpdate.Annotations[MaintainProgressAnnotationKey] = string(progressBytes)
+
+	if toUpdate.Annotations[MaintainStatusAnnotationKey] == node.Annotations[MaintainStatusAnnotationKey] &&
+		toUpdate.Annotations[MaintainProgressAnnotationKey] == node.Annotations[MaintainProgressAnnotationKey] {
 		return node, nil
 	}
-	sets := labels.Set{
+	return h.nodes.Update(toUpdate)
+}
+
+// OnMigrationChanged resyncs the node in maintenance mode when the migrations of its VMs change
+func (h *maintainNodeHandler) OnMigrationChanged(key string, vmim *kubevirtv1.VirtualMachineInstanceMigration) (*kubevirtv1.VirtualMachineInstanceMigration, error) {
+	if vmim == nil || vmim.DeletionTimestamp != nil {
+		return vmim, nil
+	}
+	if nodeName := vmim.Labels[MaintainNodeLabelKey]; nodeName != "" {
+		h.nodes.Enqueue(nodeName)
+	}
+	return vmim, nil
+}
+
+// migrateVMs creates migrations for the VMs on the node, at most maintenance-migration-concurrency VMs are
+// migrated at the same time in the order of their namespaces and names. The VMs which can't be live migrated
+// are handled according to their maintain policies.
+func (h *maintainNodeHandler) migrateVMs(node *corev1.Node) (*MaintainProgress, error) {
+	vmis, err := h.virtualMachineInstanceCache.List(corev1.NamespaceAll, labels.Set{
 		labelNodeNameKey: node.Name,
+	}.AsSelector())
+	if err != nil {
+		return nil, err
 	}
-	vmis, err := h.virtualMachineInstanceCache.List(corev1.NamespaceAll, sets.AsSelector())
+	sort.Slice(vmis, func(i, j int) bool {
+		return vmiKey(vmis[i]) < vmiKey(vmis[j])
+	})
+
+	// list the migrations from the API server rather than the cache, not to migrate a VM twice
+	// before the created migrations are synced to the cache
+	vmims, err := h.virtualMachineInstanceMigrations.List(corev1.NamespaceAll, metav1.ListOptions{
+		LabelSelector: labels.Set{MaintainNodeLabelKey: node.Name}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	migrations := getMaintainMigrations(vmims.Items)
+
+	var (
+		progress   = &MaintainProgress{}
+		toMigrate  []*kubevirtv1.VirtualMachineInstance
+		concurrent int
+	)
+	for _, vmi := range vmis {
+		key := vmiKey(vmi)
+		if vmi.IsFinal() || vmi.DeletionTimestamp != nil {
+			continue
+		}
+		migration := migrations[key]
+		if (migration.latest != nil && !migration.latest.IsFinal()) || isMigrating(vmi) {
+			progress.Migrating = append(progress.Migrating, key)
+			concurrent++
+			continue
+		}
+		if migration.failures >= maintainMigrationAttempts {
+			progress.Failed = append(progress.Failed, MaintainFailure{
+				VM:     key,
+				Reason: fmt.Sprintf("migration %s failed after %d attempts", migration.latest.Name, migration.failures),
+			})
+			continue
+		}
+		if !vmi.IsRunning() {
+			progress.Pending = append(progress.Pending, key)
+			continue
+		}
+
+		reason := getNonMigratableReason(vmi)
+		if reason == "" {
+			toMigrate = append(toMigrate, vmi)
+			continue
+		}
+		switch h.getMaintainPolicy(vmi) {
+		case MaintainPolicyLeaveRunning:
+			progress.LeftRunning = append(progress.LeftRunning, key)
+		case MaintainPolicyShutdown:
+			if err := h.shutdownVM(vmi); err != nil {
+				return nil, err
+			}
+			progress.ShuttingDown = append(progress.ShuttingDown, key)
+		default:
+			progress.Failed = append(progress.Failed, MaintainFailure{VM: key, Reason: reason})
+		}
+	}
+
+	concurrency := settings.MaintenanceConcurrency.GetInt()
+	for _, vmi := range toMigrate {
+		key := vmiKey(vmi)
+		if concurrent >= concurrency {
+			progress.Pending = append(progress.Pending, key)
+			continue
+		}
+		if err := h.createMigration(node, vmi); err != nil {
+			return nil, err
+		}
+		progress.Migrating = append(progress.Migrating, key)
+		concurrent++
+	}
+	return progress, nil
+}
+
+// maintainMigrations are the migrations created to migrate a VM off the node in maintenance mode
+type maintainMigrations struct {
+	// latest is the latest migration, the earlier ones are final
+	latest   *kubevirtv1.VirtualMachineInstanceMigration
+	failures int
+}
+
+// getMaintainMigrations groups the migrations by the VMs, a failed migration is retried by a new one
+func getMaintainMigrations(vmims []kubevirtv1.VirtualMachineInstanceMigration) map[string]maintainMigrations {
+	migrations := map[string]maintainMigrations{}
+	for i := range vmims {
+		vmim := &vmims[i]
+		key := fmt.Sprintf("%s/%s", vmim.Namespace, vmim.Spec.VMIName)
+		migration := migrations[key]
+		if vmim.Status.Phase == kubevirtv1.MigrationFailed {
+			migration.failures++
+		}
+		if migration.latest == nil || migration.latest.CreationTimestamp.Before(&vmim.CreationTimestamp) {
+			migration.latest = vmim
+		}
+		migrations[key] = migration
+	}
+	return migrations
+}
+
+func (h *maintainNodeHandler) createMigration(node *corev1.Node, vmi *kubevirtv1.VirtualMachineInstance) error {
+	logrus.Infof("migrating vm %s/%s off node %s in maintenance mode", vmi.Namespace, vmi.Name, node.Name)
+	_, err := h.virtualMachineInstanceMigrations.Create(&kubevirtv1.VirtualMachineInstanceMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: vmi.Name + "-",
+			Namespace:    vmi.Namespace,
+			Labels: map[string]string{
+				MaintainNodeLabelKey: node.Name,
+			},
+		},
+		Spec: kubevirtv1.VirtualMachineInstanceMigrationSpec{
+			VMIName: vmi.Name,
+		},
+	})
+	return err
+}
+
+// getMaintainPolicy returns the maintain policy in the annotations of the VM owning the VMI
+func (h *maintainNodeHandler) getMaintainPolicy(vmi *kubevirtv1.VirtualMachineInstance) string {
+	vm, err := h.virtualMachineCache.Get(vmi.Namespace, vmi.Name)
+	if err != nil {
+		return MaintainPolicyBlock
+	}
+	switch policy := vm.Annotations[MaintainPolicyAnnotationKey]; policy {
+	case MaintainPolicyShutdown, MaintainPolicyLeaveRunning:
+		return policy
+	default:
+		return MaintainPolicyBlock
+	}
+}
+
+func (h *maintainNodeHandler) shutdownVM(vmi *kubevirtv1.VirtualMachineInstance) error {
+	vm, err := h.virtualMachineCache.Get(vmi.Namespace, vmi.Name)
+	if err != nil {
+		return err
+	}
+	if vm.Spec.Running != nil && !*vm.Spec.Running {
+		return nil
+	}
+	logrus.Infof("shutting down vm %s/%s on node %s in maintenance mode", vm.Namespace, vm.Name, vmi.Status.NodeName)
+	toUpdate := vm.DeepCopy()
+	toUpdate.Spec.Running = pointer.BoolPtr(false)
+	toUpdate.Spec.RunStrategy = nil
+	_, err = h.virtualMachines.Update(toUpdate)
+	return err
+}
+
+// cleanupMaintenance removes the maintenance progress and the migrations created for the maintenance
+// once the node exits maintenance mode, deleting an in-flight migration cancels it
+func (h *maintainNodeHandler) cleanupMaintenance(node *corev1.Node) (*corev1.Node, error) {
+	vmims, err := h.virtualMachineInstanceMigrationCache.List(corev1.NamespaceAll, labels.Set{
+		MaintainNodeLabelKey: node.Name,
+	}.AsSelector())
 	if err != nil {
 		return node, err
 	}
-	if len(vmis) != 0 {
+	for _, vmim := range vmims {
+		if !vmim.IsFinal() {
+			logrus.Infof("cancelling migration %s/%s since node %s exits maintenance mode", vmim.Namespace, vmim.Name, node.Name)
+		}
+		if err := h.virtualMachineInstanceMigrations.Delete(vmim.Namespace, vmim.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return node, err
+		}
+	}
+
+	if _, ok := node.Annotations[MaintainProgressAnnotationKey]; !ok {
 		return node, nil
 	}
 	toUpdate := node.DeepCopy()
-	toUpdate.Annotations[MaintainStatusAnnotationKey] = MaintainStatusComplete
+	delete(toUpdate.Annotations, MaintainProgressAnnotationKey)
 	return h.nodes.Update(toUpdate)
 }
+
+// getNonMigratableReason returns why the VMI can't be live migrated, e.g. it has host devices or non-RWX volumes,
+// or an empty string if it can be live migrated
+func getNonMigratableReason(vmi *kubevirtv1.VirtualMachineInstance) string {
+	devices := vmi.Spec.Domain.Devices
+	if len(devices.HostDevices) > 0 || len(devices.GPUs) > 0 {
+		return "VM with host devices can't be live migrated"
+	}
+	for _, cond := range vmi.Status.Conditions {
+		if cond.Type == kubevirtv1.VirtualMachineInstanceIsMigratable && cond.Status == corev1.ConditionFalse {
+			if cond.Message != "" {
+				return cond.Message
+			}
+			return cond.Reason
+		}
+	}
+	return ""
+}
+
+// isMigrating checks if the VMI is being migrated, including the migrations not created for the maintenance
+func isMigrating(vmi *kubevirtv1.VirtualMachineInstance) bool {
+	if vmi.Annotations[util.AnnotationMigrationUID] != "" {
+		return true
+	}
+	return vmi.Status.MigrationState != nil && !vmi.Status.MigrationState.Completed
+}
+
+func vmiKey(vmi *kubevirtv1.VirtualMachineInstance) string {
+	return fmt.Sprintf("%s/%s", vmi.Namespace, vmi.Name)
+}
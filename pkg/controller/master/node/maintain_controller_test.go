@@ -0,0 +1,119 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/util"
+)
+
+func TestGetNonMigratableReason(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		vmi      *kubevirtv1.VirtualMachineInstance
+		expected string
+	}{
+		{
+			name: "migratable",
+			vmi: &kubevirtv1.VirtualMachineInstance{
+				Status: kubevirtv1.VirtualMachineInstanceStatus{
+					Conditions: []kubevirtv1.VirtualMachineInstanceCondition{
+						{Type: kubevirtv1.VirtualMachineInstanceIsMigratable, Status: corev1.ConditionTrue},
+					},
+				},
+			},
+			expected: "",
+		},
+		{
+			name: "host devices",
+			vmi: &kubevirtv1.VirtualMachineInstance{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Domain: kubevirtv1.DomainSpec{
+						Devices: kubevirtv1.Devices{
+							HostDevices: []kubevirtv1.HostDevice{{Name: "nic", DeviceName: "intel.com/nic"}},
+						},
+					},
+				},
+			},
+			expected: "VM with host devices can't be live migrated",
+		},
+		{
+			name: "non-RWX volumes",
+			vmi: &kubevirtv1.VirtualMachineInstance{
+				Status: kubevirtv1.VirtualMachineInstanceStatus{
+					Conditions: []kubevirtv1.VirtualMachineInstanceCondition{
+						{
+							Type:    kubevirtv1.VirtualMachineInstanceIsMigratable,
+							Status:  corev1.ConditionFalse,
+							Reason:  kubevirtv1.VirtualMachineInstanceReasonDisksNotMigratable,
+							Message: "cannot migrate VMI: PVC disk-0 is not shared, live migration requires that all PVCs must be shared (using ReadWriteMany access mode)",
+						},
+					},
+				},
+			},
+			expected: "cannot migrate VMI: PVC disk-0 is not shared, live migration requires that all PVCs must be shared (using ReadWriteMany access mode)",
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, getNonMigratableReason(tc.vmi), "case %q", tc.name)
+	}
+}
+
+func TestIsMigrating(t *testing.T) {
+	vmi := &kubevirtv1.VirtualMachineInstance{}
+	assert.False(t, isMigrating(vmi))
+
+	vmi.Status.MigrationState = &kubevirtv1.VirtualMachineInstanceMigrationState{Completed: true}
+	assert.False(t, isMigrating(vmi))
+
+	vmi.Status.MigrationState.Completed = false
+	assert.True(t, isMigrating(vmi))
+
+	vmi = &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{util.AnnotationMigrationUID: "uid"},
+		},
+	}
+	assert.True(t, isMigrating(vmi))
+}
+
+func TestGetMaintainMigrations(t *testing.T) {
+	newMigration := func(name, vmiName string, created int, phase kubevirtv1.VirtualMachineInstanceMigrationPhase) kubevirtv1.VirtualMachineInstanceMigration {
+		return kubevirtv1.VirtualMachineInstanceMigration{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(time.Date(2021, 10, 1, 0, created, 0, 0, time.UTC)),
+			},
+			Spec:   kubevirtv1.VirtualMachineInstanceMigrationSpec{VMIName: vmiName},
+			Status: kubevirtv1.VirtualMachineInstanceMigrationStatus{Phase: phase},
+		}
+	}
+
+	migrations := getMaintainMigrations([]kubevirtv1.VirtualMachineInstanceMigration{
+		newMigration("vm1-retry", "vm1", 2, kubevirtv1.MigrationRunning),
+		newMigration("vm1-first", "vm1", 1, kubevirtv1.MigrationFailed),
+		newMigration("vm2-first", "vm2", 1, kubevirtv1.MigrationFailed),
+		newMigration("vm2-second", "vm2", 2, kubevirtv1.MigrationFailed),
+		newMigration("vm2-third", "vm2", 3, kubevirtv1.MigrationFailed),
+		newMigration("vm3-first", "vm3", 1, kubevirtv1.MigrationSucceeded),
+	})
+
+	assert.Equal(t, "vm1-retry", migrations["default/vm1"].latest.Name)
+	assert.False(t, migrations["default/vm1"].latest.IsFinal())
+	assert.Equal(t, 1, migrations["default/vm1"].failures)
+
+	assert.Equal(t, "vm2-third", migrations["default/vm2"].latest.Name)
+	assert.Equal(t, maintainMigrationAttempts, migrations["default/vm2"].failures)
+
+	assert.Equal(t, "vm3-first", migrations["default/vm3"].latest.Name)
+	assert.Equal(t, 0, migrations["default/vm3"].failures)
+
+	assert.Nil(t, migrations["default/vm4"].latest)
+}
@@ -3,23 +3,44 @@ package node
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
 	kv1 "kubevirt.io/client-go/api/v1"
 
 	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	"github.com/harvester/harvester/pkg/config"
 	v1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/settings"
 )
 
 const (
 	nodeDownControllerName = "node-down-controller"
+
+	// HAPriorityAnnotationKey is the VM annotation of the HA priority, the VMs with higher priority are recovered
+	// from a down node first, the default is 0.
+	HAPriorityAnnotationKey = "harvesterhci.io/ha-priority"
+	// HARestartDelayAnnotationKey is the VM annotation of the seconds to wait in addition to the period
+	// of the vm-force-reset-policy setting before recovering the VM.
+	HARestartDelayAnnotationKey = "harvesterhci.io/ha-restart-delay"
+	// HAAutoRecoverAnnotationKey is the VM annotation to keep the VM on the down node until the node comes back
+	// when it's "false".
+	HAAutoRecoverAnnotationKey = "harvesterhci.io/ha-auto-recover"
+
+	// haCheckInterval is how often to check if the VMs with higher priority have started
+	haCheckInterval = 10 * time.Second
+	// haStartTimeout is how long at most the VMs with lower priority wait for the ones with higher priority to start
+	haStartTimeout = 5 * time.Minute
 )
 
 // nodeDownHandler force deletes VMI's pod when a node is down, so VMI can be reschduled to anothor healthy node
@@ -27,20 +48,53 @@ type nodeDownHandler struct {
 	nodes                       ctlcorev1.NodeController
 	nodeCache                   ctlcorev1.NodeCache
 	pods                        ctlcorev1.PodClient
+	pvcCache                    ctlcorev1.PersistentVolumeClaimCache
+	virtualMachineCache         v1.VirtualMachineCache
 	virtualMachineInstanceCache v1.VirtualMachineInstanceCache
+
+	// recoveredVMs are the VMs recovered from each down node, by the namespace/name of the VMs. They are kept
+	// in memory, so the VMs recovered before Harvester restarts aren't waited for after it.
+	recoveredVMsLock sync.Mutex
+	recoveredVMs     map[string]map[string]recoveredVM
+}
+
+// recoveredVM is a VM whose pod is deleted from a down node
+type recoveredVM struct {
+	priority    int
+	vmiUID      types.UID
+	recoveredAt time.Time
+}
+
+// haPolicy is how to recover a VM from a down node, parsed from the HA annotations of the VM
+type haPolicy struct {
+	Priority     int
+	RestartDelay time.Duration
+	AutoRecover  bool
+}
+
+// haCandidate is a virt-launcher pod on the down node and the HA policy of its VM
+type haCandidate struct {
+	pod    corev1.Pod
+	vmi    *kv1.VirtualMachineInstance
+	policy haPolicy
 }
 
 // NodeDownRegister registers a controller to delete VMI when node is down
 func NodeDownRegister(ctx context.Context, management *config.Management, options config.Options) error {
 	nodes := management.CoreFactory.Core().V1().Node()
 	pods := management.CoreFactory.Core().V1().Pod()
+	pvcs := management.CoreFactory.Core().V1().PersistentVolumeClaim()
 	setting := management.HarvesterFactory.Harvesterhci().V1beta1().Setting()
+	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
 	vmis := management.VirtFactory.Kubevirt().V1().VirtualMachineInstance()
 	nodeDownHandler := &nodeDownHandler{
 		nodes:                       nodes,
 		nodeCache:                   nodes.Cache(),
 		pods:                        pods,
+		pvcCache:                    pvcs.Cache(),
+		virtualMachineCache:         vms.Cache(),
 		virtualMachineInstanceCache: vmis.Cache(),
+		recoveredVMs:                map[string]map[string]recoveredVM{},
 	}
 
 	nodes.OnChange(ctx, nodeDownControllerName, nodeDownHandler.OnNodeChanged)
@@ -55,6 +109,7 @@ func NodeDownRegister(ctx context.Context, management *config.Management, option
 // 2. A node has been down for more than VMForceResetPolicy.Period seconds
 // 3. The owner of Pod is VirtualMachineInstance.
 // 4. The Pod is on a down node.
+// 5. The HA policy of the VM allows to recover it, see recoverVMs.
 func (h *nodeDownHandler) OnNodeChanged(key string, node *corev1.Node) (*corev1.Node, error) {
 	if node == nil || node.DeletionTimestamp != nil {
 		return node, nil
@@ -66,8 +121,14 @@ func (h *nodeDownHandler) OnNodeChanged(key string, node *corev1.Node) (*corev1.
 		return node, fmt.Errorf("can't find %s condition in node %s", corev1.NodeReady, node.Name)
 	}
 
-	// check whether node is healthy
+	// check whether node is healthy, the fencing confirmation is only valid while the node is down
 	if cond.Status == corev1.ConditionTrue {
+		h.forgetRecoveredVMs(node.Name)
+		if _, ok := node.Annotations[FencedAnnotationKey]; ok {
+			toUpdate := node.DeepCopy()
+			delete(toUpdate.Annotations, FencedAnnotationKey)
+			return h.nodes.Update(toUpdate)
+		}
 		return node, nil
 	}
 
@@ -100,20 +161,199 @@ func (h *nodeDownHandler) OnNodeChanged(key string, node *corev1.Node) (*corev1.
 		return node, err
 	}
 
-	gracePeriod := int64(0)
-	for _, pod := range pods.Items {
+	resetTime := cond.LastTransitionTime.Add(time.Duration(vmForceResetPolicy.Period) * time.Second)
+	requeue, err := h.recoverVMs(node, vmForceResetPolicy, resetTime, pods.Items)
+	if err != nil {
+		return node, err
+	}
+	if requeue > 0 {
+		h.nodes.EnqueueAfter(node.Name, requeue)
+	}
+	return node, nil
+}
+
+// recoverVMs force deletes the virt-launcher pods on the down node following the HA policies of the VMs:
+// 1. The VMs with the HAAutoRecoverAnnotationKey annotation "false" are left on the node.
+// 2. A VM waits for its restart delay after the reset time.
+// 3. A VM waits for the VMs with higher priority recovered from the node to start, at most haStartTimeout.
+// 4. A VM with RWO volumes waits for the node to be fenced if the policy requires fencing, since the VM
+// might still be writing the volumes on the node. The VMs with lower priority wait for it as well, at most
+// haStartTimeout after the reset time.
+// It returns how long to wait before checking the pods again, or 0 if no pod is waiting for a priority or delay.
+func (h *nodeDownHandler) recoverVMs(node *corev1.Node, vmForceResetPolicy *settings.VMForceResetPolicy, resetTime time.Time, pods []corev1.Pod) (time.Duration, error) {
+	candidates, err := h.getHACandidates(pods)
+	if err != nil {
+		return 0, err
+	}
+	blockingPriority, starting, err := h.getStartingPriority(node.Name)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		fenced  *bool
+		requeue time.Duration
+	)
+	wait := func(d time.Duration) {
+		if requeue == 0 || d < requeue {
+			requeue = d
+		}
+	}
+	for _, candidate := range candidates {
+		pod := candidate.pod
+		if !candidate.policy.AutoRecover {
+			logrus.Debugf("skip recovering pod %s/%s since its VM is not auto recovered", pod.Namespace, pod.Name)
+			continue
+		}
+		if starting && candidate.policy.Priority < blockingPriority {
+			wait(haCheckInterval)
+			continue
+		}
+		if delay := time.Until(resetTime.Add(candidate.policy.RestartDelay)); delay > 0 {
+			blockingPriority, starting = candidate.policy.Priority, true
+			wait(delay)
+			continue
+		}
+
+		if vmForceResetPolicy.RequireFencing {
+			hasRWOVolume, err := h.hasRWOVolume(candidate.vmi)
+			if err != nil {
+				return 0, err
+			}
+			if hasRWOVolume && fenced == nil {
+				isFenced := isNodeFenced(node)
+				fenced = &isFenced
+			}
+			if hasRWOVolume && !*fenced {
+				logrus.Infof("pod %s/%s has RWO volumes, waiting for node %s to be fenced", pod.Namespace, pod.Name, node.Name)
+				if time.Since(resetTime) < haStartTimeout {
+					blockingPriority, starting = candidate.policy.Priority, true
+				}
+				wait(haCheckInterval)
+				continue
+			}
+		}
+
 		logrus.Debugf("force delete pod %s/%s", pod.Namespace, pod.Name)
+		gracePeriod := int64(0)
 		if err := h.pods.Delete(
 			pod.Namespace,
 			pod.Name,
 			&metav1.DeleteOptions{
 				GracePeriodSeconds: &gracePeriod,
 			}); err != nil {
-			return node, err
+			return 0, err
+		}
+		if candidate.vmi != nil {
+			h.recordRecoveredVM(node.Name, candidate.vmi, candidate.policy.Priority)
+		}
+		// the VMs with lower priority wait for this one to start
+		blockingPriority, starting = candidate.policy.Priority, true
+		wait(haCheckInterval)
+	}
+	return requeue, nil
+}
+
+// getHACandidates returns the virt-launcher pods with the HA policies of their VMs, sorted by the priority
+func (h *nodeDownHandler) getHACandidates(pods []corev1.Pod) ([]haCandidate, error) {
+	var candidates []haCandidate
+	for _, pod := range pods {
+		candidate := haCandidate{pod: pod, policy: getHAPolicy(nil)}
+		for _, owner := range pod.OwnerReferences {
+			if owner.Kind != kv1.VirtualMachineInstanceGroupVersionKind.Kind {
+				continue
+			}
+			vmi, err := h.virtualMachineInstanceCache.Get(pod.Namespace, owner.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			candidate.vmi = vmi
+			vm, err := h.virtualMachineCache.Get(pod.Namespace, owner.Name)
+			if err != nil && !apierrors.IsNotFound(err) {
+				return nil, err
+			}
+			if vm != nil {
+				candidate.policy = getHAPolicy(vm.Annotations)
+			}
 		}
+		candidates = append(candidates, candidate)
+	}
+	sortHACandidates(candidates)
+	return candidates, nil
+}
 
+// getStartingPriority returns the highest priority of the VMs recovered from the node which are starting within
+// haStartTimeout. The VMs which have started or timed out are forgotten.
+func (h *nodeDownHandler) getStartingPriority(nodeName string) (int, bool, error) {
+	h.recoveredVMsLock.Lock()
+	defer h.recoveredVMsLock.Unlock()
+
+	var (
+		priority int
+		starting bool
+	)
+	for key, recovered := range h.recoveredVMs[nodeName] {
+		if time.Since(recovered.recoveredAt) > haStartTimeout {
+			delete(h.recoveredVMs[nodeName], key)
+			continue
+		}
+		namespace, name := ref.Parse(key)
+		vmi, err := h.virtualMachineInstanceCache.Get(namespace, name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return 0, false, err
+		}
+		// the VMI on the down node is still being replaced if it's not recreated yet
+		if err == nil && vmi.UID != recovered.vmiUID && !isStarting(vmi) {
+			delete(h.recoveredVMs[nodeName], key)
+			continue
+		}
+		if !starting || recovered.priority > priority {
+			priority, starting = recovered.priority, true
+		}
 	}
-	return node, nil
+	return priority, starting, nil
+}
+
+// recordRecoveredVM records the VM whose pod is deleted from the down node, so the VMs with lower priority on
+// the node wait for it to start
+func (h *nodeDownHandler) recordRecoveredVM(nodeName string, vmi *kv1.VirtualMachineInstance, priority int) {
+	h.recoveredVMsLock.Lock()
+	defer h.recoveredVMsLock.Unlock()
+	if h.recoveredVMs[nodeName] == nil {
+		h.recoveredVMs[nodeName] = map[string]recoveredVM{}
+	}
+	h.recoveredVMs[nodeName][ref.Construct(vmi.Namespace, vmi.Name)] = recoveredVM{
+		priority:    priority,
+		vmiUID:      vmi.UID,
+		recoveredAt: time.Now(),
+	}
+}
+
+func (h *nodeDownHandler) forgetRecoveredVMs(nodeName string) {
+	h.recoveredVMsLock.Lock()
+	defer h.recoveredVMsLock.Unlock()
+	delete(h.recoveredVMs, nodeName)
+}
+
+func (h *nodeDownHandler) hasRWOVolume(vmi *kv1.VirtualMachineInstance) (bool, error) {
+	if vmi == nil {
+		return false, nil
+	}
+	for _, volume := range vmi.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		pvc, err := h.pvcCache.Get(vmi.Namespace, volume.PersistentVolumeClaim.ClaimName)
+		if err != nil {
+			return false, err
+		}
+		for _, accessMode := range pvc.Spec.AccessModes {
+			if accessMode == corev1.ReadWriteOnce {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
 }
 
 func (h *nodeDownHandler) OnVMForceResetPolicyChanged(key string, setting *harvesterv1.Setting) (*harvesterv1.Setting, error) {
@@ -145,6 +385,38 @@ func (h *nodeDownHandler) OnVMForceResetPolicyChanged(key string, setting *harve
 	return setting, nil
 }
 
+func isStarting(vmi *kv1.VirtualMachineInstance) bool {
+	if vmi.DeletionTimestamp != nil || time.Since(vmi.CreationTimestamp.Time) > haStartTimeout {
+		return false
+	}
+	return vmi.IsUnprocessed() || vmi.IsScheduling() || vmi.IsScheduled()
+}
+
+// getHAPolicy parses the HA annotations of a VM, the invalid values are ignored
+func getHAPolicy(annotations map[string]string) haPolicy {
+	policy := haPolicy{AutoRecover: true}
+	if priority, err := strconv.Atoi(annotations[HAPriorityAnnotationKey]); err == nil {
+		policy.Priority = priority
+	}
+	if delay, err := strconv.ParseInt(annotations[HARestartDelayAnnotationKey], 10, 64); err == nil && delay > 0 {
+		policy.RestartDelay = time.Duration(delay) * time.Second
+	}
+	if autoRecover, err := strconv.ParseBool(annotations[HAAutoRecoverAnnotationKey]); err == nil {
+		policy.AutoRecover = autoRecover
+	}
+	return policy
+}
+
+// sortHACandidates sorts the candidates by the priority descending, then by the restart delay
+func sortHACandidates(candidates []haCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].policy.Priority != candidates[j].policy.Priority {
+			return candidates[i].policy.Priority > candidates[j].policy.Priority
+		}
+		return candidates[i].policy.RestartDelay < candidates[j].policy.RestartDelay
+	})
+}
+
 func getNodeCondition(conditions []corev1.NodeCondition, conditionType corev1.NodeConditionType) *corev1.NodeCondition {
 	var cond *corev1.NodeCondition
 	for _, c := range conditions {
@@ -0,0 +1,187 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corefake "k8s.io/client-go/kubernetes/fake"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+func TestGetHAPolicy(t *testing.T) {
+	var testCases = []struct {
+		name        string
+		annotations map[string]string
+		expected    haPolicy
+	}{
+		{
+			name:        "default",
+			annotations: nil,
+			expected:    haPolicy{AutoRecover: true},
+		},
+		{
+			name: "all annotations",
+			annotations: map[string]string{
+				HAPriorityAnnotationKey:     "10",
+				HARestartDelayAnnotationKey: "30",
+				HAAutoRecoverAnnotationKey:  "false",
+			},
+			expected: haPolicy{Priority: 10, RestartDelay: 30 * time.Second, AutoRecover: false},
+		},
+		{
+			name: "invalid values",
+			annotations: map[string]string{
+				HAPriorityAnnotationKey:     "high",
+				HARestartDelayAnnotationKey: "-5",
+				HAAutoRecoverAnnotationKey:  "no",
+			},
+			expected: haPolicy{AutoRecover: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, getHAPolicy(tc.annotations), "case %q", tc.name)
+	}
+}
+
+func TestSortHACandidates(t *testing.T) {
+	newCandidate := func(name string, priority int, delay time.Duration) haCandidate {
+		return haCandidate{
+			pod:    corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}},
+			policy: haPolicy{Priority: priority, RestartDelay: delay, AutoRecover: true},
+		}
+	}
+	candidates := []haCandidate{
+		newCandidate("low", -1, 0),
+		newCandidate("default", 0, 0),
+		newCandidate("high-delayed", 10, time.Minute),
+		newCandidate("high", 10, 0),
+	}
+
+	sortHACandidates(candidates)
+	var names []string
+	for _, candidate := range candidates {
+		names = append(names, candidate.pod.Name)
+	}
+	assert.Equal(t, []string{"high", "high-delayed", "default", "low"}, names)
+}
+
+func TestIsNodeFenced(t *testing.T) {
+	node := &corev1.Node{}
+	assert.False(t, isNodeFenced(node))
+
+	node.Annotations = map[string]string{FencedAnnotationKey: "true"}
+	assert.True(t, isNodeFenced(node))
+}
+
+func TestRecoverVMs(t *testing.T) {
+	newVM := func(name, priority string) *kv1.VirtualMachine {
+		return &kv1.VirtualMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        name,
+				Annotations: map[string]string{HAPriorityAnnotationKey: priority},
+			},
+		}
+	}
+	newVMI := func(name, claimName string) *kv1.VirtualMachineInstance {
+		vmi := &kv1.VirtualMachineInstance{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              name,
+				UID:               "uid-" + types.UID(name),
+				CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+			},
+			Status: kv1.VirtualMachineInstanceStatus{Phase: kv1.Running},
+		}
+		if claimName != "" {
+			vmi.Spec.Volumes = []kv1.Volume{{
+				Name: "disk-0",
+				VolumeSource: kv1.VolumeSource{
+					PersistentVolumeClaim: &kv1.PersistentVolumeClaimVolumeSource{
+						PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+					},
+				},
+			}}
+		}
+		return vmi
+	}
+	newPod := func(vmName string) corev1.Pod {
+		return corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "virt-launcher-" + vmName,
+				OwnerReferences: []metav1.OwnerReference{
+					{Kind: kv1.VirtualMachineInstanceGroupVersionKind.Kind, Name: vmName},
+				},
+			},
+		}
+	}
+	rwoPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "high-disk-0"},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+		},
+	}
+
+	clientset := fake.NewSimpleClientset(newVM("high", "10"), newVM("low", "0"), newVMI("high", "high-disk-0"), newVMI("low", ""))
+	pods := []corev1.Pod{newPod("high"), newPod("low")}
+	coreclientset := corefake.NewSimpleClientset(rwoPVC, &pods[0], &pods[1])
+	h := &nodeDownHandler{
+		pods:                        fakeclients.PodClient(coreclientset.CoreV1().Pods),
+		pvcCache:                    fakeclients.PersistentVolumeClaimCache(coreclientset.CoreV1().PersistentVolumeClaims),
+		virtualMachineCache:         fakeclients.VirtualMachineCache(clientset.KubevirtV1().VirtualMachines),
+		virtualMachineInstanceCache: fakeclients.VirtualMachineInstanceCache(clientset.KubevirtV1().VirtualMachineInstances),
+		recoveredVMs:                map[string]map[string]recoveredVM{},
+	}
+	policy := &settings.VMForceResetPolicy{Enable: true, RequireFencing: true}
+	resetTime := time.Now().Add(-time.Minute)
+	podExists := func(name string) bool {
+		_, err := coreclientset.CoreV1().Pods("default").Get(context.TODO(), name, metav1.GetOptions{})
+		return err == nil
+	}
+
+	// the VM with RWO volumes waits for the node to be fenced, and the VMs with lower priority wait for it
+	node := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	requeue, err := h.recoverVMs(node, policy, resetTime, pods)
+	assert.Nil(t, err)
+	assert.Equal(t, haCheckInterval, requeue)
+	assert.True(t, podExists("virt-launcher-high"))
+	assert.True(t, podExists("virt-launcher-low"))
+
+	// the VMs recovered from other nodes don't block the ones on this node
+	h.recordRecoveredVM("node2", newVMI("other", ""), 100)
+	_, starting, err := h.getStartingPriority("node1")
+	assert.Nil(t, err)
+	assert.False(t, starting)
+
+	// the VM with higher priority is recovered once the node is fenced, the others wait for it to start
+	node.Annotations = map[string]string{FencedAnnotationKey: "true"}
+	requeue, err = h.recoverVMs(node, policy, resetTime, pods)
+	assert.Nil(t, err)
+	assert.Equal(t, haCheckInterval, requeue)
+	assert.False(t, podExists("virt-launcher-high"))
+	assert.True(t, podExists("virt-launcher-low"))
+	priority, starting, err := h.getStartingPriority("node1")
+	assert.Nil(t, err)
+	assert.True(t, starting)
+	assert.Equal(t, 10, priority)
+
+	// the recovered VM is forgotten once its new VMI is running
+	running := newVMI("high", "high-disk-0")
+	running.UID = "uid-high-new"
+	_, err = clientset.KubevirtV1().VirtualMachineInstances("default").Update(context.TODO(), running, metav1.UpdateOptions{})
+	assert.Nil(t, err)
+	_, starting, err = h.getStartingPriority("node1")
+	assert.Nil(t, err)
+	assert.False(t, starting)
+}
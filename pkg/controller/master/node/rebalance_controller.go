@@ -0,0 +1,412 @@
+package node
+
+import (
+	"context"
+	"math"
+	"time"
+
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/rancher/wrangler/pkg/slice"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/config"
+	virtv1 "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/kubevirt.io/v1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	v1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	rebalanceControllerName = "vm-rebalance-controller"
+
+	// RebalanceLabelKey labels the migrations issued by the VM rebalancer
+	RebalanceLabelKey = "harvesterhci.io/rebalance"
+	// PinnedLabelKey pins the VM labelled with "true" to its node, the VM rebalancer never migrates it
+	PinnedLabelKey = "harvesterhci.io/pinned"
+)
+
+// rebalanceHandler migrates VMs from the most loaded node to the least loaded node periodically, when the difference
+// of their requested CPU or memory exceeds the threshold in the vm-rebalance-config setting.
+type rebalanceHandler struct {
+	ctx                              context.Context
+	namespace                        string
+	settings                         ctlharvesterv1.SettingController
+	nodeCache                        ctlcorev1.NodeCache
+	podCache                         ctlcorev1.PodCache
+	virtualMachineCache              v1.VirtualMachineCache
+	virtualMachineInstanceCache      v1.VirtualMachineInstanceCache
+	virtualMachineInstanceMigrations v1.VirtualMachineInstanceMigrationClient
+	virtRestClient                   rest.Interface
+}
+
+// RebalanceRegister registers the VM rebalancer
+func RebalanceRegister(ctx context.Context, management *config.Management, options config.Options) error {
+	copyConfig := rest.CopyConfig(management.RestConfig)
+	virtv1Client, err := virtv1.NewForConfig(copyConfig)
+	if err != nil {
+		return err
+	}
+	settings := management.HarvesterFactory.Harvesterhci().V1beta1().Setting()
+	nodes := management.CoreFactory.Core().V1().Node()
+	pods := management.CoreFactory.Core().V1().Pod()
+	vms := management.VirtFactory.Kubevirt().V1().VirtualMachine()
+	vmis := management.VirtFactory.Kubevirt().V1().VirtualMachineInstance()
+	vmims := management.VirtFactory.Kubevirt().V1().VirtualMachineInstanceMigration()
+	rebalanceHandler := &rebalanceHandler{
+		ctx:                              ctx,
+		namespace:                        options.Namespace,
+		settings:                         settings,
+		nodeCache:                        nodes.Cache(),
+		podCache:                         pods.Cache(),
+		virtualMachineCache:              vms.Cache(),
+		virtualMachineInstanceCache:      vmis.Cache(),
+		virtualMachineInstanceMigrations: vmims,
+		virtRestClient:                   virtv1Client.RESTClient(),
+	}
+
+	settings.OnChange(ctx, rebalanceControllerName, rebalanceHandler.OnRebalanceConfigChanged)
+	return nil
+}
+
+// OnRebalanceConfigChanged rebalances the VMs and requeues the setting to rebalance again after the interval
+func (h *rebalanceHandler) OnRebalanceConfigChanged(key string, setting *harvesterv1.Setting) (*harvesterv1.Setting, error) {
+	if setting == nil || setting.DeletionTimestamp != nil ||
+		setting.Name != settings.VMRebalanceConfigSettingName || setting.Value == "" {
+		return setting, nil
+	}
+
+	rebalanceConfig, err := settings.DecodeVMRebalanceConfig(setting.Value)
+	if err != nil {
+		return setting, err
+	}
+	if !rebalanceConfig.Enable {
+		return setting, nil
+	}
+
+	if err := h.rebalance(rebalanceConfig); err != nil {
+		logrus.Errorf("failed to rebalance VMs: %v", err)
+	}
+	h.settings.EnqueueAfter(setting.Name, time.Duration(rebalanceConfig.Interval)*time.Second)
+	return setting, nil
+}
+
+func (h *rebalanceHandler) rebalance(rebalanceConfig *settings.VMRebalanceConfig) error {
+	vmims, err := h.virtualMachineInstanceMigrations.List(corev1.NamespaceAll, metav1.ListOptions{
+		LabelSelector: labels.Set{RebalanceLabelKey: "true"}.String(),
+	})
+	if err != nil {
+		return err
+	}
+	inflight := 0
+	for _, vmim := range vmims.Items {
+		if !vmim.IsFinal() {
+			inflight++
+		}
+	}
+	if inflight >= rebalanceConfig.MaxConcurrentMigrations {
+		return nil
+	}
+
+	nodes, err := h.nodeCache.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	pods, err := h.podCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return err
+	}
+	vmis, err := h.virtualMachineInstanceCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return err
+	}
+	var movableVMIs []*kubevirtv1.VirtualMachineInstance
+	for _, vmi := range vmis {
+		if h.isMovable(vmi) {
+			movableVMIs = append(movableVMIs, vmi)
+		}
+	}
+
+	plan := newRebalancePlan(nodes, pods)
+	for i := inflight; i < rebalanceConfig.MaxConcurrentMigrations; i++ {
+		vmi, target := plan.next(movableVMIs, rebalanceConfig.Threshold)
+		if vmi == nil {
+			return nil
+		}
+		if err := h.migrate(vmi, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isMovable checks if the VMI can be live migrated by the rebalancer, the VMs which are pinned, have host devices,
+// or have required node affinity are left alone.
+func (h *rebalanceHandler) isMovable(vmi *kubevirtv1.VirtualMachineInstance) bool {
+	if vmi.DeletionTimestamp != nil || !vmi.IsRunning() || !vmi.IsMigratable() || isMigrating(vmi) {
+		return false
+	}
+	if getNonMigratableReason(vmi) != "" {
+		return false
+	}
+	if affinity := vmi.Spec.Affinity; affinity != nil && affinity.NodeAffinity != nil &&
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		return false
+	}
+	if vmi.Labels[PinnedLabelKey] == "true" {
+		return false
+	}
+	if vm, err := h.virtualMachineCache.Get(vmi.Namespace, vmi.Name); err == nil && vm.Labels[PinnedLabelKey] == "true" {
+		return false
+	}
+	return true
+}
+
+// migrate live migrates the VMI to the target node in the same way as the migrate action of VMs
+func (h *rebalanceHandler) migrate(vmi *kubevirtv1.VirtualMachineInstance, target string) error {
+	logrus.Infof("rebalancing vm %s/%s from node %s to node %s", vmi.Namespace, vmi.Name, vmi.Status.NodeName, target)
+	toUpdate := vmi.DeepCopy()
+	if toUpdate.Annotations == nil {
+		toUpdate.Annotations = make(map[string]string)
+	}
+	if toUpdate.Spec.NodeSelector == nil {
+		toUpdate.Spec.NodeSelector = make(map[string]string)
+	}
+	toUpdate.Annotations[util.AnnotationMigrationTarget] = target
+	toUpdate.Spec.NodeSelector[corev1.LabelHostname] = target
+	if err := util.VirtClientUpdateVmi(h.ctx, h.virtRestClient, h.namespace, vmi.Namespace, vmi.Name, toUpdate); err != nil {
+		return err
+	}
+
+	_, err := h.virtualMachineInstanceMigrations.Create(&kubevirtv1.VirtualMachineInstanceMigration{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: vmi.Name + "-",
+			Namespace:    vmi.Namespace,
+			Labels: map[string]string{
+				RebalanceLabelKey: "true",
+			},
+		},
+		Spec: kubevirtv1.VirtualMachineInstanceMigrationSpec{
+			VMIName: vmi.Name,
+		},
+	})
+	return err
+}
+
+type nodeLoad struct {
+	allocatable corev1.ResourceList
+	requested   corev1.ResourceList
+}
+
+// percentage returns the larger percentage of the requested CPU and memory of the node
+func (l *nodeLoad) percentage() float64 {
+	var result float64
+	for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+		allocatable := l.allocatable[name]
+		if allocatable.IsZero() {
+			continue
+		}
+		requested := l.requested[name]
+		result = math.Max(result, float64(requested.MilliValue())*100/float64(allocatable.MilliValue()))
+	}
+	return result
+}
+
+// rebalancePlan keeps the load of the nodes which VMs can be scheduled to, and updates the load
+// as the VMs are planned to migrate.
+type rebalancePlan struct {
+	nodes     map[string]*corev1.Node
+	nodeLoads map[string]*nodeLoad
+	pods      []*corev1.Pod
+	planned   map[string]bool
+}
+
+func newRebalancePlan(nodes []*corev1.Node, pods []*corev1.Pod) *rebalancePlan {
+	plan := &rebalancePlan{
+		nodes:     make(map[string]*corev1.Node, len(nodes)),
+		nodeLoads: make(map[string]*nodeLoad, len(nodes)),
+		planned:   make(map[string]bool),
+	}
+	for _, node := range nodes {
+		plan.nodes[node.Name] = node
+		// the nodes in maintenance mode are left to the maintain node controller
+		if !isNodeSchedulable(node) {
+			continue
+		}
+		plan.nodeLoads[node.Name] = &nodeLoad{
+			allocatable: node.Status.Allocatable,
+			requested:   corev1.ResourceList{},
+		}
+	}
+	for _, pod := range pods {
+		if util.IsPodTerminated(pod) {
+			continue
+		}
+		plan.pods = append(plan.pods, pod)
+		if load, ok := plan.nodeLoads[pod.Spec.NodeName]; ok {
+			util.AddResourceList(load.requested, util.GetPodRequests(pod))
+		}
+	}
+	return plan
+}
+
+// next picks the VMI to migrate from the most loaded node to the least loaded node, which reduces the imbalance most.
+// It returns nil if the difference of their loads is under the threshold, or none of the VMIs reduces the imbalance.
+func (p *rebalancePlan) next(vmis []*kubevirtv1.VirtualMachineInstance, threshold int) (*kubevirtv1.VirtualMachineInstance, string) {
+	var source, target string
+	for name, load := range p.nodeLoads {
+		if source == "" || load.percentage() > p.nodeLoads[source].percentage() {
+			source = name
+		}
+		if target == "" || load.percentage() < p.nodeLoads[target].percentage() {
+			target = name
+		}
+	}
+	if source == target {
+		return nil, ""
+	}
+	sourceLoad, targetLoad := p.nodeLoads[source], p.nodeLoads[target]
+	imbalance := sourceLoad.percentage() - targetLoad.percentage()
+	if imbalance < float64(threshold) {
+		return nil, ""
+	}
+
+	var (
+		picked         *kubevirtv1.VirtualMachineInstance
+		pickedRequests corev1.ResourceList
+	)
+	for _, vmi := range vmis {
+		if vmi.Status.NodeName != source || p.planned[vmiKey(vmi)] || !p.canSchedule(vmi, target) {
+			continue
+		}
+		requests := p.getVMIRequests(vmi)
+		newImbalance := math.Abs(subtractLoad(sourceLoad, requests).percentage() - addLoad(targetLoad, requests).percentage())
+		if newImbalance >= imbalance {
+			continue
+		}
+		imbalance = newImbalance
+		picked, pickedRequests = vmi, requests
+	}
+	if picked == nil {
+		return nil, ""
+	}
+
+	p.planned[vmiKey(picked)] = true
+	p.nodeLoads[source] = subtractLoad(sourceLoad, pickedRequests)
+	p.nodeLoads[target] = addLoad(targetLoad, pickedRequests)
+	return picked, target
+}
+
+// canSchedule checks the node selectors, the tolerations and the pod anti-affinity of the VMI. The preferred
+// anti-affinity, e.g. the default one of Harvester VMs, is respected by not moving the VMI to a node with more
+// matched pods than its current node.
+func (p *rebalancePlan) canSchedule(vmi *kubevirtv1.VirtualMachineInstance, target string) bool {
+	node := p.nodes[target]
+	for key, value := range vmi.Spec.NodeSelector {
+		if key != corev1.LabelHostname && node.Labels[key] != value {
+			return false
+		}
+	}
+	for i, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for _, toleration := range vmi.Spec.Tolerations {
+			if toleration.ToleratesTaint(&node.Spec.Taints[i]) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+
+	if vmi.Spec.Affinity == nil || vmi.Spec.Affinity.PodAntiAffinity == nil {
+		return true
+	}
+	antiAffinity := vmi.Spec.Affinity.PodAntiAffinity
+	for _, term := range antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if p.countMatchedPods(vmi, term, target) > 0 {
+			return false
+		}
+	}
+	for _, weightedTerm := range antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		term := weightedTerm.PodAffinityTerm
+		if p.countMatchedPods(vmi, term, target) > p.countMatchedPods(vmi, term, vmi.Status.NodeName) {
+			return false
+		}
+	}
+	return true
+}
+
+// countMatchedPods counts the pods matching the anti-affinity term in the topology domain of the node,
+// excluding the pods of the VMI itself.
+func (p *rebalancePlan) countMatchedPods(vmi *kubevirtv1.VirtualMachineInstance, term corev1.PodAffinityTerm, nodeName string) int {
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil {
+		return 0
+	}
+	namespaces := term.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{vmi.Namespace}
+	}
+	domain := p.nodes[nodeName].Labels[term.TopologyKey]
+
+	count := 0
+	for _, pod := range p.pods {
+		if pod.Labels[kubevirtv1.CreatedByLabel] == string(vmi.UID) || !slice.ContainsString(namespaces, pod.Namespace) ||
+			!selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if node, ok := p.nodes[pod.Spec.NodeName]; ok && node.Labels[term.TopologyKey] == domain {
+			count++
+		}
+	}
+	return count
+}
+
+// getVMIRequests returns the requests of the virt-launcher pod of the VMI
+func (p *rebalancePlan) getVMIRequests(vmi *kubevirtv1.VirtualMachineInstance) corev1.ResourceList {
+	for _, pod := range p.pods {
+		if pod.Namespace == vmi.Namespace && pod.Labels[kubevirtv1.CreatedByLabel] == string(vmi.UID) &&
+			pod.Spec.NodeName == vmi.Status.NodeName {
+			return util.GetPodRequests(pod)
+		}
+	}
+	return vmi.Spec.Domain.Resources.Requests
+}
+
+func addLoad(load *nodeLoad, requests corev1.ResourceList) *nodeLoad {
+	requested := corev1.ResourceList{}
+	util.AddResourceList(requested, load.requested)
+	util.AddResourceList(requested, requests)
+	return &nodeLoad{allocatable: load.allocatable, requested: requested}
+}
+
+func subtractLoad(load *nodeLoad, requests corev1.ResourceList) *nodeLoad {
+	requested := corev1.ResourceList{}
+	util.AddResourceList(requested, load.requested)
+	for name, quantity := range requests {
+		if value, ok := requested[name]; ok {
+			value.Sub(quantity)
+			requested[name] = value
+		}
+	}
+	return &nodeLoad{allocatable: load.allocatable, requested: requested}
+}
+
+func isNodeSchedulable(node *corev1.Node) bool {
+	if node.Spec.Unschedulable || node.Annotations[MaintainStatusAnnotationKey] != "" {
+		return false
+	}
+	cond := getNodeCondition(node.Status.Conditions, corev1.NodeReady)
+	return cond != nil && cond.Status == corev1.ConditionTrue
+}
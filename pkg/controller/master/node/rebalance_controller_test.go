@@ -0,0 +1,152 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
+)
+
+const testVMCreatorLabel = "harvesterhci.io/creator"
+
+func newRebalanceTestNode(name string, annotations map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{corev1.LabelHostname: name},
+			Annotations: annotations,
+		},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			},
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10"),
+				corev1.ResourceMemory: resource.MustParse("10Gi"),
+			},
+		},
+	}
+}
+
+func newRebalanceTestVMI(name, nodeName string, antiAffinity bool) *kubevirtv1.VirtualMachineInstance {
+	vmi := &kubevirtv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			UID:       types.UID(name),
+		},
+		Status: kubevirtv1.VirtualMachineInstanceStatus{
+			NodeName: nodeName,
+		},
+	}
+	if antiAffinity {
+		vmi.Spec.Affinity = &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{
+						Weight: 100,
+						PodAffinityTerm: corev1.PodAffinityTerm{
+							LabelSelector: &metav1.LabelSelector{
+								MatchExpressions: []metav1.LabelSelectorRequirement{
+									{Key: testVMCreatorLabel, Operator: metav1.LabelSelectorOpExists},
+								},
+							},
+							TopologyKey: corev1.LabelHostname,
+						},
+					},
+				},
+			},
+		}
+	}
+	return vmi
+}
+
+func newRebalanceTestPod(vmi *kubevirtv1.VirtualMachineInstance, cpu string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "virt-launcher-" + vmi.Name,
+			Namespace: vmi.Namespace,
+			Labels: map[string]string{
+				kubevirtv1.CreatedByLabel: string(vmi.UID),
+				testVMCreatorLabel:        "admin",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: vmi.Status.NodeName,
+			Containers: []corev1.Container{
+				{
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{
+							corev1.ResourceCPU:    resource.MustParse(cpu),
+							corev1.ResourceMemory: resource.MustParse("1Gi"),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestRebalancePlanNext(t *testing.T) {
+	nodes := []*corev1.Node{
+		newRebalanceTestNode("node-1", nil),
+		newRebalanceTestNode("node-2", nil),
+		newRebalanceTestNode("node-3", map[string]string{MaintainStatusAnnotationKey: MaintainStatusRunning}),
+	}
+	vmis := []*kubevirtv1.VirtualMachineInstance{
+		newRebalanceTestVMI("vm-small", "node-1", false),
+		newRebalanceTestVMI("vm-large", "node-1", false),
+		newRebalanceTestVMI("vm-medium", "node-1", false),
+	}
+	pods := []*corev1.Pod{
+		newRebalanceTestPod(vmis[0], "1"),
+		newRebalanceTestPod(vmis[1], "5"),
+		newRebalanceTestPod(vmis[2], "2"),
+	}
+
+	// node-1 is 80% loaded and node-2 is empty, moving vm-large leaves them at 30% and 50%,
+	// node-3 is in maintenance mode and never picked as the target.
+	plan := newRebalancePlan(nodes, pods)
+	vmi, target := plan.next(vmis, 20)
+	assert.Equal(t, "vm-large", vmi.Name)
+	assert.Equal(t, "node-2", target)
+
+	// now the difference is 20%, moving vm-small leaves them at 20% and 60%, which doesn't reduce the imbalance
+	vmi, _ = plan.next(vmis, 20)
+	assert.Nil(t, vmi)
+
+	// the difference is under the threshold
+	plan = newRebalancePlan(nodes, pods)
+	vmi, _ = plan.next(vmis, 90)
+	assert.Nil(t, vmi)
+}
+
+func TestRebalancePlanRespectsAntiAffinity(t *testing.T) {
+	nodes := []*corev1.Node{
+		newRebalanceTestNode("node-1", nil),
+		newRebalanceTestNode("node-2", nil),
+	}
+	vmi := newRebalanceTestVMI("vm-1", "node-1", true)
+	workload := newRebalanceTestPod(newRebalanceTestVMI("workload", "node-1", false), "4")
+	workload.Labels = nil
+	pods := []*corev1.Pod{
+		newRebalanceTestPod(vmi, "4"),
+		workload,
+		newRebalanceTestPod(newRebalanceTestVMI("vm-2", "node-2", false), "500m"),
+	}
+
+	// vm-1 is the only VM on node-1, moving it next to vm-2 breaks the preferred anti-affinity
+	plan := newRebalancePlan(nodes, pods)
+	picked, _ := plan.next([]*kubevirtv1.VirtualMachineInstance{vmi}, 20)
+	assert.Nil(t, picked)
+
+	vmi.Spec.Affinity = nil
+	plan = newRebalancePlan(nodes, pods)
+	picked, target := plan.next([]*kubevirtv1.VirtualMachineInstance{vmi}, 20)
+	assert.Equal(t, "vm-1", picked.Name)
+	assert.Equal(t, "node-2", target)
+}
@@ -25,18 +25,24 @@ type registerFunc func(context.Context, *config.Management, config.Options) erro
 
 var registerFuncs = []registerFunc{
 	image.Register,
+	image.GCRegister,
 	keypair.Register,
 	migration.Register,
 	node.PromoteRegister,
 	node.MaintainRegister,
 	node.NodeDownRegister,
+	node.RebalanceRegister,
 	setting.Register,
 	template.Register,
 	virtualmachine.Register,
 	backup.RegisterBackup,
 	backup.RegisterRestore,
 	backup.RegisterBackupTarget,
+	backup.RegisterBackupTargetHealth,
 	backup.RegisterBackupMetadata,
+	backup.RegisterBackupVerify,
+	backup.RegisterBackupSchedule,
+	backup.RegisterVMSnapshot,
 	supportbundle.Register,
 	rancher.Register,
 	upgrade.Register,
@@ -12,6 +12,12 @@ const (
 	vmControllerUnsetOwnerOfPVCsControllerName         = "VMController.UnsetOwnerOfPVCs"
 	vmiControllerUnsetOwnerOfPVCsControllerName        = "VMIController.UnsetOwnerOfPVCs"
 	vmControllerSetDefaultManagementNetworkMac         = "VMController.SetDefaultManagementNetworkMacAddress"
+	vmControllerSyncPendingChangesControllerName       = "VMController.SyncPendingChanges"
+	vmiControllerEnqueueVMControllerName               = "VMIController.EnqueueVM"
+	vmControllerSyncPowerOperationControllerName       = "VMController.SyncPowerOperation"
+	vmiControllerEnqueueVMByPowerOperationName         = "VMIController.EnqueueVMByPowerOperation"
+	vmPowerOperationRecorderName                       = "harvester-vm-power-operation"
+	pvcControllerMoveClonedVolumeControllerName        = "PVCController.MoveClonedVolume"
 )
 
 func Register(ctx context.Context, management *config.Management, options config.Options) error {
@@ -51,5 +57,32 @@ func Register(ctx context.Context, management *config.Management, options config
 	}
 	virtualMachineInstanceClient.OnChange(ctx, vmControllerSetDefaultManagementNetworkMac, vmNetworkCtl.SetDefaultNetworkMacAddress)
 
+	// register the vm pending changes controller upon the VM and VMI changes
+	var vmPendingChangesCtl = &VMPendingChangesController{
+		vmClient: vmClient,
+		vmiCache: virtualMachineInstanceClient.Cache(),
+	}
+	virtualMachineClient.OnChange(ctx, vmControllerSyncPendingChangesControllerName, vmPendingChangesCtl.SyncPendingChanges)
+	virtualMachineInstanceClient.OnChange(ctx, vmiControllerEnqueueVMControllerName, vmPendingChangesCtl.EnqueueVM)
+
+	// register the vm power operation controller upon the VM and VMI changes
+	var vmPowerOperationCtl = &VMPowerOperationController{
+		vmClient:  vmClient,
+		vmiClient: virtualMachineInstanceClient,
+		vmiCache:  virtualMachineInstanceClient.Cache(),
+		recorder:  management.NewRecorder(vmPowerOperationRecorderName, "", ""),
+	}
+	virtualMachineClient.OnChange(ctx, vmControllerSyncPowerOperationControllerName, vmPowerOperationCtl.SyncPowerOperation)
+	virtualMachineInstanceClient.OnChange(ctx, vmiControllerEnqueueVMByPowerOperationName, vmPowerOperationCtl.EnqueueVMByVMI)
+
+	// register the controller moving the volumes of the VMs cloned to another namespace
+	var vmCloneVolumeCtl = &VMCloneVolumeController{
+		pvcClient: pvcClient,
+		pvcCache:  pvcCache,
+		pvClient:  management.ClientSet.CoreV1(),
+		vmClient:  vmClient,
+	}
+	pvcClient.OnChange(ctx, pvcControllerMoveClonedVolumeControllerName, vmCloneVolumeCtl.MoveClonedVolume)
+
 	return nil
 }
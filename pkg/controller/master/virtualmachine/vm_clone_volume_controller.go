@@ -0,0 +1,140 @@
+package virtualmachine
+
+import (
+	"context"
+	"fmt"
+
+	v1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	kubevirtapis "kubevirt.io/client-go/api/v1"
+
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+// VMCloneVolumeController moves the volumes cloned for a VM cloned to another namespace. CSI volume clone only
+// copies a PVC in its own namespace, so the clone action clones the PVC in the source namespace with
+// the cloneTarget and cloneTargetUID annotations, and the controller rebinds its PV to a PVC of the same name in
+// the target namespace. The PVC webhook only allows the Harvester controller to set the annotations.
+type VMCloneVolumeController struct {
+	pvcClient v1.PersistentVolumeClaimClient
+	pvcCache  v1.PersistentVolumeClaimCache
+	pvClient  corev1client.PersistentVolumesGetter
+	vmClient  ctlkubevirtv1.VirtualMachineClient
+}
+
+// MoveClonedVolume rebinds the PV of the cloned PVC to the PVC in the target namespace of the clone, and deletes
+// the cloned PVC afterwards. The PV is pre-bound to the target PVC first, so it's no longer the claim of
+// the cloned PVC and isn't reclaimed when the cloned PVC is deleted.
+func (h *VMCloneVolumeController) MoveClonedVolume(_ string, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	if pvc == nil || pvc.DeletionTimestamp != nil {
+		return pvc, nil
+	}
+	target, ok := pvc.Annotations[util.AnnotationCloneTarget]
+	if !ok {
+		return pvc, nil
+	}
+	targetUID, ok := pvc.Annotations[util.AnnotationCloneTargetUID]
+	if !ok {
+		logrus.Warnf("skipping PVC %s/%s without the clone target UID", pvc.Namespace, pvc.Name)
+		return pvc, nil
+	}
+
+	targetNamespace, vmName := ref.Parse(target)
+	// gets the VM from the API server, the cache may not have the VM created right before the PVC yet
+	vm, err := h.vmClient.Get(targetNamespace, vmName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) || (err == nil && string(vm.UID) != targetUID) {
+		// the clone is deleted or rolled back, so the volume is no longer needed
+		return pvc, h.deleteClonedPVC(pvc)
+	} else if err != nil {
+		return pvc, fmt.Errorf("failed to get VirtualMachine(%s/%s): %w", targetNamespace, vmName, err)
+	}
+
+	// waits for the volume to be provisioned
+	if pvc.Spec.VolumeName == "" {
+		return pvc, nil
+	}
+
+	// the PVC in the target namespace is either the one moved here before, or another one which must be left alone
+	targetPVC, err := h.pvcCache.Get(targetNamespace, pvc.Name)
+	if apierrors.IsNotFound(err) {
+		targetPVC = nil
+	} else if err != nil {
+		return pvc, fmt.Errorf("failed to get PVC(%s/%s): %w", targetNamespace, pvc.Name, err)
+	} else if targetPVC.Spec.VolumeName != pvc.Spec.VolumeName {
+		logrus.Warnf("can't move the cloned PVC %s/%s, PVC %s/%s already exists", pvc.Namespace, pvc.Name, targetNamespace, pvc.Name)
+		return pvc, nil
+	}
+
+	pv, err := h.pvClient.PersistentVolumes().Get(context.TODO(), pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return pvc, fmt.Errorf("failed to get PV %s: %w", pvc.Spec.VolumeName, err)
+	}
+	if claimRef := pv.Spec.ClaimRef; claimRef == nil || claimRef.Namespace != targetNamespace || claimRef.Name != pvc.Name {
+		pvCopy := pv.DeepCopy()
+		pvCopy.Spec.ClaimRef = &corev1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       "PersistentVolumeClaim",
+			Namespace:  targetNamespace,
+			Name:       pvc.Name,
+		}
+		if _, err := h.pvClient.PersistentVolumes().Update(context.TODO(), pvCopy, metav1.UpdateOptions{}); err != nil {
+			return pvc, fmt.Errorf("failed to bind PV %s to PVC(%s/%s): %w", pv.Name, targetNamespace, pvc.Name, err)
+		}
+	}
+
+	if targetPVC == nil {
+		movedPVC, err := getMovedPVC(pvc, targetNamespace, vm)
+		if err != nil {
+			return pvc, err
+		}
+		if _, err := h.pvcClient.Create(movedPVC); err != nil && !apierrors.IsAlreadyExists(err) {
+			return pvc, fmt.Errorf("failed to create PVC(%s/%s): %w", targetNamespace, pvc.Name, err)
+		}
+	}
+
+	return pvc, h.deleteClonedPVC(pvc)
+}
+
+func (h *VMCloneVolumeController) deleteClonedPVC(pvc *corev1.PersistentVolumeClaim) error {
+	if err := h.pvcClient.Delete(pvc.Namespace, pvc.Name, &metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the cloned PVC(%s/%s): %w", pvc.Namespace, pvc.Name, err)
+	}
+	return nil
+}
+
+// getMovedPVC returns the PVC in the target namespace bound to the PV of the cloned PVC, owned by the cloned VM.
+func getMovedPVC(pvc *corev1.PersistentVolumeClaim, namespace string, vm *kubevirtapis.VirtualMachine) (*corev1.PersistentVolumeClaim, error) {
+	movedPVC := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pvc.Name,
+			Namespace:   namespace,
+			Annotations: map[string]string{},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      pvc.Spec.AccessModes,
+			Resources:        pvc.Spec.Resources,
+			StorageClassName: pvc.Spec.StorageClassName,
+			VolumeMode:       pvc.Spec.VolumeMode,
+			VolumeName:       pvc.Spec.VolumeName,
+		},
+	}
+	if imageID, ok := pvc.Annotations[util.AnnotationImageID]; ok {
+		movedPVC.Annotations[util.AnnotationImageID] = imageID
+	}
+
+	annotationSchemaOwners, err := ref.GetSchemaOwnersFromAnnotation(movedPVC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema owners from annotation: %w", err)
+	}
+	annotationSchemaOwners.Add(kubevirtapis.VirtualMachineGroupVersionKind.GroupKind(), vm)
+	if err := annotationSchemaOwners.Bind(movedPVC); err != nil {
+		return nil, fmt.Errorf("failed to apply schema owners to object: %w", err)
+	}
+	return movedPVC, nil
+}
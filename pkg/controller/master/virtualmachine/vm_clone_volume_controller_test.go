@@ -0,0 +1,175 @@
+package virtualmachine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corefake "k8s.io/client-go/kubernetes/fake"
+	kubevirtapis "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+func TestVMCloneVolumeController_MoveClonedVolume(t *testing.T) {
+	var clonedPVC = func(volumeName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "target-disk-0-abcde",
+				Annotations: map[string]string{
+					util.AnnotationCloneTarget:    "other/target",
+					util.AnnotationCloneTargetUID: "fake-vm-uid",
+					util.AnnotationImageID:        "default/image-abcde",
+				},
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteMany},
+				VolumeName:  volumeName,
+				DataSource: &corev1.TypedLocalObjectReference{
+					Kind: "PersistentVolumeClaim",
+					Name: "source-disk-0",
+				},
+			},
+		}
+	}
+	var pv = &corev1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "pvc-abcde",
+		},
+		Spec: corev1.PersistentVolumeSpec{
+			ClaimRef: &corev1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: "default",
+				Name:      "target-disk-0-abcde",
+				UID:       "fake-pvc-uid",
+			},
+		},
+	}
+	var vm = &kubevirtapis.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "other",
+			Name:      "target",
+			UID:       "fake-vm-uid",
+		},
+	}
+
+	var recreatedVM = vm.DeepCopy()
+	recreatedVM.UID = "another-vm-uid"
+	var withoutTargetUID = clonedPVC("pvc-abcde")
+	delete(withoutTargetUID.Annotations, util.AnnotationCloneTargetUID)
+	var existingPVC = &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "other",
+			Name:      "target-disk-0-abcde",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			VolumeName: "pvc-other",
+		},
+	}
+
+	var testCases = []struct {
+		name              string
+		pvc               *corev1.PersistentVolumeClaim
+		vm                *kubevirtapis.VirtualMachine
+		existingPVC       *corev1.PersistentVolumeClaim
+		expectedMoved     bool
+		expectedRemaining bool
+	}{
+		{
+			name:              "waiting for the volume to be provisioned",
+			pvc:               clonedPVC(""),
+			vm:                vm,
+			expectedRemaining: true,
+		},
+		{
+			name:          "moving the provisioned volume",
+			pvc:           clonedPVC("pvc-abcde"),
+			vm:            vm,
+			expectedMoved: true,
+		},
+		{
+			name: "deleting the volume of a deleted clone",
+			pvc:  clonedPVC("pvc-abcde"),
+		},
+		{
+			name: "deleting the volume of a clone replaced by another VM of the same name",
+			pvc:  clonedPVC("pvc-abcde"),
+			vm:   recreatedVM,
+		},
+		{
+			name:              "skipping the volume without the clone target UID",
+			pvc:               withoutTargetUID,
+			expectedRemaining: true,
+		},
+		{
+			name:              "skipping the volume when the target PVC already exists",
+			pvc:               clonedPVC("pvc-abcde"),
+			vm:                vm,
+			existingPVC:       existingPVC,
+			expectedRemaining: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		var clientset = fake.NewSimpleClientset()
+		var coreclientset = corefake.NewSimpleClientset(pv.DeepCopy(), tc.pvc)
+		if tc.vm != nil {
+			var err = clientset.Tracker().Add(tc.vm)
+			assert.Nil(t, err, "mock resource should add into fake controller tracker")
+		}
+		if tc.existingPVC != nil {
+			var err = coreclientset.Tracker().Add(tc.existingPVC.DeepCopy())
+			assert.Nil(t, err, "mock resource should add into fake controller tracker")
+		}
+
+		var ctrl = &VMCloneVolumeController{
+			pvcClient: fakeclients.PersistentVolumeClaimClient(coreclientset.CoreV1().PersistentVolumeClaims),
+			pvcCache:  fakeclients.PersistentVolumeClaimCache(coreclientset.CoreV1().PersistentVolumeClaims),
+			pvClient:  coreclientset.CoreV1(),
+			vmClient:  fakeclients.VirtualMachineClient(clientset.KubevirtV1().VirtualMachines),
+		}
+		_, err := ctrl.MoveClonedVolume("", tc.pvc)
+		assert.Nil(t, err, "case %q", tc.name)
+
+		_, err = coreclientset.CoreV1().PersistentVolumeClaims("default").Get(context.TODO(), tc.pvc.Name, metav1.GetOptions{})
+		assert.Equal(t, tc.expectedRemaining, err == nil, "case %q", tc.name)
+
+		if tc.existingPVC != nil {
+			// neither the PV nor the existing PVC is touched
+			unchangedPV, err := coreclientset.CoreV1().PersistentVolumes().Get(context.TODO(), "pvc-abcde", metav1.GetOptions{})
+			assert.Nil(t, err, "case %q", tc.name)
+			assert.Equal(t, pv.Spec.ClaimRef, unchangedPV.Spec.ClaimRef, "case %q", tc.name)
+			unchangedPVC, err := coreclientset.CoreV1().PersistentVolumeClaims("other").Get(context.TODO(), tc.pvc.Name, metav1.GetOptions{})
+			assert.Nil(t, err, "case %q", tc.name)
+			assert.Equal(t, "pvc-other", unchangedPVC.Spec.VolumeName, "case %q", tc.name)
+			continue
+		}
+
+		movedPVC, err := coreclientset.CoreV1().PersistentVolumeClaims("other").Get(context.TODO(), tc.pvc.Name, metav1.GetOptions{})
+		if !tc.expectedMoved {
+			assert.True(t, apierrors.IsNotFound(err), "case %q", tc.name)
+			continue
+		}
+		assert.Nil(t, err, "case %q", tc.name)
+		assert.Equal(t, "pvc-abcde", movedPVC.Spec.VolumeName, "case %q", tc.name)
+		assert.Nil(t, movedPVC.Spec.DataSource, "case %q", tc.name)
+		assert.Equal(t, "default/image-abcde", movedPVC.Annotations[util.AnnotationImageID], "case %q", tc.name)
+		assert.NotContains(t, movedPVC.Annotations, util.AnnotationCloneTarget, "case %q", tc.name)
+		owners, err := ref.GetSchemaOwnersFromAnnotation(movedPVC)
+		assert.Nil(t, err, "case %q", tc.name)
+		assert.Equal(t, []string{"other/target"}, owners.List(kubevirtapis.VirtualMachineGroupVersionKind.GroupKind()), "case %q", tc.name)
+
+		movedPV, err := coreclientset.CoreV1().PersistentVolumes().Get(context.TODO(), "pvc-abcde", metav1.GetOptions{})
+		assert.Nil(t, err, "case %q", tc.name)
+		assert.Equal(t, "other", movedPV.Spec.ClaimRef.Namespace, "case %q", tc.name)
+		assert.Equal(t, tc.pvc.Name, movedPV.Spec.ClaimRef.Name, "case %q", tc.name)
+		assert.Empty(t, movedPV.Spec.ClaimRef.UID, "case %q", tc.name)
+	}
+}
@@ -0,0 +1,233 @@
+package virtualmachine
+
+import (
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	vmv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	PendingChangeCPU      = "cpu"
+	PendingChangeMemory   = "memory"
+	PendingChangeNetworks = "networks"
+	PendingChangeVolumes  = "volumes"
+	PendingChangeDevices  = "devices"
+)
+
+type VMPendingChangesController struct {
+	vmClient vmv1.VirtualMachineController
+	vmiCache vmv1.VirtualMachineInstanceCache
+}
+
+// SyncPendingChanges compares the VirtualMachine's template with the spec of the running VirtualMachineInstance,
+// and saves the changes which take effect after the VM restarts in the pendingChanges annotation. The VM status
+// conditions are owned by KubeVirt, which drops the unknown ones when it syncs them.
+func (h *VMPendingChangesController) SyncPendingChanges(_ string, vm *kv1.VirtualMachine) (*kv1.VirtualMachine, error) {
+	if vm == nil || vm.DeletionTimestamp != nil {
+		return vm, nil
+	}
+
+	vmi, err := h.vmiCache.Get(vm.Namespace, vm.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return vm, fmt.Errorf("failed to get VirtualMachineInstance(%s/%s): %w", vm.Namespace, vm.Name, err)
+	}
+	if apierrors.IsNotFound(err) {
+		vmi = nil
+	}
+
+	var changes = strings.Join(GetPendingChanges(vm, vmi), ",")
+	if current, ok := vm.Annotations[util.AnnotationPendingChanges]; current == changes && (ok || changes == "") {
+		return vm, nil
+	}
+
+	var vmCopy = vm.DeepCopy()
+	if changes == "" {
+		delete(vmCopy.Annotations, util.AnnotationPendingChanges)
+	} else {
+		if vmCopy.Annotations == nil {
+			vmCopy.Annotations = make(map[string]string)
+		}
+		vmCopy.Annotations[util.AnnotationPendingChanges] = changes
+	}
+	return h.vmClient.Update(vmCopy)
+}
+
+// EnqueueVM enqueues the VirtualMachine of the changed VirtualMachineInstance to compare them again.
+func (h *VMPendingChangesController) EnqueueVM(_ string, vmi *kv1.VirtualMachineInstance) (*kv1.VirtualMachineInstance, error) {
+	if vmi == nil {
+		return vmi, nil
+	}
+	h.vmClient.Enqueue(vmi.Namespace, vmi.Name)
+	return vmi, nil
+}
+
+// GetPendingChanges returns the parts of the VirtualMachine's template which differ from the spec of the running
+// VirtualMachineInstance. The fields defaulted or changed by KubeVirt and Harvester on the VMI, e.g. the disk bus
+// and the node selector for migrations, are not compared.
+func GetPendingChanges(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) []string {
+	if vm.Spec.Template == nil || vmi == nil || vmi.IsFinal() {
+		return nil
+	}
+	var desired, observed = &vm.Spec.Template.Spec, &vmi.Spec
+
+	var changes []string
+	if !equalCPU(desired, observed) {
+		changes = append(changes, PendingChangeCPU)
+	}
+	if !equalMemory(desired, observed) {
+		changes = append(changes, PendingChangeMemory)
+	}
+	if !equalNetworks(desired, vmi) {
+		changes = append(changes, PendingChangeNetworks)
+	}
+	if !equalVolumes(desired, observed) {
+		changes = append(changes, PendingChangeVolumes)
+	}
+	if !equalDevices(desired, observed) {
+		changes = append(changes, PendingChangeDevices)
+	}
+	return changes
+}
+
+func equalCPU(desired, observed *kv1.VirtualMachineInstanceSpec) bool {
+	var topology = func(cpu *kv1.CPU) [3]uint32 {
+		var result = [3]uint32{1, 1, 1}
+		if cpu == nil {
+			return result
+		}
+		for i, value := range []uint32{cpu.Sockets, cpu.Cores, cpu.Threads} {
+			if value > 0 {
+				result[i] = value
+			}
+		}
+		return result
+	}
+	if topology(desired.Domain.CPU) != topology(observed.Domain.CPU) {
+		return false
+	}
+	return equalQuantity(desired.Domain.Resources.Limits.Cpu(), observed.Domain.Resources.Limits.Cpu())
+}
+
+func equalMemory(desired, observed *kv1.VirtualMachineInstanceSpec) bool {
+	if !equalQuantity(desired.Domain.Resources.Limits.Memory(), observed.Domain.Resources.Limits.Memory()) {
+		return false
+	}
+	var desiredGuest, observedGuest *resource.Quantity
+	if desired.Domain.Memory != nil {
+		desiredGuest = desired.Domain.Memory.Guest
+	}
+	if observed.Domain.Memory != nil {
+		observedGuest = observed.Domain.Memory.Guest
+	}
+	// the guest memory is defaulted from the limit if it's not set
+	return desiredGuest == nil || observedGuest == nil || desiredGuest.Cmp(*observedGuest) == 0
+}
+
+func equalNetworks(desired *kv1.VirtualMachineInstanceSpec, vmi *kv1.VirtualMachineInstance) bool {
+	var networkKeys = func(networks []kv1.Network) sets.String {
+		var keys = sets.String{}
+		for _, network := range networks {
+			var source = "pod"
+			if network.Multus != nil {
+				source = "multus/" + network.Multus.NetworkName
+			}
+			keys.Insert(network.Name + "=" + source)
+		}
+		return keys
+	}
+	if !networkKeys(desired.Networks).Equal(networkKeys(vmi.Spec.Networks)) {
+		return false
+	}
+
+	// the MAC address of the VM is set from the VMI status by the VMNetworkController
+	var observedMACs = make(map[string]string, len(vmi.Status.Interfaces))
+	for _, iface := range vmi.Status.Interfaces {
+		observedMACs[iface.Name] = iface.MAC
+	}
+	var interfaceKeys = func(interfaces []kv1.Interface, macs map[string]string) sets.String {
+		var keys = sets.String{}
+		for _, iface := range interfaces {
+			var mac = iface.MacAddress
+			if mac == "" {
+				mac = macs[iface.Name]
+			}
+			// KubeVirt defaults the model to virtio
+			var model = iface.Model
+			if model == "" {
+				model = "virtio"
+			}
+			keys.Insert(strings.Join([]string{iface.Name, model, interfaceBinding(iface), strings.ToLower(mac)}, "/"))
+		}
+		return keys
+	}
+	return interfaceKeys(desired.Domain.Devices.Interfaces, observedMACs).
+		Equal(interfaceKeys(vmi.Spec.Domain.Devices.Interfaces, observedMACs))
+}
+
+func interfaceBinding(iface kv1.Interface) string {
+	switch {
+	case iface.Bridge != nil:
+		return "bridge"
+	case iface.Masquerade != nil:
+		return "masquerade"
+	case iface.SRIOV != nil:
+		return "sriov"
+	case iface.Slirp != nil:
+		return "slirp"
+	default:
+		return ""
+	}
+}
+
+func equalVolumes(desired, observed *kv1.VirtualMachineInstanceSpec) bool {
+	var volumeKeys = func(volumes []kv1.Volume) sets.String {
+		var keys = sets.String{}
+		for _, volume := range volumes {
+			var source string
+			switch {
+			case volume.PersistentVolumeClaim != nil:
+				source = "pvc/" + volume.PersistentVolumeClaim.ClaimName
+			case volume.DataVolume != nil:
+				source = "dv/" + volume.DataVolume.Name
+			case volume.ContainerDisk != nil:
+				source = "containerdisk/" + volume.ContainerDisk.Image
+			}
+			keys.Insert(volume.Name + "=" + source)
+		}
+		return keys
+	}
+	var diskNames = func(disks []kv1.Disk) sets.String {
+		var names = sets.String{}
+		for _, disk := range disks {
+			names.Insert(disk.Name)
+		}
+		return names
+	}
+	return volumeKeys(desired.Volumes).Equal(volumeKeys(observed.Volumes)) &&
+		diskNames(desired.Domain.Devices.Disks).Equal(diskNames(observed.Domain.Devices.Disks))
+}
+
+func equalDevices(desired, observed *kv1.VirtualMachineInstanceSpec) bool {
+	var deviceKeys = func(devices kv1.Devices) sets.String {
+		var keys = sets.String{}
+		for _, device := range devices.HostDevices {
+			keys.Insert("host/" + device.Name + "=" + device.DeviceName)
+		}
+		for _, gpu := range devices.GPUs {
+			keys.Insert("gpu/" + gpu.Name + "=" + gpu.DeviceName)
+		}
+		return keys
+	}
+	return deviceKeys(desired.Domain.Devices).Equal(deviceKeys(observed.Domain.Devices))
+}
+
+func equalQuantity(desired, observed *resource.Quantity) bool {
+	return desired.Cmp(*observed) == 0
+}
@@ -0,0 +1,109 @@
+package virtualmachine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	kv1 "kubevirt.io/client-go/api/v1"
+)
+
+func newPendingChangesTestVMISpec() kv1.VirtualMachineInstanceSpec {
+	return kv1.VirtualMachineInstanceSpec{
+		Domain: kv1.DomainSpec{
+			CPU: &kv1.CPU{Cores: 2},
+			Resources: kv1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("2"),
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				},
+			},
+			Devices: kv1.Devices{
+				Disks: []kv1.Disk{{Name: "disk-0"}},
+				Interfaces: []kv1.Interface{
+					{Name: "default", InterfaceBindingMethod: kv1.InterfaceBindingMethod{Bridge: &kv1.InterfaceBridge{}}},
+				},
+			},
+		},
+		Networks: []kv1.Network{
+			{Name: "default", NetworkSource: kv1.NetworkSource{Multus: &kv1.MultusNetwork{NetworkName: "default/vlan1"}}},
+		},
+		Volumes: []kv1.Volume{
+			{Name: "disk-0", VolumeSource: kv1.VolumeSource{PersistentVolumeClaim: &kv1.PersistentVolumeClaimVolumeSource{
+				PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{ClaimName: "pvc-0"},
+			}}},
+		},
+	}
+}
+
+func TestGetPendingChanges(t *testing.T) {
+	var testCases = []struct {
+		name     string
+		update   func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance)
+		expected []string
+	}{
+		{
+			name:     "no change",
+			update:   func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) {},
+			expected: nil,
+		},
+		{
+			name: "fields defaulted by KubeVirt",
+			update: func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) {
+				vmi.Spec.Domain.CPU = &kv1.CPU{Sockets: 1, Cores: 2, Threads: 1}
+				vmi.Spec.Domain.Devices.Interfaces[0].Model = "virtio"
+				vmi.Spec.Domain.Devices.Disks[0].DiskDevice = kv1.DiskDevice{Disk: &kv1.DiskTarget{Bus: "virtio"}}
+				vmi.Spec.NodeSelector = map[string]string{corev1.LabelHostname: "node-1"}
+			},
+			expected: nil,
+		},
+		{
+			name: "mac address set from the VMI status",
+			update: func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) {
+				vm.Spec.Template.Spec.Domain.Devices.Interfaces[0].MacAddress = "52:54:00:AB:CD:EF"
+				vmi.Status.Interfaces = []kv1.VirtualMachineInstanceNetworkInterface{{Name: "default", MAC: "52:54:00:ab:cd:ef"}}
+			},
+			expected: nil,
+		},
+		{
+			name: "cpu and memory",
+			update: func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) {
+				vm.Spec.Template.Spec.Domain.CPU.Sockets = 2
+				vm.Spec.Template.Spec.Domain.Resources.Limits[corev1.ResourceMemory] = resource.MustParse("8Gi")
+			},
+			expected: []string{PendingChangeCPU, PendingChangeMemory},
+		},
+		{
+			name: "networks and volumes",
+			update: func(vm *kv1.VirtualMachine, vmi *kv1.VirtualMachineInstance) {
+				vm.Spec.Template.Spec.Networks[0].Multus.NetworkName = "default/vlan2"
+				vm.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName = "pvc-1"
+			},
+			expected: []string{PendingChangeNetworks, PendingChangeVolumes},
+		},
+	}
+
+	for _, tc := range testCases {
+		vm := &kv1.VirtualMachine{
+			Spec: kv1.VirtualMachineSpec{
+				Template: &kv1.VirtualMachineInstanceTemplateSpec{Spec: newPendingChangesTestVMISpec()},
+			},
+		}
+		vmi := &kv1.VirtualMachineInstance{
+			Spec:   newPendingChangesTestVMISpec(),
+			Status: kv1.VirtualMachineInstanceStatus{Phase: kv1.Running},
+		}
+		tc.update(vm, vmi)
+		assert.Equal(t, tc.expected, GetPendingChanges(vm, vmi), "case %q", tc.name)
+	}
+
+	// a stopped VM has no pending changes
+	vm := &kv1.VirtualMachine{
+		Spec: kv1.VirtualMachineSpec{
+			Template: &kv1.VirtualMachineInstanceTemplateSpec{Spec: newPendingChangesTestVMISpec()},
+		},
+	}
+	vm.Spec.Template.Spec.Domain.CPU.Cores = 4
+	assert.Nil(t, GetPendingChanges(vm, nil))
+}
@@ -0,0 +1,140 @@
+package virtualmachine
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	vmv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+const (
+	eventReasonGracefulShutdown = "GracefulShutdown"
+	eventReasonForcedShutdown   = "ForcedShutdown"
+	eventReasonGracefulReboot   = "GracefulReboot"
+	eventReasonForcedReboot     = "ForcedReboot"
+	eventReasonShutdownTimeout  = "ShutdownTimeout"
+)
+
+// VMPowerOperationController follows the graceful shutdowns and soft reboots requested by the VM actions, forces
+// the VM off if the guest doesn't shut down in the timeout, and records the outcome as events of the VM.
+type VMPowerOperationController struct {
+	vmClient  vmv1.VirtualMachineController
+	vmiClient vmv1.VirtualMachineInstanceClient
+	vmiCache  vmv1.VirtualMachineInstanceCache
+	recorder  record.EventRecorder
+}
+
+func (h *VMPowerOperationController) SyncPowerOperation(_ string, vm *kv1.VirtualMachine) (*kv1.VirtualMachine, error) {
+	if vm == nil || vm.DeletionTimestamp != nil {
+		return vm, nil
+	}
+	op, err := util.GetPowerOperation(vm)
+	if err != nil {
+		logrus.Warnf("removing the invalid power operation of VM %s/%s: %v", vm.Namespace, vm.Name, err)
+		return h.updatePowerOperation(vm, nil)
+	}
+	if op == nil {
+		return vm, nil
+	}
+
+	vmi, err := h.vmiCache.Get(vm.Namespace, vm.Name)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return vm, fmt.Errorf("failed to get VirtualMachineInstance(%s/%s): %w", vm.Namespace, vm.Name, err)
+	}
+	if apierrors.IsNotFound(err) || vmi.UID != op.VMIUID {
+		vmi = nil
+	}
+
+	// the VMI the operation was requested on is still shutting down
+	if vmi != nil && !vmi.IsFinal() {
+		remaining := time.Until(op.Deadline())
+		if remaining > 0 {
+			h.vmClient.EnqueueAfter(vm.Namespace, vm.Name, remaining)
+			return vm, nil
+		}
+		if err := h.forceOff(vmi); err != nil {
+			return vm, err
+		}
+		if op.Escalated {
+			return vm, nil
+		}
+		h.recorder.Eventf(vm, corev1.EventTypeWarning, eventReasonShutdownTimeout,
+			"The guest didn't shut down in %d seconds, forcing it off", op.TimeoutSeconds)
+		op.Escalated = true
+		return h.updatePowerOperation(vm, op)
+	}
+
+	stoppedAt := time.Now()
+	if vmi != nil {
+		stoppedAt = getFinalTime(vmi, stoppedAt)
+	}
+	forced := op.Escalated || stoppedAt.After(op.Deadline())
+	elapsed := stoppedAt.Sub(op.RequestedAt.Time).Round(time.Second)
+	switch {
+	case op.Operation == util.PowerOperationSoftReboot && forced:
+		h.recorder.Eventf(vm, corev1.EventTypeWarning, eventReasonForcedReboot,
+			"The guest didn't shut down in %d seconds, it was forced off and started again", op.TimeoutSeconds)
+	case op.Operation == util.PowerOperationSoftReboot:
+		h.recorder.Eventf(vm, corev1.EventTypeNormal, eventReasonGracefulReboot,
+			"The guest shut down gracefully in %s and was started again", elapsed)
+	case forced:
+		h.recorder.Eventf(vm, corev1.EventTypeWarning, eventReasonForcedShutdown,
+			"The guest didn't shut down in %d seconds, it was forced off", op.TimeoutSeconds)
+	default:
+		h.recorder.Eventf(vm, corev1.EventTypeNormal, eventReasonGracefulShutdown,
+			"The guest shut down gracefully in %s", elapsed)
+	}
+	return h.updatePowerOperation(vm, nil)
+}
+
+// forceOff deletes the VMI at once, the VM starts again with a new VMI for a soft reboot
+// as the restart keeps it running, and stays stopped for a shutdown.
+func (h *VMPowerOperationController) forceOff(vmi *kv1.VirtualMachineInstance) error {
+	gracePeriod := int64(0)
+	err := h.vmiClient.Delete(vmi.Namespace, vmi.Name, &metav1.DeleteOptions{
+		GracePeriodSeconds: &gracePeriod,
+		Preconditions:      &metav1.Preconditions{UID: &vmi.UID},
+	})
+	if err != nil && !apierrors.IsNotFound(err) && !apierrors.IsConflict(err) {
+		return fmt.Errorf("failed to force off VirtualMachineInstance(%s/%s): %w", vmi.Namespace, vmi.Name, err)
+	}
+	return nil
+}
+
+// EnqueueVMByVMI enqueues the VirtualMachine when its VirtualMachineInstance changes or is removed,
+// so the power operation in progress is checked again.
+func (h *VMPowerOperationController) EnqueueVMByVMI(key string, vmi *kv1.VirtualMachineInstance) (*kv1.VirtualMachineInstance, error) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		return vmi, err
+	}
+	h.vmClient.Enqueue(namespace, name)
+	return vmi, nil
+}
+
+func (h *VMPowerOperationController) updatePowerOperation(vm *kv1.VirtualMachine, op *util.PowerOperation) (*kv1.VirtualMachine, error) {
+	vmCopy := vm.DeepCopy()
+	if err := util.SetPowerOperation(vmCopy, op); err != nil {
+		return vm, err
+	}
+	return h.vmClient.Update(vmCopy)
+}
+
+// getFinalTime returns the time the VMI turned into the final phase, or the fallback if it's unknown
+func getFinalTime(vmi *kv1.VirtualMachineInstance, fallback time.Time) time.Time {
+	for _, transition := range vmi.Status.PhaseTransitionTimestamps {
+		if transition.Phase == kv1.Succeeded || transition.Phase == kv1.Failed {
+			return transition.PhaseTransitionTimestamp.Time
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,93 @@
+package virtualmachine
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kv1 "kubevirt.io/client-go/api/v1"
+
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+func TestGetFinalTime(t *testing.T) {
+	var (
+		fallback  = time.Date(2021, 10, 1, 0, 10, 0, 0, time.UTC)
+		running   = time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+		succeeded = time.Date(2021, 10, 1, 0, 1, 0, 0, time.UTC)
+	)
+	var testCases = []struct {
+		name        string
+		transitions []kv1.VirtualMachineInstancePhaseTransitionTimestamp
+		expected    time.Time
+	}{
+		{
+			name:     "no transition",
+			expected: fallback,
+		},
+		{
+			name: "not final",
+			transitions: []kv1.VirtualMachineInstancePhaseTransitionTimestamp{
+				{Phase: kv1.Running, PhaseTransitionTimestamp: metav1.NewTime(running)},
+			},
+			expected: fallback,
+		},
+		{
+			name: "succeeded",
+			transitions: []kv1.VirtualMachineInstancePhaseTransitionTimestamp{
+				{Phase: kv1.Running, PhaseTransitionTimestamp: metav1.NewTime(running)},
+				{Phase: kv1.Succeeded, PhaseTransitionTimestamp: metav1.NewTime(succeeded)},
+			},
+			expected: succeeded,
+		},
+	}
+	for _, tc := range testCases {
+		vmi := &kv1.VirtualMachineInstance{Status: kv1.VirtualMachineInstanceStatus{PhaseTransitionTimestamps: tc.transitions}}
+		assert.Equal(t, tc.expected, getFinalTime(vmi, fallback), tc.name)
+	}
+}
+
+func TestPowerOperationAnnotation(t *testing.T) {
+	vm := &kv1.VirtualMachine{}
+	op, err := util.GetPowerOperation(vm)
+	assert.Nil(t, err)
+	assert.Nil(t, op)
+
+	requestedAt := metav1.NewTime(time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC))
+	assert.Nil(t, util.SetPowerOperation(vm, &util.PowerOperation{
+		Operation:      util.PowerOperationShutdown,
+		TimeoutSeconds: 60,
+		RequestedAt:    requestedAt,
+		VMIUID:         "vmi-uid",
+	}))
+	op, err = util.GetPowerOperation(vm)
+	assert.Nil(t, err)
+	assert.Equal(t, util.PowerOperationShutdown, op.Operation)
+	assert.True(t, requestedAt.Add(time.Minute).Equal(op.Deadline()))
+
+	assert.Nil(t, util.SetPowerOperation(vm, nil))
+	_, ok := vm.Annotations[util.AnnotationPowerOperation]
+	assert.False(t, ok)
+}
+
+func TestForceOff(t *testing.T) {
+	vmi := &kv1.VirtualMachineInstance{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "vm", UID: "fake-vmi-uid"},
+	}
+	clientset := fake.NewSimpleClientset(vmi)
+	ctrl := &VMPowerOperationController{
+		vmiClient: fakeclients.VirtualMachineInstanceClient(clientset.KubevirtV1().VirtualMachineInstances),
+	}
+
+	assert.Nil(t, ctrl.forceOff(vmi))
+	_, err := clientset.KubevirtV1().VirtualMachineInstances("default").Get(context.TODO(), "vm", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	// the VMI removed already is off
+	assert.Nil(t, ctrl.forceOff(vmi))
+}
@@ -20,6 +20,7 @@ func createCRDs(ctx context.Context, restConfig *rest.Config) error {
 	return factory.
 		BatchCreateCRDsIfNotExisted(
 			crd.NonNamespacedFromGV(harvesterv1.SchemeGroupVersion, "Setting", harvesterv1.Setting{}),
+			crd.NonNamespacedFromGV(harvesterv1.SchemeGroupVersion, "BackupTarget", harvesterv1.BackupTarget{}),
 			crd.NonNamespacedFromGV(rancherv3.SchemeGroupVersion, "APIService", rancherv3.APIService{}),
 			crd.NonNamespacedFromGV(rancherv3.SchemeGroupVersion, "Setting", rancherv3.Setting{}),
 			crd.NonNamespacedFromGV(rancherv3.SchemeGroupVersion, "User", rancherv3.User{}),
@@ -37,6 +38,8 @@ func createCRDs(ctx context.Context, restConfig *rest.Config) error {
 			crd.FromGV(harvesterv1.SchemeGroupVersion, "VirtualMachineTemplateVersion", harvesterv1.VirtualMachineTemplateVersion{}),
 			crd.FromGV(harvesterv1.SchemeGroupVersion, "VirtualMachineBackup", harvesterv1.VirtualMachineBackup{}),
 			crd.FromGV(harvesterv1.SchemeGroupVersion, "VirtualMachineRestore", harvesterv1.VirtualMachineRestore{}),
+			crd.FromGV(harvesterv1.SchemeGroupVersion, "VirtualMachineBackupSchedule", harvesterv1.VirtualMachineBackupSchedule{}),
+			crd.FromGV(harvesterv1.SchemeGroupVersion, "VirtualMachineSnapshot", harvesterv1.VirtualMachineSnapshot{}),
 			crd.FromGV(harvesterv1.SchemeGroupVersion, "Preference", harvesterv1.Preference{}),
 			crd.FromGV(harvesterv1.SchemeGroupVersion, "SupportBundle", harvesterv1.SupportBundle{}),
 			// The BackingImage struct is not compatible with wrangler schemas generation, pass nil as the workaround.
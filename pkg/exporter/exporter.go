@@ -0,0 +1,228 @@
+package exporter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/rancher/wrangler/pkg/name"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	"github.com/harvester/harvester/pkg/util/diskimage"
+)
+
+const (
+	// Port is the port the exporter serves the disk on
+	Port = 8080
+
+	DiskPath    = "/disk"
+	HealthzPath = "/healthz"
+
+	// TokenHeader is the header of the token the proxy passes to the exporter, the pod IP is reachable by any pod
+	TokenHeader = "X-Harvester-Exporter-Token"
+	// TokenEnv is the environment variable the exporter reads its token from
+	TokenEnv = "EXPORTER_TOKEN"
+	// TokenKey is the key of the token in the secret of the job
+	TokenKey = "token"
+
+	FormatParam      = "format"
+	CompressionParam = "compression"
+
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+
+	blockDevicePath = "/dev/export"
+	volumeMountPath = "/volume"
+	// volumeDiskFile is the disk file in a volume of the filesystem mode, as KubeVirt stores it
+	volumeDiskFile = "disk.img"
+	workDir        = "/work"
+
+	// the qcow2 metadata takes about 10 bytes per 64KiB cluster of the disk, the margin covers it and the header
+	qcow2MetadataRatio = 1024
+	qcow2MetadataSize  = 64 << 20
+)
+
+// Options are how the disk is downloaded
+type Options struct {
+	Format      string
+	Compression string
+}
+
+// ParseOptions parses the download options from the query of the request
+func ParseOptions(query url.Values) (Options, error) {
+	options := Options{
+		Format:      query.Get(FormatParam),
+		Compression: query.Get(CompressionParam),
+	}
+	switch options.Format {
+	case "":
+		options.Format = diskimage.FormatRaw
+	case diskimage.FormatRaw, diskimage.FormatQcow2:
+	default:
+		return options, fmt.Errorf("unsupported format %q, the format must be %s or %s", options.Format, diskimage.FormatRaw, diskimage.FormatQcow2)
+	}
+	switch options.Compression {
+	case CompressionNone, CompressionGzip:
+	default:
+		return options, fmt.Errorf("unsupported compression %q, the compression must be %s", options.Compression, CompressionGzip)
+	}
+	return options, nil
+}
+
+// Query returns the query to request the disk from the exporter
+func (o Options) Query() url.Values {
+	query := url.Values{}
+	query.Set(FormatParam, o.Format)
+	if o.Compression != CompressionNone {
+		query.Set(CompressionParam, o.Compression)
+	}
+	return query
+}
+
+// FileName returns the name of the downloaded file of the disk
+func (o Options) FileName(diskName string) string {
+	fileName := diskName + "." + o.Format
+	if o.Compression == CompressionGzip {
+		fileName += ".gz"
+	}
+	return fileName
+}
+
+// JobName returns the name of the job exporting the volume
+func JobName(pvcName string) string {
+	return name.SafeConcatName(pvcName, "exporter")
+}
+
+// NewToken returns a random token of an exporter job
+func NewToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// NewTokenSecret returns the secret of the token of the exporter job, it's removed with the job
+func NewTokenSecret(job *batchv1.Job, token string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      job.Name,
+			Namespace: job.Namespace,
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: "batch/v1",
+					Kind:       "Job",
+					Name:       job.Name,
+					UID:        job.UID,
+				},
+			},
+		},
+		StringData: map[string]string{
+			TokenKey: token,
+		},
+	}
+}
+
+// WorkDirSize returns the size of the work directory to convert a disk of the size to qcow2 in,
+// the converted disk is no larger than the disk plus the qcow2 metadata.
+func WorkDirSize(diskSize int64) int64 {
+	return diskSize + diskSize/qcow2MetadataRatio + qcow2MetadataSize
+}
+
+// NewJob returns the job exporting the volume. The exporter exits when it's idle, and the job is removed then.
+// The exporter only serves the requests with the token in the secret of the same name as the job, the pod waits for
+// the secret created after the job. The work directory is limited to the size to convert the disk of the size in.
+func NewJob(namespace, pvcName string, volumeMode corev1.PersistentVolumeMode, diskSize int64, image string, owner metav1.OwnerReference) *batchv1.Job {
+	workDirSize := WorkDirSize(diskSize)
+	container := corev1.Container{
+		Name:            "exporter",
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"harvester-exporter"},
+		Env: []corev1.EnvVar{
+			{
+				Name: TokenEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: JobName(pvcName)},
+						Key:                  TokenKey,
+					},
+				},
+			},
+		},
+		Ports: []corev1.ContainerPort{
+			{
+				Name:          "http",
+				ContainerPort: Port,
+			},
+		},
+		ReadinessProbe: &corev1.Probe{
+			Handler: corev1.Handler{
+				HTTPGet: &corev1.HTTPGetAction{
+					Path: HealthzPath,
+					Port: intstr.FromInt(Port),
+				},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: "work", MountPath: workDir},
+		},
+	}
+	if volumeMode == corev1.PersistentVolumeFilesystem {
+		container.Args = []string{"--device", volumeMountPath + "/" + volumeDiskFile}
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      "disk",
+			MountPath: volumeMountPath,
+			ReadOnly:  true,
+		})
+	} else {
+		container.Args = []string{"--device", blockDevicePath}
+		container.VolumeDevices = []corev1.VolumeDevice{
+			{Name: "disk", DevicePath: blockDevicePath},
+		}
+	}
+	container.Args = append(container.Args, "--work-dir", workDir, "--work-dir-size", strconv.FormatInt(workDirSize, 10))
+
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            JobName(pvcName),
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{owner},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            pointer.Int32Ptr(0),
+			TTLSecondsAfterFinished: pointer.Int32Ptr(0),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{container},
+					Volumes: []corev1.Volume{
+						{
+							Name: "disk",
+							VolumeSource: corev1.VolumeSource{
+								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+									ClaimName: pvcName,
+								},
+							},
+						},
+						{
+							Name: "work",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{
+									SizeLimit: resource.NewQuantity(workDirSize, resource.BinarySI),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,209 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/diskimage"
+)
+
+const sizeCheckInterval = time.Second
+
+// Server serves the disk of a volume, so the data can be downloaded out of the cluster. The disk is served
+// as is for the raw format with range requests supported, and is converted once in the work directory for qcow2.
+// Only the requests with the token are served, the token is passed by the Harvester proxy.
+type Server struct {
+	Device  string
+	WorkDir string
+	// WorkDirSize is the size limit of the converted disk in the work directory, 0 means no limit
+	WorkDirSize int64
+	IdleTimeout time.Duration
+	Token       string
+
+	mu         sync.Mutex
+	active     int
+	lastActive time.Time
+
+	convertLock sync.Mutex
+	converted   bool
+}
+
+// Run serves until the context is done or no disk is downloaded in the idle timeout
+func (s *Server) Run(ctx context.Context, addr string) error {
+	s.lastActive = time.Now()
+	server := &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		ticker := time.NewTicker(s.IdleTimeout / 10)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				_ = server.Shutdown(context.Background())
+				return
+			case <-ticker.C:
+				if s.idle() {
+					logrus.Infof("No download in %s, shutting down", s.IdleTimeout)
+					_ = server.Shutdown(context.Background())
+					return
+				}
+			}
+		}
+	}()
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(HealthzPath, func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc(DiskPath, s.serveDisk)
+	return mux
+}
+
+func (s *Server) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active == 0 && time.Since(s.lastActive) > s.IdleTimeout
+}
+
+func (s *Server) track(delta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.active += delta
+	s.lastActive = time.Now()
+}
+
+func (s *Server) serveDisk(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		util.ResponseErrorMsg(rw, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if s.Token == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(TokenHeader)), []byte(s.Token)) != 1 {
+		util.ResponseErrorMsg(rw, http.StatusUnauthorized, "invalid token")
+		return
+	}
+	s.track(1)
+	defer s.track(-1)
+
+	options, err := ParseOptions(r.URL.Query())
+	if err != nil {
+		util.ResponseError(rw, http.StatusBadRequest, err)
+		return
+	}
+
+	path := s.Device
+	if options.Format == diskimage.FormatQcow2 {
+		if path, err = s.convert(r.Context()); err != nil {
+			util.ResponseError(rw, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		util.ResponseError(rw, http.StatusInternalServerError, err)
+		return
+	}
+	defer file.Close()
+
+	if options.Compression == CompressionGzip {
+		// the size of the compressed disk is unknown until it's sent, so ranges can't be served
+		rw.Header().Set("Content-Type", "application/gzip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		gz := gzip.NewWriter(rw)
+		if _, err := io.Copy(gz, file); err != nil {
+			logrus.Errorf("Failed to send the compressed disk: %v", err)
+			return
+		}
+		if err := gz.Close(); err != nil {
+			logrus.Errorf("Failed to send the compressed disk: %v", err)
+		}
+		return
+	}
+
+	// the size of a block device is known by seeking to the end, which ServeContent does
+	rw.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(rw, r, "", time.Time{}, file)
+}
+
+// convert converts the disk to qcow2 in the work directory once, the downloads of qcow2 wait for it
+func (s *Server) convert(ctx context.Context) (string, error) {
+	s.convertLock.Lock()
+	defer s.convertLock.Unlock()
+
+	path := filepath.Join(s.WorkDir, "disk."+diskimage.FormatQcow2)
+	if s.converted {
+		return path, nil
+	}
+
+	partPath := path + ".part"
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var exceeded int32
+	if s.WorkDirSize > 0 {
+		go watchFileSize(ctx, partPath, s.WorkDirSize, sizeCheckInterval, func() {
+			atomic.StoreInt32(&exceeded, 1)
+			cancel()
+		})
+	}
+
+	logrus.Infof("Converting %s to qcow2", s.Device)
+	err := diskimage.Convert(ctx, diskimage.FormatRaw, s.Device, partPath, func(progress int) {
+		logrus.Debugf("Converting %s to qcow2: %d%%", s.Device, progress)
+	})
+	if err != nil {
+		os.Remove(partPath)
+		if atomic.LoadInt32(&exceeded) == 1 {
+			return "", fmt.Errorf("the converted disk exceeds the size limit %d of the work directory", s.WorkDirSize)
+		}
+		return "", err
+	}
+	if err := os.Rename(partPath, path); err != nil {
+		return "", fmt.Errorf("failed to move the converted disk: %w", err)
+	}
+	s.converted = true
+	return path, nil
+}
+
+// watchFileSize calls onExceeded once the file grows larger than the limit, until the context is done
+func watchFileSize(ctx context.Context, path string, limit int64, interval time.Duration, onExceeded func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if info, err := os.Stat(path); err == nil && info.Size() > limit {
+				logrus.Errorf("%s is larger than the size limit %d", path, limit)
+				onExceeded()
+				return
+			}
+		}
+	}
+}
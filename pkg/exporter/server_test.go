@@ -0,0 +1,155 @@
+package exporter
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	testDisk  = "0123456789abcdefghij"
+	testToken = "token"
+)
+
+func newTestServer(t *testing.T) *Server {
+	dir, err := ioutil.TempDir("", "exporter")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	device := filepath.Join(dir, "disk.img")
+	assert.NoError(t, ioutil.WriteFile(device, []byte(testDisk), 0644))
+	return &Server{
+		Device:      device,
+		WorkDir:     dir,
+		IdleTimeout: time.Minute,
+		Token:       testToken,
+	}
+}
+
+func getDisk(s *Server, query string, header http.Header) *http.Response {
+	req := httptest.NewRequest(http.MethodGet, DiskPath+"?"+query, nil)
+	req.Header.Set(TokenHeader, testToken)
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+	rw := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rw, req)
+	return rw.Result()
+}
+
+func TestServeRawDisk(t *testing.T) {
+	s := newTestServer(t)
+
+	resp := getDisk(s, "", nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "bytes", resp.Header.Get("Accept-Ranges"))
+	assert.Equal(t, testDisk, string(body))
+
+	resp = getDisk(s, "format=raw", http.Header{"Range": []string{"bytes=10-14"}})
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "bytes 10-14/20", resp.Header.Get("Content-Range"))
+	assert.Equal(t, "abcde", string(body))
+	assert.False(t, s.idle())
+}
+
+func TestServeCompressedDisk(t *testing.T) {
+	s := newTestServer(t)
+
+	// the range is ignored as the compressed size is unknown
+	resp := getDisk(s, "compression=gzip", http.Header{"Range": []string{"bytes=10-14"}})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/gzip", resp.Header.Get("Content-Type"))
+	gz, err := gzip.NewReader(resp.Body)
+	assert.NoError(t, err)
+	body, err := ioutil.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, testDisk, string(body))
+}
+
+func TestServeDiskInvalidOptions(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusBadRequest, getDisk(s, "format=vmdk", nil).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, getDisk(s, "compression=zip", nil).StatusCode)
+}
+
+func TestServeDiskInvalidToken(t *testing.T) {
+	s := newTestServer(t)
+
+	assert.Equal(t, http.StatusUnauthorized, getDisk(s, "", http.Header{TokenHeader: []string{"other"}}).StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, getDisk(s, "", http.Header{TokenHeader: []string{""}}).StatusCode)
+	s.Token = ""
+	assert.Equal(t, http.StatusUnauthorized, getDisk(s, "", nil).StatusCode)
+}
+
+func TestOptions(t *testing.T) {
+	options, err := ParseOptions(url.Values{})
+	assert.NoError(t, err)
+	assert.Equal(t, Options{Format: "raw"}, options)
+	assert.Equal(t, "disk-0.raw", options.FileName("disk-0"))
+	assert.Equal(t, "format=raw", options.Query().Encode())
+
+	options, err = ParseOptions(url.Values{FormatParam: {"qcow2"}, CompressionParam: {"gzip"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "disk-0.qcow2.gz", options.FileName("disk-0"))
+	assert.Equal(t, "compression=gzip&format=qcow2", options.Query().Encode())
+}
+
+func TestWatchFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "exporter")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "disk.qcow2.part")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	exceeded := make(chan struct{})
+	go watchFileSize(ctx, path, int64(len(testDisk)), time.Millisecond, func() { close(exceeded) })
+
+	// a missing file or one within the limit isn't reported
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testDisk), 0644))
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case <-exceeded:
+		t.Fatal("the file within the limit is reported")
+	default:
+	}
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(testDisk+testDisk), 0644))
+	select {
+	case <-exceeded:
+	case <-time.After(time.Second):
+		t.Fatal("the file exceeding the limit isn't reported")
+	}
+}
+
+func TestNewJobWorkDirSize(t *testing.T) {
+	const diskSize = 10 << 30
+	job := NewJob("default", "disk-0", corev1.PersistentVolumeBlock, diskSize, "exporter", metav1.OwnerReference{})
+	workDirSize := WorkDirSize(diskSize)
+	assert.True(t, workDirSize > diskSize)
+
+	var workVolume *corev1.Volume
+	for i, volume := range job.Spec.Template.Spec.Volumes {
+		if volume.Name == "work" {
+			workVolume = &job.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	assert.NotNil(t, workVolume)
+	assert.Equal(t, workDirSize, workVolume.EmptyDir.SizeLimit.Value())
+	assert.Contains(t, job.Spec.Template.Spec.Containers[0].Args, "--work-dir-size")
+}
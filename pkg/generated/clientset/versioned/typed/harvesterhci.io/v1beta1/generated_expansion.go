@@ -18,6 +18,8 @@ limitations under the License.
 
 package v1beta1
 
+type BackupTargetExpansion interface{}
+
 type KeyPairExpansion interface{}
 
 type PreferenceExpansion interface{}
@@ -32,10 +34,14 @@ type VersionExpansion interface{}
 
 type VirtualMachineBackupExpansion interface{}
 
+type VirtualMachineBackupScheduleExpansion interface{}
+
 type VirtualMachineImageExpansion interface{}
 
 type VirtualMachineRestoreExpansion interface{}
 
+type VirtualMachineSnapshotExpansion interface{}
+
 type VirtualMachineTemplateExpansion interface{}
 
 type VirtualMachineTemplateVersionExpansion interface{}
@@ -26,6 +26,7 @@ import (
 
 type HarvesterhciV1beta1Interface interface {
 	RESTClient() rest.Interface
+	BackupTargetsGetter
 	KeyPairsGetter
 	PreferencesGetter
 	SettingsGetter
@@ -33,8 +34,10 @@ type HarvesterhciV1beta1Interface interface {
 	UpgradesGetter
 	VersionsGetter
 	VirtualMachineBackupsGetter
+	VirtualMachineBackupSchedulesGetter
 	VirtualMachineImagesGetter
 	VirtualMachineRestoresGetter
+	VirtualMachineSnapshotsGetter
 	VirtualMachineTemplatesGetter
 	VirtualMachineTemplateVersionsGetter
 }
@@ -44,6 +47,10 @@ type HarvesterhciV1beta1Client struct {
 	restClient rest.Interface
 }
 
+func (c *HarvesterhciV1beta1Client) BackupTargets() BackupTargetInterface {
+	return newBackupTargets(c)
+}
+
 func (c *HarvesterhciV1beta1Client) KeyPairs(namespace string) KeyPairInterface {
 	return newKeyPairs(c, namespace)
 }
@@ -72,6 +79,10 @@ func (c *HarvesterhciV1beta1Client) VirtualMachineBackups(namespace string) Virt
 	return newVirtualMachineBackups(c, namespace)
 }
 
+func (c *HarvesterhciV1beta1Client) VirtualMachineBackupSchedules(namespace string) VirtualMachineBackupScheduleInterface {
+	return newVirtualMachineBackupSchedules(c, namespace)
+}
+
 func (c *HarvesterhciV1beta1Client) VirtualMachineImages(namespace string) VirtualMachineImageInterface {
 	return newVirtualMachineImages(c, namespace)
 }
@@ -80,6 +91,10 @@ func (c *HarvesterhciV1beta1Client) VirtualMachineRestores(namespace string) Vir
 	return newVirtualMachineRestores(c, namespace)
 }
 
+func (c *HarvesterhciV1beta1Client) VirtualMachineSnapshots(namespace string) VirtualMachineSnapshotInterface {
+	return newVirtualMachineSnapshots(c, namespace)
+}
+
 func (c *HarvesterhciV1beta1Client) VirtualMachineTemplates(namespace string) VirtualMachineTemplateInterface {
 	return newVirtualMachineTemplates(c, namespace)
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/server/router"
 	"github.com/sirupsen/logrus"
 	"k8s.io/client-go/rest"
@@ -13,21 +14,28 @@ import (
 	"github.com/harvester/harvester/pkg/api/kubeconfig"
 	"github.com/harvester/harvester/pkg/api/proxy"
 	"github.com/harvester/harvester/pkg/api/supportbundle"
+	"github.com/harvester/harvester/pkg/api/vm"
 	"github.com/harvester/harvester/pkg/config"
 	"github.com/harvester/harvester/pkg/server/ui"
 )
 
 type Router struct {
-	scaled     *config.Scaled
-	restConfig *rest.Config
-	options    config.Options
+	scaled         *config.Scaled
+	restConfig     *rest.Config
+	options        config.Options
+	consoleHandler *vm.ConsoleHandler
 }
 
-func NewRouter(scaled *config.Scaled, restConfig *rest.Config, options config.Options) (*Router, error) {
+func NewRouter(scaled *config.Scaled, restConfig *rest.Config, asl accesscontrol.AccessSetLookup, options config.Options) (*Router, error) {
+	consoleHandler, err := vm.NewConsoleHandler(scaled, restConfig, asl, options.RancherURL)
+	if err != nil {
+		return nil, err
+	}
 	return &Router{
-		scaled:     scaled,
-		restConfig: restConfig,
-		options:    options,
+		scaled:         scaled,
+		restConfig:     restConfig,
+		options:        options,
+		consoleHandler: consoleHandler,
 	}, nil
 }
 
@@ -48,6 +56,8 @@ func (r *Router) Routes(h router.Handlers) http.Handler {
 
 	sbDownloadHandler := supportbundle.NewDownloadHandler(r.scaled, r.options.Namespace)
 	m.Path("/v1/harvester/supportbundles/{bundleName}/download").Methods("GET").Handler(sbDownloadHandler)
+
+	m.Path("/v1/harvester/virtualmachines/{namespace}/{name}/{subresource:console|vnc}").Methods("GET").Handler(r.consoleHandler)
 	// --- END of preposition routes ---
 
 	// adds collection action support
@@ -212,7 +212,7 @@ func (s *HarvesterServer) generateSteveServer(options config.Options) error {
 		return err
 	}
 
-	router, err := NewRouter(scaled, s.RESTConfig, options)
+	router, err := NewRouter(scaled, s.RESTConfig, s.ASL, options)
 	if err != nil {
 		return err
 	}
@@ -27,6 +27,7 @@ var (
 	UIPath                  = NewSetting("ui-path", "/usr/share/harvester/harvester")
 	UISource                = NewSetting("ui-source", "auto") // Options are 'auto', 'external' or 'bundled'
 	VolumeSnapshotClass     = NewSetting(VolumeSnapshotClassSettingName, "longhorn")
+	VMSnapshotClass         = NewSetting(VMSnapshotClassSettingName, "")
 	BackupTargetSet         = NewSetting(BackupTargetSettingName, InitBackupTargetToString())
 	UpgradableVersions      = NewSetting("upgradable-versions", "")
 	UpgradeCheckerEnabled   = NewSetting("upgrade-checker-enabled", "true")
@@ -38,26 +39,37 @@ var (
 	SupportBundleNamespaces = NewSetting("support-bundle-namespaces", "")
 	SupportBundleTimeout    = NewSetting(SupportBundleTimeoutSettingName, "10") // Unit is minute. 0 means disable timeout.
 	DefaultStorageClass     = NewSetting("default-storage-class", "longhorn")
+	BackupFreezeTimeout     = NewSetting(BackupFreezeTimeoutSettingName, "60")  // Unit is second. 0 means disable freezing the guest filesystems.
+	BackupVerifyInterval    = NewSetting(BackupVerifyIntervalSettingName, "24") // Unit is hour. 0 means disable periodic verification.
 	HTTPProxy               = NewSetting(HttpProxySettingName, "{}")
 	VMForceResetPolicySet   = NewSetting(VMForceResetPolicySettingName, InitVMForceResetPolicy())
 	OvercommitConfig        = NewSetting(OvercommitConfigSettingName, `{"cpu":1600,"memory":150,"storage":200}`)
 	VipPools                = NewSetting(VipPoolsConfigSettingName, "")
 	AutoDiskProvisionPaths  = NewSetting("auto-disk-provision-paths", "")
+	MaintenanceConcurrency  = NewSetting(MaintenanceConcurrencySettingName, "2") // Number of VMs migrated off a node in maintenance mode at the same time.
+	VMRebalanceConfigSet    = NewSetting(VMRebalanceConfigSettingName, InitVMRebalanceConfig())
+	ImageGCConfigSet        = NewSetting(ImageGCConfigSettingName, InitImageGCConfig())
 )
 
 const (
-	AdditionalCASettingName         = "additional-ca"
-	BackupTargetSettingName         = "backup-target"
-	VMForceResetPolicySettingName   = "vm-force-reset-policy"
-	SupportBundleTimeoutSettingName = "support-bundle-timeout"
-	HttpProxySettingName            = "http-proxy"
-	OvercommitConfigSettingName     = "overcommit-config"
-	SSLCertificatesSettingName      = "ssl-certificates"
-	SSLParametersName               = "ssl-parameters"
-	VipPoolsConfigSettingName       = "vip-pools"
-	VolumeSnapshotClassSettingName  = "volume-snapshot-class"
-	DefaultDashboardUIURL           = "https://releases.rancher.com/harvester-ui/dashboard/latest/index.html"
-	SupportBundleImageName          = "support-bundle-image"
+	AdditionalCASettingName           = "additional-ca"
+	BackupTargetSettingName           = "backup-target"
+	VMForceResetPolicySettingName     = "vm-force-reset-policy"
+	VMRebalanceConfigSettingName      = "vm-rebalance-config"
+	ImageGCConfigSettingName          = "image-gc-config"
+	SupportBundleTimeoutSettingName   = "support-bundle-timeout"
+	BackupFreezeTimeoutSettingName    = "backup-freeze-timeout"
+	BackupVerifyIntervalSettingName   = "backup-verify-interval"
+	HttpProxySettingName              = "http-proxy"
+	MaintenanceConcurrencySettingName = "maintenance-migration-concurrency"
+	OvercommitConfigSettingName       = "overcommit-config"
+	SSLCertificatesSettingName        = "ssl-certificates"
+	SSLParametersName                 = "ssl-parameters"
+	VipPoolsConfigSettingName         = "vip-pools"
+	VolumeSnapshotClassSettingName    = "volume-snapshot-class"
+	VMSnapshotClassSettingName        = "vm-snapshot-class"
+	DefaultDashboardUIURL             = "https://releases.rancher.com/harvester-ui/dashboard/latest/index.html"
+	SupportBundleImageName            = "support-bundle-image"
 )
 
 func init() {
@@ -174,12 +186,17 @@ type BackupTarget struct {
 	BucketRegion       string     `json:"bucketRegion"`
 	Cert               string     `json:"cert"`
 	VirtualHostedStyle bool       `json:"virtualHostedStyle"`
+	// EncryptionKeySecret references the secret which holds the key to encrypt the VM backup metadata
+	// in the backup target, the metadata is stored in plain text if it's not set.
+	EncryptionKeySecret *corev1.SecretReference `json:"encryptionKeySecret,omitempty"`
 }
 
 type VMForceResetPolicy struct {
 	Enable bool `json:"enable"`
 	// Period means how many seconds to wait for a node get back.
 	Period int64 `json:"period"`
+	// RequireFencing means VMs with RWO volumes are only reset after the node is confirmed to be fenced.
+	RequireFencing bool `json:"requireFencing"`
 }
 
 func InitBackupTargetToString() string {
@@ -230,6 +247,76 @@ func DecodeVMForceResetPolicy(value string) (*VMForceResetPolicy, error) {
 	return policy, nil
 }
 
+type VMRebalanceConfig struct {
+	Enable bool `json:"enable"`
+	// Threshold is the difference in percentage of the requested CPU or memory between the most and
+	// the least loaded nodes, above which VMs are migrated.
+	Threshold int `json:"threshold"`
+	// Interval means how many seconds to wait between two rounds of rebalancing.
+	Interval int64 `json:"interval"`
+	// MaxConcurrentMigrations is the max number of migrations issued by the rebalancer at the same time.
+	MaxConcurrentMigrations int `json:"maxConcurrentMigrations"`
+}
+
+func InitVMRebalanceConfig() string {
+	config := &VMRebalanceConfig{
+		Enable:                  false,
+		Threshold:               20,
+		Interval:                5 * 60, // 5 minutes
+		MaxConcurrentMigrations: 1,
+	}
+	configStr, err := json.Marshal(config)
+	if err != nil {
+		logrus.Errorf("failed to init %s, error: %s", VMRebalanceConfigSettingName, err.Error())
+	}
+	return string(configStr)
+}
+
+func DecodeVMRebalanceConfig(value string) (*VMRebalanceConfig, error) {
+	config := &VMRebalanceConfig{}
+	if err := json.Unmarshal([]byte(value), config); err != nil {
+		return nil, fmt.Errorf("unmarshal failed, error: %w, value: %s", err, value)
+	}
+
+	return config, nil
+}
+
+const (
+	ImageGCActionFlag   = "flag"
+	ImageGCActionDelete = "delete"
+)
+
+type ImageGCConfig struct {
+	Enable bool `json:"enable"`
+	// UnusedDays is how many days an image stays unused before it's collected.
+	UnusedDays int `json:"unusedDays"`
+	// Action is how the collected images are handled, they are labelled unused with "flag" or removed with "delete".
+	// The images referenced by template versions are never collected.
+	Action string `json:"action"`
+}
+
+func InitImageGCConfig() string {
+	config := &ImageGCConfig{
+		Enable:     false,
+		UnusedDays: 30,
+		Action:     ImageGCActionFlag,
+	}
+	configStr, err := json.Marshal(config)
+	if err != nil {
+		logrus.Errorf("failed to init %s, error: %s", ImageGCConfigSettingName, err.Error())
+	}
+	return string(configStr)
+}
+
+func DecodeImageGCConfig(value string) (*ImageGCConfig, error) {
+	config := &ImageGCConfig{}
+	if err := json.Unmarshal([]byte(value), config); err != nil {
+		return nil, fmt.Errorf("unmarshal failed, error: %w, value: %s", err, value)
+	}
+
+	return config, nil
+}
+
 type Overcommit struct {
 	Cpu     int `json:"cpu"`
 	Memory  int `json:"memory"`
@@ -10,8 +10,15 @@ const (
 	AnnotationMigrationState       = prefix + "/migrationState"
 	AnnotationTimestamp            = prefix + "/timestamp"
 	AnnotationVolumeClaimTemplates = prefix + "/volumeClaimTemplates"
+	AnnotationCloneTarget          = prefix + "/cloneTarget"
+	AnnotationCloneTargetUID       = prefix + "/cloneTargetUID"
 	AnnotationImageID              = prefix + "/imageId"
 	AnnotationHash                 = prefix + "/hash"
+	AnnotationPowerOperation       = prefix + "/powerOperation"
+	AnnotationPendingChanges       = prefix + "/pendingChanges"
+	AnnotationUploadSession        = prefix + "/uploadSession"
+	AnnotationImageUnusedSince     = prefix + "/unusedSince"
+	LabelImageUnused               = prefix + "/unused"
 
 	BackupTargetSecretName      = "harvester-backup-target-secret"
 	InternalTLSSecretName       = "tls-rancher-internal"
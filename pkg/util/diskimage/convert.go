@@ -0,0 +1,162 @@
+package diskimage
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	qemuImgProgressRegexp = regexp.MustCompile(`\((\d+(?:\.\d+)?)/100%\)`)
+
+	ovaDiskExtensions = []string{".vmdk", ".img", ".raw", ".qcow2", ".vhd", ".vhdx"}
+)
+
+// DetectFileFormat detects the format of the disk file, the name is the original file name
+func DetectFileFormat(file, name string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return DetectFormat(header[:n], name), nil
+}
+
+// Decompress decompresses gzip by the standard library, and xz and zstd by their commands
+func Decompress(ctx context.Context, format, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if format == FormatGzip {
+		reader, err := gzip.NewReader(in)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		if _, err := io.Copy(out, reader); err != nil {
+			return fmt.Errorf("failed to decompress the image: %w", err)
+		}
+		return out.Close()
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, format, "--decompress", "--stdout")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = in, out, &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to decompress the image: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Close()
+}
+
+// ExtractOVADisk extracts the first disk of the OVA, the other disks of a multi-disk VM are skipped
+func ExtractOVADisk(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	reader := tar.NewReader(in)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return "", fmt.Errorf("no disk is found in the OVA")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read the OVA: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !isOVADisk(header.Name) {
+			continue
+		}
+		out, err := os.Create(dst)
+		if err != nil {
+			return "", err
+		}
+		defer out.Close()
+		if _, err := io.Copy(out, reader); err != nil {
+			return "", fmt.Errorf("failed to extract %s from the OVA: %w", header.Name, err)
+		}
+		return header.Name, out.Close()
+	}
+}
+
+func isOVADisk(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, diskExt := range ovaDiskExtensions {
+		if ext == diskExt {
+			return true
+		}
+	}
+	return false
+}
+
+// Convert converts the disk to qcow2, and reports the progress qemu-img prints
+func Convert(ctx context.Context, format, src, dst string, onProgress func(int)) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "qemu-img", "convert", "-p", "-f", format, "-O", FormatQcow2, src, dst)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start qemu-img: %w", err)
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Split(scanProgressLines)
+	for scanner.Scan() {
+		if progress, ok := parseQemuImgProgress(scanner.Text()); ok {
+			onProgress(progress)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to convert the image from %s: %v: %s", format, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// scanProgressLines splits the output by carriage returns as well, as qemu-img rewrites the progress in place
+func scanProgressLines(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func parseQemuImgProgress(line string) (int, bool) {
+	matches := qemuImgProgressRegexp.FindStringSubmatch(line)
+	if len(matches) != 2 {
+		return 0, false
+	}
+	progress, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(progress), true
+}
@@ -0,0 +1,71 @@
+package diskimage
+
+import (
+	"bytes"
+	"path"
+	"strings"
+)
+
+// The disk formats by the names of qemu-img
+const (
+	FormatRaw   = "raw"
+	FormatQcow2 = "qcow2"
+	FormatVMDK  = "vmdk"
+	FormatVHD   = "vpc"
+	FormatVHDX  = "vhdx"
+
+	// FormatOVA is a tar archive with the OVF descriptor and the disks
+	FormatOVA = "ova"
+
+	FormatGzip = "gzip"
+	FormatXz   = "xz"
+	FormatZstd = "zstd"
+
+	// FormatVMDKDescriptor is the text descriptor of a VMDK, the data is in the separate extent files
+	FormatVMDKDescriptor = "vmdk-descriptor"
+
+	// HeaderSize is the length of the header to detect the formats
+	HeaderSize = 512
+)
+
+var formatMagics = []struct {
+	format string
+	offset int
+	magic  []byte
+}{
+	{format: FormatQcow2, magic: []byte("QFI\xfb")},
+	{format: FormatVMDK, magic: []byte("KDMV")},
+	{format: FormatVMDKDescriptor, magic: []byte("# Disk DescriptorFile")},
+	{format: FormatVHDX, magic: []byte("vhdxfile")},
+	// dynamic VHDs have a copy of the footer at the beginning
+	{format: FormatVHD, magic: []byte("conectix")},
+	{format: FormatGzip, magic: []byte{0x1f, 0x8b}},
+	{format: FormatXz, magic: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}},
+	{format: FormatZstd, magic: []byte{0x28, 0xb5, 0x2f, 0xfd}},
+	{format: FormatOVA, offset: 257, magic: []byte("ustar")},
+}
+
+// DetectFormat detects the format of a disk by the magic in its header. The fixed VHDs only have a footer,
+// they are told by the file extension.
+func DetectFormat(header []byte, name string) string {
+	for _, m := range formatMagics {
+		if len(header) >= m.offset+len(m.magic) && bytes.Equal(header[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.format
+		}
+	}
+	if strings.EqualFold(path.Ext(name), ".vhd") {
+		return FormatVHD
+	}
+	return FormatRaw
+}
+
+// NeedConversion tells whether a disk of the format has to be converted before Longhorn imports it,
+// as Longhorn backing images only accept raw and qcow2 disks
+func NeedConversion(format string) bool {
+	return format != FormatRaw && format != FormatQcow2
+}
+
+// IsCompressed tells whether the format is a compression of another disk
+func IsCompressed(format string) bool {
+	return format == FormatGzip || format == FormatXz || format == FormatZstd
+}
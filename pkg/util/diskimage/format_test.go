@@ -0,0 +1,112 @@
+package diskimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tarHeader := make([]byte, HeaderSize)
+	copy(tarHeader[257:], "ustar")
+
+	var testCases = []struct {
+		name     string
+		header   []byte
+		fileName string
+		expected string
+	}{
+		{name: "qcow2", header: []byte("QFI\xfb\x00\x00\x00\x03"), fileName: "ubuntu.img", expected: FormatQcow2},
+		{name: "sparse vmdk", header: []byte("KDMV\x01\x00\x00\x00"), fileName: "disk.vmdk", expected: FormatVMDK},
+		{name: "vmdk descriptor", header: []byte("# Disk DescriptorFile\nversion=1"), fileName: "disk.vmdk", expected: FormatVMDKDescriptor},
+		{name: "vhdx", header: []byte("vhdxfile\x00"), fileName: "disk.vhdx", expected: FormatVHDX},
+		{name: "dynamic vhd", header: []byte("conectix\x00"), fileName: "disk", expected: FormatVHD},
+		{name: "fixed vhd", header: []byte("\xeb\x63\x90"), fileName: "disk.VHD", expected: FormatVHD},
+		{name: "gzip", header: []byte{0x1f, 0x8b, 0x08}, fileName: "disk.qcow2.gz", expected: FormatGzip},
+		{name: "xz", header: []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}, fileName: "disk.qcow2.xz", expected: FormatXz},
+		{name: "zstd", header: []byte{0x28, 0xb5, 0x2f, 0xfd}, fileName: "disk.qcow2.zst", expected: FormatZstd},
+		{name: "ova", header: tarHeader, fileName: "template.ova", expected: FormatOVA},
+		{name: "raw", header: []byte("\xeb\x63\x90"), fileName: "disk.img", expected: FormatRaw},
+		{name: "empty", header: nil, fileName: "", expected: FormatRaw},
+	}
+
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, DetectFormat(tc.header, tc.fileName), tc.name)
+	}
+}
+
+func TestParseQemuImgProgress(t *testing.T) {
+	progress, ok := parseQemuImgProgress("    (45.67/100%)")
+	assert.True(t, ok)
+	assert.Equal(t, 45, progress)
+
+	_, ok = parseQemuImgProgress("qemu-img: Could not open 'disk'")
+	assert.False(t, ok)
+}
+
+func TestExtractOVADisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ova")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ova := filepath.Join(dir, "template.ova")
+	f, err := os.Create(ova)
+	assert.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for name, content := range map[string]string{
+		"template.ovf": "<Envelope/>",
+		"template.mf":  "SHA256(template.ovf)= 00",
+	} {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err = tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	disk := "KDMV disk"
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "template-disk1.vmdk", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(disk))}))
+	_, err = tw.Write([]byte(disk))
+	assert.NoError(t, err)
+	assert.NoError(t, tw.Close())
+	assert.NoError(t, f.Close())
+
+	extracted := filepath.Join(dir, "disk")
+	name, err := ExtractOVADisk(ova, extracted)
+	assert.NoError(t, err)
+	assert.Equal(t, "template-disk1.vmdk", name)
+	content, err := ioutil.ReadFile(extracted)
+	assert.NoError(t, err)
+	assert.Equal(t, disk, string(content))
+	format, err := DetectFileFormat(extracted, name)
+	assert.NoError(t, err)
+	assert.Equal(t, FormatVMDK, format)
+}
+
+func TestDecompressGzip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gzip")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err = gw.Write([]byte("QFI\xfb disk"))
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+	compressed := filepath.Join(dir, "disk.qcow2.gz")
+	assert.NoError(t, ioutil.WriteFile(compressed, buf.Bytes(), 0600))
+
+	format, err := DetectFileFormat(compressed, "disk.qcow2.gz")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatGzip, format)
+
+	decompressed := filepath.Join(dir, "disk.qcow2")
+	assert.NoError(t, Decompress(context.Background(), format, compressed, decompressed))
+	format, err = DetectFileFormat(decompressed, "disk.qcow2")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatQcow2, format)
+}
@@ -0,0 +1,38 @@
+package fakeclients
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	harvestertype "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1"
+	harvesterv1ctl "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+)
+
+type BackupTargetCache func() harvestertype.BackupTargetInterface
+
+func (c BackupTargetCache) Get(name string) (*v1beta1.BackupTarget, error) {
+	return c().Get(context.TODO(), name, metav1.GetOptions{})
+}
+
+func (c BackupTargetCache) List(selector labels.Selector) ([]*v1beta1.BackupTarget, error) {
+	list, err := c().List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*v1beta1.BackupTarget, 0, len(list.Items))
+	for i := range list.Items {
+		result = append(result, &list.Items[i])
+	}
+	return result, nil
+}
+
+func (c BackupTargetCache) AddIndexer(indexName string, indexer harvesterv1ctl.BackupTargetIndexer) {
+	panic("implement me")
+}
+
+func (c BackupTargetCache) GetByIndex(indexName, key string) ([]*v1beta1.BackupTarget, error) {
+	panic("implement me")
+}
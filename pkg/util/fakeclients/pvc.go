@@ -56,7 +56,15 @@ func (c PersistentVolumeClaimCache) Get(namespace, name string) (*corev1.Persist
 }
 
 func (c PersistentVolumeClaimCache) List(namespace string, selector labels.Selector) ([]*corev1.PersistentVolumeClaim, error) {
-	panic("implement me")
+	list, err := c(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*corev1.PersistentVolumeClaim, 0, len(list.Items))
+	for i := range list.Items {
+		result = append(result, &list.Items[i])
+	}
+	return result, err
 }
 
 func (c PersistentVolumeClaimCache) AddIndexer(indexName string, indexer ctlv1.PersistentVolumeClaimIndexer) {
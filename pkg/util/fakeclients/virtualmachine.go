@@ -4,11 +4,13 @@ import (
 	"context"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/watch"
 	kubevirtv1api "kubevirt.io/client-go/api/v1"
 
 	kubevirtv1 "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/kubevirt.io/v1"
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
 )
 
 type VirtualMachineClient func(string) kubevirtv1.VirtualMachineInterface
@@ -37,3 +39,26 @@ func (c VirtualMachineClient) Watch(namespace string, opts metav1.ListOptions) (
 func (c VirtualMachineClient) Patch(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (result *kubevirtv1api.VirtualMachine, err error) {
 	panic("implement me")
 }
+
+type VirtualMachineCache func(string) kubevirtv1.VirtualMachineInterface
+
+func (c VirtualMachineCache) Get(namespace, name string) (*kubevirtv1api.VirtualMachine, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VirtualMachineCache) List(namespace string, selector labels.Selector) ([]*kubevirtv1api.VirtualMachine, error) {
+	list, err := c(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*kubevirtv1api.VirtualMachine, 0, len(list.Items))
+	for i := range list.Items {
+		result = append(result, &list.Items[i])
+	}
+	return result, err
+}
+func (c VirtualMachineCache) AddIndexer(indexName string, indexer ctlkubevirtv1.VirtualMachineIndexer) {
+	panic("implement me")
+}
+func (c VirtualMachineCache) GetByIndex(indexName, key string) ([]*kubevirtv1api.VirtualMachine, error) {
+	panic("implement me")
+}
@@ -0,0 +1,69 @@
+package fakeclients
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	harv1type "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/tests/framework/fuzz"
+)
+
+type VirtualMachineRestoreClient func(string) harv1type.VirtualMachineRestoreInterface
+
+func (c VirtualMachineRestoreClient) Update(virtualMachineRestore *harvesterv1.VirtualMachineRestore) (*harvesterv1.VirtualMachineRestore, error) {
+	return c(virtualMachineRestore.Namespace).Update(context.TODO(), virtualMachineRestore, metav1.UpdateOptions{})
+}
+func (c VirtualMachineRestoreClient) Get(namespace, name string, options metav1.GetOptions) (*harvesterv1.VirtualMachineRestore, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VirtualMachineRestoreClient) Create(virtualMachineRestore *harvesterv1.VirtualMachineRestore) (*harvesterv1.VirtualMachineRestore, error) {
+	if virtualMachineRestore.GenerateName != "" {
+		virtualMachineRestore.Name = fmt.Sprintf("%s%s", virtualMachineRestore.GenerateName, fuzz.String(5))
+	}
+	return c(virtualMachineRestore.Namespace).Create(context.TODO(), virtualMachineRestore, metav1.CreateOptions{})
+}
+func (c VirtualMachineRestoreClient) Delete(namespace, name string, options *metav1.DeleteOptions) error {
+	panic("implement me")
+}
+func (c VirtualMachineRestoreClient) List(namespace string, opts metav1.ListOptions) (*harvesterv1.VirtualMachineRestoreList, error) {
+	panic("implement me")
+}
+func (c VirtualMachineRestoreClient) UpdateStatus(*harvesterv1.VirtualMachineRestore) (*harvesterv1.VirtualMachineRestore, error) {
+	panic("implement me")
+}
+func (c VirtualMachineRestoreClient) Watch(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	panic("implement me")
+}
+func (c VirtualMachineRestoreClient) Patch(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (result *harvesterv1.VirtualMachineRestore, err error) {
+	panic("implement me")
+}
+
+type VirtualMachineRestoreCache func(string) harv1type.VirtualMachineRestoreInterface
+
+func (c VirtualMachineRestoreCache) Get(namespace, name string) (*harvesterv1.VirtualMachineRestore, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VirtualMachineRestoreCache) List(namespace string, selector labels.Selector) ([]*harvesterv1.VirtualMachineRestore, error) {
+	list, err := c(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*harvesterv1.VirtualMachineRestore, 0, len(list.Items))
+	for i := range list.Items {
+		result = append(result, &list.Items[i])
+	}
+	return result, err
+}
+func (c VirtualMachineRestoreCache) AddIndexer(indexName string, indexer ctlharvesterv1.VirtualMachineRestoreIndexer) {
+	panic("implement me")
+}
+func (c VirtualMachineRestoreCache) GetByIndex(indexName, key string) ([]*harvesterv1.VirtualMachineRestore, error) {
+	panic("implement me")
+}
@@ -0,0 +1,69 @@
+package fakeclients
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	harv1type "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/tests/framework/fuzz"
+)
+
+type VirtualMachineSnapshotClient func(string) harv1type.VirtualMachineSnapshotInterface
+
+func (c VirtualMachineSnapshotClient) Update(virtualMachineSnapshot *harvesterv1.VirtualMachineSnapshot) (*harvesterv1.VirtualMachineSnapshot, error) {
+	return c(virtualMachineSnapshot.Namespace).Update(context.TODO(), virtualMachineSnapshot, metav1.UpdateOptions{})
+}
+func (c VirtualMachineSnapshotClient) Get(namespace, name string, options metav1.GetOptions) (*harvesterv1.VirtualMachineSnapshot, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VirtualMachineSnapshotClient) Create(virtualMachineSnapshot *harvesterv1.VirtualMachineSnapshot) (*harvesterv1.VirtualMachineSnapshot, error) {
+	if virtualMachineSnapshot.GenerateName != "" {
+		virtualMachineSnapshot.Name = fmt.Sprintf("%s%s", virtualMachineSnapshot.GenerateName, fuzz.String(5))
+	}
+	return c(virtualMachineSnapshot.Namespace).Create(context.TODO(), virtualMachineSnapshot, metav1.CreateOptions{})
+}
+func (c VirtualMachineSnapshotClient) Delete(namespace, name string, options *metav1.DeleteOptions) error {
+	panic("implement me")
+}
+func (c VirtualMachineSnapshotClient) List(namespace string, opts metav1.ListOptions) (*harvesterv1.VirtualMachineSnapshotList, error) {
+	panic("implement me")
+}
+func (c VirtualMachineSnapshotClient) UpdateStatus(*harvesterv1.VirtualMachineSnapshot) (*harvesterv1.VirtualMachineSnapshot, error) {
+	panic("implement me")
+}
+func (c VirtualMachineSnapshotClient) Watch(namespace string, opts metav1.ListOptions) (watch.Interface, error) {
+	panic("implement me")
+}
+func (c VirtualMachineSnapshotClient) Patch(namespace, name string, pt types.PatchType, data []byte, subresources ...string) (result *harvesterv1.VirtualMachineSnapshot, err error) {
+	panic("implement me")
+}
+
+type VirtualMachineSnapshotCache func(string) harv1type.VirtualMachineSnapshotInterface
+
+func (c VirtualMachineSnapshotCache) Get(namespace, name string) (*harvesterv1.VirtualMachineSnapshot, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VirtualMachineSnapshotCache) List(namespace string, selector labels.Selector) ([]*harvesterv1.VirtualMachineSnapshot, error) {
+	list, err := c(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*harvesterv1.VirtualMachineSnapshot, 0, len(list.Items))
+	for i := range list.Items {
+		result = append(result, &list.Items[i])
+	}
+	return result, err
+}
+func (c VirtualMachineSnapshotCache) AddIndexer(indexName string, indexer ctlharvesterv1.VirtualMachineSnapshotIndexer) {
+	panic("implement me")
+}
+func (c VirtualMachineSnapshotCache) GetByIndex(indexName, key string) ([]*harvesterv1.VirtualMachineSnapshot, error) {
+	panic("implement me")
+}
@@ -0,0 +1,27 @@
+package fakeclients
+
+import (
+	"context"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/v2/pkg/apis/volumesnapshot/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	snapshottype "github.com/harvester/harvester/pkg/generated/clientset/versioned/typed/snapshot.storage.k8s.io/v1beta1"
+	ctlsnapshotv1 "github.com/harvester/harvester/pkg/generated/controllers/snapshot.storage.k8s.io/v1beta1"
+)
+
+type VolumeSnapshotCache func(string) snapshottype.VolumeSnapshotInterface
+
+func (c VolumeSnapshotCache) Get(namespace, name string) (*snapshotv1.VolumeSnapshot, error) {
+	return c(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+}
+func (c VolumeSnapshotCache) List(namespace string, selector labels.Selector) ([]*snapshotv1.VolumeSnapshot, error) {
+	panic("implement me")
+}
+func (c VolumeSnapshotCache) AddIndexer(indexName string, indexer ctlsnapshotv1.VolumeSnapshotIndexer) {
+	panic("implement me")
+}
+func (c VolumeSnapshotCache) GetByIndex(indexName, key string) ([]*snapshotv1.VolumeSnapshot, error) {
+	panic("implement me")
+}
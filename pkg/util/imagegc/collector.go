@@ -0,0 +1,119 @@
+package imagegc
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/imageusage"
+)
+
+// Collector collects the images unused for the days in the image-gc-config setting. An image is annotated
+// with the time it's found unused, and is labelled unused or removed once it stays unused for the days.
+type Collector struct {
+	Images      ctlharvesterv1.VirtualMachineImageClient
+	ImageCache  ctlharvesterv1.VirtualMachineImageCache
+	UsageGetter *imageusage.Getter
+}
+
+// Collect handles the images unused for the days of the config at the time by the action of the config
+func (c *Collector) Collect(gcConfig *settings.ImageGCConfig, now time.Time) error {
+	images, err := c.ImageCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return err
+	}
+	unusedPeriod := time.Duration(gcConfig.UnusedDays) * 24 * time.Hour
+	for _, image := range images {
+		// the images still importing are used by nothing yet
+		if image.DeletionTimestamp != nil || !harvesterv1.ImageImported.IsTrue(image) {
+			continue
+		}
+		usage, err := c.UsageGetter.Get(image)
+		if err != nil {
+			return err
+		}
+		// the images referenced by template versions, VM backups or snapshots are in use, so they are never collected
+		if usage.InUse() {
+			if err := c.unmark(image); err != nil {
+				return err
+			}
+			continue
+		}
+
+		unusedSince, err := time.Parse(time.RFC3339, image.Annotations[util.AnnotationImageUnusedSince])
+		if err != nil {
+			if err := c.updateMarks(image, now.UTC().Format(time.RFC3339), false); err != nil {
+				return err
+			}
+			continue
+		}
+		if now.Sub(unusedSince) < unusedPeriod {
+			continue
+		}
+
+		switch gcConfig.Action {
+		case settings.ImageGCActionDelete:
+			logrus.Infof("deleting image %s/%s unused since %s", image.Namespace, image.Name, unusedSince.Format(time.RFC3339))
+			if err := c.Images.Delete(image.Namespace, image.Name, &metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				return err
+			}
+		default:
+			if err := c.updateMarks(image, image.Annotations[util.AnnotationImageUnusedSince], true); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarkAll removes the marks of all the images, the marks of a disabled collector are stale
+func (c *Collector) UnmarkAll() error {
+	images, err := c.ImageCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if err := c.unmark(image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Collector) unmark(image *harvesterv1.VirtualMachineImage) error {
+	return c.updateMarks(image, "", false)
+}
+
+// updateMarks sets the time the image is found unused, and the label telling it's unused for the days
+func (c *Collector) updateMarks(image *harvesterv1.VirtualMachineImage, unusedSince string, unused bool) error {
+	if image.Annotations[util.AnnotationImageUnusedSince] == unusedSince && (image.Labels[util.LabelImageUnused] == "true") == unused {
+		return nil
+	}
+	toUpdate := image.DeepCopy()
+	if unusedSince == "" {
+		delete(toUpdate.Annotations, util.AnnotationImageUnusedSince)
+	} else {
+		if toUpdate.Annotations == nil {
+			toUpdate.Annotations = map[string]string{}
+		}
+		toUpdate.Annotations[util.AnnotationImageUnusedSince] = unusedSince
+	}
+	if unused {
+		if toUpdate.Labels == nil {
+			toUpdate.Labels = map[string]string{}
+		}
+		toUpdate.Labels[util.LabelImageUnused] = "true"
+	} else {
+		delete(toUpdate.Labels, util.LabelImageUnused)
+	}
+	_, err := c.Images.Update(toUpdate)
+	return err
+}
@@ -0,0 +1,157 @@
+package imagegc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+	"github.com/harvester/harvester/pkg/util/imageusage"
+)
+
+func newTestImage(unusedSince string, unused bool) *harvesterv1.VirtualMachineImage {
+	image := &harvesterv1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "image-abcde",
+			Annotations: map[string]string{},
+			Labels:      map[string]string{},
+		},
+		Status: harvesterv1.VirtualMachineImageStatus{
+			StorageClassName: "longhorn-image-abcde",
+		},
+	}
+	harvesterv1.ImageImported.True(image)
+	if unusedSince != "" {
+		image.Annotations[util.AnnotationImageUnusedSince] = unusedSince
+	}
+	if unused {
+		image.Labels[util.LabelImageUnused] = "true"
+	}
+	return image
+}
+
+func TestCollector(t *testing.T) {
+	now := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	longAgo := now.Add(-31 * 24 * time.Hour).Format(time.RFC3339)
+	recently := now.Add(-24 * time.Hour).Format(time.RFC3339)
+	templateVersion := &harvesterv1.VirtualMachineTemplateVersion{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "template-1"},
+		Spec:       harvesterv1.VirtualMachineTemplateVersionSpec{ImageID: "default/image-abcde"},
+	}
+	storageClassName := "longhorn-image-abcde"
+	backup := &harvesterv1.VirtualMachineBackup{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-1"},
+		Status: &harvesterv1.VirtualMachineBackupStatus{
+			VolumeBackups: []harvesterv1.VolumeBackup{
+				{
+					PersistentVolumeClaim: harvesterv1.PersistentVolumeClaimSourceSpec{
+						ObjectMeta: metav1.ObjectMeta{Name: "vm-disk-0"},
+						Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+					},
+				},
+			},
+		},
+	}
+
+	var testCases = []struct {
+		name                string
+		action              string
+		image               *harvesterv1.VirtualMachineImage
+		users               []runtime.Object
+		expectedDeleted     bool
+		expectedUnusedSince string
+		expectedUnused      bool
+	}{
+		{
+			name:                "marking the time an image is found unused",
+			action:              settings.ImageGCActionFlag,
+			image:               newTestImage("", false),
+			expectedUnusedSince: now.Format(time.RFC3339),
+		},
+		{
+			name:                "waiting for the unused days",
+			action:              settings.ImageGCActionFlag,
+			image:               newTestImage(recently, false),
+			expectedUnusedSince: recently,
+		},
+		{
+			name:                "flagging an image unused for the days",
+			action:              settings.ImageGCActionFlag,
+			image:               newTestImage(longAgo, false),
+			expectedUnusedSince: longAgo,
+			expectedUnused:      true,
+		},
+		{
+			name:            "deleting an image unused for the days",
+			action:          settings.ImageGCActionDelete,
+			image:           newTestImage(longAgo, false),
+			expectedDeleted: true,
+		},
+		{
+			name:   "protecting an image referenced by a template version",
+			action: settings.ImageGCActionDelete,
+			image:  newTestImage(longAgo, true),
+			users:  []runtime.Object{templateVersion},
+		},
+		{
+			name:   "resetting the unused time of an image used by a backup",
+			action: settings.ImageGCActionFlag,
+			image:  newTestImage(recently, false),
+			users:  []runtime.Object{backup},
+		},
+	}
+
+	for _, tc := range testCases {
+		clientset := fake.NewSimpleClientset(append(tc.users, tc.image)...)
+		coreclientset := k8sfake.NewSimpleClientset()
+		collector := &Collector{
+			Images:     fakeclients.VirtualMachineImageClient(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+			ImageCache: fakeclients.VirtualMachineImageCache(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+			UsageGetter: &imageusage.Getter{
+				PVCCache:             fakeclients.PersistentVolumeClaimCache(coreclientset.CoreV1().PersistentVolumeClaims),
+				VMCache:              fakeclients.VirtualMachineCache(clientset.KubevirtV1().VirtualMachines),
+				TemplateVersionCache: fakeclients.VirtualMachineTemplateVersionCache(clientset.HarvesterhciV1beta1().VirtualMachineTemplateVersions),
+				BackupCache:          fakeclients.VirtualMachineBackupCache(clientset.HarvesterhciV1beta1().VirtualMachineBackups),
+				SnapshotCache:        fakeclients.VirtualMachineSnapshotCache(clientset.HarvesterhciV1beta1().VirtualMachineSnapshots),
+			},
+		}
+
+		err := collector.Collect(&settings.ImageGCConfig{Enable: true, UnusedDays: 30, Action: tc.action}, now)
+		assert.Nil(t, err, "case %q", tc.name)
+
+		image, err := clientset.HarvesterhciV1beta1().VirtualMachineImages("default").Get(context.TODO(), tc.image.Name, metav1.GetOptions{})
+		if tc.expectedDeleted {
+			assert.True(t, apierrors.IsNotFound(err), "case %q", tc.name)
+			continue
+		}
+		assert.Nil(t, err, "case %q", tc.name)
+		assert.Equal(t, tc.expectedUnusedSince, image.Annotations[util.AnnotationImageUnusedSince], "case %q", tc.name)
+		assert.Equal(t, tc.expectedUnused, image.Labels[util.LabelImageUnused] == "true", "case %q", tc.name)
+	}
+}
+
+func TestCollectorUnmarkAll(t *testing.T) {
+	clientset := fake.NewSimpleClientset(newTestImage(time.Now().Format(time.RFC3339), true))
+	collector := &Collector{
+		Images:     fakeclients.VirtualMachineImageClient(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+		ImageCache: fakeclients.VirtualMachineImageCache(clientset.HarvesterhciV1beta1().VirtualMachineImages),
+	}
+
+	assert.Nil(t, collector.UnmarkAll())
+	image, err := clientset.HarvesterhciV1beta1().VirtualMachineImages("default").Get(context.TODO(), "image-abcde", metav1.GetOptions{})
+	assert.Nil(t, err)
+	assert.NotContains(t, image.Annotations, util.AnnotationImageUnusedSince)
+	assert.NotContains(t, image.Labels, util.LabelImageUnused)
+}
@@ -0,0 +1,170 @@
+package imageusage
+
+import (
+	"encoding/json"
+	"sort"
+
+	ctlcorev1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
+)
+
+// Usage is what depends on an image, the resources are referred by their namespaced names
+type Usage struct {
+	Volumes          []string `json:"volumes"`
+	VirtualMachines  []string `json:"virtualMachines"`
+	TemplateVersions []string `json:"templateVersions"`
+	Backups          []string `json:"backups"`
+	Snapshots        []string `json:"snapshots"`
+}
+
+// InUse tells whether anything depends on the image
+func (u *Usage) InUse() bool {
+	return len(u.Volumes) > 0 || len(u.VirtualMachines) > 0 || len(u.TemplateVersions) > 0 ||
+		len(u.Backups) > 0 || len(u.Snapshots) > 0
+}
+
+// Getter finds the usage of images. The volumes use an image by its storage class, and the VMs and template
+// versions by the volumes or volume claim templates of the storage class. The template versions also refer to
+// the image by ImageID. The VM backups and snapshots use the image by the volumes they keep, which are restored
+// with the storage class of the image.
+type Getter struct {
+	PVCCache             ctlcorev1.PersistentVolumeClaimCache
+	VMCache              ctlkubevirtv1.VirtualMachineCache
+	TemplateVersionCache ctlharvesterv1.VirtualMachineTemplateVersionCache
+	BackupCache          ctlharvesterv1.VirtualMachineBackupCache
+	SnapshotCache        ctlharvesterv1.VirtualMachineSnapshotCache
+}
+
+func (g *Getter) Get(image *harvesterv1.VirtualMachineImage) (*Usage, error) {
+	usage := &Usage{
+		Volumes:          []string{},
+		VirtualMachines:  []string{},
+		TemplateVersions: []string{},
+		Backups:          []string{},
+		Snapshots:        []string{},
+	}
+	imageID := ref.Construct(image.Namespace, image.Name)
+
+	pvcs, err := g.PVCCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	volumes := map[string]bool{}
+	for _, pvc := range pvcs {
+		if usesImage(pvc, image, imageID) {
+			volumes[ref.Construct(pvc.Namespace, pvc.Name)] = true
+		}
+	}
+	for volume := range volumes {
+		usage.Volumes = append(usage.Volumes, volume)
+	}
+
+	vms, err := g.VMCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range vms {
+		if vmUsesImage(vm, volumes, image, imageID) {
+			usage.VirtualMachines = append(usage.VirtualMachines, ref.Construct(vm.Namespace, vm.Name))
+		}
+	}
+
+	templateVersions, err := g.TemplateVersionCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, templateVersion := range templateVersions {
+		if templateVersion.Spec.ImageID == imageID ||
+			claimTemplatesUseImage(templateVersion.Spec.VM.ObjectMeta.Annotations, image, imageID) {
+			usage.TemplateVersions = append(usage.TemplateVersions, ref.Construct(templateVersion.Namespace, templateVersion.Name))
+		}
+	}
+
+	backups, err := g.BackupCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		if backup.Status != nil && volumeBackupsUseImage(backup.Status.VolumeBackups, image, imageID) {
+			usage.Backups = append(usage.Backups, ref.Construct(backup.Namespace, backup.Name))
+		}
+	}
+
+	snapshots, err := g.SnapshotCache.List(corev1.NamespaceAll, labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Status != nil && volumeBackupsUseImage(snapshot.Status.VolumeSnapshots, image, imageID) {
+			usage.Snapshots = append(usage.Snapshots, ref.Construct(snapshot.Namespace, snapshot.Name))
+		}
+	}
+
+	sort.Strings(usage.Volumes)
+	sort.Strings(usage.VirtualMachines)
+	sort.Strings(usage.TemplateVersions)
+	sort.Strings(usage.Backups)
+	sort.Strings(usage.Snapshots)
+	return usage, nil
+}
+
+// volumeBackupsUseImage checks the volumes kept by a VM backup or snapshot, which are restored by
+// their PVC specs
+func volumeBackupsUseImage(volumeBackups []harvesterv1.VolumeBackup, image *harvesterv1.VirtualMachineImage, imageID string) bool {
+	for _, volumeBackup := range volumeBackups {
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: volumeBackup.PersistentVolumeClaim.ObjectMeta,
+			Spec:       volumeBackup.PersistentVolumeClaim.Spec,
+		}
+		if usesImage(pvc, image, imageID) {
+			return true
+		}
+	}
+	return false
+}
+
+func usesImage(pvc *corev1.PersistentVolumeClaim, image *harvesterv1.VirtualMachineImage, imageID string) bool {
+	if pvc.Spec.StorageClassName != nil && image.Status.StorageClassName != "" && *pvc.Spec.StorageClassName == image.Status.StorageClassName {
+		return true
+	}
+	return pvc.Annotations[util.AnnotationImageID] == imageID
+}
+
+func vmUsesImage(vm *kubevirtv1.VirtualMachine, volumes map[string]bool, image *harvesterv1.VirtualMachineImage, imageID string) bool {
+	if vm.Spec.Template != nil {
+		for _, volume := range vm.Spec.Template.Spec.Volumes {
+			if volume.PersistentVolumeClaim != nil && volumes[ref.Construct(vm.Namespace, volume.PersistentVolumeClaim.ClaimName)] {
+				return true
+			}
+		}
+	}
+	// the volumes of a VM which never started are only in its volume claim templates
+	return claimTemplatesUseImage(vm.Annotations, image, imageID)
+}
+
+func claimTemplatesUseImage(annotations map[string]string, image *harvesterv1.VirtualMachineImage, imageID string) bool {
+	volumeClaimTemplates, ok := annotations[util.AnnotationVolumeClaimTemplates]
+	if !ok || volumeClaimTemplates == "" {
+		return false
+	}
+	var pvcs []corev1.PersistentVolumeClaim
+	if err := json.Unmarshal([]byte(volumeClaimTemplates), &pvcs); err != nil {
+		logrus.Warnf("failed to unmarshal the volumeClaimTemplates annotation: %v", err)
+		return false
+	}
+	for i := range pvcs {
+		if usesImage(&pvcs[i], image, imageID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package imageusage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	kubevirtv1 "kubevirt.io/client-go/api/v1"
+
+	harvesterv1 "github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/generated/clientset/versioned/fake"
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/util/fakeclients"
+)
+
+const testStorageClassName = "longhorn-image-abcde"
+
+func newTestGetter(pvcs []runtime.Object, harvesterObjects ...runtime.Object) *Getter {
+	clientset := fake.NewSimpleClientset(harvesterObjects...)
+	coreclientset := k8sfake.NewSimpleClientset(pvcs...)
+	return &Getter{
+		PVCCache:             fakeclients.PersistentVolumeClaimCache(coreclientset.CoreV1().PersistentVolumeClaims),
+		VMCache:              fakeclients.VirtualMachineCache(clientset.KubevirtV1().VirtualMachines),
+		TemplateVersionCache: fakeclients.VirtualMachineTemplateVersionCache(clientset.HarvesterhciV1beta1().VirtualMachineTemplateVersions),
+		BackupCache:          fakeclients.VirtualMachineBackupCache(clientset.HarvesterhciV1beta1().VirtualMachineBackups),
+		SnapshotCache:        fakeclients.VirtualMachineSnapshotCache(clientset.HarvesterhciV1beta1().VirtualMachineSnapshots),
+	}
+}
+
+func newTestVolumeBackups(storageClassName string) []harvesterv1.VolumeBackup {
+	return []harvesterv1.VolumeBackup{
+		{
+			VolumeName: "disk-0",
+			PersistentVolumeClaim: harvesterv1.PersistentVolumeClaimSourceSpec{
+				ObjectMeta: metav1.ObjectMeta{Name: "vm-d-disk-0"},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					StorageClassName: &storageClassName,
+				},
+			},
+		},
+	}
+}
+
+func newTestPVC(name, storageClassName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      name,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+		},
+	}
+}
+
+func newTestVM(name, claimName string, annotations map[string]string) *kubevirtv1.VirtualMachine {
+	return &kubevirtv1.VirtualMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			Annotations: annotations,
+		},
+		Spec: kubevirtv1.VirtualMachineSpec{
+			Template: &kubevirtv1.VirtualMachineInstanceTemplateSpec{
+				Spec: kubevirtv1.VirtualMachineInstanceSpec{
+					Volumes: []kubevirtv1.Volume{
+						{
+							Name: "disk-0",
+							VolumeSource: kubevirtv1.VolumeSource{
+								PersistentVolumeClaim: &kubevirtv1.PersistentVolumeClaimVolumeSource{
+									PersistentVolumeClaimVolumeSource: corev1.PersistentVolumeClaimVolumeSource{
+										ClaimName: claimName,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestGetUsage(t *testing.T) {
+	image := &harvesterv1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "image-abcde",
+		},
+		Status: harvesterv1.VirtualMachineImageStatus{
+			StorageClassName: testStorageClassName,
+		},
+	}
+	claimTemplates := `[{"metadata":{"name":"vm-c-disk-0"},"spec":{"storageClassName":"` + testStorageClassName + `"}}]`
+
+	getter := newTestGetter(
+		[]runtime.Object{
+			newTestPVC("vm-a-disk-0", testStorageClassName),
+			newTestPVC("vm-b-disk-0", "longhorn"),
+		},
+		image,
+		newTestVM("vm-a", "vm-a-disk-0", nil),
+		newTestVM("vm-b", "vm-b-disk-0", nil),
+		newTestVM("vm-c", "vm-c-disk-0", map[string]string{util.AnnotationVolumeClaimTemplates: claimTemplates}),
+		&harvesterv1.VirtualMachineTemplateVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "template-1"},
+			Spec:       harvesterv1.VirtualMachineTemplateVersionSpec{ImageID: "default/image-abcde"},
+		},
+		&harvesterv1.VirtualMachineTemplateVersion{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "template-2"},
+			Spec:       harvesterv1.VirtualMachineTemplateVersionSpec{ImageID: "default/image-fghij"},
+		},
+		&harvesterv1.VirtualMachineBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-1"},
+			Status:     &harvesterv1.VirtualMachineBackupStatus{VolumeBackups: newTestVolumeBackups(testStorageClassName)},
+		},
+		&harvesterv1.VirtualMachineBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-2"},
+			Status:     &harvesterv1.VirtualMachineBackupStatus{VolumeBackups: newTestVolumeBackups("longhorn")},
+		},
+		&harvesterv1.VirtualMachineBackup{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "backup-3"},
+		},
+		&harvesterv1.VirtualMachineSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "snapshot-1"},
+			Status:     &harvesterv1.VirtualMachineSnapshotStatus{VolumeSnapshots: newTestVolumeBackups(testStorageClassName)},
+		},
+	)
+
+	usage, err := getter.Get(image)
+	assert.NoError(t, err)
+	assert.Equal(t, &Usage{
+		Volumes:          []string{"default/vm-a-disk-0"},
+		VirtualMachines:  []string{"default/vm-a", "default/vm-c"},
+		TemplateVersions: []string{"default/template-1"},
+		Backups:          []string{"default/backup-1"},
+		Snapshots:        []string{"default/snapshot-1"},
+	}, usage)
+	assert.True(t, usage.InUse())
+
+	unused, err := getter.Get(&harvesterv1.VirtualMachineImage{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "image-klmno"},
+		Status:     harvesterv1.VirtualMachineImageStatus{StorageClassName: "longhorn-image-klmno"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, unused.InUse())
+
+	// the volumes kept by backups and snapshots need the image to be restored
+	onlyBackedUp := &Usage{Backups: []string{"default/backup-1"}}
+	assert.True(t, onlyBackedUp.InUse())
+	onlySnapshotted := &Usage{Snapshots: []string{"default/snapshot-1"}}
+	assert.True(t, onlySnapshotted.InUse())
+}
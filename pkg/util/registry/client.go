@@ -0,0 +1,357 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	digestAlgorithmSHA256 = "sha256"
+)
+
+// Credentials are the username and password to log in to a registry
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// Client pulls manifests and blobs by the Docker Registry HTTP API V2, which the OCI distribution spec is based on.
+// It trusts the system CAs plus the given bundle, and uses the proxy of the environment.
+type Client struct {
+	httpClient  *http.Client
+	credentials map[string]Credentials
+
+	mutex  sync.Mutex
+	tokens map[string]string
+}
+
+func NewClient(caBundle []byte, credentials map[string]Credentials) (*Client, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if len(caBundle) > 0 && !pool.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("failed to parse the CA bundle")
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &Client{
+		httpClient:  &http.Client{Transport: transport},
+		credentials: credentials,
+		tokens:      map[string]string{},
+	}, nil
+}
+
+// ParseDockerConfigSecret returns the credentials by registry host of an image pull secret
+func ParseDockerConfigSecret(secret *corev1.Secret) (map[string]Credentials, error) {
+	var auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	switch secret.Type {
+	case corev1.SecretTypeDockerConfigJson:
+		var config struct {
+			Auths json.RawMessage `json:"auths"`
+		}
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigJsonKey], &config); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(config.Auths, &auths); err != nil {
+			return nil, err
+		}
+	case corev1.SecretTypeDockercfg:
+		if err := json.Unmarshal(secret.Data[corev1.DockerConfigKey], &auths); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("secret %s/%s is not an image pull secret", secret.Namespace, secret.Name)
+	}
+
+	result := make(map[string]Credentials, len(auths))
+	for server, auth := range auths {
+		credentials := Credentials{Username: auth.Username, Password: auth.Password}
+		if auth.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of %s in secret %s/%s: %w", server, secret.Namespace, secret.Name, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) == 2 {
+				credentials = Credentials{Username: parts[0], Password: parts[1]}
+			}
+		}
+		result[normalizeServer(server)] = credentials
+	}
+	return result, nil
+}
+
+// normalizeServer turns the keys of docker config, e.g. https://index.docker.io/v1/, into the domains of references
+func normalizeServer(server string) string {
+	if u, err := url.Parse(server); err == nil && u.Host != "" {
+		server = u.Host
+	}
+	server = strings.SplitN(server, "/", 2)[0]
+	if server == "index.docker.io" || server == dockerHubRegistry {
+		return dockerHubDomain
+	}
+	return server
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// getManifest gets the image manifest, the manifest of the current platform is chosen if it's an index
+func (c *Client) getManifest(ctx context.Context, ref *Reference) (*manifest, error) {
+	m, err := c.fetchManifest(ctx, ref, ref.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	if m.MediaType != mediaTypeOCIIndex && m.MediaType != mediaTypeDockerList && len(m.Manifests) == 0 {
+		return m, nil
+	}
+	if len(m.Manifests) == 0 {
+		return nil, fmt.Errorf("image %s has an empty index", ref)
+	}
+	chosen := m.Manifests[0]
+	for _, d := range m.Manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == runtime.GOARCH {
+			chosen = d
+			break
+		}
+	}
+	return c.fetchManifest(ctx, ref, chosen.Digest)
+}
+
+func (c *Client) fetchManifest(ctx context.Context, ref *Reference, identifier string) (*manifest, error) {
+	resp, err := c.get(ctx, ref, "manifests/"+identifier,
+		strings.Join([]string{mediaTypeOCIManifest, mediaTypeOCIIndex, mediaTypeDockerManifest, mediaTypeDockerList}, ", "))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	m := &manifest{}
+	if err := json.NewDecoder(resp.Body).Decode(m); err != nil {
+		return nil, fmt.Errorf("failed to decode the manifest of %s: %w", ref, err)
+	}
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+	return m, nil
+}
+
+// getBlob returns the blob of the descriptor, reading it fails at the end if the content doesn't match the digest
+// or the size in the manifest
+func (c *Client) getBlob(ctx context.Context, ref *Reference, desc descriptor) (io.ReadCloser, error) {
+	parts := strings.SplitN(desc.Digest, ":", 2)
+	if len(parts) != 2 || parts[0] != digestAlgorithmSHA256 {
+		return nil, fmt.Errorf("unsupported digest %q of image %s", desc.Digest, ref)
+	}
+	resp, err := c.get(ctx, ref, "blobs/"+desc.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	return &digestReader{
+		ReadCloser: resp.Body,
+		hash:       sha256.New(),
+		expected:   strings.ToLower(parts[1]),
+		desc:       desc,
+	}, nil
+}
+
+// digestReader computes the digest of the blob while it's read, and verifies it when the blob is read to the end
+type digestReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	expected string
+	desc     descriptor
+	read     int64
+	err      error
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	r.read += int64(n)
+	if err == io.EOF {
+		if r.desc.Size > 0 && r.read != r.desc.Size {
+			err = fmt.Errorf("blob %s has %d bytes, expected %d", r.desc.Digest, r.read, r.desc.Size)
+		} else if actual := hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			err = fmt.Errorf("blob %s doesn't match its digest, got %s:%s", r.desc.Digest, digestAlgorithmSHA256, actual)
+		}
+		r.err = err
+	}
+	return n, err
+}
+
+// get requests the repository API, and logs in by the scheme the registry asks if it's unauthorized
+func (c *Client) get(ctx context.Context, ref *Reference, path, accept string) (*http.Response, error) {
+	endpoint := fmt.Sprintf("https://%s/v2/%s/%s", ref.Registry(), ref.Repository, path)
+	tokenKey := ref.Registry() + "/" + ref.Repository
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		c.mutex.Lock()
+		authorization := c.tokens[tokenKey]
+		c.mutex.Unlock()
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return nil, fmt.Errorf("failed to get %s of %s: %s %s", path, ref, resp.Status, strings.TrimSpace(string(body)))
+		}
+
+		authorization, err = c.login(ctx, ref, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		c.mutex.Lock()
+		c.tokens[tokenKey] = authorization
+		c.mutex.Unlock()
+	}
+}
+
+// login returns the Authorization header for the challenge of the registry
+func (c *Client) login(ctx context.Context, ref *Reference, challenge string) (string, error) {
+	credentials, hasCredentials := c.credentials[ref.Domain]
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if !hasCredentials {
+			return "", fmt.Errorf("registry %s requires credentials", ref.Domain)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials.Username+":"+credentials.Password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication scheme of registry %s: %q", ref.Domain, challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid authentication realm of registry %s: %q", ref.Domain, params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if hasCredentials {
+		req.SetBasicAuth(credentials.Username, credentials.Password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to log in to registry %s: %s", ref.Domain, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode the token of registry %s: %w", ref.Domain, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// parseChallenge parses the WWW-Authenticate header, e.g. Bearer realm="https://auth.docker.io/token",service="registry.docker.io"
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	scheme := strings.ToLower(parts[0])
+	if len(parts) < 2 {
+		return scheme, params
+	}
+	for _, param := range splitParams(parts[1]) {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		params[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+	}
+	return scheme, params
+}
+
+// splitParams splits the parameters by the commas outside of the quotes, as the scope may contain commas
+func splitParams(s string) []string {
+	var params []string
+	var quoted bool
+	var start int
+	for i, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			params = append(params, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(params, s[start:])
+}
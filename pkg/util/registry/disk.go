@@ -0,0 +1,150 @@
+package registry
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+const (
+	// containerDiskDir is where KubeVirt containerdisk images put the disk
+	containerDiskDir = "disk"
+
+	// annotationTitle is the file name of the layers of OCI artifacts, e.g. pushed by ORAS
+	annotationTitle = "org.opencontainers.image.title"
+	// annotationUnpack tells the layer is a compressed directory instead of a file
+	annotationUnpack = "io.deis.oras.content.unpack"
+
+	tarMagicOffset = 257
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar")
+)
+
+// Disk is the disk file of an image
+type Disk struct {
+	io.Reader
+	Size    int64
+	blob    io.Reader
+	closers []io.Closer
+}
+
+// Read reads the disk, at the end the rest of the blob, e.g. after the disk in a tar layer, is read as well
+// so that the blob digest is verified before the disk is considered complete.
+func (d *Disk) Read(p []byte) (int, error) {
+	n, err := d.Reader.Read(p)
+	if err == io.EOF && d.blob != nil {
+		if _, drainErr := io.Copy(ioutil.Discard, d.blob); drainErr != nil {
+			return n, drainErr
+		}
+	}
+	return n, err
+}
+
+func (d *Disk) Close() error {
+	var err error
+	for i := len(d.closers) - 1; i >= 0; i-- {
+		if closeErr := d.closers[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// OpenDisk opens the disk of a KubeVirt containerdisk image, which is a file in the /disk directory of a layer,
+// or of an OCI artifact with the disk as a single layer. The layers are searched from the top.
+func (c *Client) OpenDisk(ctx context.Context, ref *Reference) (*Disk, error) {
+	m, err := c.getManifest(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Layers) == 0 {
+		return nil, fmt.Errorf("image %s has no layers", ref)
+	}
+
+	for i := len(m.Layers) - 1; i >= 0; i-- {
+		layer := m.Layers[i]
+		blob, err := c.getBlob(ctx, ref, layer)
+		if err != nil {
+			return nil, err
+		}
+		disk, err := openLayerDisk(blob, layer)
+		if err != nil {
+			blob.Close()
+			return nil, fmt.Errorf("failed to read layer %s of %s: %w", layer.Digest, ref, err)
+		}
+		if disk != nil {
+			return disk, nil
+		}
+		blob.Close()
+	}
+	return nil, fmt.Errorf("no disk is found in the /%s directory of image %s", containerDiskDir, ref)
+}
+
+// openLayerDisk returns the disk in the layer, or nil if it's an archive without the disk
+func openLayerDisk(blob io.ReadCloser, layer descriptor) (*Disk, error) {
+	disk := &Disk{blob: blob, closers: []io.Closer{blob}}
+	reader := bufio.NewReader(blob)
+
+	// the file of an artifact layer is the disk
+	if layer.Annotations[annotationTitle] != "" && layer.Annotations[annotationUnpack] != "true" {
+		if isGzip(reader) {
+			return nil, fmt.Errorf("the compressed disk %s is not supported", layer.Annotations[annotationTitle])
+		}
+		disk.Reader, disk.Size = reader, layer.Size
+		return disk, nil
+	}
+
+	if isGzip(reader) {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, err
+		}
+		disk.closers = append(disk.closers, gzipReader)
+		reader = bufio.NewReader(gzipReader)
+	}
+	if !isTar(reader) {
+		disk.Reader, disk.Size = reader, layer.Size
+		if len(disk.closers) > 1 {
+			return nil, fmt.Errorf("the compressed disk of layer %s is not supported", layer.Digest)
+		}
+		return disk, nil
+	}
+
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if path.Dir(strings.TrimPrefix(path.Clean("/"+header.Name), "/")) == containerDiskDir {
+			disk.Reader, disk.Size = tarReader, header.Size
+			return disk, nil
+		}
+	}
+}
+
+func isGzip(reader *bufio.Reader) bool {
+	magic, _ := reader.Peek(len(gzipMagic))
+	return bytes.Equal(magic, gzipMagic)
+}
+
+func isTar(reader *bufio.Reader) bool {
+	magic, _ := reader.Peek(tarMagicOffset + len(tarMagic))
+	return len(magic) == tarMagicOffset+len(tarMagic) && bytes.Equal(magic[tarMagicOffset:], tarMagic)
+}
@@ -0,0 +1,188 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTarLayer(t *testing.T, files map[string]string, compressed bool) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	assert.NoError(t, tw.WriteHeader(&tar.Header{Name: "disk/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, content := range files {
+		assert.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	if !compressed {
+		return buf.Bytes()
+	}
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write(buf.Bytes())
+	assert.NoError(t, err)
+	assert.NoError(t, gw.Close())
+	return gzipped.Bytes()
+}
+
+func TestOpenLayerDisk(t *testing.T) {
+	var testCases = []struct {
+		name         string
+		layer        []byte
+		annotations  map[string]string
+		expectedDisk string
+		expectNil    bool
+		expectErr    bool
+	}{
+		{
+			name:         "containerdisk layer",
+			layer:        newTarLayer(t, map[string]string{"disk/ubuntu.qcow2": "QFI disk"}, true),
+			expectedDisk: "QFI disk",
+		},
+		{
+			name:         "uncompressed containerdisk layer",
+			layer:        newTarLayer(t, map[string]string{"./disk/ubuntu.img": "raw disk"}, false),
+			expectedDisk: "raw disk",
+		},
+		{
+			name:      "layer without disk",
+			layer:     newTarLayer(t, map[string]string{"etc/hostname": "node"}, true),
+			expectNil: true,
+		},
+		{
+			name:         "artifact layer",
+			layer:        []byte("QFI artifact"),
+			annotations:  map[string]string{annotationTitle: "ubuntu.qcow2"},
+			expectedDisk: "QFI artifact",
+		},
+		{
+			name:        "compressed artifact layer",
+			layer:       newTarLayer(t, nil, true),
+			annotations: map[string]string{annotationTitle: "ubuntu.qcow2.gz"},
+			expectErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		disk, err := openLayerDisk(ioutil.NopCloser(bytes.NewReader(tc.layer)), descriptor{
+			Size:        int64(len(tc.layer)),
+			Annotations: tc.annotations,
+		})
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		if tc.expectNil {
+			assert.Nil(t, disk, tc.name)
+			continue
+		}
+		content, err := ioutil.ReadAll(disk)
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expectedDisk, string(content), tc.name)
+		assert.Equal(t, int64(len(tc.expectedDisk)), disk.Size, tc.name)
+		assert.NoError(t, disk.Close(), tc.name)
+	}
+}
+
+func TestOpenDisk(t *testing.T) {
+	const token = "secret-token"
+	diskLayer := newTarLayer(t, map[string]string{"disk/ubuntu.qcow2": "QFI disk"}, true)
+	baseLayer := newTarLayer(t, map[string]string{"etc/hostname": "node"}, true)
+	blobs := map[string][]byte{}
+	var layers []descriptor
+	for _, layer := range [][]byte{diskLayer, baseLayer} {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256(layer))
+		blobs[digest] = layer
+		layers = append(layers, descriptor{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: digest, Size: int64(len(layer))})
+	}
+	manifestContent, err := json.Marshal(manifest{MediaType: mediaTypeOCIManifest, Layers: layers})
+	assert.NoError(t, err)
+
+	// the tampered image serves another content than the digest of its layer
+	tamperedLayer := newTarLayer(t, map[string]string{"disk/ubuntu.qcow2": "QFI evil"}, true)
+	tamperedDigest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("original layer")))
+	blobs[tamperedDigest] = tamperedLayer
+	tamperedManifest, err := json.Marshal(manifest{MediaType: mediaTypeOCIManifest, Layers: []descriptor{
+		{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: tamperedDigest, Size: int64(len(tamperedLayer))},
+	}})
+	assert.NoError(t, err)
+
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			username, password, _ := req.BasicAuth()
+			if username != "user" || password != "pass" || req.URL.Query().Get("scope") != "repository:golden/ubuntu:pull" {
+				rw.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(rw).Encode(map[string]string{"token": token})
+			return
+		}
+		if req.Header.Get("Authorization") != "Bearer "+token {
+			rw.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case req.URL.Path == "/v2/golden/ubuntu/manifests/20.04":
+			rw.Header().Set("Content-Type", mediaTypeOCIManifest)
+			_, _ = rw.Write(manifestContent)
+		case req.URL.Path == "/v2/golden/ubuntu/manifests/tampered":
+			rw.Header().Set("Content-Type", mediaTypeOCIManifest)
+			_, _ = rw.Write(tamperedManifest)
+		case strings.HasPrefix(req.URL.Path, "/v2/golden/ubuntu/blobs/"):
+			blob, ok := blobs[strings.TrimPrefix(req.URL.Path, "/v2/golden/ubuntu/blobs/")]
+			if !ok {
+				rw.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = rw.Write(blob)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	host := strings.TrimPrefix(server.URL, "https://")
+	ref, err := ParseReference(host + "/golden/ubuntu:20.04")
+	assert.NoError(t, err)
+
+	client, err := NewClient(caBundle, map[string]Credentials{host: {Username: "user", Password: "pass"}})
+	assert.NoError(t, err)
+	disk, err := client.OpenDisk(context.Background(), ref)
+	assert.NoError(t, err)
+	content, err := ioutil.ReadAll(disk)
+	assert.NoError(t, err)
+	assert.Equal(t, "QFI disk", string(content))
+	assert.Equal(t, int64(len("QFI disk")), disk.Size)
+	assert.NoError(t, disk.Close())
+
+	tamperedRef, err := ParseReference(host + "/golden/ubuntu:tampered")
+	assert.NoError(t, err)
+	disk, err = client.OpenDisk(context.Background(), tamperedRef)
+	assert.NoError(t, err)
+	_, err = ioutil.ReadAll(disk)
+	assert.Error(t, err)
+	assert.NoError(t, disk.Close())
+
+	client, err = NewClient(caBundle, nil)
+	assert.NoError(t, err)
+	_, err = client.OpenDisk(context.Background(), ref)
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHubDomain   = "docker.io"
+	dockerHubRegistry = "registry-1.docker.io"
+
+	// schemePrefix is the optional prefix of the references, as KubeVirt and skopeo use it for registry images
+	schemePrefix = "docker://"
+)
+
+// Reference is a parsed image reference, e.g. harbor.example.com/golden/ubuntu:20.04
+type Reference struct {
+	// Domain is the registry host as written in the reference and the credentials, e.g. docker.io
+	Domain     string
+	Repository string
+	// Tag or Digest identifies the manifest, the digest takes precedence
+	Tag    string
+	Digest string
+}
+
+// ParseReference parses an image reference, the registry defaults to Docker Hub and the tag to latest
+// as the container runtimes do.
+func ParseReference(s string) (*Reference, error) {
+	s = strings.TrimPrefix(s, schemePrefix)
+	if s == "" {
+		return nil, fmt.Errorf("empty image reference")
+	}
+
+	ref := &Reference{}
+	remainder := s
+	if i := strings.Index(remainder, "@"); i >= 0 {
+		ref.Digest = remainder[i+1:]
+		remainder = remainder[:i]
+		if !strings.Contains(ref.Digest, ":") {
+			return nil, fmt.Errorf("invalid digest in image reference %s", s)
+		}
+	}
+	// the tag is after the last colon which isn't a part of the registry host and port
+	if i := strings.LastIndex(remainder, ":"); i > strings.LastIndex(remainder, "/") {
+		ref.Tag = remainder[i+1:]
+		remainder = remainder[:i]
+	}
+
+	parts := strings.SplitN(remainder, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Domain, ref.Repository = parts[0], parts[1]
+	} else {
+		ref.Domain, ref.Repository = dockerHubDomain, remainder
+	}
+	if ref.Domain == dockerHubDomain && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+	if ref.Repository == "" || strings.HasSuffix(ref.Repository, "/") || ref.Repository != strings.ToLower(ref.Repository) {
+		return nil, fmt.Errorf("invalid repository in image reference %s", s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+// Registry is the host the registry API is served on
+func (r *Reference) Registry() string {
+	if r.Domain == dockerHubDomain {
+		return dockerHubRegistry
+	}
+	return r.Domain
+}
+
+// Identifier is the digest or the tag of the manifest
+func (r *Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r *Reference) String() string {
+	s := r.Domain + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseReference(t *testing.T) {
+	var testCases = []struct {
+		name      string
+		input     string
+		expected  *Reference
+		expectErr bool
+	}{
+		{
+			name:  "official image on docker hub",
+			input: "ubuntu",
+			expected: &Reference{
+				Domain:     "docker.io",
+				Repository: "library/ubuntu",
+				Tag:        "latest",
+			},
+		},
+		{
+			name:  "user image on docker hub with tag",
+			input: "docker://kubevirt/fedora-cloud-container-disk-demo:v0.45.0",
+			expected: &Reference{
+				Domain:     "docker.io",
+				Repository: "kubevirt/fedora-cloud-container-disk-demo",
+				Tag:        "v0.45.0",
+			},
+		},
+		{
+			name:  "private registry with port",
+			input: "harbor.example.com:8443/golden/ubuntu:20.04",
+			expected: &Reference{
+				Domain:     "harbor.example.com:8443",
+				Repository: "golden/ubuntu",
+				Tag:        "20.04",
+			},
+		},
+		{
+			name:  "digest",
+			input: "localhost/golden/ubuntu@sha256:abcd",
+			expected: &Reference{
+				Domain:     "localhost",
+				Repository: "golden/ubuntu",
+				Digest:     "sha256:abcd",
+			},
+		},
+		{
+			name:      "empty",
+			input:     "docker://",
+			expectErr: true,
+		},
+		{
+			name:      "invalid digest",
+			input:     "harbor.example.com/golden/ubuntu@abcd",
+			expectErr: true,
+		},
+		{
+			name:      "uppercase repository",
+			input:     "harbor.example.com/Golden/ubuntu",
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		ref, err := ParseReference(tc.input)
+		if tc.expectErr {
+			assert.Error(t, err, tc.name)
+			continue
+		}
+		assert.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, ref, tc.name)
+	}
+}
+
+func TestReferenceRegistry(t *testing.T) {
+	ref, err := ParseReference("ubuntu")
+	assert.NoError(t, err)
+	assert.Equal(t, "registry-1.docker.io", ref.Registry())
+	assert.Equal(t, "docker.io/library/ubuntu:latest", ref.String())
+
+	ref, err = ParseReference("harbor.example.com/golden/ubuntu@sha256:abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "harbor.example.com", ref.Registry())
+	assert.Equal(t, "sha256:abcd", ref.Identifier())
+}
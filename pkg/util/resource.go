@@ -0,0 +1,42 @@
+package util
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// GetPodRequests returns the resources requested by the containers and the overhead of the pod
+func GetPodRequests(pod *corev1.Pod) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range pod.Spec.Containers {
+		AddResourceList(requests, container.Resources.Requests)
+	}
+	AddResourceList(requests, pod.Spec.Overhead)
+	return requests
+}
+
+// AddResourceList adds the quantities of toAdd to the list
+func AddResourceList(list, toAdd corev1.ResourceList) {
+	for name, quantity := range toAdd {
+		if value, ok := list[name]; ok {
+			value.Add(quantity)
+			list[name] = value
+		} else {
+			list[name] = quantity.DeepCopy()
+		}
+	}
+}
+
+// IsPodTerminated checks if the pod has stopped and doesn't take resources of the node
+func IsPodTerminated(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+}
+
+// GetOvercommitRequest returns the CPU or memory request of a VM lowered from its limit by the overcommit percentage
+// of the overcommit-config setting, the memory request is truncated to MiB.
+func GetOvercommitRequest(name corev1.ResourceName, limit resource.Quantity, overcommit int) resource.Quantity {
+	if name == corev1.ResourceCPU {
+		return *resource.NewMilliQuantity(limit.MilliValue()*int64(100)/int64(overcommit), limit.Format)
+	}
+	return *resource.NewQuantity(limit.Value()*int64(100)/int64(overcommit)/1048576*1048576, limit.Format)
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"encoding/json"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	kv1 "kubevirt.io/client-go/api/v1"
+)
+
+const (
+	PowerOperationShutdown   = "shutdown"
+	PowerOperationSoftReboot = "softReboot"
+)
+
+// PowerOperation is a graceful shutdown or reboot in progress, it's saved in the AnnotationPowerOperation annotation
+// of the VM until the VMI it was requested on stops.
+type PowerOperation struct {
+	Operation      string      `json:"operation"`
+	TimeoutSeconds int64       `json:"timeoutSeconds"`
+	RequestedAt    metav1.Time `json:"requestedAt"`
+	VMIUID         types.UID   `json:"vmiUID"`
+	// Escalated is set once the timeout passed and the VM is forced off
+	Escalated bool `json:"escalated,omitempty"`
+}
+
+// Deadline is the time the guest has to shut down before it's forced off
+func (op *PowerOperation) Deadline() time.Time {
+	return op.RequestedAt.Add(time.Duration(op.TimeoutSeconds) * time.Second)
+}
+
+// GetPowerOperation returns the power operation in progress on the VM, or nil if there is none
+func GetPowerOperation(vm *kv1.VirtualMachine) (*PowerOperation, error) {
+	value, ok := vm.Annotations[AnnotationPowerOperation]
+	if !ok || value == "" {
+		return nil, nil
+	}
+	op := &PowerOperation{}
+	if err := json.Unmarshal([]byte(value), op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// SetPowerOperation saves the power operation to the VM, a nil operation removes it
+func SetPowerOperation(vm *kv1.VirtualMachine, op *PowerOperation) error {
+	if op == nil {
+		delete(vm.Annotations, AnnotationPowerOperation)
+		return nil
+	}
+	value, err := json.Marshal(op)
+	if err != nil {
+		return err
+	}
+	if vm.Annotations == nil {
+		vm.Annotations = make(map[string]string)
+	}
+	vm.Annotations[AnnotationPowerOperation] = string(value)
+	return nil
+}
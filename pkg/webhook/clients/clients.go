@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/rancher/wrangler/pkg/clients"
+	ctlstoragev1 "github.com/rancher/wrangler/pkg/generated/controllers/storage"
 	"github.com/rancher/wrangler/pkg/schemes"
 	v1 "k8s.io/api/admissionregistration/v1"
 	"k8s.io/client-go/rest"
@@ -21,6 +22,7 @@ type Clients struct {
 	KubevirtFactory  *ctlkubevirtv1.Factory
 	CNIFactory       *ctlcniv1.Factory
 	SnapshotFactory  *ctlsnapshotv1.Factory
+	StorageFactory   *ctlstoragev1.Factory
 }
 
 func New(ctx context.Context, rest *rest.Config, threadiness int) (*Clients, error) {
@@ -69,11 +71,21 @@ func New(ctx context.Context, rest *rest.Config, threadiness int) (*Clients, err
 		return nil, err
 	}
 
+	storageFactory, err := ctlstoragev1.NewFactoryFromConfigWithOptions(rest, clients.FactoryOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = storageFactory.Start(ctx, threadiness); err != nil {
+		return nil, err
+	}
+
 	return &Clients{
 		Clients:          *clients,
 		HarvesterFactory: harvesterFactory,
 		KubevirtFactory:  kubevirtFactory,
 		CNIFactory:       cniFactory,
 		SnapshotFactory:  snapshotFactory,
+		StorageFactory:   storageFactory,
 	}, nil
 }
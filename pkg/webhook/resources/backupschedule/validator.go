@@ -0,0 +1,78 @@
+package backupschedule
+
+import (
+	"fmt"
+
+	"github.com/robfig/cron"
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	werror "github.com/harvester/harvester/pkg/webhook/error"
+	"github.com/harvester/harvester/pkg/webhook/types"
+)
+
+const (
+	fieldSchedule = "spec.schedule"
+	fieldVMNames  = "spec.vmNames"
+	fieldSelector = "spec.selector"
+	fieldRetain   = "spec.retain"
+	fieldMaxAge   = "spec.maxAge"
+)
+
+func NewValidator() types.Validator {
+	return &backupScheduleValidator{}
+}
+
+type backupScheduleValidator struct {
+	types.DefaultValidator
+}
+
+func (v *backupScheduleValidator) Resource() types.Resource {
+	return types.Resource{
+		Names:      []string{v1beta1.VirtualMachineBackupScheduleResourceName},
+		Scope:      admissionregv1.NamespacedScope,
+		APIGroup:   v1beta1.SchemeGroupVersion.Group,
+		APIVersion: v1beta1.SchemeGroupVersion.Version,
+		ObjectType: &v1beta1.VirtualMachineBackupSchedule{},
+		OperationTypes: []admissionregv1.OperationType{
+			admissionregv1.Create,
+			admissionregv1.Update,
+		},
+	}
+}
+
+func (v *backupScheduleValidator) Create(request *types.Request, newObj runtime.Object) error {
+	return v.checkSpec(newObj.(*v1beta1.VirtualMachineBackupSchedule))
+}
+
+func (v *backupScheduleValidator) Update(request *types.Request, oldObj runtime.Object, newObj runtime.Object) error {
+	return v.checkSpec(newObj.(*v1beta1.VirtualMachineBackupSchedule))
+}
+
+func (v *backupScheduleValidator) checkSpec(schedule *v1beta1.VirtualMachineBackupSchedule) error {
+	if _, err := cron.ParseStandard(schedule.Spec.Schedule); err != nil {
+		return werror.NewInvalidError(fmt.Sprintf("invalid cron expression %q: %v", schedule.Spec.Schedule, err), fieldSchedule)
+	}
+
+	if len(schedule.Spec.VMNames) == 0 && schedule.Spec.Selector == nil {
+		return werror.NewInvalidError("either vmNames or selector is required", fieldVMNames)
+	}
+
+	if schedule.Spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(schedule.Spec.Selector); err != nil {
+			return werror.NewInvalidError(err.Error(), fieldSelector)
+		}
+	}
+
+	if schedule.Spec.Retain < 0 {
+		return werror.NewInvalidError("retain can't be negative", fieldRetain)
+	}
+
+	if schedule.Spec.MaxAge != nil && schedule.Spec.MaxAge.Duration <= 0 {
+		return werror.NewInvalidError("maxAge must be positive", fieldMaxAge)
+	}
+
+	return nil
+}
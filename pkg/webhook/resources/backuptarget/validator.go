@@ -0,0 +1,107 @@
+package backuptarget
+
+import (
+	"fmt"
+	"strings"
+
+	admissionregv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
+	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	werror "github.com/harvester/harvester/pkg/webhook/error"
+	"github.com/harvester/harvester/pkg/webhook/types"
+)
+
+const (
+	fieldType                = "spec.type"
+	fieldEndpoint            = "spec.endpoint"
+	fieldBucketName          = "spec.bucketName"
+	fieldBucketRegion        = "spec.bucketRegion"
+	fieldCredentialSecret    = "spec.credentialSecret"
+	fieldEncryptionKeySecret = "spec.encryptionKeySecret"
+)
+
+func NewValidator(vmBackups ctlharvesterv1.VirtualMachineBackupCache) types.Validator {
+	return &backupTargetValidator{
+		vmBackups: vmBackups,
+	}
+}
+
+type backupTargetValidator struct {
+	types.DefaultValidator
+
+	vmBackups ctlharvesterv1.VirtualMachineBackupCache
+}
+
+func (v *backupTargetValidator) Resource() types.Resource {
+	return types.Resource{
+		Names:      []string{v1beta1.BackupTargetResourceName},
+		Scope:      admissionregv1.ClusterScope,
+		APIGroup:   v1beta1.SchemeGroupVersion.Group,
+		APIVersion: v1beta1.SchemeGroupVersion.Version,
+		ObjectType: &v1beta1.BackupTarget{},
+		OperationTypes: []admissionregv1.OperationType{
+			admissionregv1.Create,
+			admissionregv1.Update,
+			admissionregv1.Delete,
+		},
+	}
+}
+
+func (v *backupTargetValidator) Create(request *types.Request, newObj runtime.Object) error {
+	return v.checkSpec(newObj.(*v1beta1.BackupTarget))
+}
+
+func (v *backupTargetValidator) Update(request *types.Request, oldObj runtime.Object, newObj runtime.Object) error {
+	return v.checkSpec(newObj.(*v1beta1.BackupTarget))
+}
+
+func (v *backupTargetValidator) Delete(request *types.Request, oldObj runtime.Object) error {
+	backupTarget := oldObj.(*v1beta1.BackupTarget)
+
+	vmBackups, err := v.vmBackups.List("", labels.Everything())
+	if err != nil {
+		return err
+	}
+	var users []string
+	for _, vmBackup := range vmBackups {
+		if vmBackup.Status != nil && vmBackup.Status.BackupTarget != nil && vmBackup.Status.BackupTarget.Name == backupTarget.Name {
+			users = append(users, fmt.Sprintf("backup %s/%s", vmBackup.Namespace, vmBackup.Name))
+		}
+	}
+
+	if len(users) > 0 {
+		return werror.NewBadRequest(fmt.Sprintf("backup target %s is still used by %s", backupTarget.Name, strings.Join(users, ", ")))
+	}
+	return nil
+}
+
+func (v *backupTargetValidator) checkSpec(backupTarget *v1beta1.BackupTarget) error {
+	if backupTarget.Spec.Endpoint == "" {
+		return werror.NewInvalidError("endpoint is required", fieldEndpoint)
+	}
+
+	if secretRef := backupTarget.Spec.EncryptionKeySecret; secretRef != nil && (secretRef.Namespace == "" || secretRef.Name == "") {
+		return werror.NewInvalidError("encryption key secret namespace and name are required", fieldEncryptionKeySecret)
+	}
+
+	switch backupTarget.Spec.Type {
+	case v1beta1.BackupTargetTypeNFS:
+		return nil
+	case v1beta1.BackupTargetTypeS3:
+		if backupTarget.Spec.BucketName == "" {
+			return werror.NewInvalidError("bucket name is required for S3 backup target", fieldBucketName)
+		}
+		if backupTarget.Spec.BucketRegion == "" {
+			return werror.NewInvalidError("bucket region is required for S3 backup target", fieldBucketRegion)
+		}
+		if secretRef := backupTarget.Spec.CredentialSecret; secretRef == nil || secretRef.Namespace == "" || secretRef.Name == "" {
+			return werror.NewInvalidError("credential secret namespace and name are required for S3 backup target", fieldCredentialSecret)
+		}
+		return nil
+	default:
+		return werror.NewInvalidError(fmt.Sprintf("unsupported backup target type %q", backupTarget.Spec.Type), fieldType)
+	}
+}
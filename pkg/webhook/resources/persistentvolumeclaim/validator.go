@@ -12,6 +12,7 @@ import (
 
 	ctlkv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
 	"github.com/harvester/harvester/pkg/ref"
+	"github.com/harvester/harvester/pkg/util"
 	werror "github.com/harvester/harvester/pkg/webhook/error"
 	"github.com/harvester/harvester/pkg/webhook/types"
 )
@@ -37,12 +38,44 @@ func (v *pvcValidator) Resource() types.Resource {
 		APIVersion: corev1.SchemeGroupVersion.Version,
 		ObjectType: &corev1.PersistentVolumeClaim{},
 		OperationTypes: []admissionregv1.OperationType{
+			admissionregv1.Create,
 			admissionregv1.Delete,
 			admissionregv1.Update,
 		},
 	}
 }
 
+// controllerAnnotations are the annotations driving the controllers which only the Harvester controller can set,
+// e.g. the cloneTarget annotation makes the VM clone volume controller move the volume to another namespace.
+var controllerAnnotations = []string{
+	util.AnnotationCloneTarget,
+	util.AnnotationCloneTargetUID,
+}
+
+func (v *pvcValidator) Create(request *types.Request, newObj runtime.Object) error {
+	return checkControllerAnnotations(request, nil, newObj.(*corev1.PersistentVolumeClaim))
+}
+
+// checkControllerAnnotations rejects the controller annotations added or changed by the other users
+func checkControllerAnnotations(request *types.Request, oldPVC, newPVC *corev1.PersistentVolumeClaim) error {
+	if request.IsFromController() {
+		return nil
+	}
+	for _, key := range controllerAnnotations {
+		value, ok := newPVC.Annotations[key]
+		if !ok {
+			continue
+		}
+		if oldPVC != nil {
+			if oldValue, oldOK := oldPVC.Annotations[key]; oldOK && oldValue == value {
+				continue
+			}
+		}
+		return werror.NewInvalidError(fmt.Sprintf("annotation %s can only be set by Harvester", key), "metadata.annotations")
+	}
+	return nil
+}
+
 func (v *pvcValidator) Delete(request *types.Request, oldObj runtime.Object) error {
 	if request.IsGarbageCollection() {
 		return nil
@@ -88,6 +121,10 @@ func (v *pvcValidator) Update(request *types.Request, oldObj runtime.Object, new
 	oldPVC := oldObj.(*corev1.PersistentVolumeClaim)
 	newPVC := newObj.(*corev1.PersistentVolumeClaim)
 
+	if err := checkControllerAnnotations(request, oldPVC, newPVC); err != nil {
+		return err
+	}
+
 	newQuantity := newPVC.Spec.Resources.Requests.Storage()
 	oldQuantity := oldPVC.Spec.Resources.Requests.Storage()
 	if oldQuantity.Cmp(*newQuantity) == 0 {
@@ -0,0 +1,92 @@
+package persistentvolumeclaim
+
+import (
+	"testing"
+
+	"github.com/rancher/wrangler/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/harvester/harvester/pkg/util"
+	"github.com/harvester/harvester/pkg/webhook/config"
+	"github.com/harvester/harvester/pkg/webhook/types"
+)
+
+const controllerUsername = "system:serviceaccount:harvester-system:harvester"
+
+func newTestRequest(username string) *types.Request {
+	return types.NewRequest(&webhook.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UserInfo: authenticationv1.UserInfo{Username: username},
+		},
+	}, &config.Options{HarvesterControllerUsername: controllerUsername})
+}
+
+func newTestPVC(annotations map[string]string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "disk-0",
+			Annotations: annotations,
+		},
+	}
+}
+
+func TestCheckControllerAnnotations(t *testing.T) {
+	cloneAnnotations := map[string]string{
+		util.AnnotationCloneTarget:    "other/vm",
+		util.AnnotationCloneTargetUID: "vm-uid",
+	}
+	var testCases = []struct {
+		name      string
+		username  string
+		oldPVC    *corev1.PersistentVolumeClaim
+		newPVC    *corev1.PersistentVolumeClaim
+		expectErr bool
+	}{
+		{
+			name:     "created by the controller",
+			username: controllerUsername,
+			newPVC:   newTestPVC(cloneAnnotations),
+		},
+		{
+			name:      "created by a user",
+			username:  "user",
+			newPVC:    newTestPVC(cloneAnnotations),
+			expectErr: true,
+		},
+		{
+			name:     "created by a user without the annotations",
+			username: "user",
+			newPVC:   newTestPVC(map[string]string{util.AnnotationImageID: "default/image"}),
+		},
+		{
+			name:      "annotated by a user",
+			username:  "user",
+			oldPVC:    newTestPVC(nil),
+			newPVC:    newTestPVC(map[string]string{util.AnnotationCloneTarget: "other/vm"}),
+			expectErr: true,
+		},
+		{
+			name:      "target changed by a user",
+			username:  "user",
+			oldPVC:    newTestPVC(cloneAnnotations),
+			newPVC:    newTestPVC(map[string]string{util.AnnotationCloneTarget: "another/vm", util.AnnotationCloneTargetUID: "vm-uid"}),
+			expectErr: true,
+		},
+		{
+			name:     "other changes by a user",
+			username: "user",
+			oldPVC:   newTestPVC(cloneAnnotations),
+			newPVC:   newTestPVC(map[string]string{util.AnnotationCloneTarget: "other/vm", util.AnnotationCloneTargetUID: "vm-uid", "note": "clone"}),
+		},
+	}
+
+	for _, tc := range testCases {
+		err := checkControllerAnnotations(newTestRequest(tc.username), tc.oldPVC, tc.newPVC)
+		assert.Equal(t, tc.expectErr, err != nil, "case %q", tc.name)
+	}
+}
@@ -4,43 +4,64 @@ import (
 	"errors"
 	"fmt"
 
+	ctlstoragev1 "github.com/rancher/wrangler/pkg/generated/controllers/storage/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	kv1 "kubevirt.io/client-go/api/v1"
 
 	"github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	ctlbackup "github.com/harvester/harvester/pkg/controller/master/backup"
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	ctlcniv1 "github.com/harvester/harvester/pkg/generated/controllers/k8s.cni.cncf.io/v1"
 	ctlkubevirtv1 "github.com/harvester/harvester/pkg/generated/controllers/kubevirt.io/v1"
+	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
 	werror "github.com/harvester/harvester/pkg/webhook/error"
 	"github.com/harvester/harvester/pkg/webhook/types"
 )
 
 const (
-	fieldTargetName               = "spec.target.name"
-	fieldVirtualMachineBackupName = "spec.virtualMachineBackupName"
-	fieldNewVM                    = "spec.newVM"
+	fieldTargetName                 = "spec.target.name"
+	fieldVirtualMachineBackupName   = "spec.virtualMachineBackupName"
+	fieldVirtualMachineSnapshotName = "spec.virtualMachineSnapshotName"
+	fieldNewVM                      = "spec.newVM"
+	fieldMapping                    = "spec.mapping"
+	fieldVolumes                    = "spec.volumes"
+	fieldVolumeRestoreMode          = "spec.volumeRestoreMode"
 )
 
 func NewValidator(
 	vms ctlkubevirtv1.VirtualMachineCache,
 	setting ctlharvesterv1.SettingCache,
 	vmBackup ctlharvesterv1.VirtualMachineBackupCache,
+	vmSnapshot ctlharvesterv1.VirtualMachineSnapshotCache,
+	images ctlharvesterv1.VirtualMachineImageCache,
+	storageClasses ctlstoragev1.StorageClassCache,
+	netAttachDefs ctlcniv1.NetworkAttachmentDefinitionCache,
 ) types.Validator {
 	return &restoreValidator{
-		vms:      vms,
-		setting:  setting,
-		vmBackup: vmBackup,
+		vms:            vms,
+		setting:        setting,
+		vmBackup:       vmBackup,
+		vmSnapshot:     vmSnapshot,
+		images:         images,
+		storageClasses: storageClasses,
+		netAttachDefs:  netAttachDefs,
 	}
 }
 
 type restoreValidator struct {
 	types.DefaultValidator
 
-	vms      ctlkubevirtv1.VirtualMachineCache
-	setting  ctlharvesterv1.SettingCache
-	vmBackup ctlharvesterv1.VirtualMachineBackupCache
+	vms            ctlkubevirtv1.VirtualMachineCache
+	setting        ctlharvesterv1.SettingCache
+	vmBackup       ctlharvesterv1.VirtualMachineBackupCache
+	vmSnapshot     ctlharvesterv1.VirtualMachineSnapshotCache
+	images         ctlharvesterv1.VirtualMachineImageCache
+	storageClasses ctlstoragev1.StorageClassCache
+	netAttachDefs  ctlcniv1.NetworkAttachmentDefinitionCache
 }
 
 func (v *restoreValidator) Resource() types.Resource {
@@ -61,17 +82,43 @@ func (v *restoreValidator) Create(request *types.Request, newObj runtime.Object)
 
 	targetVM := newRestore.Spec.Target.Name
 	backupName := newRestore.Spec.VirtualMachineBackupName
+	snapshotName := newRestore.Spec.VirtualMachineSnapshotName
 	newVM := newRestore.Spec.NewVM
 
 	if targetVM == "" {
 		return werror.NewInvalidError("target VM name is empty", fieldTargetName)
 	}
-	if backupName == "" {
-		return werror.NewInvalidError("backup name is empty", fieldVirtualMachineBackupName)
+
+	if snapshotName != "" {
+		if backupName != "" {
+			return werror.NewInvalidError("backup name and snapshot name can't be set at the same time", fieldVirtualMachineSnapshotName)
+		}
+		// snapshots are kept in the cluster, no backup target is needed
+		if err := v.checkVMSnapshot(newRestore); err != nil {
+			return werror.NewInvalidError(err.Error(), fieldVirtualMachineSnapshotName)
+		}
+		if newRestore.Spec.Mapping != nil {
+			return werror.NewInvalidError("mapping is only supported when restoring a backup", fieldMapping)
+		}
+	} else {
+		if backupName == "" {
+			return werror.NewInvalidError("backup name is empty", fieldVirtualMachineBackupName)
+		}
+		if err := v.checkBackupTarget(newRestore); err != nil {
+			return werror.NewInvalidError(err.Error(), fieldVirtualMachineBackupName)
+		}
+		if err := v.checkMapping(newRestore); err != nil {
+			return werror.NewInvalidError(err.Error(), fieldMapping)
+		}
 	}
 
-	if err := v.checkBackupTarget(newRestore); err != nil {
-		return werror.NewInvalidError(err.Error(), fieldVirtualMachineBackupName)
+	if err := v.checkVolumes(newRestore); err != nil {
+		return err
+	}
+
+	// standalone volumes are restored without touching the target vm, which may be removed already
+	if len(newRestore.Spec.Volumes) > 0 && newRestore.Spec.VolumeRestoreMode != v1beta1.VolumeRestoreReplace {
+		return nil
 	}
 
 	vm, err := v.vms.Get(newRestore.Namespace, targetVM)
@@ -92,9 +139,74 @@ func (v *restoreValidator) Create(request *types.Request, newObj runtime.Object)
 		return werror.NewInvalidError(fmt.Sprintf("please stop the VM %q before doing a restore", vm.Name), fieldTargetName)
 	}
 
+	// the replaced disks must be in the target vm
+	for _, volumeName := range newRestore.Spec.Volumes {
+		if !hasPVCVolume(vm, volumeName) {
+			return werror.NewInvalidError(fmt.Sprintf("VM %q has no volume %q to replace", vm.Name, volumeName), fieldVolumes)
+		}
+	}
+
+	return nil
+}
+
+// checkVolumes makes sure the volumes selected by a partial restore are in the backup
+func (v *restoreValidator) checkVolumes(vmRestore *v1beta1.VirtualMachineRestore) error {
+	mode := vmRestore.Spec.VolumeRestoreMode
+	if mode != "" && mode != v1beta1.VolumeRestoreStandalone && mode != v1beta1.VolumeRestoreReplace {
+		return werror.NewInvalidError(fmt.Sprintf("unknown volume restore mode %q", mode), fieldVolumeRestoreMode)
+	}
+	if len(vmRestore.Spec.Volumes) == 0 {
+		if mode != "" {
+			return werror.NewInvalidError("volume restore mode is only supported when restoring selected volumes", fieldVolumeRestoreMode)
+		}
+		return nil
+	}
+	if vmRestore.Spec.NewVM {
+		return werror.NewInvalidError("selected volumes can't be restored to a new VM", fieldNewVM)
+	}
+
+	var volumeBackups []v1beta1.VolumeBackup
+	if vmRestore.Spec.VirtualMachineSnapshotName != "" {
+		vmSnapshot, err := v.vmSnapshot.Get(vmRestore.Namespace, vmRestore.Spec.VirtualMachineSnapshotName)
+		if err != nil {
+			return werror.NewInvalidError(err.Error(), fieldVirtualMachineSnapshotName)
+		}
+		volumeBackups = vmSnapshot.Status.VolumeSnapshots
+	} else {
+		vmBackup, err := v.vmBackup.Get(vmRestore.Spec.VirtualMachineBackupNamespace, vmRestore.Spec.VirtualMachineBackupName)
+		if err != nil {
+			return werror.NewInvalidError(err.Error(), fieldVirtualMachineBackupName)
+		}
+		volumeBackups = vmBackup.Status.VolumeBackups
+	}
+
+	for _, volumeName := range vmRestore.Spec.Volumes {
+		found := false
+		for _, volumeBackup := range volumeBackups {
+			if volumeBackup.VolumeName == volumeName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return werror.NewInvalidError(fmt.Sprintf("volume %q is not in the backup", volumeName), fieldVolumes)
+		}
+	}
 	return nil
 }
 
+func hasPVCVolume(vm *kv1.VirtualMachine, volumeName string) bool {
+	if vm.Spec.Template == nil {
+		return false
+	}
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		if volume.Name == volumeName && volume.PersistentVolumeClaim != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *restoreValidator) checkBackupTarget(vmRestore *v1beta1.VirtualMachineRestore) error {
 	// get backup target
 	backupTargetSetting, err := v.setting.Get(settings.BackupTargetSettingName)
@@ -123,3 +235,114 @@ func (v *restoreValidator) checkBackupTarget(vmRestore *v1beta1.VirtualMachineRe
 
 	return nil
 }
+
+func (v *restoreValidator) checkVMSnapshot(vmRestore *v1beta1.VirtualMachineRestore) error {
+	vmSnapshot, err := v.vmSnapshot.Get(vmRestore.Namespace, vmRestore.Spec.VirtualMachineSnapshotName)
+	if err != nil {
+		return fmt.Errorf("can't get vmsnapshot %s/%s, err: %w", vmRestore.Namespace, vmRestore.Spec.VirtualMachineSnapshotName, err)
+	}
+
+	if vmSnapshot.Status == nil || vmSnapshot.Status.ReadyToUse == nil || !*vmSnapshot.Status.ReadyToUse {
+		return fmt.Errorf("VM Snapshot %s is not ready", vmSnapshot.Name)
+	}
+
+	return nil
+}
+
+// checkMapping makes sure every mapping target exists and every storage class, network and image referenced
+// by the backup can be resolved in this cluster, so that a restore from another cluster fails up front.
+// The references are checked without a mapping too, as they are all expected to exist with the same names then.
+func (v *restoreValidator) checkMapping(vmRestore *v1beta1.VirtualMachineRestore) error {
+	if mapping := vmRestore.Spec.Mapping; mapping != nil {
+		for source, target := range mapping.StorageClasses {
+			if err := v.checkStorageClass(target); err != nil {
+				return fmt.Errorf("invalid storage class mapping %s: %w", source, err)
+			}
+		}
+		for source, target := range mapping.Networks {
+			if err := v.checkNetwork(vmRestore.Namespace, target); err != nil {
+				return fmt.Errorf("invalid network mapping %s: %w", source, err)
+			}
+		}
+		for source, target := range mapping.Images {
+			if err := v.checkImage(target); err != nil {
+				return fmt.Errorf("invalid image mapping %s: %w", source, err)
+			}
+		}
+		for source, target := range mapping.Namespaces {
+			if source == "" || target == "" {
+				return fmt.Errorf("invalid namespace mapping %q to %q", source, target)
+			}
+		}
+	}
+
+	vmBackup, err := v.vmBackup.Get(vmRestore.Spec.VirtualMachineBackupNamespace, vmRestore.Spec.VirtualMachineBackupName)
+	if err != nil {
+		return fmt.Errorf("can't get vmbackup %s/%s, err: %w", vmRestore.Spec.VirtualMachineBackupNamespace, vmRestore.Spec.VirtualMachineBackupName, err)
+	}
+
+	for _, volumeBackup := range vmBackup.Status.VolumeBackups {
+		pvc := volumeBackup.PersistentVolumeClaim
+		if imageID, ok := pvc.ObjectMeta.Annotations[util.AnnotationImageID]; ok {
+			if targetImageID, mapped := ctlbackup.GetRestoreImageID(vmRestore, imageID); mapped {
+				if err := v.checkImage(targetImageID); err != nil {
+					return fmt.Errorf("volume %s: %w", volumeBackup.VolumeName, err)
+				}
+				continue
+			}
+		}
+		if pvc.Spec.StorageClassName == nil {
+			continue
+		}
+		storageClassName := ctlbackup.GetRestoreStorageClassName(vmRestore, *pvc.Spec.StorageClassName)
+		if err := v.checkStorageClass(storageClassName); err != nil {
+			return fmt.Errorf("volume %s: %w", volumeBackup.VolumeName, err)
+		}
+	}
+
+	if vmBackup.Status.SourceSpec == nil || vmBackup.Status.SourceSpec.Spec.Template == nil {
+		return nil
+	}
+	for _, network := range vmBackup.Status.SourceSpec.Spec.Template.Spec.Networks {
+		if network.Multus == nil {
+			continue
+		}
+		networkName := ctlbackup.GetRestoreNetworkName(vmRestore, network.Multus.NetworkName)
+		if err := v.checkNetwork(vmRestore.Namespace, networkName); err != nil {
+			return fmt.Errorf("network %s: %w", network.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (v *restoreValidator) checkStorageClass(name string) error {
+	if _, err := v.storageClasses.Get(name); err != nil {
+		return fmt.Errorf("can't get storage class %s, err: %w", name, err)
+	}
+	return nil
+}
+
+func (v *restoreValidator) checkNetwork(vmNamespace, networkName string) error {
+	namespace, name := ref.Parse(networkName)
+	if namespace == "" {
+		// a network name without namespace refers to the namespace of the VM
+		namespace = vmNamespace
+	}
+	if _, err := v.netAttachDefs.Get(namespace, name); err != nil {
+		return fmt.Errorf("can't get network %s/%s, err: %w", namespace, name, err)
+	}
+	return nil
+}
+
+func (v *restoreValidator) checkImage(imageID string) error {
+	namespace, name := ref.Parse(imageID)
+	image, err := v.images.Get(namespace, name)
+	if err != nil {
+		return fmt.Errorf("can't get image %s, err: %w", imageID, err)
+	}
+	if image.Status.StorageClassName == "" {
+		return fmt.Errorf("image %s has no storage class", imageID)
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 
@@ -44,14 +45,19 @@ var supportedSSLProtocols = []string{"SSLv2", "SSLv3", "TLSv1", "TLSv1.1", "TLSv
 type validateSettingFunc func(setting *v1beta1.Setting) error
 
 var validateSettingFuncs = map[string]validateSettingFunc{
-	settings.HttpProxySettingName:            validateHTTPProxy,
-	settings.VMForceResetPolicySettingName:   validateVMForceResetPolicy,
-	settings.SupportBundleImageName:          validateSupportBundleImage,
-	settings.SupportBundleTimeoutSettingName: validateSupportBundleTimeout,
-	settings.OvercommitConfigSettingName:     validateOvercommitConfig,
-	settings.VipPoolsConfigSettingName:       validateVipPoolsConfig,
-	settings.SSLCertificatesSettingName:      validateSSLCertificates,
-	settings.SSLParametersName:               validateSSLParameters,
+	settings.HttpProxySettingName:              validateHTTPProxy,
+	settings.VMForceResetPolicySettingName:     validateVMForceResetPolicy,
+	settings.VMRebalanceConfigSettingName:      validateVMRebalanceConfig,
+	settings.ImageGCConfigSettingName:          validateImageGCConfig,
+	settings.SupportBundleImageName:            validateSupportBundleImage,
+	settings.SupportBundleTimeoutSettingName:   validateSupportBundleTimeout,
+	settings.BackupFreezeTimeoutSettingName:    validateBackupFreezeTimeout,
+	settings.BackupVerifyIntervalSettingName:   validateBackupVerifyInterval,
+	settings.MaintenanceConcurrencySettingName: validateMaintenanceConcurrency,
+	settings.OvercommitConfigSettingName:       validateOvercommitConfig,
+	settings.VipPoolsConfigSettingName:         validateVipPoolsConfig,
+	settings.SSLCertificatesSettingName:        validateSSLCertificates,
+	settings.SSLParametersName:                 validateSSLParameters,
 }
 
 func NewValidator(
@@ -66,6 +72,7 @@ func NewValidator(
 	}
 	validateSettingFuncs[settings.BackupTargetSettingName] = validator.validateBackupTarget
 	validateSettingFuncs[settings.VolumeSnapshotClassSettingName] = validator.validateVolumeSnapshotClass
+	validateSettingFuncs[settings.VMSnapshotClassSettingName] = validator.validateVMSnapshotClass
 	return validator
 }
 
@@ -156,6 +163,45 @@ func validateVMForceResetPolicy(setting *v1beta1.Setting) error {
 	return nil
 }
 
+func validateVMRebalanceConfig(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+
+	config, err := settings.DecodeVMRebalanceConfig(setting.Value)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "value")
+	}
+	if config.Threshold < 1 || config.Threshold > 100 {
+		return werror.NewInvalidError("threshold must be between 1 and 100", "value")
+	}
+	if config.Interval < 1 {
+		return werror.NewInvalidError("interval must be at least 1 second", "value")
+	}
+	if config.MaxConcurrentMigrations < 1 {
+		return werror.NewInvalidError("maxConcurrentMigrations must be at least 1", "value")
+	}
+	return nil
+}
+
+func validateImageGCConfig(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+
+	config, err := settings.DecodeImageGCConfig(setting.Value)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "value")
+	}
+	if config.UnusedDays < 1 {
+		return werror.NewInvalidError("unusedDays must be at least 1", "value")
+	}
+	if config.Action != settings.ImageGCActionFlag && config.Action != settings.ImageGCActionDelete {
+		return werror.NewInvalidError(fmt.Sprintf("action must be %s or %s", settings.ImageGCActionFlag, settings.ImageGCActionDelete), "value")
+	}
+	return nil
+}
+
 // chech if this backup target is updated again by controller to strip secret information
 func (v *settingValidator) isUpdatedS3BackupTarget(target *settings.BackupTarget) bool {
 	if target.Type != settings.S3BackupType || target.SecretAccessKey != "" || target.AccessKeyID != "" {
@@ -168,7 +214,8 @@ func (v *settingValidator) isUpdatedS3BackupTarget(target *settings.BackupTarget
 		return false
 	} else {
 		// when any of those fields is different, then it is from user input, not from internal re-config
-		if savedTarget.Type != target.Type || savedTarget.BucketName != target.BucketName || savedTarget.BucketRegion != target.BucketRegion || savedTarget.Endpoint != target.Endpoint || savedTarget.VirtualHostedStyle != target.VirtualHostedStyle {
+		if savedTarget.Type != target.Type || savedTarget.BucketName != target.BucketName || savedTarget.BucketRegion != target.BucketRegion || savedTarget.Endpoint != target.Endpoint || savedTarget.VirtualHostedStyle != target.VirtualHostedStyle ||
+			!reflect.DeepEqual(savedTarget.EncryptionKeySecret, target.EncryptionKeySecret) {
 			return false
 		}
 	}
@@ -206,6 +253,10 @@ func (v *settingValidator) validateBackupTargetFields(target *settings.BackupTar
 		return werror.NewInvalidError("Invalid backup target type", "value")
 	}
 
+	if secretRef := target.EncryptionKeySecret; secretRef != nil && (secretRef.Namespace == "" || secretRef.Name == "") {
+		return werror.NewInvalidError("encryption key secret should have namespace and name", "value")
+	}
+
 	return nil
 }
 
@@ -337,6 +388,51 @@ func validateSupportBundleTimeout(setting *v1beta1.Setting) error {
 	return nil
 }
 
+func validateBackupFreezeTimeout(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(setting.Value)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "value")
+	}
+	if i < 0 {
+		return werror.NewInvalidError("timeout can't be negative", "value")
+	}
+	return nil
+}
+
+func validateBackupVerifyInterval(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(setting.Value)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "value")
+	}
+	if i < 0 {
+		return werror.NewInvalidError("interval can't be negative", "value")
+	}
+	return nil
+}
+
+func validateMaintenanceConcurrency(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(setting.Value)
+	if err != nil {
+		return werror.NewInvalidError(err.Error(), "value")
+	}
+	if i < 1 {
+		return werror.NewInvalidError("concurrency must be at least 1", "value")
+	}
+	return nil
+}
+
 func validateSSLCertificates(setting *v1beta1.Setting) error {
 	if setting.Value == "" {
 		return nil
@@ -450,3 +546,15 @@ func (v *settingValidator) validateVolumeSnapshotClass(setting *v1beta1.Setting)
 	_, err := v.snapshotClassCache.Get(setting.Value)
 	return err
 }
+
+// validateVMSnapshotClass makes sure the VM snapshots stay in the cluster rather than being uploaded as backups
+func (v *settingValidator) validateVMSnapshotClass(setting *v1beta1.Setting) error {
+	if setting.Value == "" {
+		return nil
+	}
+	sc, err := v.snapshotClassCache.Get(setting.Value)
+	if err != nil {
+		return err
+	}
+	return backup.CheckVMSnapshotClassParameters(sc)
+}
@@ -121,6 +121,149 @@ func Test_validateSupportBundleTimeout(t *testing.T) {
 	}
 }
 
+func Test_validateBackupFreezeTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{name: "invalid int", value: "not int", expectedErr: true},
+		{name: "negative int", value: "-1", expectedErr: true},
+		{name: "input 0 disables freezing", value: "0", expectedErr: false},
+		{name: "empty input", value: "", expectedErr: false},
+		{name: "positive int", value: "60", expectedErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackupFreezeTimeout(&v1beta1.Setting{
+				ObjectMeta: v1.ObjectMeta{Name: settings.BackupFreezeTimeoutSettingName},
+				Value:      tt.value,
+			})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateBackupVerifyInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{name: "invalid int", value: "not int", expectedErr: true},
+		{name: "negative int", value: "-1", expectedErr: true},
+		{name: "input 0 disables periodic verification", value: "0", expectedErr: false},
+		{name: "empty input", value: "", expectedErr: false},
+		{name: "positive int", value: "24", expectedErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBackupVerifyInterval(&v1beta1.Setting{
+				ObjectMeta: v1.ObjectMeta{Name: settings.BackupVerifyIntervalSettingName},
+				Value:      tt.value,
+			})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateMaintenanceConcurrency(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{name: "invalid int", value: "not int", expectedErr: true},
+		{name: "negative int", value: "-1", expectedErr: true},
+		{name: "input 0", value: "0", expectedErr: true},
+		{name: "empty input", value: "", expectedErr: false},
+		{name: "positive int", value: "3", expectedErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMaintenanceConcurrency(&v1beta1.Setting{
+				ObjectMeta: v1.ObjectMeta{Name: settings.MaintenanceConcurrencySettingName},
+				Value:      tt.value,
+			})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateVMRebalanceConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{name: "invalid json", value: "{", expectedErr: true},
+		{name: "threshold 0", value: `{"enable":true,"threshold":0,"interval":300,"maxConcurrentMigrations":1}`, expectedErr: true},
+		{name: "threshold over 100", value: `{"enable":true,"threshold":101,"interval":300,"maxConcurrentMigrations":1}`, expectedErr: true},
+		{name: "interval 0", value: `{"enable":true,"threshold":20,"interval":0,"maxConcurrentMigrations":1}`, expectedErr: true},
+		{name: "no concurrent migrations", value: `{"enable":true,"threshold":20,"interval":300,"maxConcurrentMigrations":0}`, expectedErr: true},
+		{name: "empty input", value: "", expectedErr: false},
+		{name: "valid config", value: `{"enable":true,"threshold":30,"interval":600,"maxConcurrentMigrations":2}`, expectedErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateVMRebalanceConfig(&v1beta1.Setting{
+				ObjectMeta: v1.ObjectMeta{Name: settings.VMRebalanceConfigSettingName},
+				Value:      tt.value,
+			})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
+func Test_validateImageGCConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectedErr bool
+	}{
+		{name: "invalid json", value: "{", expectedErr: true},
+		{name: "unused days 0", value: `{"enable":true,"unusedDays":0,"action":"flag"}`, expectedErr: true},
+		{name: "unknown action", value: `{"enable":true,"unusedDays":30,"action":"archive"}`, expectedErr: true},
+		{name: "empty input", value: "", expectedErr: false},
+		{name: "valid flag config", value: `{"enable":true,"unusedDays":30,"action":"flag"}`, expectedErr: false},
+		{name: "valid delete config", value: `{"enable":true,"unusedDays":90,"action":"delete"}`, expectedErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImageGCConfig(&v1beta1.Setting{
+				ObjectMeta: v1.ObjectMeta{Name: settings.ImageGCConfigSettingName},
+				Value:      tt.value,
+			})
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+}
+
 func Test_validateSSLProtocols(t *testing.T) {
 	tests := []struct {
 		name        string
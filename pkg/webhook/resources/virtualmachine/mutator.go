@@ -13,6 +13,7 @@ import (
 
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
 	"github.com/harvester/harvester/pkg/settings"
+	"github.com/harvester/harvester/pkg/util"
 	"github.com/harvester/harvester/pkg/webhook/types"
 )
 
@@ -97,22 +98,19 @@ func (m *vmMutator) patchResourceOvercommit(vm *kubevirtv1.VirtualMachine) ([]st
 	}
 
 	if !cpu.IsZero() {
-		newRequest := cpu.MilliValue() * int64(100) / int64(overcommit.Cpu)
-		quantity := resource.NewMilliQuantity(newRequest, cpu.Format)
+		quantity := util.GetOvercommitRequest(v1.ResourceCPU, *cpu, overcommit.Cpu)
 		if requestsMissing {
-			requestsToMutate[v1.ResourceCPU] = *quantity
+			requestsToMutate[v1.ResourceCPU] = quantity
 		} else {
-			patchOps = append(patchOps, fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/domain/resources/requests/cpu", "value": "%s"}`, quantity))
+			patchOps = append(patchOps, fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/domain/resources/requests/cpu", "value": "%s"}`, quantity.String()))
 		}
 	}
 	if !mem.IsZero() {
-		// Truncate to MiB
-		newRequest := mem.Value() * int64(100) / int64(overcommit.Memory) / 1048576 * 1048576
-		quantity := resource.NewQuantity(newRequest, mem.Format)
+		quantity := util.GetOvercommitRequest(v1.ResourceMemory, *mem, overcommit.Memory)
 		if requestsMissing {
-			requestsToMutate[v1.ResourceMemory] = *quantity
+			requestsToMutate[v1.ResourceMemory] = quantity
 		} else {
-			patchOps = append(patchOps, fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/domain/resources/requests/memory", "value": "%s"}`, quantity))
+			patchOps = append(patchOps, fmt.Sprintf(`{"op": "replace", "path": "/spec/template/spec/domain/resources/requests/memory", "value": "%s"}`, quantity.String()))
 		}
 		// Reserve 100MiB (104857600 Bytes) for QEMU on guest memory
 		// Ref: https://github.com/harvester/harvester/issues/1234
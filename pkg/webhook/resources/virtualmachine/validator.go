@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
 
 	v1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	admissionregv1 "k8s.io/api/admissionregistration/v1"
@@ -12,6 +13,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	kubevirtv1 "kubevirt.io/client-go/api/v1"
 
+	ctlnode "github.com/harvester/harvester/pkg/controller/master/node"
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
 	"github.com/harvester/harvester/pkg/ref"
 	"github.com/harvester/harvester/pkg/util"
@@ -99,9 +101,52 @@ func (v *vmValidator) checkVMSpec(vm *kubevirtv1.VirtualMachine) error {
 	if err := v.checkOccupiedPVCs(vm); err != nil {
 		return err
 	}
+	if err := checkMaintainPolicyAnnotation(vm); err != nil {
+		return err
+	}
+	if err := checkHAAnnotations(vm); err != nil {
+		return err
+	}
+	return nil
+}
+
+func checkHAAnnotations(vm *kubevirtv1.VirtualMachine) error {
+	if priority, ok := vm.Annotations[ctlnode.HAPriorityAnnotationKey]; ok {
+		if _, err := strconv.Atoi(priority); err != nil {
+			message := fmt.Sprintf("the %s annotation must be an integer", ctlnode.HAPriorityAnnotationKey)
+			return werror.NewInvalidError(message, "metadata.annotations")
+		}
+	}
+	if delay, ok := vm.Annotations[ctlnode.HARestartDelayAnnotationKey]; ok {
+		if seconds, err := strconv.ParseInt(delay, 10, 64); err != nil || seconds < 0 {
+			message := fmt.Sprintf("the %s annotation must be a non-negative number of seconds", ctlnode.HARestartDelayAnnotationKey)
+			return werror.NewInvalidError(message, "metadata.annotations")
+		}
+	}
+	if autoRecover, ok := vm.Annotations[ctlnode.HAAutoRecoverAnnotationKey]; ok {
+		if _, err := strconv.ParseBool(autoRecover); err != nil {
+			message := fmt.Sprintf("the %s annotation must be true or false", ctlnode.HAAutoRecoverAnnotationKey)
+			return werror.NewInvalidError(message, "metadata.annotations")
+		}
+	}
 	return nil
 }
 
+func checkMaintainPolicyAnnotation(vm *kubevirtv1.VirtualMachine) error {
+	policy, ok := vm.Annotations[ctlnode.MaintainPolicyAnnotationKey]
+	if !ok {
+		return nil
+	}
+	switch policy {
+	case ctlnode.MaintainPolicyShutdown, ctlnode.MaintainPolicyLeaveRunning, ctlnode.MaintainPolicyBlock:
+		return nil
+	default:
+		message := fmt.Sprintf("the %s annotation must be one of %s, %s and %s", ctlnode.MaintainPolicyAnnotationKey,
+			ctlnode.MaintainPolicyShutdown, ctlnode.MaintainPolicyLeaveRunning, ctlnode.MaintainPolicyBlock)
+		return werror.NewInvalidError(message, "metadata.annotations")
+	}
+}
+
 func (v *vmValidator) checkVolumeClaimTemplatesAnnotation(vm *kubevirtv1.VirtualMachine) error {
 	volumeClaimTemplates, ok := vm.Annotations[util.AnnotationVolumeClaimTemplates]
 	if !ok || volumeClaimTemplates == "" {
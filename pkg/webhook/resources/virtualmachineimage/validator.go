@@ -14,6 +14,7 @@ import (
 
 	"github.com/harvester/harvester/pkg/apis/harvesterhci.io/v1beta1"
 	ctlharvesterv1 "github.com/harvester/harvester/pkg/generated/controllers/harvesterhci.io/v1beta1"
+	"github.com/harvester/harvester/pkg/util/registry"
 	werror "github.com/harvester/harvester/pkg/webhook/error"
 	"github.com/harvester/harvester/pkg/webhook/types"
 )
@@ -84,8 +85,18 @@ func (v *virtualMachineImageValidator) CheckImageDisplayNameAndURL(newImage *v1b
 		return werror.NewInvalidError(`url is required when image source type is "download"`, "spec.url")
 	}
 
-	if newImage.Spec.SourceType != v1beta1.VirtualMachineImageSourceTypeDownload && newImage.Spec.URL != "" {
-		return werror.NewInvalidError(`url should be empty when image source type is not "download"`, "spec.url")
+	if newImage.Spec.SourceType == v1beta1.VirtualMachineImageSourceTypeRegistry {
+		if newImage.Spec.URL == "" {
+			return werror.NewInvalidError(`url is required when image source type is "registry"`, "spec.url")
+		}
+		if _, err := registry.ParseReference(newImage.Spec.URL); err != nil {
+			return werror.NewInvalidError(err.Error(), "spec.url")
+		}
+	}
+
+	if newImage.Spec.SourceType != v1beta1.VirtualMachineImageSourceTypeDownload &&
+		newImage.Spec.SourceType != v1beta1.VirtualMachineImageSourceTypeRegistry && newImage.Spec.URL != "" {
+		return werror.NewInvalidError(`url should be empty when image source type is not "download" or "registry"`, "spec.url")
 	}
 
 	return nil